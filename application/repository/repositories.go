@@ -2,10 +2,17 @@ package repository
 
 import (
 	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	webhookrepository "github.com/fabric8-services/fabric8-cluster/webhook/repository"
 )
 
 //Repositories stands for a particular implementation of the business logic of our application
 type Repositories interface {
 	Clusters() repository.ClusterRepository
 	IdentityClusters() repository.IdentityClusterRepository
+	ClusterHealth() repository.ClusterHealthRepository
+	Audit() repository.AuditRepository
+	LeaderLeases() repository.LeaderLeaseRepository
+	Outbox() repository.OutboxRepository
+	Subscriptions() webhookrepository.SubscriptionRepository
+	Deliveries() webhookrepository.DeliveryRepository
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/fabric8-services/fabric8-cluster/application/transaction"
 	clusterservice "github.com/fabric8-services/fabric8-cluster/cluster/service"
 	"github.com/fabric8-services/fabric8-cluster/configuration"
+	webhookservice "github.com/fabric8-services/fabric8-cluster/webhook/service"
 	"github.com/fabric8-services/fabric8-common/log"
 
 	"github.com/pkg/errors"
@@ -117,6 +118,8 @@ type ServiceContextProducer func() context.ServiceContext
 type ServiceFactory struct {
 	contextProducer ServiceContextProducer
 	config          *configuration.ConfigurationData
+	clusterConfig   clusterservice.Configuration
+	encryptor       clusterservice.Encryptor
 }
 
 // NewServiceFactory initializes a new factory with some options to use alternative implementation of the underlying services
@@ -133,11 +136,35 @@ func NewServiceFactory(producer ServiceContextProducer, config *configuration.Co
 // Option an option to configure the Service Factory
 type Option func(f *ServiceFactory)
 
+// WithEncryptor an option to use an alternative encryptor of the cluster and webhook subscription secrets,
+// instead of the one using the keys from the configuration
+func WithEncryptor(e clusterservice.Encryptor) Option {
+	return func(f *ServiceFactory) {
+		f.encryptor = e
+	}
+}
+
+// WithClusterServiceConfiguration an option to use an alternative configuration in the cluster service,
+// instead of the configuration of the factory
+func WithClusterServiceConfiguration(c clusterservice.Configuration) Option {
+	return func(f *ServiceFactory) {
+		f.clusterConfig = c
+	}
+}
+
 func (f *ServiceFactory) getContext() context.ServiceContext {
 	return f.contextProducer()
 }
 
 // ClusterService returns a new cluster service implementation
 func (f *ServiceFactory) ClusterService() service.ClusterService {
-	return clusterservice.NewClusterService(f.getContext(), f.config)
+	if f.clusterConfig != nil {
+		return clusterservice.NewClusterService(f.getContext(), f.clusterConfig, f.encryptor)
+	}
+	return clusterservice.NewClusterService(f.getContext(), f.config, f.encryptor)
+}
+
+// WebhookService returns a new webhook service implementation
+func (f *ServiceFactory) WebhookService() service.WebhookService {
+	return webhookservice.NewWebhookService(f.getContext(), f.config, f.encryptor)
 }
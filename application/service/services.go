@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	webhookrepository "github.com/fabric8-services/fabric8-cluster/webhook/repository"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -22,20 +24,57 @@ and use the factory method from the step #4
 //Services creates instances of service layer objects
 type Services interface {
 	ClusterService() ClusterService
+	WebhookService() WebhookService
 }
 
 // ClusterService the interface for the cluster service
 type ClusterService interface {
-	InitializeClusterWatcher() (func() error, error)
-	CreateOrSaveClusterFromConfig(ctx context.Context) error
-	CreateOrSaveCluster(ctx context.Context, clustr *repository.Cluster) error
+	InitializeClusterWatcher(ctx context.Context) (func(), error)
+	InitializeHealthProber() func()
+	InitializeSATokenPromoter() func()
+	InitializeLeaderElection(onElected func()) func()
+	InitializeChangeFeed() (func(), error)
+	IsLeader() bool
+	Leadership() repository.Leadership
+	ProbeClusters(ctx context.Context) error
+	CreateOrSaveClusterFromConfig(ctx context.Context) (*repository.ClusterConfigSyncResult, error)
+	LastClusterConfigSync() *repository.ClusterConfigSyncResult
+	ValidateClusterConfig(ctx context.Context, clusterConfigFile string) (*repository.ClusterConfigSyncPlan, error)
+	ReloadClusterConfig(ctx context.Context, trigger string) (*repository.ClusterConfigSyncResult, error)
+	ReencryptSecrets(ctx context.Context) (int, error)
+	CreateOrSaveCluster(ctx context.Context, clustr *repository.Cluster, expectedVersions ...int) error
+	PatchCluster(ctx context.Context, clusterID uuid.UUID, patch repository.ClusterPatch, expectedVersions ...int) (*repository.Cluster, error)
+	RotateSAToken(ctx context.Context, clusterID uuid.UUID, saToken string, saTokenEncrypted bool, gracePeriod *time.Duration) (*repository.Cluster, error)
+	PromoteSATokens(ctx context.Context) (int, error)
 	Load(ctx context.Context, clusterID uuid.UUID) (*repository.Cluster, error)
 	LoadForAuth(ctx context.Context, clusterID uuid.UUID) (*repository.Cluster, error)
 	FindByURL(ctx context.Context, clusterURL string) (*repository.Cluster, error)
 	FindByURLForAuth(ctx context.Context, clusterURL string) (*repository.Cluster, error)
-	List(ctx context.Context, clusterType *string) ([]repository.Cluster, error)
-	ListForAuth(ctx context.Context, clusterType *string) ([]repository.Cluster, error)
-	Delete(ctx context.Context, clusterID uuid.UUID) error
+	List(ctx context.Context, options repository.ClusterListOptions) ([]repository.Cluster, int, error)
+	ListForAuth(ctx context.Context, options repository.ClusterListOptions) ([]repository.Cluster, int, error)
+	WatchClusters(ctx context.Context, resourceVersion *string, fn func(repository.ClusterWatchEvent) error) error
+	Delete(ctx context.Context, clusterID uuid.UUID, expectedVersions ...int) error
+	ListAudit(ctx context.Context, clusterID uuid.UUID) ([]repository.AuditEntry, error)
+	ListAuditSince(ctx context.Context, since time.Time) ([]repository.AuditEntry, error)
+	ListClustersForIdentities(ctx context.Context, identityIDs ...uuid.UUID) ([]repository.IdentityCluster, error)
+	ListIdentities(ctx context.Context, clusterID uuid.UUID, offset, limit int) ([]repository.IdentityCluster, int, error)
+	StreamIdentities(ctx context.Context, clusterID uuid.UUID, fn func(repository.IdentityCluster) error) error
+	LinkIdentitiesToClusters(ctx context.Context, links []repository.IdentityClusterLink, transactional bool) ([]repository.IdentityClusterLinkResult, error)
+	RemoveIdentitiesToClustersLinks(ctx context.Context, links []repository.IdentityClusterLink, transactional bool) ([]repository.IdentityClusterLinkResult, error)
 	LinkIdentityToCluster(ctx context.Context, identityID uuid.UUID, clusterURL string, ignoreError bool) error
 	RemoveIdentityToClusterLink(ctx context.Context, identityID uuid.UUID, clusterURL string) error
+	MigrateIdentities(ctx context.Context, identityIDs []uuid.UUID, sourceClusterURL, targetClusterURL string, dryRun bool) (*repository.IdentityMigration, error)
+	PlaceIdentity(ctx context.Context, identityID uuid.UUID, clusterType, strategy *string) (*repository.Cluster, error)
+}
+
+// WebhookService the interface for the webhook service
+type WebhookService interface {
+	InitializeDispatcher() func()
+	DispatchEvents(ctx context.Context) error
+	CreateSubscription(ctx context.Context, subscription *webhookrepository.Subscription) error
+	ListSubscriptions(ctx context.Context) ([]webhookrepository.Subscription, error)
+	LoadSubscription(ctx context.Context, subscriptionID uuid.UUID) (*webhookrepository.Subscription, error)
+	DeleteSubscription(ctx context.Context, subscriptionID uuid.UUID) error
+	ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, state *string) ([]webhookrepository.Delivery, error)
+	Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*webhookrepository.Delivery, error)
 }
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
+	"github.com/goadesign/goa/middleware"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// AuditOperationClusterCreate the operation recorded when a cluster is created
+	AuditOperationClusterCreate = "cluster.create"
+	// AuditOperationClusterUpdate the operation recorded when a cluster is updated
+	AuditOperationClusterUpdate = "cluster.update"
+	// AuditOperationClusterDelete the operation recorded when a cluster is deleted
+	AuditOperationClusterDelete = "cluster.delete"
+	// AuditOperationIdentityLink the operation recorded when an identity is linked to a cluster
+	AuditOperationIdentityLink = "identity_cluster.create"
+	// AuditOperationIdentityUnlink the operation recorded when an identity is unlinked from a cluster
+	AuditOperationIdentityUnlink = "identity_cluster.delete"
+
+	// SystemActor the actor recorded when a mutation is not performed on behalf of a request
+	// (eg: when the clusters are loaded from the configuration file)
+	SystemActor = "system"
+	// UnknownActor the actor recorded when the token of the request has neither a service account name nor a subject
+	UnknownActor = "unknown"
+
+	// redactedValue the value recorded in place of the secrets
+	redactedValue = "********"
+)
+
+// AuditEntry an entry of the audit log, which records a mutation on a cluster or on an identity/cluster relationship
+type AuditEntry struct {
+	// This is the primary key value
+	ID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:audit_id"`
+	// The time of the mutation
+	CreatedAt time.Time
+	// The ID of the cluster
+	ClusterID uuid.UUID `sql:"type:uuid"`
+	// The ID of the identity, for the identity/cluster relationship mutations
+	IdentityID *uuid.UUID `sql:"type:uuid"`
+	// The mutation (eg: `cluster.update`)
+	Operation string
+	// The name of the service account (or the ID of the identity) on behalf of which the mutation was performed
+	Actor string
+	// The ID of the request in which the mutation was performed, if any
+	RequestID string
+	// The changed fields of the cluster, with the secrets redacted
+	Changes AuditChanges `sql:"type:jsonb"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (e AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+// AuditChange the old and new values of a field. The old value is nil when the cluster was created,
+// and the new value is nil when the cluster was deleted.
+type AuditChange struct {
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// AuditChanges the changes of a mutation, indexed by the name of the field in the cluster configuration file
+type AuditChanges map[string]AuditChange
+
+// Value implements the driver.Valuer interface
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface
+func (c *AuditChanges) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	default:
+		return fmt.Errorf("unable to scan the audit changes from a value of type %T", src)
+	}
+}
+
+// DiffClusters returns the fields which differ between the `before` and `after` clusters. Either one can be nil,
+// when the cluster was created or deleted. The values of the fields tagged with `audit:"secret"` are redacted.
+func DiffClusters(before, after *Cluster) AuditChanges {
+	changes := AuditChanges{}
+	t := reflect.TypeOf(Cluster{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, secret := auditOptions(field)
+		if name == "" {
+			continue
+		}
+		var oldValue, newValue interface{}
+		if before != nil {
+			oldValue = reflect.ValueOf(*before).Field(i).Interface()
+		}
+		if after != nil {
+			newValue = reflect.ValueOf(*after).Field(i).Interface()
+		}
+		if before != nil && after != nil && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if secret {
+			oldValue = redact(oldValue)
+			newValue = redact(newValue)
+		}
+		changes[name] = AuditChange{Old: oldValue, New: newValue}
+	}
+	return changes
+}
+
+// auditOptions returns the name of the given cluster field in the audit entries (its `mapstructure` key, unless another
+// name is given in its `audit` tag, eg: `audit:"pending-service-account-token,secret"`) and whether its value is a secret.
+// An empty name means that the field is not audited, which is also the case of the fields tagged with `audit:"-"`.
+func auditOptions(field reflect.StructField) (string, bool) {
+	name := field.Tag.Get("mapstructure")
+	secret := false
+	for _, opt := range strings.Split(field.Tag.Get("audit"), ",") {
+		switch opt {
+		case "":
+			// ignore
+		case "secret":
+			secret = true
+		case "-":
+			return "", false
+		default:
+			name = opt
+		}
+	}
+	return name, secret
+}
+
+// redact replaces the given secret with a placeholder, unless it is empty
+func redact(value interface{}) interface{} {
+	if s, ok := value.(string); ok && s != "" {
+		return redactedValue
+	}
+	return value
+}
+
+// Actor returns the name of the service account (or else, the subject) of the token in the given context,
+// or `system` if the context holds no token
+func Actor(ctx context.Context) string {
+	if ctx == nil {
+		return SystemActor
+	}
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return SystemActor
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return UnknownActor
+	}
+	if name, ok := claims["service_accountname"].(string); ok && name != "" {
+		return name
+	}
+	if sub, ok := claims["sub"].(string); ok && sub != "" {
+		return sub
+	}
+	return UnknownActor
+}
+
+// requestID returns the ID of the request in the given context, if any
+func requestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	return middleware.ContextRequestID(ctx)
+}
+
+// recordAudit records the given mutation in the audit log and in the outbox of the events to dispatch to the webhook
+// subscriptions, using the same DB (and hence, the same transaction) as the mutation itself
+func recordAudit(ctx context.Context, db *gorm.DB, operation string, clusterID uuid.UUID, identityID *uuid.UUID, changes AuditChanges) error {
+	e := &AuditEntry{
+		ID:         uuid.NewV4(),
+		ClusterID:  clusterID,
+		IdentityID: identityID,
+		Operation:  operation,
+		Changes:    changes,
+	}
+	if err := NewAuditRepository(db).Create(ctx, e); err != nil {
+		return err
+	}
+	return recordOutboxEvent(ctx, db, e)
+}
+
+// AuditRepository represents the storage interface.
+type AuditRepository interface {
+	Create(ctx context.Context, e *AuditEntry) error
+	Load(ctx context.Context, id uuid.UUID) (*AuditEntry, error)
+	ListForCluster(ctx context.Context, clusterID uuid.UUID) ([]AuditEntry, error)
+	ListSince(ctx context.Context, since time.Time) ([]AuditEntry, error)
+}
+
+// GormAuditRepository is the implementation of the storage interface for AuditEntry.
+type GormAuditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new storage type.
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &GormAuditRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormAuditRepository) TableName() string {
+	return "audit_log"
+}
+
+// Create creates a new record. The actor and the request ID are retrieved from the given context
+// if they are not set in the entry.
+func (m *GormAuditRepository) Create(ctx context.Context, e *AuditEntry) error {
+	defer goa.MeasureSince([]string{"goa", "db", "audit_log", "create"}, time.Now())
+	if e.Actor == "" {
+		e.Actor = Actor(ctx)
+	}
+	if e.RequestID == "" {
+		e.RequestID = requestID(ctx)
+	}
+	err := m.db.Create(e).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"cluster_id": e.ClusterID.String(),
+			"operation":  e.Operation,
+			"err":        err,
+		}, "unable to create the audit entry")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// Load returns the audit entry with the given ID
+func (m *GormAuditRepository) Load(ctx context.Context, id uuid.UUID) (*AuditEntry, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "audit_log", "load"}, time.Now())
+	var native AuditEntry
+	err := m.db.Table(m.TableName()).Where("audit_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("audit_log", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// ListForCluster returns the audit entries of the cluster with the given ID, from the oldest to the newest
+func (m *GormAuditRepository) ListForCluster(ctx context.Context, clusterID uuid.UUID) ([]AuditEntry, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "audit_log", "list_for_cluster"}, time.Now())
+	return m.query(func(db *gorm.DB) *gorm.DB {
+		return db.Where("cluster_id = ?", clusterID)
+	})
+}
+
+// ListSince returns the audit entries created at or after the given time, from the oldest to the newest
+func (m *GormAuditRepository) ListSince(ctx context.Context, since time.Time) ([]AuditEntry, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "audit_log", "list_since"}, time.Now())
+	return m.query(func(db *gorm.DB) *gorm.DB {
+		return db.Where("created_at >= ?", since)
+	})
+}
+
+func (m *GormAuditRepository) query(funcs ...func(*gorm.DB) *gorm.DB) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	err := m.db.Scopes(funcs...).Table(m.TableName()).Order("created_at, audit_id").Find(&entries).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,255 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	"github.com/fabric8-services/fabric8-cluster/test"
+	"github.com/fabric8-services/fabric8-common/auth"
+	"github.com/fabric8-services/fabric8-common/resource"
+	authtestsupport "github.com/fabric8-services/fabric8-common/test/auth"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type auditTestSuite struct {
+	gormtestsupport.DBTestSuite
+	repo repository.AuditRepository
+}
+
+func TestAudit(t *testing.T) {
+	suite.Run(t, &auditTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *auditTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = s.Application.Audit()
+}
+
+func (s *auditTestSuite) TestClusterMutations() {
+	// given
+	ctx, err := authtestsupport.EmbedServiceAccountTokenInContext(context.Background(), &authtestsupport.Identity{
+		Username: auth.ToolChainOperator,
+		ID:       uuid.NewV4(),
+	})
+	require.NoError(s.T(), err)
+	clusterRepo := repository.NewClusterRepository(s.DB)
+	c := test.NewCluster()
+
+	s.T().Run("create", func(t *testing.T) {
+		// when
+		err := clusterRepo.Create(ctx, &c)
+		// then
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, repository.AuditOperationClusterCreate, entries[0].Operation)
+		assert.Equal(t, auth.ToolChainOperator, entries[0].Actor)
+		assert.Empty(t, entries[0].RequestID) // no request ID outside of an HTTP request
+		assert.Nil(t, entries[0].IdentityID)
+		assert.Equal(t, c.Name, entries[0].Changes["name"].New)
+		assert.Nil(t, entries[0].Changes["name"].Old)
+		// secrets are redacted
+		assert.Equal(t, "********", entries[0].Changes["service-account-token"].New)
+		assert.Equal(t, "********", entries[0].Changes["auth-client-secret"].New)
+	})
+
+	s.T().Run("update", func(t *testing.T) {
+		// given
+		previousName := c.Name
+		c.Name = uuid.NewV4().String()
+		c.AuthClientSecret = uuid.NewV4().String()
+		// when
+		err := clusterRepo.Save(ctx, &c)
+		// then
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, repository.AuditOperationClusterUpdate, entries[1].Operation)
+		require.Len(t, entries[1].Changes, 2)
+		assert.Equal(t, previousName, entries[1].Changes["name"].Old)
+		assert.Equal(t, c.Name, entries[1].Changes["name"].New)
+		assert.Equal(t, "********", entries[1].Changes["auth-client-secret"].Old)
+		assert.Equal(t, "********", entries[1].Changes["auth-client-secret"].New)
+	})
+
+	s.T().Run("update without change", func(t *testing.T) {
+		// when
+		err := clusterRepo.Save(ctx, &c)
+		// then no new entry was recorded
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	s.T().Run("update capacity exhausted", func(t *testing.T) {
+		// when
+		err := clusterRepo.UpdateCapacityExhausted(ctx, c.ClusterID, true)
+		// then
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, repository.AuditOperationClusterUpdate, entries[2].Operation)
+		assert.Equal(t, repository.AuditChanges{
+			"capacity-exhausted": {Old: false, New: true},
+		}, entries[2].Changes)
+	})
+
+	s.T().Run("update state", func(t *testing.T) {
+		// when
+		err := clusterRepo.UpdateState(ctx, c.ClusterID, repository.ClusterStateDraining)
+		// then
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+		assert.Equal(t, repository.AuditOperationClusterUpdate, entries[3].Operation)
+		assert.Equal(t, repository.AuditChanges{
+			"state": {Old: repository.ClusterStateActive, New: repository.ClusterStateDraining},
+		}, entries[3].Changes)
+	})
+
+	s.T().Run("update state without change", func(t *testing.T) {
+		// when
+		err := clusterRepo.UpdateState(ctx, c.ClusterID, repository.ClusterStateDraining)
+		// then no new entry was recorded
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Len(t, entries, 4)
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+		// when
+		err := clusterRepo.Delete(context.Background(), c.ClusterID)
+		// then the entries are kept after the cluster was deleted
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		require.Len(t, entries, 5)
+		assert.Equal(t, repository.AuditOperationClusterDelete, entries[4].Operation)
+		assert.Equal(t, repository.SystemActor, entries[4].Actor)
+		assert.Empty(t, entries[4].RequestID)
+		assert.Equal(t, c.Name, entries[4].Changes["name"].Old)
+		assert.Nil(t, entries[4].Changes["name"].New)
+	})
+}
+
+func (s *auditTestSuite) TestIdentityClusterMutations() {
+	// given
+	c := test.CreateCluster(s.T(), s.DB)
+	identityID := uuid.NewV4()
+	identityClusterRepo := repository.NewIdentityClusterRepository(s.DB)
+
+	s.T().Run("link", func(t *testing.T) {
+		// when
+		err := identityClusterRepo.Create(context.Background(), &repository.IdentityCluster{
+			IdentityID: identityID,
+			ClusterID:  c.ClusterID,
+		})
+		// then
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, repository.AuditOperationIdentityLink, entries[1].Operation)
+		require.NotNil(t, entries[1].IdentityID)
+		assert.Equal(t, identityID, *entries[1].IdentityID)
+		assert.Empty(t, entries[1].Changes)
+	})
+
+	s.T().Run("unlink", func(t *testing.T) {
+		// when
+		err := identityClusterRepo.Delete(context.Background(), identityID, c.URL)
+		// then
+		require.NoError(t, err)
+		entries, err := s.repo.ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, repository.AuditOperationIdentityUnlink, entries[2].Operation)
+		require.NotNil(t, entries[2].IdentityID)
+		assert.Equal(t, identityID, *entries[2].IdentityID)
+	})
+}
+
+func (s *auditTestSuite) TestListSince() {
+	// given
+	c1 := test.CreateCluster(s.T(), s.DB)
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	c2 := test.CreateCluster(s.T(), s.DB)
+	c3 := test.CreateCluster(s.T(), s.DB)
+	// when
+	entries, err := s.repo.ListSince(context.Background(), since)
+	// then
+	require.NoError(s.T(), err)
+	clusterIDs := make(map[uuid.UUID]bool, len(entries))
+	for _, e := range entries {
+		assert.False(s.T(), e.CreatedAt.Before(since))
+		clusterIDs[e.ClusterID] = true
+	}
+	assert.False(s.T(), clusterIDs[c1.ClusterID])
+	assert.True(s.T(), clusterIDs[c2.ClusterID])
+	assert.True(s.T(), clusterIDs[c3.ClusterID])
+}
+
+func TestDiffClusters(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	before := test.NewCluster()
+	after := before
+	after.URL = "https://api.cluster.com/"
+	after.SAToken = ""
+	after.MaxIdentities = 10
+
+	t.Run("update", func(t *testing.T) {
+		// when
+		changes := repository.DiffClusters(&before, &after)
+		// then
+		assert.Equal(t, repository.AuditChanges{
+			"api-url":               {Old: before.URL, New: after.URL},
+			"service-account-token": {Old: "********", New: ""},
+			"max-identities":        {Old: 0, New: 10},
+		}, changes)
+	})
+
+	t.Run("pending token", func(t *testing.T) {
+		// given
+		rotated := before
+		rotated.PendingSAToken = "PendingSAToken"
+		// when
+		changes := repository.DiffClusters(&before, &rotated)
+		// then
+		assert.Equal(t, repository.AuditChanges{
+			"pending-service-account-token": {Old: "", New: "********"},
+		}, changes)
+	})
+
+	t.Run("no change", func(t *testing.T) {
+		// when
+		changes := repository.DiffClusters(&before, &before)
+		// then
+		assert.Empty(t, changes)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		// when
+		changes := repository.DiffClusters(nil, &after)
+		// then
+		assert.Equal(t, repository.AuditChange{Old: nil, New: after.URL}, changes["api-url"])
+		assert.Equal(t, repository.AuditChange{Old: nil, New: "********"}, changes["auth-client-secret"])
+		assert.NotContains(t, changes, "IdentitiesCount")
+		assert.NotContains(t, changes, "service-account-token-file")
+		assert.NotContains(t, changes, "auth-client-secret-file")
+	})
+}
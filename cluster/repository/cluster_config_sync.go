@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// ClusterConfigSyncChange a change applied on a cluster during the synchronization with the config file
+type ClusterConfigSyncChange struct {
+	// The ID of the cluster (unknown until a cluster to create is saved)
+	ClusterID uuid.UUID
+	// The API URL of the cluster
+	URL string
+	// The name of the cluster
+	Name string
+	// The fields which differ between the cluster in the DB and in the config file (updates only)
+	Changes AuditChanges
+	// The number of identities linked to the cluster (decommissions only)
+	IdentitiesCount int
+}
+
+// ClusterConfigSyncPlan the changes computed from the config file and the clusters in the DB,
+// which are all applied in a single transaction
+type ClusterConfigSyncPlan struct {
+	// The clusters declared in the config file which don't exist in the DB yet
+	Creates []ClusterConfigSyncChange
+	// The clusters declared in the config file which already exist in the DB
+	Updates []ClusterConfigSyncChange
+	// The clusters which originate from the config file but which are not declared in it anymore
+	Decommissions []ClusterConfigSyncChange
+}
+
+// ClusterConfigSyncResult the result of a synchronization of the clusters with the config file
+type ClusterConfigSyncResult struct {
+	// The sync policy in use (`authoritative`, `merge-only` or `disabled`)
+	Policy string
+	// The time of the synchronization
+	Time time.Time
+	// The computed plan
+	Plan ClusterConfigSyncPlan
+	// `true` if the plan was applied, `false` if the synchronization is disabled, if it failed or if it was left
+	// to the leader among the replicas of the service
+	Applied bool
+	// The error which caused the whole plan to be rolled back, if any
+	Error error
+}
+
+// The triggers of a reload of the cluster configuration
+const (
+	// ClusterConfigReloadTriggerWatcher the reload was triggered by a change of the config file (or of a secret file referenced in it)
+	ClusterConfigReloadTriggerWatcher = "watcher"
+	// ClusterConfigReloadTriggerSignal the reload was triggered by a SIGHUP signal
+	ClusterConfigReloadTriggerSignal = "signal"
+	// ClusterConfigReloadTriggerAPI the reload was triggered through the admin endpoint of the REST API
+	ClusterConfigReloadTriggerAPI = "api"
+	// ClusterConfigReloadTriggerElection the reload was triggered by the election of the current replica as the leader
+	ClusterConfigReloadTriggerElection = "election"
+)
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/gormsupport"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// HealthStateHealthy the state of a cluster whose URLs all responded during the latest probe
+	HealthStateHealthy = "healthy"
+	// HealthStateUnhealthy the state of a cluster for which at least one URL failed to respond during the latest probe
+	HealthStateUnhealthy = "unhealthy"
+)
+
+// ClusterHealth the struct that holds the result of the latest health probe of a cluster
+type ClusterHealth struct {
+	gormsupport.LifecycleHardDelete
+	// The ID of the probed cluster. This is also the primary key value
+	ClusterID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:cluster_id"`
+	// The health state of the cluster (`healthy` or `unhealthy`)
+	State string
+	// The time (in milliseconds) it took for the cluster API to respond during the latest probe
+	Latency int64 `gorm:"column:latency_ms"`
+	// The time of the latest probe
+	LastCheckedAt time.Time
+	// The time of the latest successful probe, if any
+	LastSuccessAt *time.Time
+	// The error of the latest failed probe, if any
+	LastError string
+	// The time of the latest failed probe, if any
+	LastErrorAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (h ClusterHealth) TableName() string {
+	return "cluster_health"
+}
+
+// ClusterHealthRepository represents the storage interface.
+type ClusterHealthRepository interface {
+	Load(ctx context.Context, clusterID uuid.UUID) (*ClusterHealth, error)
+	List(ctx context.Context) (map[uuid.UUID]ClusterHealth, error)
+	CreateOrSave(ctx context.Context, h *ClusterHealth) error
+}
+
+// GormClusterHealthRepository is the implementation of the storage interface for ClusterHealth.
+type GormClusterHealthRepository struct {
+	db *gorm.DB
+}
+
+// NewClusterHealthRepository creates a new storage type.
+func NewClusterHealthRepository(db *gorm.DB) ClusterHealthRepository {
+	return &GormClusterHealthRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormClusterHealthRepository) TableName() string {
+	return "cluster_health"
+}
+
+// Load returns the health of the cluster with the given ID
+func (m *GormClusterHealthRepository) Load(ctx context.Context, clusterID uuid.UUID) (*ClusterHealth, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "cluster_health", "load"}, time.Now())
+	var native ClusterHealth
+	err := m.db.Table(m.TableName()).Where("cluster_id = ?", clusterID).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("cluster_health", clusterID.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// List returns the health of all probed clusters, indexed by cluster ID
+func (m *GormClusterHealthRepository) List(ctx context.Context) (map[uuid.UUID]ClusterHealth, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "cluster_health", "list"}, time.Now())
+	var rows []ClusterHealth
+	err := m.db.Table(m.TableName()).Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	result := make(map[uuid.UUID]ClusterHealth, len(rows))
+	for _, h := range rows {
+		result[h.ClusterID] = h
+	}
+	return result, nil
+}
+
+// CreateOrSave creates or updates the health record of the cluster
+func (m *GormClusterHealthRepository) CreateOrSave(ctx context.Context, h *ClusterHealth) error {
+	defer goa.MeasureSince([]string{"goa", "db", "cluster_health", "create_or_save"}, time.Now())
+	existing, err := m.Load(ctx, h.ClusterID)
+	if err != nil {
+		if ok, _ := errors.IsNotFoundError(err); !ok {
+			return err
+		}
+		err = m.db.Create(h).Error
+	} else {
+		h.CreatedAt = existing.CreatedAt
+		err = m.db.Save(h).Error
+	}
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"cluster_id": h.ClusterID.String(),
+			"err":        err,
+		}, "unable to save the cluster health")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"cluster_id": h.ClusterID.String(),
+		"state":      h.State,
+	}, "cluster health saved")
+	return nil
+}
@@ -0,0 +1,122 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	"github.com/fabric8-services/fabric8-cluster/test"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type clusterHealthTestSuite struct {
+	gormtestsupport.DBTestSuite
+	repo repository.ClusterHealthRepository
+}
+
+func TestClusterHealth(t *testing.T) {
+	suite.Run(t, &clusterHealthTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *clusterHealthTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = s.Application.ClusterHealth()
+}
+
+func (s *clusterHealthTestSuite) TestCreateOrSave() {
+
+	s.T().Run("create", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		// when
+		h := test.CreateClusterHealth(t, s.DB, c, repository.HealthStateHealthy)
+		// then
+		loaded, err := s.repo.Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.HealthStateHealthy, loaded.State)
+		assert.Equal(t, h.Latency, loaded.Latency)
+		require.NotNil(t, loaded.LastSuccessAt)
+		assert.Nil(t, loaded.LastErrorAt)
+		assert.Empty(t, loaded.LastError)
+	})
+
+	s.T().Run("save", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		h := test.CreateClusterHealth(t, s.DB, c, repository.HealthStateHealthy)
+		// when
+		now := time.Now()
+		h.State = repository.HealthStateUnhealthy
+		h.LastCheckedAt = now
+		h.LastError = "failure"
+		h.LastErrorAt = &now
+		err := s.repo.CreateOrSave(context.Background(), &h)
+		// then
+		require.NoError(t, err)
+		loaded, err := s.repo.Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.HealthStateUnhealthy, loaded.State)
+		assert.Equal(t, "failure", loaded.LastError)
+		require.NotNil(t, loaded.LastErrorAt)
+		require.NotNil(t, loaded.LastSuccessAt) // unchanged
+	})
+
+	s.T().Run("unknown cluster", func(t *testing.T) {
+		// given
+		h := repository.ClusterHealth{
+			ClusterID:     uuid.NewV4(),
+			State:         repository.HealthStateHealthy,
+			LastCheckedAt: time.Now(),
+		}
+		// when
+		err := s.repo.CreateOrSave(context.Background(), &h)
+		// then
+		require.Error(t, err)
+	})
+}
+
+func (s *clusterHealthTestSuite) TestLoadUnknownFails() {
+	// given
+	id := uuid.NewV4()
+	// when
+	_, err := s.repo.Load(context.Background(), id)
+	// then
+	test.AssertError(s.T(), err, errors.NotFoundError{}, "cluster_health with id '%s' not found", id)
+}
+
+func (s *clusterHealthTestSuite) TestList() {
+	// given
+	c1 := test.CreateCluster(s.T(), s.DB)
+	c2 := test.CreateCluster(s.T(), s.DB)
+	c3 := test.CreateCluster(s.T(), s.DB) // not probed yet
+	test.CreateClusterHealth(s.T(), s.DB, c1, repository.HealthStateHealthy)
+	test.CreateClusterHealth(s.T(), s.DB, c2, repository.HealthStateUnhealthy)
+	// when
+	healths, err := s.repo.List(context.Background())
+	// then
+	require.NoError(s.T(), err)
+	require.Contains(s.T(), healths, c1.ClusterID)
+	assert.Equal(s.T(), repository.HealthStateHealthy, healths[c1.ClusterID].State)
+	require.Contains(s.T(), healths, c2.ClusterID)
+	assert.Equal(s.T(), repository.HealthStateUnhealthy, healths[c2.ClusterID].State)
+	assert.NotContains(s.T(), healths, c3.ClusterID)
+}
+
+func (s *clusterHealthTestSuite) TestOnDeleteCascade() {
+	// given
+	c := test.CreateCluster(s.T(), s.DB)
+	test.CreateClusterHealth(s.T(), s.DB, c, repository.HealthStateHealthy)
+	// when
+	err := s.Application.Clusters().Delete(context.Background(), c.ClusterID)
+	// then
+	require.NoError(s.T(), err)
+	_, err = s.repo.Load(context.Background(), c.ClusterID)
+	test.AssertError(s.T(), err, errors.NotFoundError{}, "cluster_health with id '%s' not found", c.ClusterID)
+}
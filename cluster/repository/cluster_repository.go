@@ -21,6 +21,51 @@ import (
 	"fmt"
 )
 
+const (
+	// ClusterStateProvisioning the state of a cluster which is being set up and cannot host identities yet
+	ClusterStateProvisioning = "provisioning"
+	// ClusterStateActive the state of a cluster which can host new identities
+	ClusterStateActive = "active"
+	// ClusterStateDraining the state of a cluster which keeps its identities but refuses new ones
+	ClusterStateDraining = "draining"
+	// ClusterStateDecommissioned the state of a retired cluster. Its identity/cluster relationships are kept.
+	ClusterStateDecommissioned = "decommissioned"
+)
+
+const (
+	// ClusterOriginConfig the origin of a cluster declared in the cluster configuration file
+	ClusterOriginConfig = "config"
+	// ClusterOriginAPI the origin of a cluster registered through the REST API
+	ClusterOriginAPI = "api"
+)
+
+// clusterStateTransitions the allowed transitions between the lifecycle states of a cluster
+var clusterStateTransitions = map[string][]string{
+	ClusterStateProvisioning:   {ClusterStateActive, ClusterStateDecommissioned},
+	ClusterStateActive:         {ClusterStateDraining, ClusterStateDecommissioned},
+	ClusterStateDraining:       {ClusterStateActive, ClusterStateDecommissioned},
+	ClusterStateDecommissioned: {ClusterStateProvisioning, ClusterStateActive},
+}
+
+// IsValidClusterState returns `true` if the given state is one of the lifecycle states of a cluster
+func IsValidClusterState(state string) bool {
+	_, found := clusterStateTransitions[state]
+	return found
+}
+
+// CanTransitionClusterState returns `true` if a cluster can be moved from the `from` state to the `to` state
+func CanTransitionClusterState(from, to string) bool {
+	if from == to {
+		return IsValidClusterState(from)
+	}
+	for _, s := range clusterStateTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 // Cluster the struct that holds the cluster info
 type Cluster struct {
 	gormsupport.LifecycleHardDelete
@@ -39,23 +84,59 @@ type Cluster struct {
 	// Application host name used by the cluster
 	AppDNS string `mapstructure:"app-dns"`
 	// Service Account token (encrypted or not, depending on the state of the sibling SATokenEncrypted field)
-	SAToken string `mapstructure:"service-account-token"`
+	SAToken string `mapstructure:"service-account-token" audit:"secret"`
+	// Path to the file which contains the Service Account token, as an alternative to setting the token itself
+	// in the config file. Not stored in the DB, the token is read from the file when the config file is loaded
+	SATokenFile string `mapstructure:"service-account-token-file" optional:"true" audit:"-" gorm:"-"` // Optional in config file
 	// Service Account username
 	SAUsername string `mapstructure:"service-account-username"`
 	// SA Token encrypted
 	SATokenEncrypted bool `mapstructure:"service-account-token-encrypted" optional:"true" default:"true"` // Optional in config file
+	// Time at which the current SA token was set
+	SATokenCreatedAt *time.Time
+	// SA token which replaces the current one at the end of its rotation grace period (encrypted or not, depending on
+	// the state of the sibling PendingSATokenEncrypted field). During this period, both tokens are valid. Empty if no rotation is in progress
+	PendingSAToken string `audit:"pending-service-account-token,secret"`
+	// Pending SA token encrypted. Replaces the `SATokenEncrypted` flag along with the pending SA token
+	PendingSATokenEncrypted bool
+	// Time at which the pending SA token was set
+	PendingSATokenCreatedAt *time.Time
+	// Time after which the pending SA token replaces the current one
+	PendingSATokenPromotionAt *time.Time
 	// Token Provider ID
 	TokenProviderID string `mapstructure:"token-provider-id"`
 	// OAuthClient ID used to link users account
 	AuthClientID string `mapstructure:"auth-client-id"`
 	// OAuthClient secret used to link users account
-	AuthClientSecret string `mapstructure:"auth-client-secret"`
+	AuthClientSecret string `mapstructure:"auth-client-secret" audit:"secret"`
+	// Path to the file which contains the OAuthClient secret, as an alternative to setting the secret itself
+	// in the config file. Not stored in the DB, the secret is read from the file when the config file is loaded
+	AuthClientSecretFile string `mapstructure:"auth-client-secret-file" optional:"true" audit:"-" gorm:"-"` // Optional in config file
+	// ID of the key with which the `SAToken`, `PendingSAToken` and `AuthClientSecret` are encrypted at rest. Empty if they are stored in clear
+	SecretsKeyID string
 	// OAuthClient default scope used to link users account
 	AuthDefaultScope string `mapstructure:"auth-client-default-scope"`
 	// Cluster type. Such as OSD, OSO, OCP, etc
 	Type string `mapstructure:"type" optional:"true" default:"OSO"` // Optional in config file
 	// cluster capacity exhausted by default false
 	CapacityExhausted bool `mapstructure:"capacity-exhausted" optional:"true"` // Optional in config file
+	// Maximum number of identities which can be linked to the cluster. If set, the `CapacityExhausted` flag
+	// is automatically managed when identities are linked to or unlinked from the cluster. `0` means no limit.
+	MaxIdentities int `mapstructure:"max-identities" optional:"true"` // Optional in config file
+	// Percentage of the `MaxIdentities` above which the cluster capacity is considered as exhausted.
+	// `0` means that the default threshold from the service configuration applies.
+	CapacityThreshold int `mapstructure:"capacity-threshold" optional:"true"` // Optional in config file
+	// Lifecycle state of the cluster (`provisioning`, `active`, `draining` or `decommissioned`). `active` by default
+	State string `mapstructure:"state" optional:"true"` // Optional in config file
+	// Origin of the cluster (`config` if it is declared in the cluster configuration file, `api` if it was registered
+	// through the REST API). Only the clusters which originate from the configuration file are reconciled with it.
+	Origin string `audit:"origin"`
+	// Version of the cluster record, incremented each time the cluster is modified (optimistic concurrency control)
+	Version int
+	// Number of identities linked to the cluster. Not stored in the DB, this value is computed on demand
+	IdentitiesCount int `gorm:"-"`
+	// Result of the latest health probe of the cluster, if any. Not stored in the `cluster` table
+	Health *ClusterHealth `gorm:"-"`
 }
 
 // Normalize fills the `console`, `metrics` and `logging` URL if there were missing,
@@ -97,9 +178,76 @@ func (c *Cluster) Normalize() error {
 	if c.Type == "" {
 		c.Type = cluster.OSO
 	}
+	// apply default state of cluster
+	if c.State == "" {
+		c.State = ClusterStateActive
+	}
 	return nil
 }
 
+// ClusterPatch the changes to apply on a cluster. Only the non-nil fields are applied, the other ones are left unchanged.
+type ClusterPatch struct {
+	Name              *string
+	URL               *string
+	ConsoleURL        *string
+	MetricsURL        *string
+	LoggingURL        *string
+	AppDNS            *string
+	Type              *string
+	CapacityExhausted *bool
+	MaxIdentities     *int
+	CapacityThreshold *int
+	State             *string
+	SAToken           *string
+	SATokenEncrypted  *bool
+	SAUsername        *string
+	TokenProviderID   *string
+	AuthClientID      *string
+	AuthClientSecret  *string
+	AuthDefaultScope  *string
+}
+
+// Apply applies the non-nil fields of the patch on the given cluster.
+// An empty console, metrics or logging URL will be derived from the API URL when the cluster is normalized.
+// A new SA token is considered as unencrypted, unless the `SATokenEncrypted` flag of the patch is set.
+func (p ClusterPatch) Apply(c *Cluster) {
+	applyString(p.Name, &c.Name)
+	applyString(p.URL, &c.URL)
+	applyString(p.ConsoleURL, &c.ConsoleURL)
+	applyString(p.MetricsURL, &c.MetricsURL)
+	applyString(p.LoggingURL, &c.LoggingURL)
+	applyString(p.AppDNS, &c.AppDNS)
+	applyString(p.Type, &c.Type)
+	if p.CapacityExhausted != nil {
+		c.CapacityExhausted = *p.CapacityExhausted
+	}
+	if p.MaxIdentities != nil {
+		c.MaxIdentities = *p.MaxIdentities
+	}
+	if p.CapacityThreshold != nil {
+		c.CapacityThreshold = *p.CapacityThreshold
+	}
+	applyString(p.State, &c.State)
+	if p.SAToken != nil {
+		c.SAToken = *p.SAToken
+		c.SATokenEncrypted = false
+	}
+	if p.SATokenEncrypted != nil {
+		c.SATokenEncrypted = *p.SATokenEncrypted
+	}
+	applyString(p.SAUsername, &c.SAUsername)
+	applyString(p.TokenProviderID, &c.TokenProviderID)
+	applyString(p.AuthClientID, &c.AuthClientID)
+	applyString(p.AuthClientSecret, &c.AuthClientSecret)
+	applyString(p.AuthDefaultScope, &c.AuthDefaultScope)
+}
+
+func applyString(value *string, field *string) {
+	if value != nil {
+		*field = *value
+	}
+}
+
 // GormClusterRepository is the implementation of the storage interface for Cluster.
 type GormClusterRepository struct {
 	db *gorm.DB
@@ -114,13 +262,19 @@ func NewClusterRepository(db *gorm.DB) ClusterRepository {
 type ClusterRepository interface {
 	base.Exister
 	Load(ctx context.Context, ID uuid.UUID) (*Cluster, error)
+	LoadForUpdate(ctx context.Context, ID uuid.UUID) (*Cluster, error)
 	Create(ctx context.Context, u *Cluster) error
 	Save(ctx context.Context, u *Cluster) error
 	CreateOrSave(ctx context.Context, u *Cluster) error
 	Delete(ctx context.Context, ID uuid.UUID) error
 	Query(funcs ...func(*gorm.DB) *gorm.DB) ([]Cluster, error)
 	FindByURL(ctx context.Context, url string) (*Cluster, error)
-	List(ctx context.Context, clusterType *string) ([]Cluster, error)
+	List(ctx context.Context, clusterType *string, states ...string) ([]Cluster, error)
+	ListPage(ctx context.Context, options ClusterListOptions) ([]Cluster, int, error)
+	UpdateCapacityExhausted(ctx context.Context, ID uuid.UUID, exhausted bool) error
+	UpdateState(ctx context.Context, ID uuid.UUID, state string) error
+	UpdateSecrets(ctx context.Context, u *Cluster, previousKeyID string) error
+	ListPendingSATokens(ctx context.Context, promotionBefore time.Time) ([]Cluster, error)
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -154,6 +308,18 @@ func (m *GormClusterRepository) Load(ctx context.Context, id uuid.UUID) (*Cluste
 	return &native, errs.WithStack(err)
 }
 
+// LoadForUpdate returns a single Cluster as a Database Model, and locks its row (`SELECT ... FOR UPDATE`) until the end
+// of the current transaction, so concurrent read-modify-write operations on the same cluster are serialized
+func (m *GormClusterRepository) LoadForUpdate(ctx context.Context, id uuid.UUID) (*Cluster, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "cluster", "load_for_update"}, time.Now())
+	var native Cluster
+	err := m.db.Table(m.TableName()).Set("gorm:query_option", "FOR UPDATE").Where("cluster_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("cluster", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
 // LoadByURL returns a single Cluster filtered using 'url'
 func (m *GormClusterRepository) FindByURL(ctx context.Context, url string) (*Cluster, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "cluster", "loadClusterByURL"}, time.Now())
@@ -176,6 +342,9 @@ func (m *GormClusterRepository) Create(ctx context.Context, c *Cluster) error {
 	if err != nil {
 		return errs.WithStack(err)
 	}
+	if c.Origin == "" {
+		c.Origin = ClusterOriginAPI
+	}
 	err = m.db.Create(c).Error
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
@@ -184,13 +353,18 @@ func (m *GormClusterRepository) Create(ctx context.Context, c *Cluster) error {
 		}, "unable to create the cluster")
 		return errs.WithStack(err)
 	}
+	err = recordAudit(ctx, m.db, AuditOperationClusterCreate, c.ClusterID, nil, DiffClusters(nil, c))
+	if err != nil {
+		return err
+	}
 	log.Debug(ctx, map[string]interface{}{
 		"cluster_id": c.ClusterID.String(),
 	}, "Cluster created!")
 	return nil
 }
 
-// Save modifies a single record
+// Save modifies a single record. The given cluster must have the same version as the stored record,
+// otherwise a `VersionConflictError` is returned.
 func (m *GormClusterRepository) Save(ctx context.Context, c *Cluster) error {
 	defer goa.MeasureSince([]string{"goa", "db", "cluster", "save"}, time.Now())
 
@@ -202,10 +376,13 @@ func (m *GormClusterRepository) Save(ctx context.Context, c *Cluster) error {
 		}, "unable to update cluster")
 		return errs.WithStack(err)
 	}
+	if c.Version != existing.Version {
+		return errors.NewVersionConflictError(fmt.Sprintf("version %d of cluster with id '%s' is outdated (current version is %d)", c.Version, c.ClusterID.String(), existing.Version))
+	}
 	return m.update(ctx, existing, c)
 }
 
-// update updates the existing cluster record with the given "new" one
+// update updates the existing cluster record with the given "new" one, and increments its version if it changed
 func (m *GormClusterRepository) update(ctx context.Context, existing, c *Cluster) error {
 	err := c.Normalize()
 	if err != nil {
@@ -214,6 +391,26 @@ func (m *GormClusterRepository) update(ctx context.Context, existing, c *Cluster
 	c.ClusterID = existing.ClusterID
 	c.CreatedAt = existing.CreatedAt
 	c.UpdatedAt = existing.UpdatedAt
+	if c.Origin == "" {
+		c.Origin = existing.Origin
+	}
+	changes := DiffClusters(existing, c)
+	c.Version = existing.Version
+	if len(changes) > 0 {
+		c.Version++
+	}
+	// make sure that the cluster was not modified since the existing record was loaded
+	result := m.db.Model(&Cluster{}).Where("cluster_id = ? AND version = ?", c.ClusterID, existing.Version).UpdateColumn("version", c.Version)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"cluster_id": c.ClusterID.String(),
+			"err":        result.Error,
+		}, "unable to update cluster")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewVersionConflictError(fmt.Sprintf("cluster with id '%s' was modified concurrently", c.ClusterID.String()))
+	}
 	err = m.db.Save(c).Error
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
@@ -222,6 +419,13 @@ func (m *GormClusterRepository) update(ctx context.Context, existing, c *Cluster
 		}, "unable to update cluster")
 		return errs.WithStack(err)
 	}
+	// no need to record an audit entry when the cluster was saved with the same values (eg: when reloading the config file)
+	if len(changes) > 0 {
+		err = recordAudit(ctx, m.db, AuditOperationClusterUpdate, c.ClusterID, nil, changes)
+		if err != nil {
+			return err
+		}
+	}
 
 	log.Info(ctx, map[string]interface{}{
 		"cluster_id":  c.ClusterID.String(),
@@ -230,7 +434,8 @@ func (m *GormClusterRepository) update(ctx context.Context, existing, c *Cluster
 	return nil
 }
 
-// CreateOrSave creates cluster or saves cluster if any cluster found using url
+// CreateOrSave creates cluster or saves cluster if any cluster found using url. Unlike `Save`, the existing cluster
+// is overwritten whatever the version of the given cluster.
 func (m *GormClusterRepository) CreateOrSave(ctx context.Context, c *Cluster) error {
 	existing, err := m.FindByURL(ctx, c.URL)
 	if err != nil {
@@ -246,7 +451,128 @@ func (m *GormClusterRepository) CreateOrSave(ctx context.Context, c *Cluster) er
 	return m.update(ctx, existing, c)
 }
 
+// UpdateCapacityExhausted updates the `capacity_exhausted` column of the cluster identified by the given ID
+func (m *GormClusterRepository) UpdateCapacityExhausted(ctx context.Context, id uuid.UUID, exhausted bool) error {
+	defer goa.MeasureSince([]string{"goa", "db", "cluster", "update_capacity_exhausted"}, time.Now())
+	existing, err := m.Load(ctx, id)
+	if err != nil {
+		return err
+	}
+	columns := map[string]interface{}{
+		"capacity_exhausted": exhausted,
+	}
+	if existing.CapacityExhausted != exhausted {
+		columns["version"] = gorm.Expr("version + 1")
+	}
+	result := m.db.Model(&Cluster{}).Where("cluster_id = ?", id).Updates(columns)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"cluster_id": id.String(),
+			"err":        result.Error,
+		}, "unable to update the cluster capacity")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("cluster", id.String())
+	}
+	if existing.CapacityExhausted != exhausted {
+		err = recordAudit(ctx, m.db, AuditOperationClusterUpdate, id, nil, AuditChanges{
+			"capacity-exhausted": {Old: existing.CapacityExhausted, New: exhausted},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Info(ctx, map[string]interface{}{
+		"cluster_id":         id.String(),
+		"capacity_exhausted": exhausted,
+	}, "cluster capacity updated")
+	return nil
+}
+
+// UpdateState updates the lifecycle state of the cluster identified by the given ID.
+// The transition is not checked here, see `CanTransitionClusterState`.
+func (m *GormClusterRepository) UpdateState(ctx context.Context, id uuid.UUID, state string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "cluster", "update_state"}, time.Now())
+	existing, err := m.Load(ctx, id)
+	if err != nil {
+		return err
+	}
+	columns := map[string]interface{}{
+		"state": state,
+	}
+	if existing.State != state {
+		columns["version"] = gorm.Expr("version + 1")
+	}
+	result := m.db.Model(&Cluster{}).Where("cluster_id = ?", id).Updates(columns)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"cluster_id": id.String(),
+			"state":      state,
+			"err":        result.Error,
+		}, "unable to update the cluster state")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("cluster", id.String())
+	}
+	if existing.State != state {
+		err = recordAudit(ctx, m.db, AuditOperationClusterUpdate, id, nil, AuditChanges{
+			"state": {Old: existing.State, New: state},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.Info(ctx, map[string]interface{}{
+		"cluster_id": id.String(),
+		"state":      state,
+	}, "cluster state updated")
+	return nil
+}
+
+// UpdateSecrets replaces the (encrypted) SA tokens and OAuth client secret of the cluster record with the ones of the given
+// cluster, along with the ID of the key used to encrypt them. The update only applies if the secrets are still encrypted
+// with the `previousKeyID` key, otherwise a `DataConflictError` is returned.
+func (m *GormClusterRepository) UpdateSecrets(ctx context.Context, c *Cluster, previousKeyID string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "cluster", "update_secrets"}, time.Now())
+	id := c.ClusterID
+	keyID := c.SecretsKeyID
+	result := m.db.Model(&Cluster{}).Where("cluster_id = ? AND secrets_key_id = ?", id, previousKeyID).Updates(map[string]interface{}{
+		"sa_token":           c.SAToken,
+		"pending_sa_token":   c.PendingSAToken,
+		"auth_client_secret": c.AuthClientSecret,
+		"secrets_key_id":     keyID,
+		"version":            gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"cluster_id": id.String(),
+			"err":        result.Error,
+		}, "unable to update the cluster secrets")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if _, err := m.Load(ctx, id); err != nil {
+			return err
+		}
+		return errors.NewDataConflictError(fmt.Sprintf("secrets of cluster '%s' are no longer encrypted with key '%s'", id.String(), previousKeyID))
+	}
+	err := recordAudit(ctx, m.db, AuditOperationClusterUpdate, id, nil, AuditChanges{
+		"secrets-key-id": {Old: previousKeyID, New: keyID},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"cluster_id": id.String(),
+		"key_id":     keyID,
+	}, "cluster secrets updated")
+	return nil
+}
+
 // Delete removes a single record. This is a hard delete!
+// Note: the cluster service moves the clusters to the `decommissioned` state instead.
 // Also, removes all identity/cluster relationship associated with this cluster.
 func (m *GormClusterRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "cluster", "delete"}, time.Now())
@@ -270,6 +596,10 @@ func (m *GormClusterRepository) Delete(ctx context.Context, id uuid.UUID) error
 	if result.RowsAffected == 0 {
 		return errors.NewNotFoundError("cluster", id.String())
 	}
+	err = recordAudit(ctx, m.db, AuditOperationClusterDelete, id, nil, DiffClusters(toDelete, nil))
+	if err != nil {
+		return err
+	}
 
 	log.Info(ctx, map[string]interface{}{
 		"cluster_id":  id.String(),
@@ -293,17 +623,168 @@ func (m *GormClusterRepository) Query(funcs ...func(*gorm.DB) *gorm.DB) ([]Clust
 	return objs, nil
 }
 
-// List lists all clusters (with the given optional type)
-func (m *GormClusterRepository) List(ctx context.Context, clusterType *string) ([]Cluster, error) {
+// List lists all clusters (with the given optional type), in any of the given states (or in any state if none is given)
+func (m *GormClusterRepository) List(ctx context.Context, clusterType *string, states ...string) ([]Cluster, error) {
 	funcs := []func(*gorm.DB) *gorm.DB{}
 	if clusterType != nil {
 		funcs = append(funcs, filterByType(*clusterType))
 	}
+	if len(states) > 0 {
+		funcs = append(funcs, filterByStates(states))
+	}
 	return m.Query(funcs...)
 }
 
+// ClusterListOptions the criteria to filter, sort and paginate the clusters to list. Filters which are not set are ignored.
+type ClusterListOptions struct {
+	// Type of the clusters to list
+	Type *string
+	// States of the clusters to list (any state if empty)
+	States []string
+	// Name of the clusters to list
+	Name *string
+	// Application domain name of the clusters to list
+	AppDNS *string
+	// Capacity exhaustion flag of the clusters to list
+	CapacityExhausted *bool
+	// If `true`, only the clusters whose latest health probe succeeded are listed. If `false`, only the clusters
+	// whose latest health probe failed or did not happen yet are listed
+	Healthy *bool
+	// Time after which the clusters to list were created (inclusive)
+	CreatedAfter *time.Time
+	// Time before which the clusters to list were created (exclusive)
+	CreatedBefore *time.Time
+	// Sort order: `name`, `-name`, `created-at` (the default) or `-created-at`
+	Sort string
+	// Number of clusters to skip
+	Offset int
+	// Maximum number of clusters to list. `0` means no limit
+	Limit int
+}
+
+// clusterSortOrders the `ORDER BY` clauses matching the supported sort orders. The cluster ID is appended to each
+// clause to guarantee a stable order between pages.
+var clusterSortOrders = map[string]string{
+	"":            "created_at ASC, cluster_id ASC",
+	"created-at":  "created_at ASC, cluster_id ASC",
+	"-created-at": "created_at DESC, cluster_id ASC",
+	"name":        "name ASC, cluster_id ASC",
+	"-name":       "name DESC, cluster_id ASC",
+}
+
+// ListPage lists the clusters matching the given options, along with the total number of matching clusters
+// (regardless of the offset and limit)
+func (m *GormClusterRepository) ListPage(ctx context.Context, options ClusterListOptions) ([]Cluster, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "cluster", "listPage"}, time.Now())
+	order, found := clusterSortOrders[options.Sort]
+	if !found {
+		return nil, 0, errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid sort order: '%s' (expected 'name', '-name', 'created-at' or '-created-at')", options.Sort))
+	}
+	if options.Offset < 0 {
+		return nil, 0, errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid offset: %d (expected a positive value)", options.Offset))
+	}
+	if options.Limit < 0 {
+		return nil, 0, errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid limit: %d (expected a positive value)", options.Limit))
+	}
+	filters := []func(*gorm.DB) *gorm.DB{}
+	if options.Type != nil {
+		filters = append(filters, filterByType(*options.Type))
+	}
+	if len(options.States) > 0 {
+		filters = append(filters, filterByStates(options.States))
+	}
+	if options.Name != nil {
+		filters = append(filters, filterByName(*options.Name))
+	}
+	if options.AppDNS != nil {
+		filters = append(filters, filterByAppDNS(*options.AppDNS))
+	}
+	if options.CapacityExhausted != nil {
+		filters = append(filters, filterByCapacityExhausted(*options.CapacityExhausted))
+	}
+	if options.Healthy != nil {
+		filters = append(filters, filterByHealth(*options.Healthy))
+	}
+	if options.CreatedAfter != nil || options.CreatedBefore != nil {
+		filters = append(filters, filterByCreationTime(options.CreatedAfter, options.CreatedBefore))
+	}
+	var total int
+	err := m.db.Scopes(filters...).Table(m.TableName()).Count(&total).Error
+	if err != nil {
+		return nil, 0, errs.WithStack(err)
+	}
+	clusters, err := m.Query(append(filters, paginate(order, options.Offset, options.Limit))...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return clusters, total, nil
+}
+
+// ListPendingSATokens lists all clusters with a pending SA token which should be promoted before the given time
+func (m *GormClusterRepository) ListPendingSATokens(ctx context.Context, promotionBefore time.Time) ([]Cluster, error) {
+	return m.Query(func(db *gorm.DB) *gorm.DB {
+		return db.Where("pending_sa_token <> '' AND pending_sa_token_promotion_at <= ?", promotionBefore)
+	})
+}
+
 func filterByType(clusterType string) func(*gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
 		return db.Where("type = ?", clusterType)
 	}
 }
+
+func filterByStates(states []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("state IN (?)", states)
+	}
+}
+
+func filterByName(name string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("name = ?", name)
+	}
+}
+
+func filterByAppDNS(appDNS string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// the app DNS is stored with a trailing slash (see the Cluster.Normalize() method)
+		return db.Where("app_dns = ?", httpsupport.AddTrailingSlashToURL(appDNS))
+	}
+}
+
+func filterByCapacityExhausted(exhausted bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("capacity_exhausted = ?", exhausted)
+	}
+}
+
+func filterByHealth(healthy bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if healthy {
+			return db.Where("cluster_id IN (SELECT cluster_id FROM cluster_health WHERE state = ?)", HealthStateHealthy)
+		}
+		return db.Where("cluster_id NOT IN (SELECT cluster_id FROM cluster_health WHERE state = ?)", HealthStateHealthy)
+	}
+}
+
+func filterByCreationTime(after, before *time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if after != nil {
+			db = db.Where("created_at >= ?", *after)
+		}
+		if before != nil {
+			db = db.Where("created_at < ?", *before)
+		}
+		return db
+	}
+}
+
+func paginate(order string, offset, limit int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Order(order).Offset(offset)
+		if limit > 0 {
+			db = db.Limit(limit)
+		}
+		return db
+	}
+}
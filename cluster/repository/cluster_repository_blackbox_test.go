@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
 	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
@@ -182,6 +183,30 @@ func (s *clusterRepositoryTestSuite) TestLoadUnknownFails() {
 	test.AssertError(s.T(), err, errors.NotFoundError{}, "cluster with id '%s' not found", id)
 }
 
+func (s *clusterRepositoryTestSuite) TestLoadForUpdate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		cluster1 := test.CreateCluster(t, s.DB)
+		test.CreateCluster(t, s.DB) // noise
+		// when
+		loaded, err := s.repo.LoadForUpdate(context.Background(), cluster1.ClusterID)
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, loaded)
+		test.AssertEqualCluster(t, cluster1, *loaded, true)
+	})
+
+	s.T().Run("unknown", func(t *testing.T) {
+		// given
+		id := uuid.NewV4()
+		// when
+		_, err := s.repo.LoadForUpdate(context.Background(), id)
+		// then
+		test.AssertError(t, err, errors.NotFoundError{}, "cluster with id '%s' not found", id)
+	})
+}
+
 func (s *clusterRepositoryTestSuite) TestSaveOK() {
 	// given
 	cluster1 := test.CreateCluster(s.T(), s.DB)
@@ -225,6 +250,222 @@ func (s *clusterRepositoryTestSuite) TestSaveOK() {
 	assert.False(s.T(), loaded1.CapacityExhausted)
 }
 
+func (s *clusterRepositoryTestSuite) TestVersion() {
+
+	s.T().Run("incremented on change", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		require.Equal(t, 0, c.Version)
+		c.Name = uuid.NewV4().String()
+		// when
+		err := s.repo.Save(context.Background(), &c)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, c.Version)
+		loaded, err := s.repo.Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, loaded.Version)
+		// also when the state or the capacity is updated
+		err = s.repo.UpdateState(context.Background(), c.ClusterID, repository.ClusterStateDraining)
+		require.NoError(t, err)
+		err = s.repo.UpdateCapacityExhausted(context.Background(), c.ClusterID, !c.CapacityExhausted)
+		require.NoError(t, err)
+		loaded, err = s.repo.Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, loaded.Version)
+	})
+
+	s.T().Run("unchanged without change", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		// when
+		err := s.repo.Save(context.Background(), &c)
+		require.NoError(t, err)
+		err = s.repo.UpdateState(context.Background(), c.ClusterID, c.State)
+		require.NoError(t, err)
+		// then
+		loaded, err := s.repo.Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, loaded.Version)
+	})
+
+	s.T().Run("conflict on save", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		other := c
+		other.Name = uuid.NewV4().String()
+		err := s.repo.Save(context.Background(), &other)
+		require.NoError(t, err)
+		// when saving the outdated version
+		c.AppDNS = uuid.NewV4().String()
+		err = s.repo.Save(context.Background(), &c)
+		// then
+		test.AssertError(t, err, errors.VersionConflictError{}, "version 0 of cluster with id '%s' is outdated (current version is 1)", c.ClusterID)
+		loaded, err := s.repo.Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, other, *loaded, true)
+	})
+
+	s.T().Run("no conflict on create or save", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		other := c
+		other.Name = uuid.NewV4().String()
+		err := s.repo.Save(context.Background(), &other)
+		require.NoError(t, err)
+		// when
+		c.AppDNS = uuid.NewV4().String()
+		err = s.repo.CreateOrSave(context.Background(), &c)
+		// then the existing cluster is overwritten
+		require.NoError(t, err)
+		assert.Equal(t, 2, c.Version)
+		loaded, err := s.repo.Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, c, *loaded, true)
+	})
+}
+
+func (s *clusterRepositoryTestSuite) TestUpdateCapacityExhausted() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		cluster1 := test.CreateCluster(t, s.DB, test.WithMaxIdentities(100, 80))
+		cluster2 := test.CreateCluster(t, s.DB) // noise
+		// when
+		err := s.repo.UpdateCapacityExhausted(context.Background(), cluster1.ClusterID, true)
+		// then only the `capacity_exhausted` column was updated
+		require.NoError(t, err)
+		loaded1, err := s.repo.Load(context.Background(), cluster1.ClusterID)
+		require.NoError(t, err)
+		cluster1.CapacityExhausted = true
+		test.AssertEqualCluster(t, cluster1, *loaded1, true)
+		loaded2, err := s.repo.Load(context.Background(), cluster2.ClusterID)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, cluster2, *loaded2, true)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given
+		id := uuid.NewV4()
+		// when
+		err := s.repo.UpdateCapacityExhausted(context.Background(), id, true)
+		// then
+		test.AssertError(t, err, errors.NotFoundError{}, "cluster with id '%s' not found", id)
+	})
+}
+
+func (s *clusterRepositoryTestSuite) TestUpdateState() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		cluster1 := test.CreateCluster(t, s.DB)
+		cluster2 := test.CreateCluster(t, s.DB) // noise
+		// when
+		err := s.repo.UpdateState(context.Background(), cluster1.ClusterID, repository.ClusterStateDraining)
+		// then only the `state` column was updated
+		require.NoError(t, err)
+		loaded1, err := s.repo.Load(context.Background(), cluster1.ClusterID)
+		require.NoError(t, err)
+		cluster1.State = repository.ClusterStateDraining
+		test.AssertEqualCluster(t, cluster1, *loaded1, true)
+		loaded2, err := s.repo.Load(context.Background(), cluster2.ClusterID)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, cluster2, *loaded2, true)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given
+		id := uuid.NewV4()
+		// when
+		err := s.repo.UpdateState(context.Background(), id, repository.ClusterStateDraining)
+		// then
+		test.AssertError(t, err, errors.NotFoundError{}, "cluster with id '%s' not found", id)
+	})
+}
+
+func (s *clusterRepositoryTestSuite) TestUpdateSecrets() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		cluster1 := test.CreateCluster(t, s.DB)
+		cluster2 := test.CreateCluster(t, s.DB) // noise
+		updated := cluster1
+		updated.Name = "ignored"
+		updated.SAToken = "encrypted-token"
+		updated.PendingSAToken = "encrypted-pending-token"
+		updated.AuthClientSecret = "encrypted-secret"
+		updated.SecretsKeyID = "key-2"
+		// when
+		err := s.repo.UpdateSecrets(context.Background(), &updated, "")
+		// then only the secrets and the key ID were updated
+		require.NoError(t, err)
+		loaded1, err := s.repo.Load(context.Background(), cluster1.ClusterID)
+		require.NoError(t, err)
+		cluster1.SAToken = "encrypted-token"
+		cluster1.PendingSAToken = "encrypted-pending-token"
+		cluster1.AuthClientSecret = "encrypted-secret"
+		cluster1.SecretsKeyID = "key-2"
+		test.AssertEqualCluster(t, cluster1, *loaded1, true)
+		loaded2, err := s.repo.Load(context.Background(), cluster2.ClusterID)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, cluster2, *loaded2, true)
+	})
+
+	s.T().Run("conflict", func(t *testing.T) {
+		// given
+		cluster1 := test.CreateCluster(t, s.DB)
+		updated := cluster1
+		updated.SAToken = "encrypted-token"
+		updated.SecretsKeyID = "key-2"
+		// when the secrets are not encrypted with the expected key
+		err := s.repo.UpdateSecrets(context.Background(), &updated, "key-1")
+		// then
+		test.AssertError(t, err, errors.DataConflictError{}, "secrets of cluster '%s' are no longer encrypted with key 'key-1'", cluster1.ClusterID)
+		loaded1, err := s.repo.Load(context.Background(), cluster1.ClusterID)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, cluster1, *loaded1, true)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given
+		id := uuid.NewV4()
+		updated := test.NewCluster()
+		updated.ClusterID = id
+		updated.SecretsKeyID = "key-2"
+		// when
+		err := s.repo.UpdateSecrets(context.Background(), &updated, "")
+		// then
+		test.AssertError(t, err, errors.NotFoundError{}, "cluster with id '%s' not found", id)
+	})
+}
+
+func (s *clusterRepositoryTestSuite) TestListPendingSATokens() {
+	// given
+	now := time.Now()
+	past := now.Add(-1 * time.Minute)
+	future := now.Add(time.Hour)
+	expired := test.CreateCluster(s.T(), s.DB)
+	expired.PendingSAToken = "PendingSAToken"
+	expired.PendingSATokenCreatedAt = &past
+	expired.PendingSATokenPromotionAt = &past
+	err := s.repo.Save(context.Background(), &expired)
+	require.NoError(s.T(), err)
+	pending := test.CreateCluster(s.T(), s.DB)
+	pending.PendingSAToken = "PendingSAToken"
+	pending.PendingSATokenCreatedAt = &past
+	pending.PendingSATokenPromotionAt = &future
+	err = s.repo.Save(context.Background(), &pending)
+	require.NoError(s.T(), err)
+	test.CreateCluster(s.T(), s.DB) // noise: no pending token
+	// when
+	clusters, err := s.repo.ListPendingSATokens(context.Background(), now)
+	// then
+	require.NoError(s.T(), err)
+	require.Len(s.T(), clusters, 1)
+	assert.Equal(s.T(), expired.ClusterID, clusters[0].ClusterID)
+	assert.Equal(s.T(), "PendingSAToken", clusters[0].PendingSAToken)
+}
+
 func (s *clusterRepositoryTestSuite) TestSaveUnknownFails() {
 	// given
 	id := uuid.NewV4()
@@ -281,4 +522,154 @@ func (s *clusterRepositoryTestSuite) TestList() {
 		require.Len(t, clusters, 1)
 		test.AssertClusters(t, clusters, cluster1, true)
 	})
+
+	s.T().Run("filter by states", func(t *testing.T) {
+		// given
+		cluster3 := test.CreateCluster(t, s.DB, test.WithState(repository.ClusterStateDraining))
+		cluster4 := test.CreateCluster(t, s.DB, test.WithState(repository.ClusterStateDecommissioned))
+		// when
+		clusters, err := s.repo.List(context.Background(), nil, repository.ClusterStateActive, repository.ClusterStateDraining)
+		// then
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, len(clusters))
+		for i, c := range clusters {
+			assert.Contains(t, []string{repository.ClusterStateActive, repository.ClusterStateDraining}, c.State)
+			ids[i] = c.ClusterID
+		}
+		assert.Contains(t, ids, cluster1.ClusterID)
+		assert.Contains(t, ids, cluster2.ClusterID)
+		assert.Contains(t, ids, cluster3.ClusterID)
+		assert.NotContains(t, ids, cluster4.ClusterID)
+	})
+}
+
+func (s *clusterRepositoryTestSuite) TestListPage() {
+	// given
+	clusterType := uuid.NewV4().String() // the type of all clusters in this test, to ignore the other clusters in the DB
+	cluster1 := test.CreateCluster(s.T(), s.DB, test.WithType(clusterType), test.WithName("b"))
+	cluster2 := test.CreateCluster(s.T(), s.DB, test.WithType(clusterType), test.WithName("a"), test.WithCapacityExhausted(true))
+	cluster3 := test.CreateCluster(s.T(), s.DB, test.WithType(clusterType), test.WithName("c"))
+	test.CreateClusterHealth(s.T(), s.DB, cluster1, repository.HealthStateHealthy)
+	test.CreateClusterHealth(s.T(), s.DB, cluster2, repository.HealthStateUnhealthy)
+	// reload the cluster to get its creation time with the same precision as in the DB
+	loaded2, err := s.repo.Load(context.Background(), cluster2.ClusterID)
+	require.NoError(s.T(), err)
+
+	clusterIDs := func(clusters []repository.Cluster) []uuid.UUID {
+		ids := make([]uuid.UUID, len(clusters))
+		for i, c := range clusters {
+			ids[i] = c.ClusterID
+		}
+		return ids
+	}
+
+	s.T().Run("sort", func(t *testing.T) {
+		for sort, expected := range map[string][]uuid.UUID{
+			"":            {cluster1.ClusterID, cluster2.ClusterID, cluster3.ClusterID},
+			"created-at":  {cluster1.ClusterID, cluster2.ClusterID, cluster3.ClusterID},
+			"-created-at": {cluster3.ClusterID, cluster2.ClusterID, cluster1.ClusterID},
+			"name":        {cluster2.ClusterID, cluster1.ClusterID, cluster3.ClusterID},
+			"-name":       {cluster3.ClusterID, cluster1.ClusterID, cluster2.ClusterID},
+		} {
+			t.Run(sort, func(t *testing.T) {
+				// when
+				clusters, total, err := s.repo.ListPage(context.Background(), repository.ClusterListOptions{
+					Type: &clusterType,
+					Sort: sort,
+				})
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, 3, total)
+				assert.Equal(t, expected, clusterIDs(clusters))
+			})
+		}
+	})
+
+	s.T().Run("paginate", func(t *testing.T) {
+		// when
+		clusters, total, err := s.repo.ListPage(context.Background(), repository.ClusterListOptions{
+			Type:   &clusterType,
+			Offset: 1,
+			Limit:  1,
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []uuid.UUID{cluster2.ClusterID}, clusterIDs(clusters))
+	})
+
+	s.T().Run("paginate beyond the last cluster", func(t *testing.T) {
+		// when
+		clusters, total, err := s.repo.ListPage(context.Background(), repository.ClusterListOptions{
+			Type:   &clusterType,
+			Offset: 3,
+			Limit:  2,
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Empty(t, clusters)
+	})
+
+	s.T().Run("filter", func(t *testing.T) {
+		exhausted := true
+		healthy := true
+		unhealthy := false
+		name := "c"
+		appDNS := strings.TrimSuffix(cluster1.AppDNS, "/")
+		for desc, tc := range map[string]struct {
+			options  repository.ClusterListOptions
+			expected []uuid.UUID
+		}{
+			"by name": {
+				options:  repository.ClusterListOptions{Name: &name},
+				expected: []uuid.UUID{cluster3.ClusterID},
+			},
+			"by app dns": {
+				options:  repository.ClusterListOptions{AppDNS: &appDNS},
+				expected: []uuid.UUID{cluster1.ClusterID},
+			},
+			"by capacity exhausted": {
+				options:  repository.ClusterListOptions{CapacityExhausted: &exhausted},
+				expected: []uuid.UUID{cluster2.ClusterID},
+			},
+			"healthy": {
+				options:  repository.ClusterListOptions{Healthy: &healthy},
+				expected: []uuid.UUID{cluster1.ClusterID},
+			},
+			"unhealthy": {
+				options:  repository.ClusterListOptions{Healthy: &unhealthy},
+				expected: []uuid.UUID{cluster2.ClusterID, cluster3.ClusterID},
+			},
+			"created after": {
+				options:  repository.ClusterListOptions{CreatedAfter: &loaded2.CreatedAt},
+				expected: []uuid.UUID{cluster2.ClusterID, cluster3.ClusterID},
+			},
+			"created before": {
+				options:  repository.ClusterListOptions{CreatedBefore: &loaded2.CreatedAt},
+				expected: []uuid.UUID{cluster1.ClusterID},
+			},
+		} {
+			t.Run(desc, func(t *testing.T) {
+				// given
+				options := tc.options
+				options.Type = &clusterType
+				// when
+				clusters, total, err := s.repo.ListPage(context.Background(), options)
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, len(tc.expected), total)
+				assert.Equal(t, tc.expected, clusterIDs(clusters))
+			})
+		}
+	})
+
+	s.T().Run("invalid sort order", func(t *testing.T) {
+		// when
+		_, _, err := s.repo.ListPage(context.Background(), repository.ClusterListOptions{
+			Sort: "url",
+		})
+		// then
+		test.AssertError(t, err, errors.BadParameterError{}, "invalid sort order: 'url' (expected 'name', '-name', 'created-at' or '-created-at')")
+	})
 }
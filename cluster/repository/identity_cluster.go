@@ -19,8 +19,14 @@ import (
 type IdentityClusterRepository interface {
 	Load(ctx context.Context, identityID, clusterID uuid.UUID) (*IdentityCluster, error)
 	ListClustersForIdentity(ctx context.Context, identityID uuid.UUID) ([]Cluster, error)
+	ListForIdentities(ctx context.Context, identityIDs []uuid.UUID) ([]IdentityCluster, error)
+	CountIdentitiesByCluster(ctx context.Context) (map[uuid.UUID]int, error)
+	CountIdentities(ctx context.Context, clusterID uuid.UUID) (int, error)
+	ListIdentities(ctx context.Context, clusterID uuid.UUID, offset, limit int) ([]IdentityCluster, int, error)
+	StreamIdentities(ctx context.Context, clusterID uuid.UUID, fn func(IdentityCluster) error) error
 	Create(ctx context.Context, u *IdentityCluster) error
 	Delete(ctx context.Context, identityID uuid.UUID, clusterURL string) error
+	Move(ctx context.Context, identityID, sourceClusterID, targetClusterID uuid.UUID) error
 }
 
 // IdentityCluster a type that associates an Identity to a Cluster
@@ -34,6 +40,53 @@ type IdentityCluster struct {
 	ClusterID uuid.UUID ` sql:"type:uuid" gorm:"primary_key;column:cluster_id"`
 }
 
+// IdentityMigration the report of the migration of identities from a source cluster to a target cluster
+type IdentityMigration struct {
+	// The cluster from which the identities are moved
+	SourceCluster Cluster
+	// The cluster to which the identities are moved
+	TargetCluster Cluster
+	// The IDs of the identities which are moved
+	IdentityIDs []uuid.UUID
+	// `true` if the migration was only simulated
+	DryRun bool
+}
+
+// IdentityClusterLink a link to create (or remove) between an identity and a cluster, as part of a bulk operation
+type IdentityClusterLink struct {
+	// The ID of the identity
+	IdentityID uuid.UUID
+	// The API URL of the cluster
+	ClusterURL string
+	// `true` if the link should not fail when it already exists (ignored when removing the link)
+	IgnoreIfAlreadyExists bool
+}
+
+// the status of the items of a bulk operation on identity/cluster links
+const (
+	// IdentityClusterLinkCreated the link was created
+	IdentityClusterLinkCreated = "created"
+	// IdentityClusterLinkAlreadyExists the link already existed, and was left unchanged
+	IdentityClusterLinkAlreadyExists = "already-exists"
+	// IdentityClusterLinkDeleted the link was removed
+	IdentityClusterLinkDeleted = "deleted"
+	// IdentityClusterLinkFailed the link could not be created (or removed)
+	IdentityClusterLinkFailed = "failed"
+	// IdentityClusterLinkRolledBack the link was created (or removed), but the transaction was rolled back afterwards
+	IdentityClusterLinkRolledBack = "rolled-back"
+	// IdentityClusterLinkSkipped the link was not processed, because the transaction was already rolled back
+	IdentityClusterLinkSkipped = "skipped"
+)
+
+// IdentityClusterLinkResult the outcome of a link creation (or removal) in a bulk operation
+type IdentityClusterLinkResult struct {
+	IdentityClusterLink
+	// The status of the link
+	Status string
+	// The error which occurred, if the status is `failed`
+	Error error
+}
+
 // GormIdentityClusterRepository is the implementation of the storage interface for IdentityCluster.
 type GormIdentityClusterRepository struct {
 	db *gorm.DB
@@ -84,6 +137,93 @@ func (m *GormIdentityClusterRepository) ListClustersForIdentity(ctx context.Cont
 	return clusters, nil
 }
 
+// ListForIdentities returns the identity/cluster relationships of all the given identities, with their cluster,
+// ordered by identity ID and by creation time
+func (m *GormIdentityClusterRepository) ListForIdentities(ctx context.Context, identityIDs []uuid.UUID) ([]IdentityCluster, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_cluster", "list_for_identities"}, time.Now())
+	rows := []IdentityCluster{}
+	if len(identityIDs) == 0 {
+		return rows, nil
+	}
+	err := m.db.Table(m.TableName()).Preload("Cluster").Where("identity_id in (?)", identityIDs).Order("identity_id, created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// CountIdentitiesByCluster returns the number of identities linked to each cluster, indexed by cluster ID.
+// Clusters without any linked identity are not part of the result.
+func (m *GormIdentityClusterRepository) CountIdentitiesByCluster(ctx context.Context) (map[uuid.UUID]int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_cluster", "count_identities_by_cluster"}, time.Now())
+	rows, err := m.db.Table(m.TableName()).Select("cluster_id, count(identity_id)").Group("cluster_id").Rows()
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	defer rows.Close()
+	result := map[uuid.UUID]int{}
+	for rows.Next() {
+		var clusterID uuid.UUID
+		var count int
+		if err := rows.Scan(&clusterID, &count); err != nil {
+			return nil, errs.WithStack(err)
+		}
+		result[clusterID] = count
+	}
+	return result, errs.WithStack(rows.Err())
+}
+
+// CountIdentities returns the number of identities linked to the cluster with the given ID
+func (m *GormIdentityClusterRepository) CountIdentities(ctx context.Context, clusterID uuid.UUID) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_cluster", "count_identities"}, time.Now())
+	var count int
+	err := m.db.Table(m.TableName()).Where("cluster_id = ?", clusterID).Count(&count).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
+
+// ListIdentities returns the identity/cluster relationships of the cluster with the given ID, ordered by identity ID,
+// along with the total number of identities linked to the cluster. A `0` limit means no limit.
+func (m *GormIdentityClusterRepository) ListIdentities(ctx context.Context, clusterID uuid.UUID, offset, limit int) ([]IdentityCluster, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_cluster", "list_identities"}, time.Now())
+	total, err := m.CountIdentities(ctx, clusterID)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := m.db.Table(m.TableName()).Where("cluster_id = ?", clusterID).Order("identity_id").Offset(offset)
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	var rows []IdentityCluster
+	if err := db.Find(&rows).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, errs.WithStack(err)
+	}
+	return rows, total, nil
+}
+
+// StreamIdentities calls the given function with each identity/cluster relationship of the cluster with the given ID,
+// ordered by identity ID, without loading them all in memory. Stops at the first error returned by the function.
+func (m *GormIdentityClusterRepository) StreamIdentities(ctx context.Context, clusterID uuid.UUID, fn func(IdentityCluster) error) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_cluster", "stream_identities"}, time.Now())
+	rows, err := m.db.Table(m.TableName()).Where("cluster_id = ?", clusterID).Order("identity_id").Rows()
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var identityCluster IdentityCluster
+		if err := m.db.ScanRows(rows, &identityCluster); err != nil {
+			return errs.WithStack(err)
+		}
+		if err := fn(identityCluster); err != nil {
+			return err
+		}
+	}
+	return errs.WithStack(rows.Err())
+}
+
 // Create creates a new record.
 func (m *GormIdentityClusterRepository) Create(ctx context.Context, c *IdentityCluster) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity_cluster", "create"}, time.Now())
@@ -96,6 +236,10 @@ func (m *GormIdentityClusterRepository) Create(ctx context.Context, c *IdentityC
 		}, "unable to create the identity cluster")
 		return errs.WithStack(err)
 	}
+	err = recordAudit(ctx, m.db, AuditOperationIdentityLink, c.ClusterID, &c.IdentityID, nil)
+	if err != nil {
+		return err
+	}
 	log.Debug(ctx, map[string]interface{}{
 		"cluster_id":  c.ClusterID.String(),
 		"identity_id": c.IdentityID.String(),
@@ -127,6 +271,14 @@ func (m *GormIdentityClusterRepository) Delete(ctx context.Context, identityID u
 		}
 		return errors.NewNotFoundErrorFromString(fmt.Sprintf(`nothing to delete: identity cluster not found (identity-id:'%s', cluster-url:'%s')`, identityID.String(), clusterURL))
 	}
+	c, err := NewClusterRepository(m.db).FindByURL(ctx, clusterURL)
+	if err != nil {
+		return err
+	}
+	err = recordAudit(ctx, m.db, AuditOperationIdentityUnlink, c.ClusterID, &identityID, nil)
+	if err != nil {
+		return err
+	}
 
 	log.Debug(ctx, map[string]interface{}{
 		"cluster_url": clusterURL,
@@ -135,3 +287,38 @@ func (m *GormIdentityClusterRepository) Delete(ctx context.Context, identityID u
 
 	return nil
 }
+
+// Move moves the identity/cluster relationship of the identity with the given ID from the source cluster to the target cluster.
+// Returns a NotFoundError if the identity is not linked to the source cluster.
+func (m *GormIdentityClusterRepository) Move(ctx context.Context, identityID, sourceClusterID, targetClusterID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_cluster", "move"}, time.Now())
+
+	result := m.db.Exec(`update identity_cluster set cluster_id = ?, updated_at = now() where identity_id = ? and cluster_id = ?`,
+		targetClusterID.String(), identityID.String(), sourceClusterID.String())
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id":       identityID.String(),
+			"source_cluster_id": sourceClusterID.String(),
+			"target_cluster_id": targetClusterID.String(),
+			"err":               result.Error,
+		}, "unable to move the identity cluster")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundErrorFromString(fmt.Sprintf("identity_cluster with identity ID %s and cluster ID %s not found", identityID, sourceClusterID))
+	}
+	// from the point of view of each cluster, the identity was unlinked from the source and linked to the target
+	if err := recordAudit(ctx, m.db, AuditOperationIdentityUnlink, sourceClusterID, &identityID, nil); err != nil {
+		return err
+	}
+	if err := recordAudit(ctx, m.db, AuditOperationIdentityLink, targetClusterID, &identityID, nil); err != nil {
+		return err
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id":       identityID.String(),
+		"source_cluster_id": sourceClusterID.String(),
+		"target_cluster_id": targetClusterID.String(),
+	}, "Identity cluster moved!")
+	return nil
+}
@@ -3,6 +3,7 @@ package repository_test
 import (
 	"context"
 	"fmt"
+	"sort"
 	"testing"
 
 	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
@@ -52,6 +53,190 @@ func (s *identityClusterTestSuite) TestListClustersForUnknownIdentityOK() {
 	assert.Len(s.T(), clusters, 0)
 }
 
+func (s *identityClusterTestSuite) TestListForIdentities() {
+	// given
+	idCluster1 := test.CreateIdentityCluster(s.T(), s.DB)
+	idCluster2 := test.CreateIdentityCluster(s.T(), s.DB, test.WithIdentityID(idCluster1.IdentityID))
+	idCluster3 := test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
+	test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster)) // noise
+
+	s.T().Run("many identities", func(t *testing.T) {
+		// when
+		identityClusters, err := s.repo.ListForIdentities(context.Background(), []uuid.UUID{idCluster1.IdentityID, idCluster3.IdentityID, uuid.NewV4()})
+		// then
+		require.NoError(t, err)
+		require.Len(t, identityClusters, 3)
+		for _, expected := range []repository.IdentityCluster{idCluster1, idCluster2, idCluster3} {
+			found := false
+			for _, actual := range identityClusters {
+				if actual.IdentityID == expected.IdentityID && actual.ClusterID == expected.ClusterID {
+					found = true
+					assert.Equal(t, expected.CreatedAt, actual.CreatedAt)
+					test.AssertEqualCluster(t, expected.Cluster, actual.Cluster, true)
+				}
+			}
+			assert.True(t, found, "missing link between identity %s and cluster %s", expected.IdentityID, expected.ClusterID)
+		}
+	})
+
+	s.T().Run("ordered by identity", func(t *testing.T) {
+		// when
+		identityClusters, err := s.repo.ListForIdentities(context.Background(), []uuid.UUID{idCluster1.IdentityID, idCluster3.IdentityID})
+		// then
+		require.NoError(t, err)
+		ids := identityIDs(identityClusters)
+		assert.True(t, sort.SliceIsSorted(ids, func(i, j int) bool {
+			return ids[i].String() < ids[j].String()
+		}))
+	})
+
+	s.T().Run("unknown identity", func(t *testing.T) {
+		// when
+		identityClusters, err := s.repo.ListForIdentities(context.Background(), []uuid.UUID{uuid.NewV4()})
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, identityClusters)
+	})
+
+	s.T().Run("no identity", func(t *testing.T) {
+		// when
+		identityClusters, err := s.repo.ListForIdentities(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, identityClusters)
+	})
+}
+
+func (s *identityClusterTestSuite) TestCountIdentitiesByCluster() {
+	// given
+	idCluster1 := test.CreateIdentityCluster(s.T(), s.DB)
+	test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
+	idCluster3 := test.CreateIdentityCluster(s.T(), s.DB, test.WithIdentityID(idCluster1.IdentityID))
+	emptyCluster := test.CreateCluster(s.T(), s.DB)
+	// when
+	counts, err := s.repo.CountIdentitiesByCluster(context.Background())
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, counts[idCluster1.ClusterID])
+	assert.Equal(s.T(), 1, counts[idCluster3.ClusterID])
+	assert.NotContains(s.T(), counts, emptyCluster.ClusterID)
+}
+
+func (s *identityClusterTestSuite) TestCountIdentities() {
+	// given
+	idCluster1 := test.CreateIdentityCluster(s.T(), s.DB)
+	test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
+	test.CreateIdentityCluster(s.T(), s.DB) // noise
+	emptyCluster := test.CreateCluster(s.T(), s.DB)
+	// when
+	count, err := s.repo.CountIdentities(context.Background(), idCluster1.ClusterID)
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, count)
+	// when
+	count, err = s.repo.CountIdentities(context.Background(), emptyCluster.ClusterID)
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 0, count)
+}
+
+func (s *identityClusterTestSuite) TestListIdentities() {
+	// given
+	idCluster1 := test.CreateIdentityCluster(s.T(), s.DB)
+	idCluster2 := test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
+	idCluster3 := test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
+	test.CreateIdentityCluster(s.T(), s.DB, test.WithIdentityID(idCluster1.IdentityID)) // noise
+	expected := sortedIdentityIDs(idCluster1, idCluster2, idCluster3)
+
+	s.T().Run("all", func(t *testing.T) {
+		// when
+		identityClusters, total, err := s.repo.ListIdentities(context.Background(), idCluster1.ClusterID, 0, 0)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, expected, identityIDs(identityClusters))
+		for _, identityCluster := range identityClusters {
+			assert.Equal(t, idCluster1.ClusterID, identityCluster.ClusterID)
+		}
+	})
+
+	s.T().Run("page", func(t *testing.T) {
+		// when
+		identityClusters, total, err := s.repo.ListIdentities(context.Background(), idCluster1.ClusterID, 1, 1)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, expected[1:2], identityIDs(identityClusters))
+	})
+
+	s.T().Run("empty cluster", func(t *testing.T) {
+		// given
+		emptyCluster := test.CreateCluster(t, s.DB)
+		// when
+		identityClusters, total, err := s.repo.ListIdentities(context.Background(), emptyCluster.ClusterID, 0, 0)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, total)
+		assert.Empty(t, identityClusters)
+	})
+}
+
+func (s *identityClusterTestSuite) TestStreamIdentities() {
+	// given
+	idCluster1 := test.CreateIdentityCluster(s.T(), s.DB)
+	idCluster2 := test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
+	idCluster3 := test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
+	test.CreateIdentityCluster(s.T(), s.DB, test.WithIdentityID(idCluster1.IdentityID)) // noise
+	expected := sortedIdentityIDs(idCluster1, idCluster2, idCluster3)
+
+	s.T().Run("all", func(t *testing.T) {
+		// given
+		var identityClusters []repository.IdentityCluster
+		// when
+		err := s.repo.StreamIdentities(context.Background(), idCluster1.ClusterID, func(identityCluster repository.IdentityCluster) error {
+			identityClusters = append(identityClusters, identityCluster)
+			return nil
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, expected, identityIDs(identityClusters))
+		for _, identityCluster := range identityClusters {
+			assert.Equal(t, idCluster1.ClusterID, identityCluster.ClusterID)
+		}
+	})
+
+	s.T().Run("stop at first error", func(t *testing.T) {
+		// given
+		count := 0
+		// when
+		err := s.repo.StreamIdentities(context.Background(), idCluster1.ClusterID, func(identityCluster repository.IdentityCluster) error {
+			count++
+			return fmt.Errorf("mock error")
+		})
+		// then
+		require.Error(t, err)
+		assert.Equal(t, "mock error", err.Error())
+		assert.Equal(t, 1, count)
+	})
+}
+
+// sortedIdentityIDs returns the IDs of the identities of the given identity/cluster relationships, in the order of the database
+func sortedIdentityIDs(identityClusters ...repository.IdentityCluster) []uuid.UUID {
+	ids := identityIDs(identityClusters)
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+	return ids
+}
+
+func identityIDs(identityClusters []repository.IdentityCluster) []uuid.UUID {
+	ids := make([]uuid.UUID, len(identityClusters))
+	for i, identityCluster := range identityClusters {
+		ids[i] = identityCluster.IdentityID
+	}
+	return ids
+}
+
 func assertContainsCluster(t *testing.T, clusters []repository.Cluster, cluster repository.Cluster) {
 	require.NotEqual(t, uuid.UUID{}, cluster.ClusterID)
 	for _, cls := range clusters {
@@ -98,6 +283,39 @@ func (s *identityClusterTestSuite) TestDeleteUnknownFails() {
 	test.AssertError(s.T(), err, errors.NotFoundError{}, fmt.Sprintf(`nothing to delete: identity cluster not found (cluster with URL '%s' not found)`, "http://foo"))
 }
 
+func (s *identityClusterTestSuite) TestMoveOK() {
+	idCluster1 := test.CreateIdentityCluster(s.T(), s.DB)
+	target := test.CreateCluster(s.T(), s.DB)
+
+	// Noise
+	idCluster2 := test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
+
+	err := s.repo.Move(context.Background(), idCluster1.IdentityID, idCluster1.ClusterID, target.ClusterID)
+	require.NoError(s.T(), err)
+
+	_, err = s.repo.Load(context.Background(), idCluster1.IdentityID, idCluster1.ClusterID)
+	test.AssertError(s.T(), err, errors.NotFoundError{}, "identity_cluster with identity ID %s and cluster ID %s not found", idCluster1.IdentityID, idCluster1.ClusterID)
+	loaded, err := s.repo.Load(context.Background(), idCluster1.IdentityID, target.ClusterID)
+	require.NoError(s.T(), err)
+	test.AssertEqualCluster(s.T(), target, loaded.Cluster, true)
+
+	// Noise is still here
+	loaded, err = s.repo.Load(context.Background(), idCluster2.IdentityID, idCluster2.ClusterID)
+	require.NoError(s.T(), err)
+	test.AssertEqualIdentityClusters(s.T(), idCluster2, *loaded)
+}
+
+func (s *identityClusterTestSuite) TestMoveUnknownFails() {
+	// given
+	id := uuid.NewV4()
+	source := test.CreateCluster(s.T(), s.DB)
+	target := test.CreateCluster(s.T(), s.DB)
+	// when
+	err := s.repo.Move(context.Background(), id, source.ClusterID, target.ClusterID)
+	// then
+	test.AssertError(s.T(), err, errors.NotFoundError{}, "identity_cluster with identity ID %s and cluster ID %s not found", id, source.ClusterID)
+}
+
 func (s *identityClusterTestSuite) TestOnDeleteCascade() {
 	idCluster1 := test.CreateIdentityCluster(s.T(), s.DB)
 	idCluster2 := test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(idCluster1.Cluster))
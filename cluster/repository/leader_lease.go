@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+)
+
+// LeaderLease a lease through which the replicas of the service elect a leader. The holder of the lease is the leader
+// until the lease expires, so it must renew the lease before then.
+type LeaderLease struct {
+	// The name of the lease. This is also the primary key value
+	Name string `gorm:"primary_key;column:name"`
+	// The identity of the replica which holds the lease
+	Holder string
+	// The time at which the current holder acquired the lease
+	AcquiredAt time.Time
+	// The time at which the current holder last renewed the lease
+	RenewedAt time.Time
+	// The time after which another replica can acquire the lease, unless it is renewed before
+	ExpiresAt time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (l LeaderLease) TableName() string {
+	return "leader_lease"
+}
+
+// Leadership the leadership state of the current replica of the service
+type Leadership struct {
+	// `false` if the leader election is disabled, in which case the replica acts as the leader
+	Enabled bool
+	// The identity of the current replica
+	Identity string
+	// `true` if the current replica is the leader
+	Leader bool
+	// The identity of the replica which holds the leader lease, if known
+	Holder string
+	// The time at which the leader lease expires, if known
+	ExpiresAt *time.Time
+}
+
+// LeaderLeaseRepository represents the storage interface.
+type LeaderLeaseRepository interface {
+	Load(ctx context.Context, name string) (*LeaderLease, error)
+	Acquire(ctx context.Context, name, holder string, duration time.Duration) (*LeaderLease, error)
+	Release(ctx context.Context, name, holder string) error
+	LockIfHeldBy(ctx context.Context, name, holder string) (bool, error)
+}
+
+// GormLeaderLeaseRepository is the implementation of the storage interface for LeaderLease.
+type GormLeaderLeaseRepository struct {
+	db *gorm.DB
+}
+
+// NewLeaderLeaseRepository creates a new storage type.
+func NewLeaderLeaseRepository(db *gorm.DB) LeaderLeaseRepository {
+	return &GormLeaderLeaseRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormLeaderLeaseRepository) TableName() string {
+	return "leader_lease"
+}
+
+// Load returns the lease with the given name
+func (m *GormLeaderLeaseRepository) Load(ctx context.Context, name string) (*LeaderLease, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "leader_lease", "load"}, time.Now())
+	var native LeaderLease
+	err := m.db.Table(m.TableName()).Where("name = ?", name).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("leader_lease", name)
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Acquire acquires the lease with the given name on behalf of the given holder for the given duration, if it does not
+// exist yet, if it has expired or if it is already held by the given holder (in which case it is renewed).
+// Returns the lease after the attempt, which may be held by another replica. The expiry is computed with the clock of
+// the database, so that it does not depend on the clocks of the replicas.
+func (m *GormLeaderLeaseRepository) Acquire(ctx context.Context, name, holder string, duration time.Duration) (*LeaderLease, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "leader_lease", "acquire"}, time.Now())
+	err := m.db.Exec(`INSERT INTO leader_lease (name, holder, acquired_at, renewed_at, expires_at)
+		VALUES (?, ?, now(), now(), now() + ? * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET
+			holder = EXCLUDED.holder,
+			acquired_at = CASE WHEN leader_lease.holder = EXCLUDED.holder THEN leader_lease.acquired_at ELSE now() END,
+			renewed_at = now(),
+			expires_at = EXCLUDED.expires_at
+		WHERE leader_lease.holder = EXCLUDED.holder OR leader_lease.expires_at < now()`,
+		name, holder, int64(duration/time.Millisecond)).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"lease":  name,
+			"holder": holder,
+			"err":    err,
+		}, "unable to acquire the leader lease")
+		return nil, errs.WithStack(err)
+	}
+	return m.Load(ctx, name)
+}
+
+// Release releases the lease with the given name if it is held by the given holder, so that another replica can
+// acquire it without waiting for its expiry
+func (m *GormLeaderLeaseRepository) Release(ctx context.Context, name, holder string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "leader_lease", "release"}, time.Now())
+	err := m.db.Table(m.TableName()).Where("name = ? AND holder = ?", name, holder).Delete(&LeaderLease{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"lease":  name,
+			"holder": holder,
+			"err":    err,
+		}, "unable to release the leader lease")
+		return errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"lease":  name,
+		"holder": holder,
+	}, "leader lease released")
+	return nil
+}
+
+// LockIfHeldBy locks the lease with the given name (`SELECT ... FOR SHARE`) until the end of the current transaction
+// if it is held by the given holder and has not expired, so that no other replica can acquire it in the mean time.
+// Returns `false` if the lease is not held by the given holder (or if it has expired).
+func (m *GormLeaderLeaseRepository) LockIfHeldBy(ctx context.Context, name, holder string) (bool, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "leader_lease", "lock_if_held_by"}, time.Now())
+	var native LeaderLease
+	err := m.db.Table(m.TableName()).Set("gorm:query_option", "FOR SHARE").
+		Where("name = ? AND holder = ? AND expires_at > now()", name, holder).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, errs.WithStack(err)
+	}
+	return true, nil
+}
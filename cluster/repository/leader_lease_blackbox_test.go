@@ -0,0 +1,168 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type leaderLeaseTestSuite struct {
+	gormtestsupport.DBTestSuite
+	repo repository.LeaderLeaseRepository
+}
+
+func TestLeaderLease(t *testing.T) {
+	suite.Run(t, &leaderLeaseTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *leaderLeaseTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = s.Application.LeaderLeases()
+}
+
+func (s *leaderLeaseTestSuite) TestAcquire() {
+
+	s.T().Run("new lease", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		// when
+		lease, err := s.repo.Acquire(context.Background(), name, "replica1", time.Minute)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, name, lease.Name)
+		assert.Equal(t, "replica1", lease.Holder)
+		assert.True(t, lease.ExpiresAt.After(lease.RenewedAt))
+	})
+
+	s.T().Run("renewed lease", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		acquired, err := s.repo.Acquire(context.Background(), name, "replica1", time.Minute)
+		require.NoError(t, err)
+		// when
+		renewed, err := s.repo.Acquire(context.Background(), name, "replica1", time.Minute)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "replica1", renewed.Holder)
+		assert.Equal(t, acquired.AcquiredAt.Unix(), renewed.AcquiredAt.Unix())
+		assert.False(t, renewed.ExpiresAt.Before(acquired.ExpiresAt))
+	})
+
+	s.T().Run("lease held by another replica", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		acquired, err := s.repo.Acquire(context.Background(), name, "replica1", time.Minute)
+		require.NoError(t, err)
+		// when
+		lease, err := s.repo.Acquire(context.Background(), name, "replica2", time.Minute)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "replica1", lease.Holder)
+		assert.Equal(t, acquired.ExpiresAt.Unix(), lease.ExpiresAt.Unix())
+	})
+
+	s.T().Run("expired lease", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		_, err := s.repo.Acquire(context.Background(), name, "replica1", time.Millisecond)
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		// when
+		lease, err := s.repo.Acquire(context.Background(), name, "replica2", time.Minute)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "replica2", lease.Holder)
+	})
+}
+
+func (s *leaderLeaseTestSuite) TestRelease() {
+
+	s.T().Run("by the holder", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		_, err := s.repo.Acquire(context.Background(), name, "replica1", time.Minute)
+		require.NoError(t, err)
+		// when
+		err = s.repo.Release(context.Background(), name, "replica1")
+		// then
+		require.NoError(t, err)
+		_, err = s.repo.Load(context.Background(), name)
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+		// and the lease can be acquired by another replica
+		lease, err := s.repo.Acquire(context.Background(), name, "replica2", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, "replica2", lease.Holder)
+	})
+
+	s.T().Run("by another replica", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		_, err := s.repo.Acquire(context.Background(), name, "replica1", time.Minute)
+		require.NoError(t, err)
+		// when
+		err = s.repo.Release(context.Background(), name, "replica2")
+		// then
+		require.NoError(t, err)
+		lease, err := s.repo.Load(context.Background(), name)
+		require.NoError(t, err)
+		assert.Equal(t, "replica1", lease.Holder)
+	})
+}
+
+func (s *leaderLeaseTestSuite) TestLockIfHeldBy() {
+
+	s.T().Run("by the holder", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		_, err := s.repo.Acquire(context.Background(), name, "replica1", time.Minute)
+		require.NoError(t, err)
+		// when
+		held, err := s.repo.LockIfHeldBy(context.Background(), name, "replica1")
+		// then
+		require.NoError(t, err)
+		assert.True(t, held)
+	})
+
+	s.T().Run("by another replica", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		_, err := s.repo.Acquire(context.Background(), name, "replica1", time.Minute)
+		require.NoError(t, err)
+		// when
+		held, err := s.repo.LockIfHeldBy(context.Background(), name, "replica2")
+		// then
+		require.NoError(t, err)
+		assert.False(t, held)
+	})
+
+	s.T().Run("expired lease", func(t *testing.T) {
+		// given
+		name := uuid.NewV4().String()
+		_, err := s.repo.Acquire(context.Background(), name, "replica1", time.Millisecond)
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		// when
+		held, err := s.repo.LockIfHeldBy(context.Background(), name, "replica1")
+		// then
+		require.NoError(t, err)
+		assert.False(t, held)
+	})
+
+	s.T().Run("unknown lease", func(t *testing.T) {
+		// when
+		held, err := s.repo.LockIfHeldBy(context.Background(), uuid.NewV4().String(), "replica1")
+		// then
+		require.NoError(t, err)
+		assert.False(t, held)
+	})
+}
@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// OutboxEvent an event of the outbox, which records a change on a cluster or on an identity/cluster relationship
+// (through its audit entry) in the same transaction as the change itself, until it is dispatched to the webhook
+// subscriptions. The IDs of the events are sequential, in the order of the changes.
+type OutboxEvent struct {
+	// This is the primary key value
+	ID int64 `gorm:"primary_key;column:event_id"`
+	// The ID of the transaction which recorded the event (set by the database)
+	TxID int64 `gorm:"column:txid"`
+	// The time of the change
+	CreatedAt time.Time
+	// The ID of the audit entry of the change
+	AuditID uuid.UUID `sql:"type:uuid"`
+	// The state of the cluster right after the change, without its secrets. Nil for the deletions of clusters and
+	// for the changes on the identity/cluster relationships
+	Cluster *ClusterSnapshot `gorm:"column:cluster"`
+	// The time at which the event was dispatched to the webhook subscriptions, if it was
+	DispatchedAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (e OutboxEvent) TableName() string {
+	return "outbox_event"
+}
+
+// Position returns the position of the event in the outbox
+func (e OutboxEvent) Position() OutboxPosition {
+	return OutboxPosition{TxID: e.TxID, EventID: e.ID}
+}
+
+// OutboxPosition the position of an event in the outbox. The events are ordered by the ID of the transaction which
+// recorded them, then by ID. Unlike the order of the IDs alone, this order is stable once the transactions are
+// settled (i.e, once all transactions up to the current one are finished): no event can then be recorded before
+// the settled ones, even by a transaction which started earlier and committed later.
+type OutboxPosition struct {
+	TxID    int64
+	EventID int64
+}
+
+// String returns the position in the `<txid>.<event_id>` format
+func (p OutboxPosition) String() string {
+	return fmt.Sprintf("%d.%d", p.TxID, p.EventID)
+}
+
+// Before returns `true` if the position precedes the other one
+func (p OutboxPosition) Before(other OutboxPosition) bool {
+	return p.TxID < other.TxID || (p.TxID == other.TxID && p.EventID < other.EventID)
+}
+
+// ParseOutboxPosition parses a position in the `<txid>.<event_id>` format
+func ParseOutboxPosition(s string) (OutboxPosition, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return OutboxPosition{}, errs.Errorf("invalid outbox position: '%s'", s)
+	}
+	txID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || txID < 0 {
+		return OutboxPosition{}, errs.Errorf("invalid outbox position: '%s'", s)
+	}
+	eventID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || eventID < 0 {
+		return OutboxPosition{}, errs.Errorf("invalid outbox position: '%s'", s)
+	}
+	return OutboxPosition{TxID: txID, EventID: eventID}, nil
+}
+
+// OutboxPositionExpiredError the error returned when listing the changes after a position which precedes the newest
+// event purged from the outbox, i.e, when some of the changes after this position are no longer available
+type OutboxPositionExpiredError struct {
+	Position  OutboxPosition
+	Watermark OutboxPosition
+}
+
+// Error implements the error interface
+func (e OutboxPositionExpiredError) Error() string {
+	return fmt.Sprintf("the changes after position '%s' are no longer available (oldest available position: '%s')", e.Position, e.Watermark)
+}
+
+// OutboxChange a settled outbox event, along with the change recorded in its audit entry
+type OutboxChange struct {
+	TxID      int64 `gorm:"column:txid"`
+	EventID   int64
+	ClusterID uuid.UUID
+	Operation string
+	Changes   AuditChanges
+	Cluster   *ClusterSnapshot
+}
+
+// Position returns the position of the change in the outbox
+func (c OutboxChange) Position() OutboxPosition {
+	return OutboxPosition{TxID: c.TxID, EventID: c.EventID}
+}
+
+const (
+	// ClusterWatchEventAdded the type of the watch events of the clusters which appeared in the watched collection
+	ClusterWatchEventAdded = "ADDED"
+	// ClusterWatchEventModified the type of the watch events of the clusters which were updated
+	ClusterWatchEventModified = "MODIFIED"
+	// ClusterWatchEventDeleted the type of the watch events of the clusters which left the watched collection
+	// (ie, which were decommissioned or deleted)
+	ClusterWatchEventDeleted = "DELETED"
+	// ClusterWatchEventBookmark the type of the watch events which only carry the position up to which the watch
+	// is up-to-date, when it caught up with the changes
+	ClusterWatchEventBookmark = "BOOKMARK"
+)
+
+// ClusterWatchEvent an event sent to the watchers of the clusters
+type ClusterWatchEvent struct {
+	// The type of event: `ADDED`, `MODIFIED`, `DELETED` or `BOOKMARK`
+	Type string
+	// The position of the change in the outbox, from which the watch can be resumed
+	Position OutboxPosition
+	// The ID of the cluster (except for the bookmarks)
+	ClusterID uuid.UUID
+	// The state of the cluster right after the change, or nil if the cluster was deleted (or for the bookmarks)
+	Cluster *Cluster
+}
+
+// ClusterSnapshot the state of a cluster right after a change recorded in the outbox, without the values of the
+// fields tagged with `audit:"secret"`
+type ClusterSnapshot Cluster
+
+// newClusterSnapshot returns a snapshot of the given cluster, without its secrets
+func newClusterSnapshot(c Cluster) *ClusterSnapshot {
+	v := reflect.ValueOf(&c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if _, secret := auditOptions(t.Field(i)); secret {
+			v.Field(i).Set(reflect.Zero(t.Field(i).Type))
+		}
+	}
+	snapshot := ClusterSnapshot(c)
+	return &snapshot
+}
+
+// Value implements the driver.Valuer interface
+func (c *ClusterSnapshot) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface
+func (c *ClusterSnapshot) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, c)
+	case string:
+		return json.Unmarshal([]byte(src), c)
+	default:
+		return fmt.Errorf("unable to scan the cluster snapshot from a value of type %T", src)
+	}
+}
+
+// recordOutboxEvent records an event for the given audit entry in the outbox, using the same DB (and hence, the same
+// transaction) as the change itself. The events of the creations and updates of clusters carry the state of the
+// cluster right after the change, which must have been applied already.
+func recordOutboxEvent(ctx context.Context, db *gorm.DB, e *AuditEntry) error {
+	event := &OutboxEvent{
+		AuditID: e.ID,
+	}
+	switch e.Operation {
+	case AuditOperationClusterCreate, AuditOperationClusterUpdate:
+		c, err := NewClusterRepository(db).Load(ctx, e.ClusterID)
+		if err != nil {
+			return err
+		}
+		event.Cluster = newClusterSnapshot(*c)
+	}
+	return NewOutboxRepository(db).Create(ctx, event)
+}
+
+// OutboxRepository represents the storage interface.
+type OutboxRepository interface {
+	Create(ctx context.Context, e *OutboxEvent) error
+	Load(ctx context.Context, id int64) (*OutboxEvent, error)
+	ListSettled(ctx context.Context, after OutboxPosition, limit int) ([]OutboxChange, error)
+	LastSettledPosition(ctx context.Context) (OutboxPosition, error)
+	Watermark(ctx context.Context) (OutboxPosition, error)
+	Purge(ctx context.Context, before time.Time) error
+}
+
+// GormOutboxRepository is the implementation of the storage interface for OutboxEvent.
+type GormOutboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository creates a new storage type.
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &GormOutboxRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormOutboxRepository) TableName() string {
+	return "outbox_event"
+}
+
+// Create creates a new record.
+func (m *GormOutboxRepository) Create(ctx context.Context, e *OutboxEvent) error {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "create"}, time.Now())
+	err := m.db.Create(e).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"audit_id": e.AuditID.String(),
+			"err":      err,
+		}, "unable to create the outbox event")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// Load returns the outbox event with the given ID
+func (m *GormOutboxRepository) Load(ctx context.Context, id int64) (*OutboxEvent, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "load"}, time.Now())
+	var native OutboxEvent
+	err := m.db.Table(m.TableName()).Where("event_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("outbox_event", fmt.Sprintf("%d", id))
+	}
+	return &native, errs.WithStack(err)
+}
+
+// OutboxEventSettled the condition on the outbox events whose transaction is settled, i.e, whose transaction and all
+// the transactions which started before it are finished. No event can be recorded before the settled ones anymore.
+const OutboxEventSettled = "txid < txid_snapshot_xmin(txid_current_snapshot())"
+
+// ListSettled returns the settled changes after the given position, in the order of their position, up to the given
+// limit. Returns an OutboxPositionExpiredError if the given position precedes the newest event purged from the outbox.
+func (m *GormOutboxRepository) ListSettled(ctx context.Context, after OutboxPosition, limit int) ([]OutboxChange, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "listSettled"}, time.Now())
+	watermark, err := m.Watermark(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if after.Before(watermark) {
+		return nil, OutboxPositionExpiredError{Position: after, Watermark: watermark}
+	}
+	var changes []OutboxChange
+	err = m.db.Raw(`SELECT e.txid, e.event_id, a.cluster_id, a.operation, a.changes, e.cluster
+		FROM outbox_event e JOIN audit_log a ON a.audit_id = e.audit_id
+		WHERE (e.txid, e.event_id) > (?, ?) AND e.`+OutboxEventSettled+`
+		ORDER BY e.txid, e.event_id LIMIT ?`, after.TxID, after.EventID, limit).Scan(&changes).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"after": after.String(),
+			"err":   err,
+		}, "unable to list the settled outbox events")
+		return nil, errs.WithStack(err)
+	}
+	return changes, nil
+}
+
+// LastSettledPosition returns the position of the newest settled event, or the watermark if all the settled
+// events were purged. All the events recorded after the call are positioned after this position.
+func (m *GormOutboxRepository) LastSettledPosition(ctx context.Context) (OutboxPosition, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "lastSettledPosition"}, time.Now())
+	var p OutboxPosition
+	err := m.db.Raw(`SELECT txid, event_id FROM (
+			SELECT txid, event_id FROM outbox_event WHERE `+OutboxEventSettled+`
+			UNION ALL SELECT txid, event_id FROM outbox_watermark
+		) p ORDER BY txid DESC, event_id DESC LIMIT 1`).Row().Scan(&p.TxID, &p.EventID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to retrieve the position of the last settled outbox event")
+		return OutboxPosition{}, errs.WithStack(err)
+	}
+	return p, nil
+}
+
+// Watermark returns the position of the newest event purged from the outbox
+func (m *GormOutboxRepository) Watermark(ctx context.Context) (OutboxPosition, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "watermark"}, time.Now())
+	var p OutboxPosition
+	err := m.db.Raw("SELECT txid, event_id FROM outbox_watermark").Row().Scan(&p.TxID, &p.EventID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to retrieve the outbox watermark")
+		return OutboxPosition{}, errs.WithStack(err)
+	}
+	return p, nil
+}
+
+// Purge removes the events which were dispatched before the given time and which have no remaining webhook delivery,
+// and moves the watermark to the newest purged event
+func (m *GormOutboxRepository) Purge(ctx context.Context, before time.Time) error {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "purge"}, time.Now())
+	err := m.db.Exec(`WITH purged AS (
+			DELETE FROM outbox_event e WHERE e.dispatched_at < ?
+			AND NOT EXISTS (SELECT 1 FROM webhook_delivery d WHERE d.event_id = e.event_id)
+			RETURNING e.txid, e.event_id
+		)
+		UPDATE outbox_watermark w SET txid = p.txid, event_id = p.event_id
+		FROM (SELECT txid, event_id FROM purged ORDER BY txid DESC, event_id DESC LIMIT 1) p
+		WHERE (p.txid, p.event_id) > (w.txid, w.event_id)`, before).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"before": before.String(),
+			"err":    err,
+		}, "unable to purge the outbox events")
+		return errs.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	"github.com/fabric8-services/fabric8-cluster/test"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type outboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+	repo repository.OutboxRepository
+}
+
+func TestOutbox(t *testing.T) {
+	suite.Run(t, &outboxTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *outboxTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = s.Application.Outbox()
+}
+
+func (s *outboxTestSuite) TestRecordedWithAuditEntries() {
+
+	s.T().Run("committed mutations", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		// when
+		entries, err := s.Application.Audit().ListForCluster(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		events := s.listEvents(t, c.ClusterID)
+		// then
+		require.Len(t, events, 2)
+		assert.Equal(t, entries[0].ID, events[0].AuditID)
+		assert.Equal(t, entries[1].ID, events[1].AuditID)
+		assert.True(t, events[0].ID < events[1].ID)
+		assert.Nil(t, events[0].DispatchedAt)
+	})
+
+	s.T().Run("rolled back mutation", func(t *testing.T) {
+		// given
+		c := test.NewCluster()
+		tx := s.DB.Begin()
+		err := repository.NewClusterRepository(tx).Create(context.Background(), &c)
+		require.NoError(t, err)
+		// when
+		tx.Rollback()
+		// then
+		assert.Empty(t, s.listEvents(t, c.ClusterID))
+	})
+}
+
+func (s *outboxTestSuite) TestLoad() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		events := s.listEvents(t, c.ClusterID)
+		require.Len(t, events, 1)
+		// when
+		e, err := s.repo.Load(context.Background(), events[0].ID)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, events[0].AuditID, e.AuditID)
+	})
+
+	s.T().Run("unknown", func(t *testing.T) {
+		// when
+		_, err := s.repo.Load(context.Background(), -1)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *outboxTestSuite) TestPositions() {
+
+	s.T().Run("parse", func(t *testing.T) {
+		// when
+		p, err := repository.ParseOutboxPosition("1234.56")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, repository.OutboxPosition{TxID: 1234, EventID: 56}, p)
+		assert.Equal(t, "1234.56", p.String())
+	})
+
+	s.T().Run("parse invalid", func(t *testing.T) {
+		for _, value := range []string{"", "1234", "1234.", ".56", "1234.56.7", "a.b", "-1.56"} {
+			t.Run(value, func(t *testing.T) {
+				// when
+				_, err := repository.ParseOutboxPosition(value)
+				// then
+				require.Error(t, err)
+			})
+		}
+	})
+
+	s.T().Run("order", func(t *testing.T) {
+		assert.True(t, repository.OutboxPosition{TxID: 1, EventID: 9}.Before(repository.OutboxPosition{TxID: 2, EventID: 1}))
+		assert.True(t, repository.OutboxPosition{TxID: 2, EventID: 1}.Before(repository.OutboxPosition{TxID: 2, EventID: 2}))
+		assert.False(t, repository.OutboxPosition{TxID: 2, EventID: 2}.Before(repository.OutboxPosition{TxID: 2, EventID: 2}))
+		assert.False(t, repository.OutboxPosition{TxID: 3, EventID: 1}.Before(repository.OutboxPosition{TxID: 2, EventID: 2}))
+	})
+}
+
+func (s *outboxTestSuite) TestListSettled() {
+	// given
+	before, err := s.repo.LastSettledPosition(context.Background())
+	require.NoError(s.T(), err)
+	c := test.CreateCluster(s.T(), s.DB)
+	test.CreateIdentityCluster(s.T(), s.DB, test.WithCluster(c))
+
+	s.T().Run("after a position", func(t *testing.T) {
+		// when
+		changes, err := s.repo.ListSettled(context.Background(), before, 100)
+		// then
+		require.NoError(t, err)
+		require.NotEmpty(t, changes)
+		var operations []string
+		for i, change := range changes {
+			assert.True(t, before.Before(change.Position()))
+			if i > 0 {
+				assert.True(t, changes[i-1].Position().Before(change.Position()))
+			}
+			if change.ClusterID == c.ClusterID {
+				operations = append(operations, change.Operation)
+			}
+		}
+		assert.Equal(t, []string{repository.AuditOperationClusterCreate, repository.AuditOperationIdentityLink}, operations)
+		last, err := s.repo.LastSettledPosition(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, changes[len(changes)-1].Position(), last)
+	})
+
+	s.T().Run("with a limit", func(t *testing.T) {
+		// when
+		changes, err := s.repo.ListSettled(context.Background(), before, 1)
+		// then
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, c.ClusterID, changes[0].ClusterID)
+		assert.Equal(t, repository.ClusterStateActive, changes[0].Changes["state"].New)
+	})
+
+	s.T().Run("cluster state", func(t *testing.T) {
+		// when
+		changes, err := s.repo.ListSettled(context.Background(), before, 100)
+		// then
+		require.NoError(t, err)
+		var snapshots []*repository.ClusterSnapshot
+		for _, change := range changes {
+			if change.ClusterID == c.ClusterID {
+				snapshots = append(snapshots, change.Cluster)
+			}
+		}
+		require.Len(t, snapshots, 2)
+		// the creation carries the state of the cluster, without its secrets
+		require.NotNil(t, snapshots[0])
+		assert.Equal(t, c.Name, snapshots[0].Name)
+		assert.Equal(t, c.URL, snapshots[0].URL)
+		assert.Equal(t, c.SAUsername, snapshots[0].SAUsername)
+		assert.Equal(t, repository.ClusterStateActive, snapshots[0].State)
+		assert.NotEmpty(t, c.SAToken)
+		assert.Empty(t, snapshots[0].SAToken)
+		assert.NotEmpty(t, c.AuthClientSecret)
+		assert.Empty(t, snapshots[0].AuthClientSecret)
+		// but not the identity/cluster relationship change
+		assert.Nil(t, snapshots[1])
+	})
+
+	s.T().Run("state of each update", func(t *testing.T) {
+		// given
+		updated := test.CreateCluster(t, s.DB)
+		after, err := s.repo.LastSettledPosition(context.Background())
+		require.NoError(t, err)
+		err = s.Application.Clusters().UpdateState(context.Background(), updated.ClusterID, repository.ClusterStateDraining)
+		require.NoError(t, err)
+		err = s.Application.Clusters().UpdateCapacityExhausted(context.Background(), updated.ClusterID, true)
+		require.NoError(t, err)
+		// when
+		changes, err := s.repo.ListSettled(context.Background(), after, 100)
+		// then
+		require.NoError(t, err)
+		var snapshots []*repository.ClusterSnapshot
+		for _, change := range changes {
+			if change.ClusterID == updated.ClusterID {
+				snapshots = append(snapshots, change.Cluster)
+			}
+		}
+		require.Len(t, snapshots, 2)
+		require.NotNil(t, snapshots[0])
+		assert.Equal(t, repository.ClusterStateDraining, snapshots[0].State)
+		assert.False(t, snapshots[0].CapacityExhausted)
+		require.NotNil(t, snapshots[1])
+		assert.Equal(t, repository.ClusterStateDraining, snapshots[1].State)
+		assert.True(t, snapshots[1].CapacityExhausted)
+	})
+
+	s.T().Run("pending transaction", func(t *testing.T) {
+		// given a change which is not committed yet
+		tx := s.DB.Begin()
+		defer tx.Rollback()
+		pending := test.NewCluster()
+		err := repository.NewClusterRepository(tx).Create(context.Background(), &pending)
+		require.NoError(t, err)
+		// when a change is committed after it
+		committed := test.CreateCluster(t, s.DB)
+		// then neither change is settled yet
+		changes, err := s.repo.ListSettled(context.Background(), before, 100)
+		require.NoError(t, err)
+		for _, change := range changes {
+			assert.NotEqual(t, pending.ClusterID, change.ClusterID)
+			assert.NotEqual(t, committed.ClusterID, change.ClusterID)
+		}
+	})
+}
+
+func (s *outboxTestSuite) TestPurge() {
+	// given
+	dispatched := test.CreateCluster(s.T(), s.DB)
+	recent := test.CreateCluster(s.T(), s.DB)
+	err := s.DB.Exec(`UPDATE outbox_event SET dispatched_at = now() - interval '2 hours'
+		WHERE audit_id IN (SELECT audit_id FROM audit_log WHERE cluster_id = ?)`, dispatched.ClusterID).Error
+	require.NoError(s.T(), err)
+	purgedEvents := s.listEvents(s.T(), dispatched.ClusterID)
+	require.Len(s.T(), purgedEvents, 1)
+	// when
+	err = s.repo.Purge(context.Background(), time.Now().Add(-1*time.Hour))
+	// then
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), s.listEvents(s.T(), dispatched.ClusterID))
+	assert.Len(s.T(), s.listEvents(s.T(), recent.ClusterID), 1)
+	watermark, err := s.repo.Watermark(context.Background())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), purgedEvents[0].Position(), watermark)
+
+	s.T().Run("expired position", func(t *testing.T) {
+		// when
+		_, err := s.repo.ListSettled(context.Background(), repository.OutboxPosition{}, 100)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, repository.OutboxPositionExpiredError{}, err)
+	})
+
+	s.T().Run("position at the watermark", func(t *testing.T) {
+		// when
+		_, err := s.repo.ListSettled(context.Background(), watermark, 100)
+		// then
+		require.NoError(t, err)
+	})
+}
+
+// listEvents returns the outbox events of the mutations on the given cluster, in the order of the mutations
+func (s *outboxTestSuite) listEvents(t *testing.T, clusterID uuid.UUID) []repository.OutboxEvent {
+	var events []repository.OutboxEvent
+	err := s.DB.Table("outbox_event").
+		Joins("JOIN audit_log ON audit_log.audit_id = outbox_event.audit_id").
+		Where("audit_log.cluster_id = ?", clusterID).
+		Order("outbox_event.event_id").
+		Select("outbox_event.*").
+		Find(&events).Error
+	require.NoError(t, err)
+	return events
+}
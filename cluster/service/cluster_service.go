@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fabric8-services/fabric8-common/auth"
@@ -19,40 +21,214 @@ import (
 	"github.com/fabric8-services/fabric8-common/errors"
 	"github.com/fabric8-services/fabric8-common/log"
 
-	"github.com/fsnotify/fsnotify"
 	errs "github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
 )
 
 type clusterService struct {
 	base.BaseService
-	loader ConfigLoader
+	config    Configuration
+	encryptor Encryptor
 }
 
 // ConfigLoader to interface for the config watcher/loader
 type ConfigLoader interface {
-	ReloadClusterConfig() error
+	ReadClusterConfig() (map[string]repository.Cluster, error)
+	LoadClusterConfig(clusters map[string]repository.Cluster)
 	GetClusterConfigurationFilePath() string
+	GetClusterConfigSecretFiles() []string
 	GetClusters() map[string]repository.Cluster
 }
 
-// NewClusterService creates a new cluster service with the default implementation
-func NewClusterService(context servicectx.ServiceContext, loader ConfigLoader) service.ClusterService {
+// Configuration the interface for the configuration used by the cluster service
+type Configuration interface {
+	ConfigLoader
+	GetClusterPlacementStrategy() string
+	GetClusterPlacementWeights() map[string]int
+	GetClusterCapacityThreshold() int
+	GetClusterHealthProbeInterval() time.Duration
+	GetClusterHealthProbeTimeout() time.Duration
+	GetClusterHealthProbeConcurrency() int
+	GetClusterEncryptionKeys() map[string][]byte
+	GetClusterEncryptionKeyID() string
+	GetClusterSATokenRotationGracePeriod() time.Duration
+	GetClusterSATokenPromotionInterval() time.Duration
+	GetClusterConfigSyncPolicy() string
+	GetClusterConfigSyncMaxDecommissions() int
+	GetClusterConfigSyncMaxIdentityLinksLosses() int
+	GetClusterConfigWatcherDebounce() time.Duration
+	GetLeaderElectionLeaseDuration() time.Duration
+	GetLeaderElectionRenewInterval() time.Duration
+	GetPostgresConfigString() string
+	GetClusterWatchPollInterval() time.Duration
+	GetClusterWatchTimeout() time.Duration
+}
+
+// NewClusterService creates a new cluster service with the default implementation.
+// If no encryptor is given, the secrets of the clusters are encrypted with the keys from the configuration.
+func NewClusterService(context servicectx.ServiceContext, config Configuration, encryptor Encryptor) service.ClusterService {
 	return &clusterService{
 		BaseService: base.NewBaseService(context),
-		loader:      loader,
+		config:      config,
+		encryptor:   encryptor,
 	}
 }
 
-// CreateOrSaveClusterFromConfig creates clusters or save updated cluster info from config
-func (s clusterService) CreateOrSaveClusterFromConfig(ctx context.Context) error {
-	log.Warn(ctx, map[string]interface{}{}, "creating/updating clusters from config file")
-	toDelete, err := s.Repositories().Clusters().List(ctx, nil)
+// lastClusterConfigSync the result of the last synchronization of the clusters with the config file,
+// shared by all service instances of the current process
+var lastClusterConfigSync = struct {
+	sync.RWMutex
+	result *repository.ClusterConfigSyncResult
+}{}
+
+// LastClusterConfigSync returns the result of the last synchronization of the clusters with the config file,
+// or `nil` if no synchronization occurred yet
+func (s clusterService) LastClusterConfigSync() *repository.ClusterConfigSyncResult {
+	lastClusterConfigSync.RLock()
+	defer lastClusterConfigSync.RUnlock()
+	return lastClusterConfigSync.result
+}
+
+func recordClusterConfigSync(result *repository.ClusterConfigSyncResult) {
+	lastClusterConfigSync.Lock()
+	defer lastClusterConfigSync.Unlock()
+	lastClusterConfigSync.result = result
+}
+
+// CreateOrSaveClusterFromConfig creates clusters or save updated cluster info from config.
+// The changes are computed as a plan which is applied in a single transaction: either all clusters are created,
+// updated and decommissioned, or none of them is. If the leader election is enabled, the changes are only applied
+// if the current replica holds the leader lease.
+func (s clusterService) CreateOrSaveClusterFromConfig(ctx context.Context) (*repository.ClusterConfigSyncResult, error) {
+	return s.createOrSaveClustersFromConfig(ctx, s.config.GetClusters())
+}
+
+// createOrSaveClustersFromConfig creates clusters or save updated cluster info from the given config clusters
+func (s clusterService) createOrSaveClustersFromConfig(ctx context.Context, configClusters map[string]repository.Cluster) (*repository.ClusterConfigSyncResult, error) {
+	policy := s.config.GetClusterConfigSyncPolicy()
+	result := &repository.ClusterConfigSyncResult{
+		Policy: policy,
+		Time:   time.Now(),
+	}
+	if err := checkClusterConfigSyncPolicy(policy); err != nil {
+		return nil, err
+	}
+	if policy == configuration.ClusterConfigSyncPolicyDisabled {
+		log.Warn(ctx, map[string]interface{}{}, "synchronization of the clusters with the config file is disabled")
+		recordClusterConfigSync(result)
+		return result, nil
+	}
+	log.Warn(ctx, map[string]interface{}{
+		"policy": policy,
+	}, "creating/updating clusters from config file")
+	err := s.ExecuteInTransaction(func() error {
+		// make sure that another replica did not take over in the mean time, and that it cannot until the changes are committed
+		if err := s.checkLeaderLease(ctx); err != nil {
+			return err
+		}
+		planned, err := s.planClusterConfigSync(ctx, configClusters, policy)
+		if err != nil {
+			return err
+		}
+		result.Plan = planned.plan
+		log.Info(ctx, map[string]interface{}{
+			"policy":        policy,
+			"creates":       len(result.Plan.Creates),
+			"updates":       len(result.Plan.Updates),
+			"decommissions": len(result.Plan.Decommissions),
+		}, "applying the cluster config sync plan")
+		// the clusters to create come first, so their IDs can be reported in the plan once they are saved
+		for i, rc := range planned.toSave {
+			if err := s.Repositories().Clusters().CreateOrSave(ctx, rc); err != nil {
+				return errs.Wrapf(err, "unable to save the cluster with url '%s'", rc.URL)
+			}
+			if err := s.refreshCapacity(ctx, rc.ClusterID); err != nil {
+				return err
+			}
+			if i < len(result.Plan.Creates) {
+				result.Plan.Creates[i].ClusterID = rc.ClusterID
+			}
+		}
+		for _, c := range result.Plan.Decommissions {
+			if err := s.Repositories().Clusters().UpdateState(ctx, c.ClusterID, repository.ClusterStateDecommissioned); err != nil {
+				return errs.Wrapf(err, "unable to decommission the cluster with url '%s'", c.URL)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		result.Error = err
+		log.Error(ctx, map[string]interface{}{
+			"err":    err,
+			"policy": policy,
+		}, "creating/updating clusters from config file failed: none of the changes were applied")
+		recordClusterConfigSync(result)
+		return result, err
+	}
+	result.Applied = true
+	recordClusterConfigSync(result)
+	log.Info(ctx, map[string]interface{}{}, "creating/updating clusters from config file has been completed/done")
+	return result, nil
+}
+
+// ValidateClusterConfig reads the given cluster config file, validates all its clusters with the same rules as
+// the clusters registered through the API, and returns the plan which would be applied with the current sync policy,
+// without applying it. Returns an error if any cluster is invalid, or if the plan would decommission too many
+// clusters or lose too many identity links.
+func (s clusterService) ValidateClusterConfig(ctx context.Context, clusterConfigFile string) (*repository.ClusterConfigSyncPlan, error) {
+	policy := s.config.GetClusterConfigSyncPolicy()
+	if err := checkClusterConfigSyncPolicy(policy); err != nil {
+		return nil, err
+	}
+	configClusters, err := configuration.ReadClusterConfigFile(clusterConfigFile)
+	if err != nil {
+		return nil, errs.Wrapf(err, "invalid cluster config file '%s'", clusterConfigFile)
+	}
+	planned, err := s.planClusterConfigSync(ctx, configClusters, policy)
+	if err != nil {
+		return nil, errs.Wrapf(err, "invalid cluster config file '%s'", clusterConfigFile)
+	}
+	return &planned.plan, nil
+}
+
+// checkClusterConfigSyncPolicy returns an error if the given policy is unknown
+func checkClusterConfigSyncPolicy(policy string) error {
+	switch policy {
+	case configuration.ClusterConfigSyncPolicyAuthoritative, configuration.ClusterConfigSyncPolicyMergeOnly, configuration.ClusterConfigSyncPolicyDisabled:
+		return nil
+	default:
+		return errs.Errorf("invalid cluster config sync policy: '%s' (expected '%s', '%s' or '%s')", policy,
+			configuration.ClusterConfigSyncPolicyAuthoritative, configuration.ClusterConfigSyncPolicyMergeOnly, configuration.ClusterConfigSyncPolicyDisabled)
+	}
+}
+
+// clusterConfigSync the plan to synchronize the clusters with the config file, along with the clusters to save
+type clusterConfigSync struct {
+	plan repository.ClusterConfigSyncPlan
+	// the clusters to create, followed by the clusters to update (in the same order as in the plan)
+	toSave []*repository.Cluster
+}
+
+// planClusterConfigSync computes the clusters to create or update from the given config clusters, and the existing
+// clusters to decommission. Only the clusters which originate from the config file are decommissioned, and only with
+// the `authoritative` policy. Clusters registered through the API are left untouched. The decommissioned clusters which
+// are declared in the config file without state keep their state.
+// Returns an error if a config cluster is invalid, or if the plan exceeds the configured thresholds.
+func (s clusterService) planClusterConfigSync(ctx context.Context, configClusters map[string]repository.Cluster, policy string) (*clusterConfigSync, error) {
+	remaining, err := s.Repositories().Clusters().List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// process the clusters of the config file in a consistent order
+	urls := make([]string, 0, len(configClusters))
+	for clusterURL := range configClusters {
+		urls = append(urls, clusterURL)
 	}
-	for _, configCluster := range s.loader.GetClusters() {
-		var err error
+	sort.Strings(urls)
+	var toCreate, toUpdate []*repository.Cluster
+	var creates, updates []repository.ClusterConfigSyncChange
+	for _, clusterURL := range urls {
+		configCluster := configClusters[clusterURL]
 		rc := &repository.Cluster{
 			Name:              configCluster.Name,
 			URL:               configCluster.URL,
@@ -61,6 +237,9 @@ func (s clusterService) CreateOrSaveClusterFromConfig(ctx context.Context) error
 			LoggingURL:        configCluster.LoggingURL,
 			AppDNS:            configCluster.AppDNS,
 			CapacityExhausted: configCluster.CapacityExhausted,
+			MaxIdentities:     configCluster.MaxIdentities,
+			CapacityThreshold: configCluster.CapacityThreshold,
+			State:             configCluster.State,
 			Type:              configCluster.Type,
 			SAToken:           configCluster.SAToken,
 			SAUsername:        configCluster.SAUsername,
@@ -69,45 +248,111 @@ func (s clusterService) CreateOrSaveClusterFromConfig(ctx context.Context) error
 			AuthClientID:      configCluster.AuthClientID,
 			AuthClientSecret:  configCluster.AuthClientSecret,
 			AuthDefaultScope:  configCluster.AuthDefaultScope,
+			// a cluster registered through the API is owned by the config file once it is declared in it
+			Origin: repository.ClusterOriginConfig,
 		}
-		for i, c := range toDelete {
+		if err := s.validate(ctx, rc); err != nil {
+			return nil, errs.Wrapf(err, "invalid cluster with url '%s'", rc.URL)
+		}
+		var existing *repository.Cluster
+		for i, c := range remaining {
 			if httpsupport.AddTrailingSlashToURL(c.URL) == httpsupport.AddTrailingSlashToURL(rc.URL) {
-				// Don't delete the cluster found in the config
-				toDelete = append(toDelete[:i], toDelete[i+1:]...)
+				existing = &c
+				state := rc.State
+				if state == "" && existing.State == repository.ClusterStateDecommissioned {
+					// a cluster decommissioned through the API remains decommissioned until its state is set in the
+					// config file, without blocking the synchronization of the other clusters
+					log.Warn(ctx, map[string]interface{}{
+						"cluster_id":  existing.ClusterID.String(),
+						"cluster_url": existing.URL,
+					}, "keeping the decommissioned state of the cluster declared in the config file")
+					state = existing.State
+				}
+				state, err := initialState(state, existing)
+				if err != nil {
+					return nil, errs.Wrapf(err, "invalid cluster with url '%s'", rc.URL)
+				}
+				rc.State = state
+				// Don't decommission the cluster found in the config
+				remaining = append(remaining[:i], remaining[i+1:]...)
 				break
 			}
 		}
-		err = s.ExecuteInTransaction(func() error {
-			return s.Repositories().Clusters().CreateOrSave(ctx, rc)
+		if err := s.applySATokenRotation(rc, existing); err != nil {
+			return nil, err
+		}
+		if err := s.encryptSecrets(rc, existing); err != nil {
+			return nil, err
+		}
+		if err := rc.Normalize(); err != nil {
+			return nil, errs.Wrapf(err, "invalid cluster with url '%s'", rc.URL)
+		}
+		if existing == nil {
+			toCreate = append(toCreate, rc)
+			creates = append(creates, repository.ClusterConfigSyncChange{
+				URL:  rc.URL,
+				Name: rc.Name,
+			})
+			continue
+		}
+		toUpdate = append(toUpdate, rc)
+		updates = append(updates, repository.ClusterConfigSyncChange{
+			ClusterID: existing.ClusterID,
+			URL:       rc.URL,
+			Name:      rc.Name,
+			Changes:   repository.DiffClusters(existing, rc),
 		})
+	}
+	var decommissions []repository.ClusterConfigSyncChange
+	if policy == configuration.ClusterConfigSyncPolicyAuthoritative {
+		identities, err := s.Repositories().IdentityClusters().CountIdentitiesByCluster(ctx)
 		if err != nil {
-			return err
+			return nil, err
 		}
-	}
-	// Clusters which don't exist in the configuration will be deleted from DB
-	for _, c := range toDelete {
-		err = s.ExecuteInTransaction(func() error {
-			err := s.Repositories().Clusters().Delete(ctx, c.ClusterID)
-			notFound, _ := errors.IsNotFoundError(err)
-			if notFound {
-				log.Warn(ctx, map[string]interface{}{
-					"cluster_id":  c.ClusterID.String(),
-					"cluster_url": c.URL,
-					"err":         err,
-				}, "unable to delete the cluster because it doesn't exist")
-				// Just log the error and proceed. The cluster might be already deleted.
-				return nil
+		for _, c := range remaining {
+			if c.State != repository.ClusterStateDecommissioned && c.Origin == repository.ClusterOriginConfig {
+				decommissions = append(decommissions, repository.ClusterConfigSyncChange{
+					ClusterID:       c.ClusterID,
+					URL:             c.URL,
+					Name:            c.Name,
+					IdentitiesCount: identities[c.ClusterID],
+				})
 			}
-			return err
-		})
+		}
+		if err := s.checkClusterConfigSyncThresholds(decommissions); err != nil {
+			return nil, err
+		}
 	}
+	return &clusterConfigSync{
+		plan: repository.ClusterConfigSyncPlan{
+			Creates:       creates,
+			Updates:       updates,
+			Decommissions: decommissions,
+		},
+		toSave: append(toCreate, toUpdate...),
+	}, nil
+}
 
-	log.Info(ctx, map[string]interface{}{}, "creating/updating clusters from config file has been completed/done")
+// checkClusterConfigSyncThresholds returns an error if the given decommissions exceed the maximum number of clusters
+// or identity links which can be lost when the config file is applied
+func (s clusterService) checkClusterConfigSyncThresholds(decommissions []repository.ClusterConfigSyncChange) error {
+	identities := 0
+	for _, c := range decommissions {
+		identities += c.IdentitiesCount
+	}
+	if max := s.config.GetClusterConfigSyncMaxDecommissions(); max >= 0 && len(decommissions) > max {
+		return errs.Errorf("refusing to decommission %d clusters removed from the config file (at most %d allowed)", len(decommissions), max)
+	}
+	if max := s.config.GetClusterConfigSyncMaxIdentityLinksLosses(); max >= 0 && identities > max {
+		return errs.Errorf("refusing to decommission clusters removed from the config file with %d linked identities (at most %d allowed)", identities, max)
+	}
 	return nil
 }
 
-// CreateOrSaveCluster creates clusters or save updated cluster info
-func (s clusterService) CreateOrSaveCluster(ctx context.Context, clustr *repository.Cluster) error {
+// CreateOrSaveCluster creates clusters or save updated cluster info.
+// If `expectedVersions` are given, the cluster with the same URL must exist and have one of these versions, otherwise a
+// VersionConflictError is returned. In all cases, a VersionConflictError is returned if the cluster was modified concurrently.
+func (s clusterService) CreateOrSaveCluster(ctx context.Context, clustr *repository.Cluster, expectedVersions ...int) error {
 	// check that the token belongs to a user
 	if !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
 		log.Error(ctx, nil, "unauthorized access to cluster info")
@@ -118,8 +363,354 @@ func (s clusterService) CreateOrSaveCluster(ctx context.Context, clustr *reposit
 		return errs.Wrapf(err, "failed to create or save cluster named '%s'", clustr.Name)
 	}
 	return s.ExecuteInTransaction(func() error {
-		return s.Repositories().Clusters().CreateOrSave(ctx, clustr)
+		existing, err := s.Repositories().Clusters().FindByURL(ctx, clustr.URL)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); !notFound {
+				return err
+			}
+		}
+		if existing == nil && len(expectedVersions) > 0 {
+			return errors.NewVersionConflictError(fmt.Sprintf("cluster with url '%s' does not exist", clustr.URL))
+		}
+		if existing != nil {
+			if err := checkVersion(*existing, expectedVersions); err != nil {
+				return err
+			}
+		}
+		state, err := initialState(clustr.State, existing)
+		if err != nil {
+			return err
+		}
+		clustr.State = state
+		// the secrets are encrypted in the record to store, but the caller keeps them in clear
+		stored := *clustr
+		if err := s.applySATokenRotation(&stored, existing); err != nil {
+			return err
+		}
+		if err := s.encryptSecrets(&stored, existing); err != nil {
+			return err
+		}
+		if existing != nil {
+			// the clusters declared in the config file remain owned by it when they are updated through the API
+			stored.Origin = existing.Origin
+			// overwrite the version of the cluster which was checked above, not a more recent one
+			stored.ClusterID = existing.ClusterID
+			stored.Version = existing.Version
+			err = s.Repositories().Clusters().Save(ctx, &stored)
+		} else {
+			stored.Origin = repository.ClusterOriginAPI
+			err = s.Repositories().Clusters().Create(ctx, &stored)
+		}
+		if err != nil {
+			return err
+		}
+		clustr.ClusterID = stored.ClusterID
+		clustr.Version = stored.Version
+		clustr.Origin = stored.Origin
+		return s.refreshCapacity(ctx, clustr.ClusterID)
+	})
+}
+
+// initialState returns the state of a cluster which is created or saved as a whole: the given `state` if it is set and
+// if the `existing` cluster (if any) can be moved to it, otherwise the state of the `existing` cluster, or `active`
+// for a new cluster. A decommissioned cluster is not revived unless its new state is given explicitly.
+// Returns a BadParameterError if the transition to the given state is not allowed, or if no state is given for a
+// decommissioned cluster.
+func initialState(state string, existing *repository.Cluster) (string, error) {
+	if existing == nil || existing.State == "" {
+		if state != "" {
+			return state, nil
+		}
+		return repository.ClusterStateActive, nil
+	}
+	if state == "" {
+		if existing.State == repository.ClusterStateDecommissioned {
+			return "", errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster with url '%s' is decommissioned, its state must be set to revive it", existing.URL))
+		}
+		return existing.State, nil
+	}
+	if !repository.CanTransitionClusterState(existing.State, state) {
+		return "", errors.NewBadParameterErrorFromString(fmt.Sprintf(errInvalidStateTransitionMsg, existing.State, state))
+	}
+	return state, nil
+}
+
+// applySATokenRotation sets the creation time of the SA token of the given cluster record, which is about to be saved
+// as a whole, and carries over the SA token rotation of the `existing` record (if any):
+// - if the SA token is unchanged, the pending token (if any) is kept, along with its timestamps,
+// - if the SA token is replaced with the pending token, the rotation is complete,
+// - otherwise, the SA token was overwritten and the pending token (if any) is discarded.
+func (s clusterService) applySATokenRotation(clustr, existing *repository.Cluster) error {
+	if err := s.decryptSecrets(clustr); err != nil {
+		return err
+	}
+	now := time.Now()
+	if existing == nil {
+		clustr.SATokenCreatedAt = &now
+		clearPendingSAToken(clustr)
+		return nil
+	}
+	current := *existing
+	if err := s.decryptSecrets(&current); err != nil {
+		return err
+	}
+	switch {
+	case clustr.SAToken == current.SAToken:
+		clustr.SATokenCreatedAt = current.SATokenCreatedAt
+		clustr.PendingSAToken = current.PendingSAToken
+		clustr.PendingSATokenEncrypted = current.PendingSATokenEncrypted
+		clustr.PendingSATokenCreatedAt = current.PendingSATokenCreatedAt
+		clustr.PendingSATokenPromotionAt = current.PendingSATokenPromotionAt
+	case current.PendingSAToken != "" && clustr.SAToken == current.PendingSAToken:
+		clustr.SATokenCreatedAt = current.PendingSATokenCreatedAt
+		clearPendingSAToken(clustr)
+	default:
+		clustr.SATokenCreatedAt = &now
+		clearPendingSAToken(clustr)
+	}
+	return nil
+}
+
+// checkVersion returns a VersionConflictError if `expectedVersions` are given and the version of the cluster is not one of them
+func checkVersion(clustr repository.Cluster, expectedVersions []int) error {
+	if len(expectedVersions) == 0 {
+		return nil
+	}
+	for _, v := range expectedVersions {
+		if v == clustr.Version {
+			return nil
+		}
+	}
+	return errors.NewVersionConflictError(fmt.Sprintf("cluster with id '%s' does not match the expected version (current version is %d)", clustr.ClusterID.String(), clustr.Version))
+}
+
+// clearPendingSAToken removes the pending SA token (if any) from the given cluster record
+func clearPendingSAToken(c *repository.Cluster) {
+	c.PendingSAToken = ""
+	c.PendingSATokenEncrypted = false
+	c.PendingSATokenCreatedAt = nil
+	c.PendingSATokenPromotionAt = nil
+}
+
+// PatchCluster applies the given changes on the cluster identified by the given `clusterID`, following the
+// JSON merge-patch semantics: the fields which are not set in the patch are left unchanged (including the secrets).
+// The resulting cluster must satisfy the same validation rules as when the cluster is created.
+// If `expectedVersions` are given, the cluster must have one of these versions.
+// This method is allowed for the 'toolchain operator' service account only.
+// returns the updated cluster (without the sensitive info), a NotFoundError error if no cluster with the given ID exists, a BadParameterError
+// if the resulting cluster is invalid, a DataConflictError if its API URL is already used by another cluster or a VersionConflictError
+// if the cluster does not have the expected version (or was modified concurrently)
+func (s clusterService) PatchCluster(ctx context.Context, clusterID uuid.UUID, patch repository.ClusterPatch, expectedVersions ...int) (*repository.Cluster, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
+		log.Error(ctx, nil, "unauthorized access to cluster info")
+		return nil, errors.NewUnauthorizedError("unauthorized access to cluster info")
+	}
+	var result *repository.Cluster
+	err := s.ExecuteInTransaction(func() error {
+		existing, err := s.Repositories().Clusters().Load(ctx, clusterID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(*existing, expectedVersions); err != nil {
+			return err
+		}
+		clustr := *existing
+		if err := s.decryptSecrets(&clustr); err != nil {
+			return err
+		}
+		previousState := clustr.State
+		patch.Apply(&clustr)
+		if strings.TrimSpace(clustr.TokenProviderID) == "" {
+			// reset to the default value
+			clustr.TokenProviderID = clustr.ClusterID.String()
+		}
+		if err := s.validate(ctx, &clustr); err != nil {
+			return errs.Wrapf(err, "failed to patch cluster named '%s'", clustr.Name)
+		}
+		if !repository.CanTransitionClusterState(previousState, clustr.State) {
+			return errors.NewBadParameterErrorFromString(fmt.Sprintf(errInvalidStateTransitionMsg, previousState, clustr.State))
+		}
+		// make sure that the API URL is not used by another cluster
+		if patch.URL != nil {
+			other, err := s.Repositories().Clusters().FindByURL(ctx, clustr.URL)
+			if err != nil {
+				if notFound, _ := errors.IsNotFoundError(err); !notFound {
+					return err
+				}
+			} else if other.ClusterID != clusterID {
+				return errors.NewDataConflictError(fmt.Sprintf("cluster with url '%s' already exists", clustr.URL))
+			}
+		}
+		if err := s.applySATokenRotation(&clustr, existing); err != nil {
+			return err
+		}
+		if err := s.encryptSecrets(&clustr, existing); err != nil {
+			return err
+		}
+		if err := s.Repositories().Clusters().Save(ctx, &clustr); err != nil {
+			return err
+		}
+		if err := s.refreshCapacity(ctx, clusterID); err != nil {
+			return err
+		}
+		result, err = s.load(ctx, clusterID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// hide all sensitive info from the cluster record to return
+	hideSensitiveInfo(result)
+	return result, nil
+}
+
+// RotateSAToken starts the rotation of the SA token of the cluster identified by the given `clusterID`: the new token
+// is stored as the pending token, and replaces the current one once the grace period is over. During this period,
+// both tokens are returned to the Auth service. A new rotation replaces the one in progress, if any.
+// The `saTokenEncrypted` flag tells whether the new token is encrypted, and replaces the flag of the current token along
+// with it. If no grace period is given, the default one from the configuration applies. A zero grace period replaces
+// the current token immediately.
+// This method is allowed for the 'toolchain operator' service account only.
+// returns the cluster (without the sensitive info), a NotFoundError error if no cluster with the given ID exists,
+// or a BadParameterError if the token or the grace period is invalid
+func (s clusterService) RotateSAToken(ctx context.Context, clusterID uuid.UUID, saToken string, saTokenEncrypted bool, gracePeriod *time.Duration) (*repository.Cluster, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
+		log.Error(ctx, nil, "unauthorized access to cluster info")
+		return nil, errors.NewUnauthorizedError("unauthorized access to cluster info")
+	}
+	if strings.TrimSpace(saToken) == "" {
+		return nil, errors.NewBadParameterErrorFromString(fmt.Sprintf(errEmptyFieldMsg, "service-account-token"))
+	}
+	period := s.config.GetClusterSATokenRotationGracePeriod()
+	if gracePeriod != nil {
+		period = *gracePeriod
+	}
+	if period < 0 {
+		return nil, errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid grace-period: %v (expected a positive value, or 0 to replace the token immediately)", period))
+	}
+	var result *repository.Cluster
+	err := s.ExecuteInTransaction(func() error {
+		existing, err := s.Repositories().Clusters().Load(ctx, clusterID)
+		if err != nil {
+			return err
+		}
+		clustr := *existing
+		if err := s.decryptSecrets(&clustr); err != nil {
+			return err
+		}
+		if saToken == clustr.SAToken {
+			return errors.NewBadParameterErrorFromString("the new service-account-token must differ from the current one")
+		}
+		now := time.Now()
+		if period == 0 {
+			clustr.SAToken = saToken
+			clustr.SATokenEncrypted = saTokenEncrypted
+			clustr.SATokenCreatedAt = &now
+			clearPendingSAToken(&clustr)
+		} else {
+			promotionAt := now.Add(period)
+			clustr.PendingSAToken = saToken
+			clustr.PendingSATokenEncrypted = saTokenEncrypted
+			clustr.PendingSATokenCreatedAt = &now
+			clustr.PendingSATokenPromotionAt = &promotionAt
+		}
+		if err := s.encryptSecrets(&clustr, existing); err != nil {
+			return err
+		}
+		if err := s.Repositories().Clusters().Save(ctx, &clustr); err != nil {
+			return err
+		}
+		result, err = s.load(ctx, clusterID)
+		return err
 	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"cluster_id":   clusterID.String(),
+		"grace_period": period.String(),
+	}, "cluster SA token rotation started")
+	// hide all sensitive info from the cluster record to return
+	hideSensitiveInfo(result)
+	return result, nil
+}
+
+// PromoteSATokens replaces the SA token of the clusters whose rotation grace period is over with their pending token.
+// Returns the number of clusters whose SA token was replaced
+func (s clusterService) PromoteSATokens(ctx context.Context) (int, error) {
+	now := time.Now()
+	clusters, err := s.Repositories().Clusters().ListPendingSATokens(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, c := range clusters {
+		promoted := false
+		err := s.ExecuteInTransaction(func() error {
+			// reload the cluster, in case its token was changed in the mean time
+			clustr, err := s.Repositories().Clusters().Load(ctx, c.ClusterID)
+			if err != nil {
+				return err
+			}
+			if clustr.PendingSAToken == "" || clustr.PendingSATokenPromotionAt == nil || clustr.PendingSATokenPromotionAt.After(now) {
+				return nil
+			}
+			// the pending token is encrypted at rest with the same key as the current one
+			clustr.SAToken = clustr.PendingSAToken
+			clustr.SATokenEncrypted = clustr.PendingSATokenEncrypted
+			clustr.SATokenCreatedAt = clustr.PendingSATokenCreatedAt
+			clearPendingSAToken(clustr)
+			promoted = true
+			return s.Repositories().Clusters().Save(ctx, clustr)
+		})
+		if err != nil {
+			return count, err
+		}
+		if promoted {
+			log.Info(ctx, map[string]interface{}{
+				"cluster_id":  c.ClusterID.String(),
+				"cluster_url": c.URL,
+			}, "cluster SA token promoted")
+			count++
+		}
+	}
+	return count, nil
+}
+
+// InitializeSATokenPromoter starts a background routine which periodically replaces the SA tokens whose rotation
+// grace period is over, unless the promotion interval in the configuration is not positive.
+// Returns the function to call to stop the routine.
+func (s clusterService) InitializeSATokenPromoter() func() {
+	interval := s.config.GetClusterSATokenPromotionInterval()
+	if interval <= 0 {
+		log.Warn(context.Background(), map[string]interface{}{}, "cluster SA token promoter disabled")
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// only the leader promotes the tokens
+			if !isLeader() {
+				log.Debug(ctx, map[string]interface{}{}, "not the leader, skipping the promotion of the pending SA tokens")
+			} else if _, err := s.PromoteSATokens(ctx); err != nil {
+				// Do not crash. Log the error and try again later
+				log.Error(ctx, map[string]interface{}{
+					"err": err,
+				}, "unable to promote the pending SA tokens")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Info(context.Background(), map[string]interface{}{
+		"interval": interval.String(),
+	}, "cluster SA token promoter initialized")
+	return cancel
 }
 
 // Load loads the cluster given its ID, but without the sentitive info (token, etc.)
@@ -134,18 +725,12 @@ func (s clusterService) Load(ctx context.Context, clusterID uuid.UUID) (*reposit
 	if !auth.IsSpecificServiceAccount(ctx, auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth) {
 		return nil, errors.NewUnauthorizedError("unauthorized access to cluster info")
 	}
-	result, err := s.Repositories().Clusters().Load(ctx, clusterID)
+	result, err := s.load(ctx, clusterID)
 	if err != nil {
 		return nil, err
 	}
 	// hide all sensitive info from the cluster record to return
-	result.AuthDefaultScope = ""
-	result.AuthClientID = ""
-	result.AuthClientSecret = ""
-	result.SAToken = ""
-	result.SAUsername = ""
-	result.TokenProviderID = ""
-	result.SATokenEncrypted = false
+	hideSensitiveInfo(result)
 	return result, nil
 }
 
@@ -156,7 +741,44 @@ func (s clusterService) LoadForAuth(ctx context.Context, clusterID uuid.UUID) (*
 	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
 		return nil, errors.NewUnauthorizedError("unauthorized access to cluster info")
 	}
-	return s.Repositories().Clusters().Load(ctx, clusterID)
+	result, err := s.load(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decryptSecrets(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// load loads the cluster given its ID, along with the number of identities linked to it and its health
+func (s clusterService) load(ctx context.Context, clusterID uuid.UUID) (*repository.Cluster, error) {
+	result, err := s.Repositories().Clusters().Load(ctx, clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadDetails(ctx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// loadDetails sets the number of identities linked to the given cluster and its health (if it was already probed)
+func (s clusterService) loadDetails(ctx context.Context, clustr *repository.Cluster) error {
+	count, err := s.Repositories().IdentityClusters().CountIdentities(ctx, clustr.ClusterID)
+	if err != nil {
+		return err
+	}
+	clustr.IdentitiesCount = count
+	health, err := s.Repositories().ClusterHealth().Load(ctx, clustr.ClusterID)
+	if err != nil {
+		if ok, _ := errors.IsNotFoundError(err); ok {
+			return nil
+		}
+		return err
+	}
+	clustr.Health = health
+	return nil
 }
 
 // FindByURL loads the cluster given its URL, but without the sentitive info (token, etc.)
@@ -177,13 +799,7 @@ func (s clusterService) FindByURL(ctx context.Context, clusterURL string) (*repo
 		return nil, err
 	}
 	// hide all sensitive info from the cluster record to return
-	result.AuthDefaultScope = ""
-	result.AuthClientID = ""
-	result.AuthClientSecret = ""
-	result.SAToken = ""
-	result.SAUsername = ""
-	result.TokenProviderID = ""
-	result.SATokenEncrypted = false
+	hideSensitiveInfo(result)
 	return result, nil
 }
 
@@ -194,7 +810,14 @@ func (s clusterService) FindByURLForAuth(ctx context.Context, clusterURL string)
 	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
 		return nil, errors.NewUnauthorizedError("unauthorized access to cluster info")
 	}
-	return s.findByURL(ctx, clusterURL)
+	result, err := s.findByURL(ctx, clusterURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decryptSecrets(result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 func (s clusterService) findByURL(ctx context.Context, clusterURL string) (*repository.Cluster, error) {
@@ -203,7 +826,14 @@ func (s clusterService) findByURL(ctx context.Context, clusterURL string) (*repo
 	if err != nil {
 		return nil, errors.NewBadParameterError("cluster-url", clusterURL)
 	}
-	return s.Repositories().Clusters().FindByURL(ctx, clusterURL)
+	result, err := s.Repositories().Clusters().FindByURL(ctx, clusterURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadDetails(ctx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 const (
@@ -213,6 +843,14 @@ const (
 	errInvalidURLMsg = "'%s' URL '%s' is invalid: %v"
 	// errInvalidTypeMsg the error template when the type of cluster is invalid
 	errInvalidTypeMsg = "invalid type of cluster: '%s' (expected 'OSO', 'OCP' or 'OSD')"
+	// errInvalidMaxIdentitiesMsg the error template when the maximum number of identities is invalid
+	errInvalidMaxIdentitiesMsg = "invalid max-identities: %d (expected a positive value, or 0 for no limit)"
+	// errInvalidCapacityThresholdMsg the error template when the capacity threshold is invalid
+	errInvalidCapacityThresholdMsg = "invalid capacity-threshold: %d (expected a percentage between 0 and 100)"
+	// errInvalidStateMsg the error template when the state of cluster is invalid
+	errInvalidStateMsg = "invalid state of cluster: '%s' (expected 'provisioning', 'active', 'draining' or 'decommissioned')"
+	// errInvalidStateTransitionMsg the error template when the state of cluster cannot be changed
+	errInvalidStateTransitionMsg = "invalid state transition of cluster from '%s' to '%s'"
 )
 
 // validate checks if all data in the given cluster is valid, and fills the missing/optional URLs using the `APIURL`
@@ -265,6 +903,17 @@ func (s clusterService) validate(ctx context.Context, clustr *repository.Cluster
 	if strings.TrimSpace(clustr.SAUsername) == "" {
 		return errors.NewBadParameterErrorFromString(fmt.Sprintf(errEmptyFieldMsg, "service-account-username"))
 	}
+	// validate the capacity settings
+	if clustr.MaxIdentities < 0 {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf(errInvalidMaxIdentitiesMsg, clustr.MaxIdentities))
+	}
+	if clustr.CapacityThreshold < 0 || clustr.CapacityThreshold > 100 {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf(errInvalidCapacityThresholdMsg, clustr.CapacityThreshold))
+	}
+	// validate the state, if specified (`active` by default)
+	if clustr.State != "" && !repository.IsValidClusterState(clustr.State) {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf(errInvalidStateMsg, clustr.State))
+	}
 	if strings.TrimSpace(clustr.TokenProviderID) == "" {
 		existingClustr, err := s.Repositories().Clusters().FindByURL(ctx, clustr.URL)
 		if err != nil {
@@ -299,95 +948,231 @@ func validateURL(urlStr string) error {
 	return nil
 }
 
-// Delete deletes the cluster identified by the given `clusterID`
-func (s clusterService) Delete(ctx context.Context, clusterID uuid.UUID) error {
+// Delete decommissions the cluster identified by the given `clusterID`. The cluster record and its identity/cluster
+// relationships are kept, but the cluster is not listed by default anymore and it refuses new identities.
+// If `expectedVersions` are given, the cluster must have one of these versions, otherwise a VersionConflictError is returned.
+func (s clusterService) Delete(ctx context.Context, clusterID uuid.UUID, expectedVersions ...int) error {
 	// check that the token belongs to the `toolchain operator` SA
 	if !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
 		return errors.NewUnauthorizedError("unauthorized access to delete a cluster configuration")
 	}
-	return s.Repositories().Clusters().Delete(ctx, clusterID)
+	// update the cluster state and record the audit entry in the same transaction
+	return s.ExecuteInTransaction(func() error {
+		clustr, err := s.Repositories().Clusters().Load(ctx, clusterID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(*clustr, expectedVersions); err != nil {
+			return err
+		}
+		if !repository.CanTransitionClusterState(clustr.State, repository.ClusterStateDecommissioned) {
+			return errors.NewBadParameterErrorFromString(fmt.Sprintf(errInvalidStateTransitionMsg, clustr.State, repository.ClusterStateDecommissioned))
+		}
+		clustr.State = repository.ClusterStateDecommissioned
+		return s.Repositories().Clusters().Save(ctx, clustr)
+	})
 }
 
-// InitializeClusterWatcher initializes a file watcher for the cluster config file
-// When the file is updated the configuration synchronously reload the cluster configuration
-func (s clusterService) InitializeClusterWatcher() (func() error, error) {
-	watcher, err := fsnotify.NewWatcher()
+// ListAudit returns the audit entries of the cluster identified by the given `clusterID`, from the oldest to the newest.
+// The entries are kept after the cluster was deleted.
+// This method is allowed for the `toolchain operator` service account only
+func (s clusterService) ListAudit(ctx context.Context, clusterID uuid.UUID) ([]repository.AuditEntry, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
+		return nil, errors.NewUnauthorizedError("unauthorized access to the audit log")
+	}
+	return s.Repositories().Audit().ListForCluster(ctx, clusterID)
+}
+
+// ListAuditSince returns the audit entries of all clusters created at or after the given time, from the oldest to the newest
+// This method is allowed for the `toolchain operator` service account only
+func (s clusterService) ListAuditSince(ctx context.Context, since time.Time) ([]repository.AuditEntry, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
+		return nil, errors.NewUnauthorizedError("unauthorized access to the audit log")
+	}
+	return s.Repositories().Audit().ListSince(ctx, since)
+}
+
+// ListIdentities returns the identity/cluster relationships of the cluster identified by the given `clusterID`,
+// ordered by identity ID, along with the total number of identities linked to the cluster. A `0` limit means no limit.
+// This method is allowed for the following service accounts:
+// - Auth
+// - Tenant
+// Returns a NotFoundError if the cluster does not exist.
+func (s clusterService) ListIdentities(ctx context.Context, clusterID uuid.UUID, offset, limit int) ([]repository.IdentityCluster, int, error) {
+	if err := s.checkIdentitiesAccess(ctx, clusterID); err != nil {
+		return nil, 0, err
+	}
+	if offset < 0 {
+		return nil, 0, errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid offset: %d (expected a positive value)", offset))
+	}
+	if limit < 0 {
+		return nil, 0, errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid limit: %d (expected a positive value)", limit))
+	}
+	return s.Repositories().IdentityClusters().ListIdentities(ctx, clusterID, offset, limit)
+}
+
+// StreamIdentities calls the given function with each identity/cluster relationship of the cluster identified by
+// the given `clusterID`, ordered by identity ID, without loading them all in memory.
+// The function is not called if the caller is not allowed to list the identities, or if the cluster does not exist.
+// This method is allowed for the following service accounts:
+// - Auth
+// - Tenant
+func (s clusterService) StreamIdentities(ctx context.Context, clusterID uuid.UUID, fn func(repository.IdentityCluster) error) error {
+	if err := s.checkIdentitiesAccess(ctx, clusterID); err != nil {
+		return err
+	}
+	return s.Repositories().IdentityClusters().StreamIdentities(ctx, clusterID, fn)
+}
+
+// ListClustersForIdentities returns the identity/cluster relationships of all the given identities, with their
+// cluster (without the sensitive info), ordered by identity ID and by creation time.
+// Identities which are not linked to any cluster are ignored.
+// This method is allowed for the following service accounts:
+// - OSO Proxy
+// - Tenant
+// - Jenkins Idler
+// - Jenkins Proxy
+// - Auth
+func (s clusterService) ListClustersForIdentities(ctx context.Context, identityIDs ...uuid.UUID) ([]repository.IdentityCluster, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth) {
+		return nil, errors.NewUnauthorizedError("unauthorized access to cluster info")
+	}
+	identityClusters, err := s.Repositories().IdentityClusters().ListForIdentities(ctx, identityIDs)
 	if err != nil {
 		return nil, err
 	}
+	for i := range identityClusters {
+		hideSensitiveInfo(&identityClusters[i].Cluster)
+	}
+	return identityClusters, nil
+}
 
+// checkIdentitiesAccess verifies that the caller is allowed to list the identities linked to a cluster,
+// and that the cluster exists
+func (s clusterService) checkIdentitiesAccess(ctx context.Context, clusterID uuid.UUID) error {
+	if !auth.IsSpecificServiceAccount(ctx, auth.Auth, auth.Tenant) {
+		return errors.NewUnauthorizedError("unauthorized access to the identities of the cluster")
+	}
+	return s.Repositories().Clusters().CheckExists(ctx, clusterID.String())
+}
+
+// InitializeClusterWatcher initializes a file watcher for the cluster config file (or directory) and for the secret
+// files referenced in it. When they are updated, the cluster configuration is reloaded and synchronized with the DB.
+// The watcher stops when the given context is done, or when the returned func is called.
+func (s clusterService) InitializeClusterWatcher(ctx context.Context) (func(), error) {
+	configFilePath, err := configuration.PathExists(s.config.GetClusterConfigurationFilePath())
+	if err != nil {
+		return nil, err
+	}
+	if configFilePath == "" {
+		// OK in Dev Mode (the built-in config is used)
+		log.Warn(ctx, map[string]interface{}{
+			"file": s.config.GetClusterConfigurationFilePath(),
+		}, "cluster config file watcher not initialized for non-existent file")
+		return func() {}, nil
+	}
+	watcher, err := configuration.NewFileWatcher(func() []string {
+		return append([]string{configFilePath}, s.config.GetClusterConfigSecretFiles()...)
+	}, s.config.GetClusterConfigWatcherDebounce(), func() {
+		// Do not crash. The error is logged and the existing configuration is kept
+		s.ReloadClusterConfig(context.Background(), repository.ClusterConfigReloadTriggerWatcher)
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
 	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				if event.Op&fsnotify.Remove == fsnotify.Remove {
-					time.Sleep(1 * time.Second) // Wait for one second before re-adding and reloading. It might be needed if the file is removed and then re-added in some environments
-					err = watcher.Add(event.Name)
-					if err != nil {
-						log.Error(context.Background(), map[string]interface{}{
-							"file": event.Name,
-						}, "cluster config was removed but unable to re-add it to watcher")
-					}
-				}
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Remove == fsnotify.Remove {
-					// Reload config if operation is Write or Remove.
-					// Both can be part of file update depending on environment and actual operation.
-					err = s.loader.ReloadClusterConfig()
-					if err != nil {
-						// Do not crash. Log the error and keep using the existing configuration
-						log.Error(context.Background(), map[string]interface{}{
-							"err":  err,
-							"file": event.Name,
-							"op":   event.Op.String(),
-						}, "unable to reload cluster config file")
-					} else {
-						log.Info(context.Background(), map[string]interface{}{
-							"file": event.Name,
-							"op":   event.Op.String(),
-						}, "cluster config file modified and reloaded")
-						if err := s.CreateOrSaveClusterFromConfig(context.Background()); err != nil {
-							// Do not crash. Log the error and keep using the existing configuration from DB
-							log.Error(context.Background(), map[string]interface{}{
-								"err":  err,
-								"file": event.Name,
-								"op":   event.Op.String(),
-							}, "unable to save reloaded cluster config file")
-						}
-					}
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Error(context.Background(), map[string]interface{}{
-					"err": err,
-				}, "cluster config file watcher error")
-			}
-		}
+		defer close(done)
+		watcher.Run(ctx)
 	}()
-	configPath := s.loader.GetClusterConfigurationFilePath()
-
-	// this will make dev mode config path relative to current directory
-	if configPath == "./configuration/conf-files/oso-clusters.conf" {
-		configPath = "./../../" + configPath
-	}
-	configFilePath, err := configuration.PathExists(configPath)
-	if err == nil && configFilePath != "" {
-		err = watcher.Add(configFilePath)
-		log.Info(context.Background(), map[string]interface{}{
-			"file": configFilePath,
-		}, "cluster config file watcher initialized")
-	} else {
-		// OK in Dev Mode
-		log.Warn(context.Background(), map[string]interface{}{
-			"file": configFilePath,
-		}, "cluster config file watcher not initialized for non-existent file")
+	log.Info(ctx, map[string]interface{}{
+		"file": configFilePath,
+	}, "cluster config file watcher initialized")
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
+
+// clusterConfigReloadLock serializes the reloads of the cluster configuration, whether they are triggered by the
+// watcher, by a signal or through the API. Shared by all service instances of the current process
+var clusterConfigReloadLock sync.Mutex
+
+// ReloadClusterConfig reloads the cluster config file (or directory) and synchronizes the clusters in the DB with it.
+// The file is read once, then the new config is validated and compared with the DB first, so that an invalid config is
+// never loaded, and the config which is loaded and synchronized is the one which was validated.
+// The reloads are serialized, and only the toolchain operator SA is allowed to trigger one through the API.
+// Returns the outcome of the synchronization, along with an error if the config is invalid (BadParameterError), if the
+// synchronization failed, or if the current replica is not the leader (DataConflictError), in which case the config is
+// reloaded but the clusters are left to the leader. The outcome is only nil if the reload is not allowed.
+func (s clusterService) ReloadClusterConfig(ctx context.Context, trigger string) (*repository.ClusterConfigSyncResult, error) {
+	if trigger == repository.ClusterConfigReloadTriggerAPI && !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
+		log.Error(ctx, nil, "the account is not authorized to reload the cluster configuration")
+		return nil, errors.NewUnauthorizedError("account not authorized to reload the cluster configuration")
 	}
+	clusterConfigReloadLock.Lock()
+	defer clusterConfigReloadLock.Unlock()
 
-	return watcher.Close, err
+	configFile := s.config.GetClusterConfigurationFilePath()
+	policy := s.config.GetClusterConfigSyncPolicy()
+	result := &repository.ClusterConfigSyncResult{
+		Policy: policy,
+		Time:   time.Now(),
+	}
+	if err := checkClusterConfigSyncPolicy(policy); err != nil {
+		result.Error = err
+		return result, err
+	}
+	var planned *clusterConfigSync
+	configClusters, err := s.config.ReadClusterConfig()
+	if err == nil {
+		planned, err = s.planClusterConfigSync(ctx, configClusters, policy)
+	}
+	if err != nil {
+		// keep using the existing configuration
+		log.Error(ctx, map[string]interface{}{
+			"err":     err,
+			"file":    configFile,
+			"trigger": trigger,
+		}, "refusing to reload invalid cluster config file")
+		result.Error = errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid cluster config file '%s': %v", configFile, err))
+		return result, result.Error
+	}
+	result.Plan = planned.plan
+	log.Info(ctx, map[string]interface{}{
+		"file":          configFile,
+		"trigger":       trigger,
+		"creates":       len(planned.plan.Creates),
+		"updates":       len(planned.plan.Updates),
+		"decommissions": len(planned.plan.Decommissions),
+	}, "cluster config file validated")
+	s.config.LoadClusterConfig(configClusters)
+	log.Info(ctx, map[string]interface{}{
+		"file":    configFile,
+		"trigger": trigger,
+	}, "cluster config file reloaded")
+	if !isLeader() {
+		// the clusters are reconciled with the config file by the leader only
+		log.Info(ctx, map[string]interface{}{
+			"file":    configFile,
+			"trigger": trigger,
+			"leader":  s.Leadership().Holder,
+		}, "not the leader, skipping the reconciliation of the clusters with the reloaded cluster config file")
+		result.Error = errNotLeader(s.Leadership().Holder)
+		return result, result.Error
+	}
+	result, err = s.createOrSaveClustersFromConfig(ctx, configClusters)
+	if err != nil {
+		// keep using the existing configuration from DB
+		log.Error(ctx, map[string]interface{}{
+			"err":     err,
+			"file":    configFile,
+			"trigger": trigger,
+		}, "unable to save reloaded cluster config file")
+		return result, err
+	}
+	return result, nil
 }
 
 // LinkIdentityToCluster links Identity to Cluster
@@ -396,36 +1181,66 @@ func (s clusterService) LinkIdentityToCluster(ctx context.Context, identityID uu
 		log.Error(ctx, nil, "the account is not authorized to create identity cluster relationship")
 		return errors.NewUnauthorizedError("account not authorized to create identity cluster relationship")
 	}
+	_, err := s.linkIdentityToCluster(ctx, identityID, clusterURL, ignoreIfExists)
+	return err
+}
+
+// LinkIdentitiesToClusters links identities to clusters in bulk. If `transactional` is `true`, then all the links are
+// created in a single transaction, which is rolled back at the first failure. Otherwise, each link is created on
+// its own, and a failure does not prevent the other links from being created.
+// Returns the outcome of each link, in the same order as the given links.
+func (s clusterService) LinkIdentitiesToClusters(ctx context.Context, links []repository.IdentityClusterLink, transactional bool) ([]repository.IdentityClusterLinkResult, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
+		log.Error(ctx, nil, "the account is not authorized to create identity cluster relationship")
+		return nil, errors.NewUnauthorizedError("account not authorized to create identity cluster relationship")
+	}
+	return s.bulkUpdateIdentityClusters(links, transactional, func(link repository.IdentityClusterLink) (string, error) {
+		created, err := s.linkIdentityToCluster(ctx, link.IdentityID, link.ClusterURL, link.IgnoreIfAlreadyExists)
+		if err != nil || !created {
+			return repository.IdentityClusterLinkAlreadyExists, err
+		}
+		return repository.IdentityClusterLinkCreated, nil
+	})
+}
+
+// linkIdentityToCluster links the identity to the cluster with the given URL.
+// Returns `false` if the link already existed and `ignoreIfExists` is `true`
+func (s clusterService) linkIdentityToCluster(ctx context.Context, identityID uuid.UUID, clusterURL string, ignoreIfExists bool) (bool, error) {
 	if err := validateURL(clusterURL); err != nil {
-		return errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster-url '%s' is invalid", clusterURL))
+		return false, errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster-url '%s' is invalid", clusterURL))
 	}
 	rc, err := s.Repositories().Clusters().FindByURL(ctx, clusterURL)
 	if err != nil {
-		return err
+		return false, err
 	}
 	// do not fail silently even if identity is linked to cluster and ignoreIfExists is false
 	if !ignoreIfExists {
-		return s.createIdentityCluster(ctx, identityID, rc.ClusterID)
+		return true, s.createIdentityCluster(ctx, identityID, *rc)
 	}
 
 	_, err = s.Repositories().IdentityClusters().Load(ctx, identityID, rc.ClusterID)
 	if err != nil {
 		if ok, _ := errors.IsNotFoundError(err); ok {
-			return s.createIdentityCluster(ctx, identityID, rc.ClusterID)
+			return true, s.createIdentityCluster(ctx, identityID, *rc)
 		}
-		return err
+		return false, err
 	}
-	return nil
+	return false, nil
 }
 
-func (s clusterService) createIdentityCluster(ctx context.Context, identityID, clusterID uuid.UUID) error {
+func (s clusterService) createIdentityCluster(ctx context.Context, identityID uuid.UUID, clustr repository.Cluster) error {
+	// only the active clusters accept new identities
+	if clustr.State != repository.ClusterStateActive {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster with url '%s' does not accept new identities (state: '%s')", clustr.URL, clustr.State))
+	}
+	clusterID := clustr.ClusterID
 	identityCluster := &repository.IdentityCluster{IdentityID: identityID, ClusterID: clusterID}
 
 	return s.ExecuteInTransaction(func() error {
 		if err := s.Repositories().IdentityClusters().Create(ctx, identityCluster); err != nil {
 			return errors.NewInternalErrorFromString(fmt.Sprintf("failed to link identity '%s' with cluster '%s': %v", identityID, clusterID, err))
 		}
-		return nil
+		return s.refreshCapacity(ctx, clusterID)
 	})
 }
 
@@ -435,49 +1250,643 @@ func (s clusterService) RemoveIdentityToClusterLink(ctx context.Context, identit
 		log.Error(ctx, nil, "the account is not authorized to remove identity cluster relationship")
 		return errors.NewUnauthorizedError("account not authorized to remove identity cluster relationship")
 	}
+	return s.removeIdentityToClusterLink(ctx, identityID, clusterURL)
+}
+
+// RemoveIdentitiesToClustersLinks removes links between identities and clusters in bulk. If `transactional` is `true`,
+// then all the links are removed in a single transaction, which is rolled back at the first failure. Otherwise, each
+// link is removed on its own, and a failure does not prevent the other links from being removed.
+// Returns the outcome of each link removal, in the same order as the given links.
+func (s clusterService) RemoveIdentitiesToClustersLinks(ctx context.Context, links []repository.IdentityClusterLink, transactional bool) ([]repository.IdentityClusterLinkResult, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
+		log.Error(ctx, nil, "the account is not authorized to remove identity cluster relationship")
+		return nil, errors.NewUnauthorizedError("account not authorized to remove identity cluster relationship")
+	}
+	return s.bulkUpdateIdentityClusters(links, transactional, func(link repository.IdentityClusterLink) (string, error) {
+		return repository.IdentityClusterLinkDeleted, s.removeIdentityToClusterLink(ctx, link.IdentityID, link.ClusterURL)
+	})
+}
+
+func (s clusterService) removeIdentityToClusterLink(ctx context.Context, identityID uuid.UUID, clusterURL string) error {
 	if err := validateURL(clusterURL); err != nil {
 		return errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster-url '%s' is invalid", clusterURL))
 	}
 	return s.ExecuteInTransaction(func() error {
-		return s.Repositories().IdentityClusters().Delete(ctx, identityID, clusterURL)
+		if err := s.Repositories().IdentityClusters().Delete(ctx, identityID, clusterURL); err != nil {
+			return err
+		}
+		rc, err := s.Repositories().Clusters().FindByURL(ctx, clusterURL)
+		if err != nil {
+			return err
+		}
+		return s.refreshCapacity(ctx, rc.ClusterID)
+	})
+}
+
+// bulkUpdateIdentityClusters applies the given update on each link, and returns the outcome of each update.
+// If `transactional` is `true`, then all the updates are applied in a single transaction, which is rolled back
+// at the first failure (the remaining links are skipped). Otherwise, each update is applied on its own.
+// An error is returned only if the transaction failed for another reason than the failure of an update.
+func (s clusterService) bulkUpdateIdentityClusters(links []repository.IdentityClusterLink, transactional bool, update func(repository.IdentityClusterLink) (string, error)) ([]repository.IdentityClusterLinkResult, error) {
+	results := make([]repository.IdentityClusterLinkResult, len(links))
+	for i, link := range links {
+		results[i] = repository.IdentityClusterLinkResult{
+			IdentityClusterLink: link,
+			Status:              repository.IdentityClusterLinkSkipped,
+		}
+	}
+	apply := func(i int) error {
+		status, err := update(links[i])
+		if err != nil {
+			results[i].Status = repository.IdentityClusterLinkFailed
+			results[i].Error = err
+			return err
+		}
+		results[i].Status = status
+		return nil
+	}
+	if !transactional {
+		for i := range links {
+			apply(i) // the failure is recorded in the results
+		}
+		return results, nil
+	}
+	err := s.ExecuteInTransaction(func() error {
+		for i := range links {
+			if err := apply(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return results, nil
+	}
+	// the transaction was rolled back
+	failed := false
+	for i := range results {
+		switch results[i].Status {
+		case repository.IdentityClusterLinkFailed:
+			failed = true
+		case repository.IdentityClusterLinkSkipped:
+		default:
+			results[i].Status = repository.IdentityClusterLinkRolledBack
+		}
+	}
+	if !failed {
+		return nil, err
+	}
+	return results, nil
+}
+
+// MigrateIdentities moves the identities with the given IDs from the source cluster to the target cluster, in a single transaction:
+// either all identities are moved, or none. The target cluster must be active, of the same type as the source cluster, and must have
+// enough capacity for the migrated identities. If `dryRun` is `true`, the migration is only validated and nothing is changed.
+// Returns the report of the migration, in which the clusters have no sensitive info and their number of identities is the one
+// after the migration.
+func (s clusterService) MigrateIdentities(ctx context.Context, identityIDs []uuid.UUID, sourceClusterURL, targetClusterURL string, dryRun bool) (*repository.IdentityMigration, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
+		log.Error(ctx, nil, "the account is not authorized to migrate identities")
+		return nil, errors.NewUnauthorizedError("account not authorized to migrate identities")
+	}
+	if len(identityIDs) == 0 {
+		return nil, errors.NewBadParameterErrorFromString("no identity to migrate")
+	}
+	for _, clusterURL := range []string{sourceClusterURL, targetClusterURL} {
+		if err := validateURL(clusterURL); err != nil {
+			return nil, errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster-url '%s' is invalid", clusterURL))
+		}
+	}
+	// ignore the duplicate IDs
+	ids := make([]uuid.UUID, 0, len(identityIDs))
+	seen := make(map[uuid.UUID]bool, len(identityIDs))
+	for _, id := range identityIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	var result *repository.IdentityMigration
+	err := s.ExecuteInTransaction(func() error {
+		source, err := s.Repositories().Clusters().FindByURL(ctx, sourceClusterURL)
+		if err != nil {
+			return err
+		}
+		target, err := s.Repositories().Clusters().FindByURL(ctx, targetClusterURL)
+		if err != nil {
+			return err
+		}
+		if source.ClusterID == target.ClusterID {
+			return errors.NewBadParameterErrorFromString("source and target clusters must be different")
+		}
+		if target.State != repository.ClusterStateActive {
+			return errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster with url '%s' does not accept new identities (state: '%s')", target.URL, target.State))
+		}
+		if target.Type != source.Type {
+			return errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster with url '%s' is of type '%s' (expected '%s')", target.URL, target.Type, source.Type))
+		}
+		for _, id := range ids {
+			// the identity must be linked to the source cluster, but not to the target cluster yet
+			if _, err := s.Repositories().IdentityClusters().Load(ctx, id, source.ClusterID); err != nil {
+				return err
+			}
+			_, err := s.Repositories().IdentityClusters().Load(ctx, id, target.ClusterID)
+			if err == nil {
+				return errors.NewBadParameterErrorFromString(fmt.Sprintf("identity '%s' is already linked to cluster with url '%s'", id, target.URL))
+			} else if notFound, _ := errors.IsNotFoundError(err); !notFound {
+				return err
+			}
+		}
+		sourceCount, err := s.Repositories().IdentityClusters().CountIdentities(ctx, source.ClusterID)
+		if err != nil {
+			return err
+		}
+		targetCount, err := s.Repositories().IdentityClusters().CountIdentities(ctx, target.ClusterID)
+		if err != nil {
+			return err
+		}
+		if target.CapacityExhausted && target.MaxIdentities <= 0 {
+			return errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster with url '%s' has no capacity left", target.URL))
+		}
+		if target.MaxIdentities > 0 && targetCount+len(ids) > target.MaxIdentities {
+			return errors.NewBadParameterErrorFromString(fmt.Sprintf("cluster with url '%s' does not have enough capacity: %d identities to migrate, %d identities linked out of %d",
+				target.URL, len(ids), targetCount, target.MaxIdentities))
+		}
+		if !dryRun {
+			for _, id := range ids {
+				if err := s.Repositories().IdentityClusters().Move(ctx, id, source.ClusterID, target.ClusterID); err != nil {
+					return err
+				}
+			}
+			if err := s.refreshCapacity(ctx, source.ClusterID); err != nil {
+				return err
+			}
+			if err := s.refreshCapacity(ctx, target.ClusterID); err != nil {
+				return err
+			}
+			// reload the clusters to return their refreshed capacity
+			if source, err = s.Repositories().Clusters().Load(ctx, source.ClusterID); err != nil {
+				return err
+			}
+			if target, err = s.Repositories().Clusters().Load(ctx, target.ClusterID); err != nil {
+				return err
+			}
+		}
+		source.IdentitiesCount = sourceCount - len(ids)
+		target.IdentitiesCount = targetCount + len(ids)
+		hideSensitiveInfo(source)
+		hideSensitiveInfo(target)
+		result = &repository.IdentityMigration{
+			SourceCluster: *source,
+			TargetCluster: *target,
+			IdentityIDs:   ids,
+			DryRun:        dryRun,
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"source_cluster_url": result.SourceCluster.URL,
+		"target_cluster_url": result.TargetCluster.URL,
+		"identities":         len(result.IdentityIDs),
+		"dry_run":            dryRun,
+	}, "identities migration completed")
+	return result, nil
+}
+
+// refreshCapacity updates the `CapacityExhausted` flag of the cluster with the given ID according to the number of
+// linked identities, if the cluster has a maximum number of identities. Otherwise, the flag is left unchanged.
+// This method must be called within the transaction which linked or unlinked the identities: the cluster row is locked
+// until the end of this transaction, so that concurrent links on the same cluster do not compute the flag from a stale count.
+func (s clusterService) refreshCapacity(ctx context.Context, clusterID uuid.UUID) error {
+	rc, err := s.Repositories().Clusters().LoadForUpdate(ctx, clusterID)
+	if err != nil {
+		return err
+	}
+	if rc.MaxIdentities <= 0 {
+		return nil
+	}
+	count, err := s.Repositories().IdentityClusters().CountIdentities(ctx, clusterID)
+	if err != nil {
+		return err
+	}
+	threshold := rc.CapacityThreshold
+	if threshold <= 0 {
+		threshold = s.config.GetClusterCapacityThreshold()
+	}
+	exhausted := IsCapacityExhausted(count, rc.MaxIdentities, threshold)
+	if exhausted == rc.CapacityExhausted {
+		return nil
+	}
+	log.Info(ctx, map[string]interface{}{
+		"cluster_id":         clusterID.String(),
+		"cluster_url":        rc.URL,
+		"identities":         count,
+		"max_identities":     rc.MaxIdentities,
+		"capacity_threshold": threshold,
+		"capacity_exhausted": exhausted,
+	}, "cluster capacity threshold crossed")
+	return s.Repositories().Clusters().UpdateCapacityExhausted(ctx, clusterID, exhausted)
+}
+
+// IsCapacityExhausted returns `true` if the given number of identities reached the `threshold` percentage of
+// the given maximum number of identities
+func IsCapacityExhausted(identities, maxIdentities, threshold int) bool {
+	return identities*100 >= maxIdentities*threshold
 }
 
-// List lists ALL clusters
+// List lists the clusters matching the given options, along with the total number of matching clusters
 // This method is allowed for the following service accounts:
 // - Auth
 // - OSO Proxy
 // - Tenant
 // - Jenkins Idler
 // - Jenkins Proxy
-func (s clusterService) List(ctx context.Context, clusterType *string) ([]repository.Cluster, error) {
+// Only the clusters in the given states are returned (the `active` ones if no state is specified).
+func (s clusterService) List(ctx context.Context, options repository.ClusterListOptions) ([]repository.Cluster, int, error) {
 	if !auth.IsSpecificServiceAccount(ctx, auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth) {
-		return []repository.Cluster{}, errors.NewUnauthorizedError("unauthorized access to clusters info")
+		return []repository.Cluster{}, 0, errors.NewUnauthorizedError("unauthorized access to clusters info")
 	}
-	clusters, err := s.Repositories().Clusters().List(ctx, clusterType)
+	clusters, total, err := s.list(ctx, options)
 	if err != nil {
-		return []repository.Cluster{}, err
+		return []repository.Cluster{}, 0, err
 	}
-	// hide all sensitive info in the cluster records to return
-	for i, c := range clusters {
-		c.AuthDefaultScope = ""
-		c.AuthClientID = ""
-		c.AuthClientSecret = ""
-		c.SAToken = ""
-		c.SAUsername = ""
-		c.SATokenEncrypted = false
-		c.TokenProviderID = ""
-		// need to replace entry in slice since it's not a slice of pointers
-		clusters[i] = c
+	for i := range clusters {
+		// hide all sensitive info in the cluster records to return
+		hideSensitiveInfo(&clusters[i])
 	}
-	return clusters, nil
+	return clusters, total, nil
 }
 
-// List lists ALL clusters, including sensitive information
+// ListForAuth lists the clusters matching the given options (the `active` ones if no state is specified),
+// including sensitive information, along with the total number of matching clusters.
 // This method is allowed for the `Auth` service account only
-func (s clusterService) ListForAuth(ctx context.Context, clusterType *string) ([]repository.Cluster, error) {
+func (s clusterService) ListForAuth(ctx context.Context, options repository.ClusterListOptions) ([]repository.Cluster, int, error) {
 	if !auth.IsSpecificServiceAccount(ctx, auth.Auth) {
-		return []repository.Cluster{}, errors.NewUnauthorizedError("unauthorized access to clusters info")
+		return []repository.Cluster{}, 0, errors.NewUnauthorizedError("unauthorized access to clusters info")
+	}
+	clusters, total, err := s.list(ctx, options)
+	if err != nil {
+		return []repository.Cluster{}, 0, err
+	}
+	for i := range clusters {
+		if err := s.decryptSecrets(&clusters[i]); err != nil {
+			return []repository.Cluster{}, 0, err
+		}
+	}
+	return clusters, total, nil
+}
+
+// list lists the clusters matching the given options (in the `active` state if no state is specified), along with
+// the number of identities linked to each one of them and their health
+func (s clusterService) list(ctx context.Context, options repository.ClusterListOptions) ([]repository.Cluster, int, error) {
+	if len(options.States) == 0 {
+		options.States = []string{repository.ClusterStateActive}
+	}
+	for _, state := range options.States {
+		if !repository.IsValidClusterState(state) {
+			return nil, 0, errors.NewBadParameterErrorFromString(fmt.Sprintf(errInvalidStateMsg, state))
+		}
+	}
+	clusters, total, err := s.Repositories().Clusters().ListPage(ctx, options)
+	if err != nil {
+		return nil, 0, err
+	}
+	counts, err := s.Repositories().IdentityClusters().CountIdentitiesByCluster(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	healths, err := s.Repositories().ClusterHealth().List(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range clusters {
+		clusters[i].IdentitiesCount = counts[clusters[i].ClusterID]
+		if health, found := healths[clusters[i].ClusterID]; found {
+			clusters[i].Health = &health
+		}
+	}
+	return clusters, total, nil
+}
+
+// ProbeClusters probes the URLs of all clusters (except the decommissioned ones) and records their health.
+// The clusters are probed concurrently, up to the number set in the configuration at the same time.
+func (s clusterService) ProbeClusters(ctx context.Context) error {
+	clusters, err := s.Repositories().Clusters().List(ctx, nil, repository.ClusterStateProvisioning, repository.ClusterStateActive, repository.ClusterStateDraining)
+	if err != nil {
+		return err
+	}
+	healths, err := s.Repositories().ClusterHealth().List(ctx)
+	if err != nil {
+		return err
+	}
+	prober := NewHealthProber(s.config.GetClusterHealthProbeTimeout())
+	results := make([]repository.ClusterHealth, len(clusters))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, s.config.GetClusterHealthProbeConcurrency())
+	for i, c := range clusters {
+		var previous *repository.ClusterHealth
+		if h, found := healths[c.ClusterID]; found {
+			previous = &h
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, c repository.Cluster, previous *repository.ClusterHealth) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = prober.Probe(ctx, c, previous)
+		}(i, c, previous)
+	}
+	wg.Wait()
+	for i, c := range clusters {
+		if previous, found := healths[c.ClusterID]; !found || previous.State != results[i].State {
+			log.Info(ctx, map[string]interface{}{
+				"cluster_id":  c.ClusterID.String(),
+				"cluster_url": c.URL,
+				"state":       results[i].State,
+				"last_error":  results[i].LastError,
+			}, "cluster health changed")
+		}
+		// keep on saving the health of the other clusters if something wrong happened
+		// (eg: the cluster was deleted in the mean time)
+		if err := s.Repositories().ClusterHealth().CreateOrSave(ctx, &results[i]); err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"cluster_id": c.ClusterID.String(),
+				"err":        err,
+			}, "unable to record the cluster health")
+		}
+	}
+	return nil
+}
+
+// InitializeHealthProber starts a background routine which periodically probes the URLs of all clusters,
+// unless the probe interval in the configuration is not positive.
+// Returns the function to call to stop the routine.
+func (s clusterService) InitializeHealthProber() func() {
+	interval := s.config.GetClusterHealthProbeInterval()
+	if interval <= 0 {
+		log.Warn(context.Background(), map[string]interface{}{}, "cluster health prober disabled")
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// only the leader probes the clusters
+			if !isLeader() {
+				log.Debug(ctx, map[string]interface{}{}, "not the leader, skipping the cluster health probes")
+			} else if err := s.ProbeClusters(ctx); err != nil {
+				// Do not crash. Log the error and try again later
+				log.Error(ctx, map[string]interface{}{
+					"err": err,
+				}, "unable to probe the clusters")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Info(context.Background(), map[string]interface{}{
+		"interval": interval.String(),
+	}, "cluster health prober initialized")
+	return cancel
+}
+
+// hideSensitiveInfo removes all sensitive info (tokens, OAuth client, etc.) from the given cluster record
+func hideSensitiveInfo(c *repository.Cluster) {
+	c.AuthDefaultScope = ""
+	c.AuthClientID = ""
+	c.AuthClientSecret = ""
+	c.SAToken = ""
+	c.SAUsername = ""
+	c.SATokenEncrypted = false
+	c.PendingSAToken = ""
+	c.PendingSATokenEncrypted = false
+	c.SecretsKeyID = ""
+	c.TokenProviderID = ""
+}
+
+// secretsEncryptor returns the encryptor of the cluster secrets given to the service, or a new one using the keys from the configuration
+func (s clusterService) secretsEncryptor() (Encryptor, error) {
+	if s.encryptor != nil {
+		return s.encryptor, nil
+	}
+	encryptor, err := NewEncryptor(s.config.GetClusterEncryptionKeys(), s.config.GetClusterEncryptionKeyID())
+	if err != nil {
+		return nil, errors.NewInternalErrorFromString(fmt.Sprintf("unable to initialize the encryption of the cluster secrets: %v", err))
+	}
+	return encryptor, nil
+}
+
+// secretFields returns the fields of the given cluster record which are encrypted at rest
+func secretFields(c *repository.Cluster) []*string {
+	return []*string{&c.SAToken, &c.PendingSAToken, &c.AuthClientSecret}
+}
+
+// encryptSecrets encrypts the SA tokens and the OAuth client secret of the given cluster record with the current key.
+// If the `existing` record of the cluster is already encrypted with the current key and holds the same secrets, then its
+// encrypted values are reused, so that saving the cluster again with the same secrets does not change them in the DB.
+func (s clusterService) encryptSecrets(clustr, existing *repository.Cluster) error {
+	encryptor, err := s.secretsEncryptor()
+	if err != nil {
+		return err
+	}
+	// the secrets of a record with a key ID are already encrypted
+	if err := s.decryptSecrets(clustr); err != nil {
+		return err
+	}
+	keyID := encryptor.KeyID()
+	var current *repository.Cluster
+	if existing != nil && existing.SecretsKeyID != "" && existing.SecretsKeyID == keyID {
+		decrypted := *existing
+		if err := s.decryptSecrets(&decrypted); err == nil {
+			current = &decrypted
+		}
+	}
+	for i, field := range secretFields(clustr) {
+		if current != nil && *secretFields(current)[i] == *field {
+			*field = *secretFields(existing)[i]
+			continue
+		}
+		if *field, err = encryptor.Encrypt(*field); err != nil {
+			return errors.NewInternalErrorFromString(fmt.Sprintf("unable to encrypt the secrets of cluster with url '%s': %v", clustr.URL, err))
+		}
+	}
+	clustr.SecretsKeyID = keyID
+	return nil
+}
+
+// decryptSecrets decrypts the SA tokens and the OAuth client secret of the given cluster record, unless they are stored in clear
+func (s clusterService) decryptSecrets(clustr *repository.Cluster) error {
+	if clustr.SecretsKeyID == "" {
+		return nil
+	}
+	encryptor, err := s.secretsEncryptor()
+	if err != nil {
+		return err
+	}
+	fields := secretFields(clustr)
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		if values[i], err = encryptor.Decrypt(clustr.SecretsKeyID, *field); err != nil {
+			return errors.NewInternalErrorFromString(fmt.Sprintf("unable to decrypt the secrets of cluster with url '%s': %v", clustr.URL, err))
+		}
+	}
+	for i, field := range fields {
+		*field = values[i]
+	}
+	clustr.SecretsKeyID = ""
+	return nil
+}
+
+// ReencryptSecrets re-encrypts the secrets of all clusters and webhook subscriptions which are not encrypted with the
+// current key (including the secrets still stored in clear), so that the previous keys can then be removed from the
+// configuration. A cluster or a subscription whose secrets were updated in the mean time is skipped.
+// Returns the number of clusters and subscriptions whose secrets were re-encrypted
+func (s clusterService) ReencryptSecrets(ctx context.Context) (int, error) {
+	encryptor, err := s.secretsEncryptor()
+	if err != nil {
+		return 0, err
+	}
+	clusters, err := s.Repositories().Clusters().List(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, c := range clusters {
+		if c.SecretsKeyID == encryptor.KeyID() {
+			continue
+		}
+		previousKeyID := c.SecretsKeyID
+		if err := s.encryptSecrets(&c, nil); err != nil {
+			return count, err
+		}
+		err := s.ExecuteInTransaction(func() error {
+			return s.Repositories().Clusters().UpdateSecrets(ctx, &c, previousKeyID)
+		})
+		if err != nil {
+			if _, conflict := errs.Cause(err).(errors.DataConflictError); conflict {
+				log.Warn(ctx, map[string]interface{}{
+					"cluster_id":  c.ClusterID.String(),
+					"cluster_url": c.URL,
+					"err":         err,
+				}, "skipping the re-encryption of the cluster secrets")
+				continue
+			}
+			return count, err
+		}
+		count++
+	}
+	subscriptions, err := s.Repositories().Subscriptions().List(ctx, nil)
+	if err != nil {
+		return count, err
+	}
+	for _, subscription := range subscriptions {
+		if subscription.SecretKeyID == encryptor.KeyID() {
+			continue
+		}
+		previousKeyID := subscription.SecretKeyID
+		if subscription.Secret, err = encryptor.Decrypt(previousKeyID, subscription.Secret); err != nil {
+			return count, errors.NewInternalErrorFromString(fmt.Sprintf("unable to decrypt the secret of subscription '%s': %v", subscription.ID, err))
+		}
+		if subscription.Secret, err = encryptor.Encrypt(subscription.Secret); err != nil {
+			return count, errors.NewInternalErrorFromString(fmt.Sprintf("unable to encrypt the secret of subscription '%s': %v", subscription.ID, err))
+		}
+		subscription.SecretKeyID = encryptor.KeyID()
+		err := s.ExecuteInTransaction(func() error {
+			return s.Repositories().Subscriptions().UpdateSecret(ctx, &subscription, previousKeyID)
+		})
+		if err != nil {
+			if _, conflict := errs.Cause(err).(errors.DataConflictError); conflict {
+				log.Warn(ctx, map[string]interface{}{
+					"subscription_id": subscription.ID.String(),
+					"err":             err,
+				}, "skipping the re-encryption of the webhook subscription secret")
+				continue
+			}
+			return count, err
+		}
+		count++
+	}
+	log.Info(ctx, map[string]interface{}{
+		"key_id": encryptor.KeyID(),
+		"count":  count,
+	}, "cluster and webhook subscription secrets re-encrypted")
+	return count, nil
+}
+
+// PlaceIdentity returns the cluster on which the given identity should be provisioned, without the sensitive info (token, etc.)
+// If the identity is already linked to an active cluster (with the matching type, if specified), then this cluster is returned,
+// even if its capacity is exhausted since the identity is already counted in it. The links to the clusters which are not active
+// (ie, provisioning, draining or decommissioned) are ignored, so that the identity is placed on another cluster.
+// Otherwise, the cluster is selected among the active ones whose capacity is not exhausted, using the given placement strategy
+// (or the default one if none is specified) and restricted to the clusters of the given type (if specified).
+// This method is allowed for the following service accounts:
+// - Auth
+// - Tenant
+// returns a NotFoundError error if no cluster is available, or a BadParameterError if the strategy is unknown
+func (s clusterService) PlaceIdentity(ctx context.Context, identityID uuid.UUID, clusterType, strategyName *string) (*repository.Cluster, error) {
+	if !auth.IsSpecificServiceAccount(ctx, auth.Auth, auth.Tenant) {
+		return nil, errors.NewUnauthorizedError("unauthorized access to cluster placement")
+	}
+	name := s.config.GetClusterPlacementStrategy()
+	if strategyName != nil {
+		name = *strategyName
+	}
+	strategy, err := NewPlacementStrategy(name, s.config.GetClusterPlacementWeights())
+	if err != nil {
+		return nil, err
+	}
+	if clusterType != nil {
+		strategy = NewTypeRestrictedStrategy(*clusterType, strategy)
+	}
+	// check if the identity was already placed on a cluster
+	linked, err := s.Repositories().IdentityClusters().ListClustersForIdentity(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range linked {
+		if c.State != repository.ClusterStateActive {
+			continue
+		}
+		if clusterType == nil || c.Type == *clusterType {
+			result := c
+			hideSensitiveInfo(&result)
+			return &result, nil
+		}
+	}
+	// only the active clusters accept new identities
+	clusters, err := s.Repositories().Clusters().List(ctx, nil, repository.ClusterStateActive)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]repository.Cluster, 0, len(clusters))
+	for _, c := range clusters {
+		if !c.CapacityExhausted {
+			candidates = append(candidates, c)
+		}
+	}
+	loads, err := s.Repositories().IdentityClusters().CountIdentitiesByCluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := strategy.Select(candidates, loads)
+	if err != nil {
+		return nil, err
 	}
-	return s.Repositories().Clusters().List(ctx, clusterType)
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID.String(),
+		"cluster_url": selected.URL,
+		"strategy":    name,
+	}, "selected cluster for identity")
+	result := *selected
+	hideSensitiveInfo(&result)
+	return &result, nil
 }
@@ -5,16 +5,25 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/fabric8-services/fabric8-cluster/application/service"
+	"github.com/fabric8-services/fabric8-cluster/application/service/factory"
 	"github.com/fabric8-services/fabric8-cluster/cluster"
 	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	clusterservice "github.com/fabric8-services/fabric8-cluster/cluster/service"
 	"github.com/fabric8-services/fabric8-cluster/configuration"
 	"github.com/fabric8-services/fabric8-cluster/gormapplication"
 	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
 	"github.com/fabric8-services/fabric8-cluster/test"
+	webhookrepository "github.com/fabric8-services/fabric8-cluster/webhook/repository"
 	"github.com/fabric8-services/fabric8-common/auth"
 	"github.com/fabric8-services/fabric8-common/errors"
 	"github.com/fabric8-services/fabric8-common/httpsupport"
@@ -38,7 +47,7 @@ type ClusterServiceTestSuite struct {
 
 func (s *ClusterServiceTestSuite) TestCreateOrSaveClusterFromConfigOK() {
 	// when
-	err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+	_, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
 	// then
 	require.NoError(s.T(), err)
 	// lookup OSO clusters
@@ -53,8 +62,20 @@ func (s *ClusterServiceTestSuite) TestCreateOrSaveClusterFromConfigOK() {
 	})
 	require.NoError(s.T(), err)
 	assert.Len(s.T(), osdClusters, 1)
-	// verify all records
-	verifyClusters(s.T(), s.Configuration.GetClusters(), append(osoClusters, osdClusters...), true)
+	// verify all records (with their secrets decrypted)
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+	clusters, _, err := s.Application.ClusterService().ListForAuth(ctx, repository.ClusterListOptions{})
+	require.NoError(s.T(), err)
+	verifyClusters(s.T(), s.Configuration.GetClusters(), clusters, true)
+	// and verify that the secrets are encrypted in the DB
+	for _, stored := range append(osoClusters, osdClusters...) {
+		assert.Equal(s.T(), "dev", stored.SecretsKeyID)
+		c, err := test.FilterClusterByURL(stored.URL, clusters)
+		require.NoError(s.T(), err)
+		assert.NotEqual(s.T(), c.SAToken, stored.SAToken)
+		assert.NotEqual(s.T(), c.AuthClientSecret, stored.AuthClientSecret)
+	}
 }
 
 func (s *ClusterServiceTestSuite) TestMiddleClusterRemovedFromConfig() {
@@ -76,10 +97,10 @@ func (s *ClusterServiceTestSuite) checkUpdateFromConfigOK(configFileWithRemovedC
 	db := gormapplication.NewGormDB(s.DB, cd)
 	cs := db.ClusterService()
 	// when
-	err = cs.CreateOrSaveClusterFromConfig(ctx)
+	_, err = cs.CreateOrSaveClusterFromConfig(ctx)
 	// then
 	require.NoError(s.T(), err)
-	clusters, err := cs.ListForAuth(ctx, nil)
+	clusters, _, err := cs.ListForAuth(ctx, repository.ClusterListOptions{})
 	require.NoError(s.T(), err)
 	verifyClusters(s.T(), cd.GetClusters(), clusters, true)
 
@@ -89,13 +110,537 @@ func (s *ClusterServiceTestSuite) checkUpdateFromConfigOK(configFileWithRemovedC
 	db = gormapplication.NewGormDB(s.DB, cd)
 	cs = db.ClusterService()
 	// when
-	err = cs.CreateOrSaveClusterFromConfig(ctx)
+	_, err = cs.CreateOrSaveClusterFromConfig(ctx)
 	// then
 	require.NoError(s.T(), err)
-	clusters, err = cs.ListForAuth(ctx, nil)
+	clusters, _, err = cs.ListForAuth(ctx, repository.ClusterListOptions{})
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), len(cd.GetClusters()), len(clusters))
 	verifyClusters(s.T(), cd.GetClusters(), clusters, true)
+	// and the clusters removed from the configuration were decommissioned
+	clusters, _, err = cs.ListForAuth(ctx, repository.ClusterListOptions{States: []string{repository.ClusterStateDecommissioned}})
+	require.NoError(s.T(), err)
+	assert.NotEmpty(s.T(), clusters)
+	for _, c := range clusters {
+		assert.NotContains(s.T(), cd.GetClusters(), c.URL)
+	}
+}
+
+func (s *ClusterServiceTestSuite) TestCreateOrSaveClusterFromConfigPlan() {
+	// given the default configuration
+	cd, err := configuration.NewConfigurationData("", "./../../configuration/conf-files/oso-clusters.conf")
+	require.NoError(s.T(), err)
+	cs := gormapplication.NewGormDB(s.DB, cd).ClusterService()
+
+	s.T().Run("creates", func(t *testing.T) {
+		// when
+		result, err := cs.CreateOrSaveClusterFromConfig(context.Background())
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.True(t, result.Applied)
+		assert.NoError(t, result.Error)
+		assert.Equal(t, configuration.ClusterConfigSyncPolicyAuthoritative, result.Policy)
+		assert.Len(t, result.Plan.Creates, len(cd.GetClusters()))
+		assert.Empty(t, result.Plan.Updates)
+		assert.Empty(t, result.Plan.Decommissions)
+		for _, c := range result.Plan.Creates {
+			assert.NotEqual(t, uuid.Nil, c.ClusterID)
+			stored, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, stored.URL, c.URL)
+		}
+		// and the result is the last one
+		assert.Equal(t, result, cs.LastClusterConfigSync())
+	})
+
+	s.T().Run("updates", func(t *testing.T) {
+		// when
+		result, err := cs.CreateOrSaveClusterFromConfig(context.Background())
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.True(t, result.Applied)
+		assert.Empty(t, result.Plan.Creates)
+		assert.Len(t, result.Plan.Updates, len(cd.GetClusters()))
+		assert.Empty(t, result.Plan.Decommissions)
+		assert.Equal(t, result, cs.LastClusterConfigSync())
+	})
+
+	s.T().Run("all or nothing", func(t *testing.T) {
+		// given a configuration with two new clusters, and without the existing clusters
+		invalid, err := configuration.NewConfigurationData("", "./../../configuration/conf-files/tests/oso-clusters-with-new-clusters.conf")
+		require.NoError(t, err)
+		// and no limit on the decommissions
+		config := clusterConfigSyncLimitsConfig{ConfigurationData: invalid, maxDecommissions: -1, maxIdentityLinksLosses: -1}
+		// and a DB constraint which makes the creation of the second cluster fail
+		err = s.DB.Exec("ALTER TABLE cluster ADD CONSTRAINT test_reject_cluster CHECK (url <> 'https://api.starter-us-east-4b.openshift.com/')").Error
+		require.NoError(t, err)
+		defer func() {
+			err := s.DB.Exec("ALTER TABLE cluster DROP CONSTRAINT test_reject_cluster").Error
+			require.NoError(t, err)
+		}()
+		// when
+		result, err := gormapplication.NewGormDB(s.DB, invalid, factory.WithClusterServiceConfiguration(config)).ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+		// then
+		require.Error(t, err)
+		require.NotNil(t, result)
+		assert.False(t, result.Applied)
+		assert.Equal(t, err, result.Error)
+		assert.Len(t, result.Plan.Creates, 2)
+		assert.Len(t, result.Plan.Decommissions, len(cd.GetClusters()))
+		assert.Equal(t, result, cs.LastClusterConfigSync())
+		// and none of the changes was applied
+		_, err = s.Application.Clusters().FindByURL(context.Background(), "https://api.starter-us-east-4a.openshift.com/")
+		testsupport.AssertError(t, err, errors.NotFoundError{}, "cluster with url 'https://api.starter-us-east-4a.openshift.com/' not found")
+		for _, c := range cd.GetClusters() {
+			stored, err := s.Application.Clusters().FindByURL(context.Background(), httpsupport.AddTrailingSlashToURL(c.URL))
+			require.NoError(t, err)
+			assert.NotEqual(t, repository.ClusterStateDecommissioned, stored.State)
+		}
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestValidateClusterConfig() {
+	// given the clusters of the default configuration
+	cd, err := configuration.NewConfigurationData("", "./../../configuration/conf-files/oso-clusters.conf")
+	require.NoError(s.T(), err)
+	cs := gormapplication.NewGormDB(s.DB, cd).ClusterService()
+	_, err = cs.CreateOrSaveClusterFromConfig(context.Background())
+	require.NoError(s.T(), err)
+	removed, err := s.Application.Clusters().FindByURL(context.Background(), "https://api.starter-us-east-1a.openshift.com/")
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("updates", func(t *testing.T) {
+			// when
+			plan, err := cs.ValidateClusterConfig(context.Background(), "./../../configuration/conf-files/tests/oso-clusters-capacity-updated.conf")
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, plan.Creates)
+			assert.Empty(t, plan.Decommissions)
+			require.Len(t, plan.Updates, 4)
+			changed := 0
+			for _, c := range plan.Updates {
+				if len(c.Changes) > 0 {
+					changed++
+					assert.Contains(t, c.Changes, "capacity-exhausted")
+				}
+			}
+			assert.Equal(t, 2, changed)
+			// and nothing was applied
+			c, err := s.Application.Clusters().FindByURL(context.Background(), "https://api.starter-us-east-2a.openshift.com/")
+			require.NoError(t, err)
+			assert.False(t, c.CapacityExhausted)
+		})
+
+		t.Run("decommissions", func(t *testing.T) {
+			// given
+			test.CreateIdentityCluster(t, s.DB, test.WithCluster(*removed))
+			test.CreateIdentityCluster(t, s.DB, test.WithCluster(*removed))
+			// when
+			plan, err := cs.ValidateClusterConfig(context.Background(), "./../../configuration/conf-files/tests/oso-clusters-with-removed-clusters.conf")
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, plan.Creates)
+			assert.Len(t, plan.Updates, 3)
+			require.Len(t, plan.Decommissions, 1)
+			assert.Equal(t, removed.ClusterID, plan.Decommissions[0].ClusterID)
+			assert.Equal(t, 2, plan.Decommissions[0].IdentitiesCount)
+			// and nothing was applied
+			c, err := s.Application.Clusters().Load(context.Background(), removed.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterStateActive, c.State)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("invalid cluster", func(t *testing.T) {
+			// when
+			_, err := cs.ValidateClusterConfig(context.Background(), "./../../configuration/conf-files/tests/oso-clusters-invalid-capacity-threshold.conf")
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid capacity-threshold: 150 (expected a percentage between 0 and 100)")
+		})
+
+		t.Run("missing keys", func(t *testing.T) {
+			// when
+			_, err := cs.ValidateClusterConfig(context.Background(), "./../../configuration/conf-files/tests/oso-clusters-missing-keys.conf")
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "is missing in cluster config")
+		})
+
+		t.Run("too many decommissions", func(t *testing.T) {
+			// given
+			config := clusterConfigSyncLimitsConfig{ConfigurationData: cd, maxDecommissions: 0, maxIdentityLinksLosses: -1}
+			cs := gormapplication.NewGormDB(s.DB, cd, factory.WithClusterServiceConfiguration(config)).ClusterService()
+			// when
+			_, err := cs.ValidateClusterConfig(context.Background(), "./../../configuration/conf-files/tests/oso-clusters-with-removed-clusters.conf")
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "refusing to decommission 1 clusters removed from the config file (at most 0 allowed)")
+		})
+
+		t.Run("too many identity links losses", func(t *testing.T) {
+			// given
+			config := clusterConfigSyncLimitsConfig{ConfigurationData: cd, maxDecommissions: -1, maxIdentityLinksLosses: 1}
+			cs := gormapplication.NewGormDB(s.DB, cd, factory.WithClusterServiceConfiguration(config)).ClusterService()
+			// when
+			_, err := cs.ValidateClusterConfig(context.Background(), "./../../configuration/conf-files/tests/oso-clusters-with-removed-clusters.conf")
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "refusing to decommission clusters removed from the config file with 2 linked identities (at most 1 allowed)")
+		})
+
+		t.Run("thresholds also apply to the reload", func(t *testing.T) {
+			// given
+			updated, err := configuration.NewConfigurationData("", "./../../configuration/conf-files/tests/oso-clusters-with-removed-clusters.conf")
+			require.NoError(t, err)
+			config := clusterConfigSyncLimitsConfig{ConfigurationData: updated, maxDecommissions: 0, maxIdentityLinksLosses: -1}
+			// when
+			result, err := gormapplication.NewGormDB(s.DB, updated, factory.WithClusterServiceConfiguration(config)).ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+			// then
+			require.Error(t, err)
+			assert.False(t, result.Applied)
+			c, err := s.Application.Clusters().Load(context.Background(), removed.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterStateActive, c.State)
+		})
+
+		t.Run("unknown file", func(t *testing.T) {
+			// when
+			_, err := cs.ValidateClusterConfig(context.Background(), "./../../configuration/conf-files/tests/unknown.conf")
+			// then
+			require.Error(t, err)
+		})
+	})
+}
+
+// clusterConfigSyncLimitsConfig a configuration with custom limits for the synchronization of the clusters with the config file
+type clusterConfigSyncLimitsConfig struct {
+	*configuration.ConfigurationData
+	maxDecommissions       int
+	maxIdentityLinksLosses int
+}
+
+func (c clusterConfigSyncLimitsConfig) GetClusterConfigSyncMaxDecommissions() int {
+	return c.maxDecommissions
+}
+
+func (c clusterConfigSyncLimitsConfig) GetClusterConfigSyncMaxIdentityLinksLosses() int {
+	return c.maxIdentityLinksLosses
+}
+
+func (s *ClusterServiceTestSuite) TestReloadClusterConfig() {
+	// do not decommission the clusters created by the other tests
+	existingPolicy := os.Getenv("F8_CLUSTER_CONFIG_SYNC_POLICY")
+	defer os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", existingPolicy)
+	os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", configuration.ClusterConfigSyncPolicyMergeOnly)
+	// given a configuration loaded from a temp file
+	tmpFileName := createTempClusterConfigFile(s.T())
+	defer os.Remove(tmpFileName)
+	config, err := configuration.NewConfigurationData("", tmpFileName)
+	require.NoError(s.T(), err)
+	cs := gormapplication.NewGormDB(s.DB, config).ClusterService()
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("api", func(t *testing.T) {
+			// given
+			updateClusterConfigFile(t, tmpFileName, "./configuration/conf-files/tests/oso-clusters-capacity-updated.conf")
+			defer updateClusterConfigFile(t, tmpFileName, "./configuration/conf-files/oso-clusters.conf")
+			ctx, err := createContext(auth.ToolChainOperator)
+			require.NoError(t, err)
+			// when
+			result, err := cs.ReloadClusterConfig(ctx, repository.ClusterConfigReloadTriggerAPI)
+			// then
+			require.NoError(t, err)
+			require.NotNil(t, result)
+			assert.True(t, result.Applied)
+			assert.Equal(t, configuration.ClusterConfigSyncPolicyMergeOnly, result.Policy)
+			assert.Len(t, append(result.Plan.Creates, result.Plan.Updates...), 4)
+			// the configuration was reloaded and the clusters were saved
+			assert.True(t, config.GetClusterByURL("https://api.starter-us-east-2a.openshift.com").CapacityExhausted)
+			c, err := s.Application.Clusters().FindByURL(context.Background(), "https://api.starter-us-east-2a.openshift.com/")
+			require.NoError(t, err)
+			assert.True(t, c.CapacityExhausted)
+		})
+
+		t.Run("signal", func(t *testing.T) {
+			// when the reload is triggered without any service account
+			result, err := cs.ReloadClusterConfig(context.Background(), repository.ClusterConfigReloadTriggerSignal)
+			// then
+			require.NoError(t, err)
+			require.NotNil(t, result)
+			assert.True(t, result.Applied)
+			assert.False(t, config.GetClusterByURL("https://api.starter-us-east-2a.openshift.com").CapacityExhausted)
+		})
+
+		t.Run("concurrent triggers", func(t *testing.T) {
+			// given
+			var wg sync.WaitGroup
+			errs := make(chan error, 10)
+			// when
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := cs.ReloadClusterConfig(context.Background(), repository.ClusterConfigReloadTriggerSignal)
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			// then
+			for err := range errs {
+				assert.NoError(t, err)
+			}
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth} {
+				t.Run(username, func(t *testing.T) {
+					// given
+					ctx, err := createContext(username)
+					require.NoError(t, err)
+					// when
+					_, err = cs.ReloadClusterConfig(ctx, repository.ClusterConfigReloadTriggerAPI)
+					// then
+					testsupport.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to reload the cluster configuration")
+				})
+			}
+		})
+
+		t.Run("invalid config file", func(t *testing.T) {
+			// given
+			updateClusterConfigFile(t, tmpFileName, "./configuration/conf-files/tests/oso-clusters-invalid-capacity-threshold.conf")
+			defer updateClusterConfigFile(t, tmpFileName, "./configuration/conf-files/oso-clusters.conf")
+			// when
+			result, err := cs.ReloadClusterConfig(context.Background(), repository.ClusterConfigReloadTriggerSignal)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, err)
+			require.NotNil(t, result)
+			assert.False(t, result.Applied)
+			assert.Equal(t, err, result.Error)
+			// and the configuration was not reloaded
+			assert.Len(t, config.GetClusters(), 4)
+		})
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestLeaderElection() {
+	// do not decommission the clusters created by the other tests
+	existingPolicy := os.Getenv("F8_CLUSTER_CONFIG_SYNC_POLICY")
+	existingDuration := os.Getenv("F8_LEADER_ELECTION_LEASE_DURATION")
+	existingInterval := os.Getenv("F8_LEADER_ELECTION_RENEW_INTERVAL")
+	defer func() {
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", existingPolicy)
+		os.Setenv("F8_LEADER_ELECTION_LEASE_DURATION", existingDuration)
+		os.Setenv("F8_LEADER_ELECTION_RENEW_INTERVAL", existingInterval)
+	}()
+	os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", configuration.ClusterConfigSyncPolicyMergeOnly)
+	os.Setenv("F8_LEADER_ELECTION_LEASE_DURATION", "1s")
+	os.Setenv("F8_LEADER_ELECTION_RENEW_INTERVAL", "100ms")
+	const leaseName = "fabric8-cluster"
+
+	s.T().Run("disabled", func(t *testing.T) {
+		// given
+		os.Setenv("F8_LEADER_ELECTION_RENEW_INTERVAL", "0")
+		defer os.Setenv("F8_LEADER_ELECTION_RENEW_INTERVAL", "100ms")
+		config, err := configuration.GetConfigurationData()
+		require.NoError(t, err)
+		cs := gormapplication.NewGormDB(s.DB, config).ClusterService()
+		// when
+		halt := cs.InitializeLeaderElection(nil)
+		defer halt()
+		// then
+		assert.True(t, cs.IsLeader())
+		assert.False(t, cs.Leadership().Enabled)
+		_, err = s.Application.LeaderLeases().Load(context.Background(), leaseName)
+		testsupport.AssertError(t, err, errors.NotFoundError{}, "leader_lease with id 'fabric8-cluster' not found")
+	})
+
+	s.T().Run("leader", func(t *testing.T) {
+		// given
+		config, err := configuration.GetConfigurationData()
+		require.NoError(t, err)
+		cs := gormapplication.NewGormDB(s.DB, config).ClusterService()
+		// when
+		halt := cs.InitializeLeaderElection(nil)
+		// then
+		assert.True(t, cs.IsLeader())
+		leadership := cs.Leadership()
+		assert.True(t, leadership.Enabled)
+		assert.True(t, leadership.Leader)
+		assert.Equal(t, leadership.Identity, leadership.Holder)
+		require.NotNil(t, leadership.ExpiresAt)
+		lease, err := s.Application.LeaderLeases().Load(context.Background(), leaseName)
+		require.NoError(t, err)
+		assert.Equal(t, leadership.Identity, lease.Holder)
+		// when the election is stopped
+		halt()
+		// then the lease is released
+		_, err = s.Application.LeaderLeases().Load(context.Background(), leaseName)
+		testsupport.AssertError(t, err, errors.NotFoundError{}, "leader_lease with id 'fabric8-cluster' not found")
+	})
+
+	s.T().Run("follower", func(t *testing.T) {
+		// given a lease held by another replica
+		_, err := s.Application.LeaderLeases().Acquire(context.Background(), leaseName, "other-replica", time.Minute)
+		require.NoError(t, err)
+		defer s.Application.LeaderLeases().Release(context.Background(), leaseName, "other-replica")
+		config, err := configuration.GetConfigurationData()
+		require.NoError(t, err)
+		cs := gormapplication.NewGormDB(s.DB, config).ClusterService()
+		// when
+		halt := cs.InitializeLeaderElection(nil)
+		defer halt()
+		// then
+		assert.False(t, cs.IsLeader())
+		leadership := cs.Leadership()
+		assert.True(t, leadership.Enabled)
+		assert.False(t, leadership.Leader)
+		assert.Equal(t, "other-replica", leadership.Holder)
+		// and the clusters are not reconciled with the reloaded config file
+		reloaded, err := cs.ReloadClusterConfig(context.Background(), repository.ClusterConfigReloadTriggerSignal)
+		testsupport.AssertError(t, err, errors.DataConflictError{}, "this replica is not the leader (the leader is 'other-replica')")
+		require.NotNil(t, reloaded)
+		assert.False(t, reloaded.Applied)
+		assert.Equal(t, err, reloaded.Error)
+		// nor when the reconciliation is requested directly
+		result, err := cs.CreateOrSaveClusterFromConfig(context.Background())
+		testsupport.AssertError(t, err, errors.DataConflictError{}, "this replica is not the leader")
+		require.NotNil(t, result)
+		assert.False(t, result.Applied)
+	})
+
+	s.T().Run("failover", func(t *testing.T) {
+		// given a lease held by another replica which stopped renewing it
+		_, err := s.Application.LeaderLeases().Acquire(context.Background(), leaseName, "other-replica", 300*time.Millisecond)
+		require.NoError(t, err)
+		config, err := configuration.GetConfigurationData()
+		require.NoError(t, err)
+		cs := gormapplication.NewGormDB(s.DB, config).ClusterService()
+		elected := make(chan struct{})
+		// when
+		halt := cs.InitializeLeaderElection(func() {
+			close(elected)
+		})
+		defer halt()
+		assert.False(t, cs.IsLeader())
+		// then
+		waitForLeadership(t, cs)
+		assert.Equal(t, cs.Leadership().Identity, cs.Leadership().Holder)
+		// and the callback was called once the replica took over
+		select {
+		case <-elected:
+		case <-time.After(3 * time.Second):
+			require.Fail(t, "callback was not called within 3s after the replica took over")
+		}
+	})
+
+	s.T().Run("fencing", func(t *testing.T) {
+		// given a leader whose lease was taken over by another replica in the mean time (eg: after a long pause)
+		config, err := configuration.GetConfigurationData()
+		require.NoError(t, err)
+		cs := gormapplication.NewGormDB(s.DB, config).ClusterService()
+		halt := cs.InitializeLeaderElection(nil)
+		defer halt()
+		require.True(t, cs.IsLeader())
+		err = s.DB.Exec("UPDATE leader_lease SET holder = 'other-replica' WHERE name = ?", leaseName).Error
+		require.NoError(t, err)
+		defer s.Application.LeaderLeases().Release(context.Background(), leaseName, "other-replica")
+		// when
+		result, err := cs.CreateOrSaveClusterFromConfig(context.Background())
+		// then the clusters are not reconciled
+		testsupport.AssertError(t, err, errors.DataConflictError{}, "this replica is not the leader")
+		require.NotNil(t, result)
+		assert.False(t, result.Applied)
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestCreateOrSaveClusterFromConfigWithSyncPolicy() {
+	existing := os.Getenv("F8_CLUSTER_CONFIG_SYNC_POLICY")
+	defer func() {
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", existing)
+	}()
+
+	s.T().Run("authoritative", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", configuration.ClusterConfigSyncPolicyAuthoritative)
+		apiCluster := test.CreateCluster(t, s.DB, test.WithOrigin(repository.ClusterOriginAPI))
+		configCluster := test.CreateCluster(t, s.DB, test.WithOrigin(repository.ClusterOriginConfig))
+		// when
+		_, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+		// then
+		require.NoError(t, err)
+		// the cluster registered through the API was left untouched
+		c, err := s.Application.Clusters().Load(context.Background(), apiCluster.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterStateActive, c.State)
+		assert.Equal(t, repository.ClusterOriginAPI, c.Origin)
+		// the cluster which originates from the config file but which is not declared in it anymore was decommissioned
+		c, err = s.Application.Clusters().Load(context.Background(), configCluster.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterStateDecommissioned, c.State)
+		// and the clusters declared in the config file are owned by it
+		for _, declared := range s.Configuration.GetClusters() {
+			c, err := s.Application.Clusters().FindByURL(context.Background(), httpsupport.AddTrailingSlashToURL(declared.URL))
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterOriginConfig, c.Origin)
+		}
+	})
+
+	s.T().Run("merge-only", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", configuration.ClusterConfigSyncPolicyMergeOnly)
+		configCluster := test.CreateCluster(t, s.DB, test.WithOrigin(repository.ClusterOriginConfig))
+		// when
+		_, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+		// then
+		require.NoError(t, err)
+		// the cluster which is not declared in the config file anymore was not decommissioned
+		c, err := s.Application.Clusters().Load(context.Background(), configCluster.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterStateActive, c.State)
+		// but the clusters declared in the config file were created or updated
+		for _, declared := range s.Configuration.GetClusters() {
+			_, err := s.Application.Clusters().FindByURL(context.Background(), httpsupport.AddTrailingSlashToURL(declared.URL))
+			require.NoError(t, err)
+		}
+	})
+
+	s.T().Run("disabled", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", configuration.ClusterConfigSyncPolicyDisabled)
+		configCluster := test.CreateCluster(t, s.DB, test.WithOrigin(repository.ClusterOriginConfig),
+			test.WithMaxIdentities(10, 80))
+		// when
+		_, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+		// then
+		require.NoError(t, err)
+		// the cluster was neither updated nor decommissioned
+		c, err := s.Application.Clusters().Load(context.Background(), configCluster.ClusterID)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, configCluster, *c, true)
+	})
+
+	s.T().Run("invalid", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", "foo")
+		// when
+		_, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid cluster config sync policy: 'foo'")
+	})
 }
 
 func (s *ClusterServiceTestSuite) TestCreateOrSaveCluster() {
@@ -470,40 +1015,380 @@ func (s *ClusterServiceTestSuite) TestCreateOrSaveCluster() {
 				// then
 				testsupport.AssertError(t, err, errors.BadParameterError{}, fmt.Sprintf("failed to create or save cluster named '%s': invalid type of cluster: '%s' (expected 'OSO', 'OCP' or 'OSD')", c.Name, c.Type))
 			})
-		})
-	})
-}
-
-func (s *ClusterServiceTestSuite) TestLoad() {
 
-	s.T().Run("ok", func(t *testing.T) {
-		// given
-		c := test.CreateCluster(t, s.DB)
+			t.Run("negative max-identities", func(t *testing.T) {
+				// given
+				c := newTestCluster()
+				c.MaxIdentities = -1
+				// when
+				err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+				// then
+				testsupport.AssertError(t, err, errors.BadParameterError{}, fmt.Sprintf("failed to create or save cluster named '%s': invalid max-identities: -1 (expected a positive value, or 0 for no limit)", c.Name))
+			})
 
-		for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth} {
-			t.Run(username, func(t *testing.T) {
+			t.Run("invalid capacity-threshold", func(t *testing.T) {
 				// given
-				ctx, err := createContext(username)
-				require.NoError(t, err)
+				c := newTestCluster()
+				c.MaxIdentities = 10
+				c.CapacityThreshold = 101
 				// when
-				result, err := s.Application.ClusterService().Load(ctx, c.ClusterID)
+				err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
 				// then
-				require.NoError(t, err)
-				require.NotNil(t, result)
-				test.AssertEqualCluster(t, c, *result, false)
+				testsupport.AssertError(t, err, errors.BadParameterError{}, fmt.Sprintf("failed to create or save cluster named '%s': invalid capacity-threshold: 101 (expected a percentage between 0 and 100)", c.Name))
 			})
-		}
+		})
 	})
+}
 
-	s.T().Run("failures", func(t *testing.T) {
-
-		t.Run("unauthorized", func(t *testing.T) {
-			// given
-			c := test.CreateCluster(t, s.DB)
+func (s *ClusterServiceTestSuite) TestCreateOrSaveClusterState() {
+	ctx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
 
-			for _, username := range []string{auth.ToolChainOperator, "other"} {
-				t.Run(username, func(t *testing.T) {
-					// given
+	s.T().Run("decommissioned cluster not revived without state", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		err = s.Application.ClusterService().Delete(ctx, c.ClusterID)
+		require.NoError(t, err)
+		// when
+		saved := newTestCluster()
+		saved.URL = c.URL
+		err = s.Application.ClusterService().CreateOrSaveCluster(ctx, saved)
+		// then
+		testsupport.AssertError(t, err, errors.BadParameterError{}, fmt.Sprintf("cluster with url '%s' is decommissioned, its state must be set to revive it", httpsupport.AddTrailingSlashToURL(c.URL)))
+		loaded, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterStateDecommissioned, loaded.State)
+
+		t.Run("revived with explicit state", func(t *testing.T) {
+			// given
+			saved.State = repository.ClusterStateProvisioning
+			// when
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, saved)
+			// then
+			require.NoError(t, err)
+			loaded, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterStateProvisioning, loaded.State)
+		})
+	})
+
+	s.T().Run("invalid state transition", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		// when
+		saved := *c
+		saved.State = repository.ClusterStateProvisioning
+		err = s.Application.ClusterService().CreateOrSaveCluster(ctx, &saved)
+		// then
+		testsupport.AssertError(t, err, errors.BadParameterError{}, "invalid state transition of cluster from 'active' to 'provisioning'")
+	})
+
+	s.T().Run("decommissioned cluster not revived from the config file", func(t *testing.T) {
+		// given
+		_, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+		require.NoError(t, err)
+		var declared repository.Cluster
+		for _, declared = range s.Configuration.GetClusters() {
+			break
+		}
+		c, err := s.Application.Clusters().FindByURL(context.Background(), httpsupport.AddTrailingSlashToURL(declared.URL))
+		require.NoError(t, err)
+		err = s.Application.ClusterService().Delete(ctx, c.ClusterID)
+		require.NoError(t, err)
+		// when
+		result, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+		// then the synchronization of the other clusters is not blocked
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.True(t, result.Applied)
+		assert.Empty(t, result.Plan.Decommissions)
+		// and the cluster keeps its state
+		var update *repository.ClusterConfigSyncChange
+		for i := range result.Plan.Updates {
+			if result.Plan.Updates[i].ClusterID == c.ClusterID {
+				update = &result.Plan.Updates[i]
+			}
+		}
+		require.NotNil(t, update)
+		assert.NotContains(t, update.Changes, "state")
+		loaded, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterStateDecommissioned, loaded.State)
+		// revive the cluster, so the config file can be synchronized in other tests
+		loaded.State = repository.ClusterStateActive
+		err = s.Application.Clusters().Save(context.Background(), loaded)
+		require.NoError(t, err)
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestCreateOrSaveClusterOrigin() {
+	ctx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
+
+	s.T().Run("new cluster originates from the api", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		// when
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterOriginAPI, c.Origin)
+		stored, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterOriginAPI, stored.Origin)
+	})
+
+	s.T().Run("updated cluster keeps its origin", func(t *testing.T) {
+		// given
+		existing := test.CreateCluster(t, s.DB, test.WithOrigin(repository.ClusterOriginConfig), test.WithValidURLs())
+		c := newTestCluster()
+		c.URL = existing.URL
+		// when
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, existing.ClusterID, c.ClusterID)
+		assert.Equal(t, repository.ClusterOriginConfig, c.Origin)
+		stored, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterOriginConfig, stored.Origin)
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestPatchCluster() {
+
+	ctx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("single field", func(t *testing.T) {
+			// given
+			c := newTestCluster()
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+			require.NoError(t, err)
+			stored, err := s.Application.Clusters().Load(ctx, c.ClusterID)
+			require.NoError(t, err)
+			exhausted := false
+			// when
+			result, err := s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, repository.ClusterPatch{
+				CapacityExhausted: &exhausted,
+			})
+			// then
+			require.NoError(t, err)
+			c.CapacityExhausted = false
+			test.AssertEqualCluster(t, *c, *result, false)
+			// also verify that the sensitive info was left unchanged
+			authCtx, err := createContext(auth.Auth)
+			require.NoError(t, err)
+			loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+			require.NoError(t, err)
+			test.AssertEqualCluster(t, *c, *loaded, true)
+			// including the encrypted values in the DB
+			updated, err := s.Application.Clusters().Load(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, stored.SAToken, updated.SAToken)
+			assert.Equal(t, stored.AuthClientSecret, updated.AuthClientSecret)
+		})
+
+		t.Run("new secrets", func(t *testing.T) {
+			// given
+			c := newTestCluster()
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+			require.NoError(t, err)
+			token := "NewServiceAccountToken"
+			secret := "NewAuthClientSecret"
+			// when
+			_, err = s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, repository.ClusterPatch{
+				SAToken:          &token,
+				AuthClientSecret: &secret,
+			})
+			// then
+			require.NoError(t, err)
+			authCtx, err := createContext(auth.Auth)
+			require.NoError(t, err)
+			loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+			require.NoError(t, err)
+			c.SAToken = token
+			c.SATokenEncrypted = false // new token is not encrypted
+			c.AuthClientSecret = secret
+			test.AssertEqualCluster(t, *c, *loaded, true)
+		})
+
+		t.Run("new encrypted SA token", func(t *testing.T) {
+			// given
+			c := newTestCluster()
+			c.SATokenEncrypted = false
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+			require.NoError(t, err)
+			token := "NewEncryptedServiceAccountToken"
+			encrypted := true
+			// when
+			_, err = s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, repository.ClusterPatch{
+				SAToken:          &token,
+				SATokenEncrypted: &encrypted,
+			})
+			// then
+			require.NoError(t, err)
+			authCtx, err := createContext(auth.Auth)
+			require.NoError(t, err)
+			loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+			require.NoError(t, err)
+			c.SAToken = token
+			c.SATokenEncrypted = true
+			test.AssertEqualCluster(t, *c, *loaded, true)
+		})
+
+		t.Run("derived URLs and default token provider ID", func(t *testing.T) {
+			// given
+			c := newTestCluster()
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+			require.NoError(t, err)
+			name := uuid.NewV4().String()
+			apiURL := fmt.Sprintf("https://api.cluster.%s", name)
+			empty := ""
+			// when
+			result, err := s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, repository.ClusterPatch{
+				URL:             &apiURL,
+				ConsoleURL:      &empty,
+				TokenProviderID: &empty,
+			})
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, c.ClusterID, result.ClusterID)
+			assert.Equal(t, httpsupport.AddTrailingSlashToURL(apiURL), result.URL)
+			assert.Equal(t, fmt.Sprintf("https://console.cluster.%s/console/", name), result.ConsoleURL)
+			assert.Equal(t, c.MetricsURL, result.MetricsURL) // unchanged
+			loaded, err := s.Application.Clusters().Load(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, c.ClusterID.String(), loaded.TokenProviderID)
+		})
+
+		t.Run("state", func(t *testing.T) {
+			// given
+			c := newTestCluster()
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+			require.NoError(t, err)
+			state := repository.ClusterStateDraining
+			// when
+			result, err := s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, repository.ClusterPatch{
+				State: &state,
+			})
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterStateDraining, result.State)
+			loaded, err := s.Application.Clusters().Load(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterStateDraining, loaded.State)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Auth)
+			require.NoError(t, err)
+			name := "foo"
+			// when
+			_, err = s.Application.ClusterService().PatchCluster(ctx, uuid.NewV4(), repository.ClusterPatch{Name: &name})
+			// then
+			testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to cluster info")
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// given
+			id := uuid.NewV4()
+			name := "foo"
+			// when
+			_, err := s.Application.ClusterService().PatchCluster(ctx, id, repository.ClusterPatch{Name: &name})
+			// then
+			testsupport.AssertError(t, err, errors.NotFoundError{}, errors.NewNotFoundError("cluster", id.String()).Error())
+		})
+
+		t.Run("bad parameter", func(t *testing.T) {
+			// given
+			c := newTestCluster()
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+			require.NoError(t, err)
+			clusterType := "FOO"
+			// when
+			_, err = s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, repository.ClusterPatch{Type: &clusterType})
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, fmt.Sprintf("failed to patch cluster named '%s': invalid type of cluster: 'FOO' (expected 'OSO', 'OCP' or 'OSD')", c.Name))
+			// verify that the cluster was not updated
+			loaded, err := s.Application.Clusters().Load(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, c.Type, loaded.Type)
+		})
+
+		t.Run("conflict", func(t *testing.T) {
+			// given
+			c1 := newTestCluster()
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c1)
+			require.NoError(t, err)
+			c2 := newTestCluster()
+			err = s.Application.ClusterService().CreateOrSaveCluster(ctx, c2)
+			require.NoError(t, err)
+			// when
+			_, err = s.Application.ClusterService().PatchCluster(ctx, c2.ClusterID, repository.ClusterPatch{URL: &c1.URL})
+			// then
+			testsupport.AssertError(t, err, errors.DataConflictError{}, fmt.Sprintf("cluster with url '%s' already exists", c1.URL))
+		})
+
+		t.Run("invalid state transition", func(t *testing.T) {
+			// given
+			c := newTestCluster()
+			c.State = repository.ClusterStateProvisioning
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+			require.NoError(t, err)
+			state := repository.ClusterStateDraining
+			// when
+			_, err = s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, repository.ClusterPatch{State: &state})
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "invalid state transition of cluster from 'provisioning' to 'draining'")
+			// verify that the cluster was not updated
+			loaded, err := s.Application.Clusters().Load(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterStateProvisioning, loaded.State)
+		})
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestLoad() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+
+		for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				ctx, err := createContext(username)
+				require.NoError(t, err)
+				// when
+				result, err := s.Application.ClusterService().Load(ctx, c.ClusterID)
+				// then
+				require.NoError(t, err)
+				require.NotNil(t, result)
+				test.AssertEqualCluster(t, c, *result, false)
+			})
+		}
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			c := test.CreateCluster(t, s.DB)
+
+			for _, username := range []string{auth.ToolChainOperator, "other"} {
+				t.Run(username, func(t *testing.T) {
+					// given
 					ctx, err := createContext(username)
 					require.NoError(t, err)
 					// when
@@ -733,7 +1618,7 @@ func (s *ClusterServiceTestSuite) TestList() {
 					ctx, err := createContext(username)
 					require.NoError(t, err)
 					// when
-					result, err := s.Application.ClusterService().List(ctx, nil)
+					result, _, err := s.Application.ClusterService().List(ctx, repository.ClusterListOptions{})
 					// then
 					require.NoError(t, err)
 					expected, err := repository.NewClusterRepository(s.DB).List(ctx, nil)
@@ -750,7 +1635,7 @@ func (s *ClusterServiceTestSuite) TestList() {
 					ctx, err := createContext(username)
 					require.NoError(t, err)
 					// when
-					result, err := s.Application.ClusterService().List(ctx, &clusterType)
+					result, _, err := s.Application.ClusterService().List(ctx, repository.ClusterListOptions{Type: &clusterType})
 					// then
 					require.NoError(t, err)
 					expected, err := repository.NewClusterRepository(s.DB).List(ctx, &clusterType)
@@ -759,6 +1644,93 @@ func (s *ClusterServiceTestSuite) TestList() {
 				})
 			}
 		})
+
+		t.Run("by health", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Tenant)
+			require.NoError(t, err)
+			healthyCluster := test.CreateCluster(t, s.DB)
+			test.CreateClusterHealth(t, s.DB, healthyCluster, repository.HealthStateHealthy)
+			unhealthyCluster := test.CreateCluster(t, s.DB)
+			test.CreateClusterHealth(t, s.DB, unhealthyCluster, repository.HealthStateUnhealthy)
+			unprobedCluster := test.CreateCluster(t, s.DB)
+
+			t.Run("healthy", func(t *testing.T) {
+				// when
+				healthy := true
+				result, _, err := s.Application.ClusterService().List(ctx, repository.ClusterListOptions{Healthy: &healthy})
+				// then
+				require.NoError(t, err)
+				assert.Contains(t, clusterIDs(result), healthyCluster.ClusterID)
+				assert.NotContains(t, clusterIDs(result), unhealthyCluster.ClusterID)
+				assert.NotContains(t, clusterIDs(result), unprobedCluster.ClusterID)
+				for _, c := range result {
+					require.NotNil(t, c.Health)
+					assert.Equal(t, repository.HealthStateHealthy, c.Health.State)
+				}
+			})
+
+			t.Run("not healthy", func(t *testing.T) {
+				// when
+				healthy := false
+				result, _, err := s.Application.ClusterService().List(ctx, repository.ClusterListOptions{Healthy: &healthy})
+				// then
+				require.NoError(t, err)
+				assert.NotContains(t, clusterIDs(result), healthyCluster.ClusterID)
+				assert.Contains(t, clusterIDs(result), unhealthyCluster.ClusterID)
+				assert.Contains(t, clusterIDs(result), unprobedCluster.ClusterID)
+			})
+		})
+
+		t.Run("by state", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Tenant)
+			require.NoError(t, err)
+			activeCluster := test.CreateCluster(t, s.DB)
+			drainingCluster := test.CreateCluster(t, s.DB, test.WithState(repository.ClusterStateDraining))
+
+			t.Run("active by default", func(t *testing.T) {
+				// when
+				result, _, err := s.Application.ClusterService().List(ctx, repository.ClusterListOptions{})
+				// then
+				require.NoError(t, err)
+				assert.Contains(t, clusterIDs(result), activeCluster.ClusterID)
+				assert.NotContains(t, clusterIDs(result), drainingCluster.ClusterID)
+			})
+
+			t.Run("draining", func(t *testing.T) {
+				// when
+				state := repository.ClusterStateDraining
+				result, _, err := s.Application.ClusterService().List(ctx, repository.ClusterListOptions{States: []string{state}})
+				// then
+				require.NoError(t, err)
+				assert.NotContains(t, clusterIDs(result), activeCluster.ClusterID)
+				assert.Contains(t, clusterIDs(result), drainingCluster.ClusterID)
+			})
+		})
+
+		t.Run("paginated", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Tenant)
+			require.NoError(t, err)
+			clusterType := uuid.NewV4().String()
+			cluster1 := test.CreateCluster(t, s.DB, test.WithType(clusterType))
+			cluster2 := test.CreateCluster(t, s.DB, test.WithType(clusterType))
+			test.CreateCluster(t, s.DB, test.WithType(clusterType))
+			// when
+			result, total, err := s.Application.ClusterService().List(ctx, repository.ClusterListOptions{
+				Type:  &clusterType,
+				Limit: 2,
+			})
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 3, total)
+			assert.Equal(t, []uuid.UUID{cluster1.ClusterID, cluster2.ClusterID}, clusterIDs(result))
+			for _, c := range result {
+				assert.Empty(t, c.SAToken)
+				assert.Empty(t, c.AuthClientSecret)
+			}
+		})
 	})
 
 	s.T().Run("failures", func(t *testing.T) {
@@ -773,15 +1745,35 @@ func (s *ClusterServiceTestSuite) TestList() {
 					ctx, err := createContext(username)
 					require.NoError(t, err)
 					// when
-					_, err = s.Application.ClusterService().List(ctx, nil)
+					_, _, err = s.Application.ClusterService().List(ctx, repository.ClusterListOptions{})
 					// then
 					require.Error(t, err)
 					testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to clusters info")
 				})
 			}
 		})
+
+		t.Run("invalid state", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Tenant)
+			require.NoError(t, err)
+			state := "foo"
+			// when
+			_, _, err = s.Application.ClusterService().List(ctx, repository.ClusterListOptions{States: []string{state}})
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "invalid state of cluster: 'foo' (expected 'provisioning', 'active', 'draining' or 'decommissioned')")
+		})
 	})
 }
+
+func clusterIDs(clusters []repository.Cluster) []uuid.UUID {
+	result := make([]uuid.UUID, len(clusters))
+	for i, c := range clusters {
+		result[i] = c.ClusterID
+	}
+	return result
+}
+
 func (s *ClusterServiceTestSuite) TestListForAuth() {
 
 	s.T().Run("ok", func(t *testing.T) {
@@ -802,7 +1794,7 @@ func (s *ClusterServiceTestSuite) TestListForAuth() {
 					ctx, err := createContext(username)
 					require.NoError(t, err)
 					// when
-					result, err := s.Application.ClusterService().ListForAuth(ctx, nil)
+					result, _, err := s.Application.ClusterService().ListForAuth(ctx, repository.ClusterListOptions{})
 					// then
 					require.NoError(t, err)
 					expected, err := repository.NewClusterRepository(s.DB).List(context.Background(), nil)
@@ -819,7 +1811,7 @@ func (s *ClusterServiceTestSuite) TestListForAuth() {
 					ctx, err := createContext(username)
 					require.NoError(t, err)
 					// when
-					result, err := s.Application.ClusterService().ListForAuth(ctx, &clusterType)
+					result, _, err := s.Application.ClusterService().ListForAuth(ctx, repository.ClusterListOptions{Type: &clusterType})
 					// then
 					require.NoError(t, err)
 					expected, err := repository.NewClusterRepository(s.DB).List(context.Background(), &clusterType)
@@ -839,7 +1831,7 @@ func (s *ClusterServiceTestSuite) TestListForAuth() {
 					ctx, err := createContext(username)
 					require.NoError(t, err)
 					// when
-					_, err = s.Application.ClusterService().ListForAuth(ctx, nil)
+					_, _, err = s.Application.ClusterService().ListForAuth(ctx, repository.ClusterListOptions{})
 					// then
 					require.Error(t, err)
 					testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to clusters info")
@@ -866,14 +1858,24 @@ func (s *ClusterServiceTestSuite) TestDelete() {
 		err = s.Application.ClusterService().Delete(ctx, c.ClusterID)
 		// then
 		require.NoError(t, err)
-		// check that cluster and links to identities were removed
+		// check that cluster was decommissioned and that the links to identities were kept
 		r, err := repository.NewClusterRepository(s.DB).Load(ctx, c.ClusterID)
-		testsupport.AssertError(t, err, errors.NotFoundError{}, errors.NewNotFoundError("cluster", c.ClusterID.String()).Error())
-		require.Nil(t, r)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterStateDecommissioned, r.State)
 		_, err = repository.NewIdentityClusterRepository(s.DB).Load(ctx, idCuster1.IdentityID, idCuster1.ClusterID)
-		testsupport.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf("identity_cluster with identity ID %s and cluster ID %s not found", idCuster1.IdentityID, idCuster1.ClusterID.String()))
+		require.NoError(t, err)
 		_, err = repository.NewIdentityClusterRepository(s.DB).Load(ctx, idCuster2.IdentityID, idCuster2.ClusterID)
-		testsupport.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf("identity_cluster with identity ID %s and cluster ID %s not found", idCuster2.IdentityID, idCuster2.ClusterID.String()))
+		require.NoError(t, err)
+		// check that the cluster is not listed by default anymore
+		authCtx, err := createContext(auth.Auth)
+		require.NoError(t, err)
+		active, _, err := s.Application.ClusterService().ListForAuth(authCtx, repository.ClusterListOptions{})
+		require.NoError(t, err)
+		assert.NotContains(t, clusterIDs(active), c.ClusterID)
+		decommissioned := repository.ClusterStateDecommissioned
+		inactive, _, err := s.Application.ClusterService().ListForAuth(authCtx, repository.ClusterListOptions{States: []string{decommissioned}})
+		require.NoError(t, err)
+		assert.Contains(t, clusterIDs(inactive), c.ClusterID)
 		// also check that other cluster/identities (noise) still exist
 		_, err = repository.NewIdentityClusterRepository(s.DB).Load(ctx, noiseIdCuster1.IdentityID, noiseIdCuster1.ClusterID)
 		require.NoError(t, err)
@@ -915,25 +1917,462 @@ func (s *ClusterServiceTestSuite) TestDelete() {
 
 }
 
-func newTestCluster() *repository.Cluster {
-	name := uuid.NewV4().String()
-	return &repository.Cluster{
-		Name:              name,
-		Type:              cluster.OCP,
-		URL:               fmt.Sprintf("https://api.cluster.%s", name),
-		AppDNS:            fmt.Sprintf("https://cluster.%s", name),
-		ConsoleURL:        fmt.Sprintf("https://console.cluster.%s", name),
-		LoggingURL:        fmt.Sprintf("https://logging.cluster.%s", name),
-		MetricsURL:        fmt.Sprintf("https://metrics.cluster.%s", name),
-		CapacityExhausted: true,
-		SAToken:           "ServiceAccountToken",
-		SAUsername:        "ServiceAccountUsername",
-		SATokenEncrypted:  true,
-		TokenProviderID:   "TokenProviderID",
-		AuthClientID:      "AuthClientID",
-		AuthClientSecret:  "AuthClientSecret",
-		AuthDefaultScope:  "AuthClientDefaultScope",
-	}
+func (s *ClusterServiceTestSuite) TestWatchClusters() {
+	existingInterval := os.Getenv("F8_CLUSTER_WATCH_POLL_INTERVAL")
+	defer os.Setenv("F8_CLUSTER_WATCH_POLL_INTERVAL", existingInterval)
+	os.Setenv("F8_CLUSTER_WATCH_POLL_INTERVAL", "100ms")
+	config, err := configuration.GetConfigurationData()
+	require.NoError(s.T(), err)
+	cs := gormapplication.NewGormDB(s.DB, config).ClusterService()
+	halt, err := cs.InitializeChangeFeed()
+	require.NoError(s.T(), err)
+	defer halt()
+	ctx, err := createContext(auth.Tenant)
+	require.NoError(s.T(), err)
+	operatorCtx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
+	// the position of the watch before the changes below
+	var bookmark repository.ClusterWatchEvent
+	// the cluster created, updated and decommissioned during the watch
+	var c repository.Cluster
+
+	s.T().Run("changes", func(t *testing.T) {
+		// given
+		existing := test.CreateCluster(t, s.DB)
+		w := startWatch(ctx, cs, nil)
+		defer w.stop()
+		// then the current clusters are sent first
+		added := w.waitFor(t, repository.ClusterWatchEventAdded, existing.ClusterID)
+		require.NotNil(t, added.Cluster)
+		assert.Equal(t, existing.Name, added.Cluster.Name)
+		assert.Empty(t, added.Cluster.SAToken)
+		bookmark = w.waitFor(t, repository.ClusterWatchEventBookmark, uuid.Nil)
+
+		// when a cluster is created
+		c = test.CreateCluster(t, s.DB)
+		// then
+		added = w.waitFor(t, repository.ClusterWatchEventAdded, c.ClusterID)
+		require.NotNil(t, added.Cluster)
+		assert.True(t, bookmark.Position.Before(added.Position))
+
+		// when the cluster is updated
+		loaded, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		loaded.CapacityExhausted = true
+		err = s.Application.Clusters().Save(context.Background(), loaded)
+		require.NoError(t, err)
+		// then
+		modified := w.waitFor(t, repository.ClusterWatchEventModified, c.ClusterID)
+		require.NotNil(t, modified.Cluster)
+		assert.True(t, modified.Cluster.CapacityExhausted)
+		assert.True(t, added.Position.Before(modified.Position))
+
+		// when the cluster is decommissioned
+		err = s.Application.ClusterService().Delete(operatorCtx, c.ClusterID)
+		require.NoError(t, err)
+		// then
+		deleted := w.waitFor(t, repository.ClusterWatchEventDeleted, c.ClusterID)
+		require.NotNil(t, deleted.Cluster)
+		assert.Equal(t, repository.ClusterStateDecommissioned, deleted.Cluster.State)
+		assert.True(t, modified.Position.Before(deleted.Position))
+	})
+
+	s.T().Run("resume", func(t *testing.T) {
+		// given
+		resourceVersion := bookmark.Position.String()
+		// when
+		w := startWatch(ctx, cs, &resourceVersion)
+		defer w.stop()
+		// then only the changes after the resource version are sent, each one with the state of the cluster
+		// right after the change, even if the cluster was decommissioned since then
+		added := w.waitFor(t, repository.ClusterWatchEventAdded, c.ClusterID)
+		require.NotNil(t, added.Cluster)
+		assert.False(t, added.Cluster.CapacityExhausted)
+		assert.Equal(t, repository.ClusterStateActive, added.Cluster.State)
+		assert.Empty(t, added.Cluster.SAToken)
+		modified := w.waitFor(t, repository.ClusterWatchEventModified, c.ClusterID)
+		require.NotNil(t, modified.Cluster)
+		assert.True(t, modified.Cluster.CapacityExhausted)
+		assert.Equal(t, repository.ClusterStateActive, modified.Cluster.State)
+		deleted := w.waitFor(t, repository.ClusterWatchEventDeleted, c.ClusterID)
+		require.NotNil(t, deleted.Cluster)
+		assert.Equal(t, repository.ClusterStateDecommissioned, deleted.Cluster.State)
+		w.waitFor(t, repository.ClusterWatchEventBookmark, uuid.Nil)
+	})
+
+	s.T().Run("resume with events without cluster state", func(t *testing.T) {
+		// given the events recorded before the states of the clusters were kept in the outbox
+		before, err := s.Application.Outbox().LastSettledPosition(context.Background())
+		require.NoError(t, err)
+		updated := test.CreateCluster(t, s.DB)
+		loaded, err := s.Application.Clusters().Load(context.Background(), updated.ClusterID)
+		require.NoError(t, err)
+		loaded.CapacityExhausted = true
+		err = s.Application.Clusters().Save(context.Background(), loaded)
+		require.NoError(t, err)
+		decommissioned := test.CreateCluster(t, s.DB)
+		err = s.Application.ClusterService().Delete(operatorCtx, decommissioned.ClusterID)
+		require.NoError(t, err)
+		err = s.DB.Exec(`UPDATE outbox_event SET cluster = NULL
+			WHERE audit_id IN (SELECT audit_id FROM audit_log WHERE cluster_id IN (?, ?))`, updated.ClusterID, decommissioned.ClusterID).Error
+		require.NoError(t, err)
+		resourceVersion := before.String()
+		// when
+		w := startWatch(ctx, cs, &resourceVersion)
+		defer w.stop()
+		// then the events carry the current state of the cluster
+		added := w.waitFor(t, repository.ClusterWatchEventAdded, updated.ClusterID)
+		require.NotNil(t, added.Cluster)
+		assert.True(t, added.Cluster.CapacityExhausted)
+		modified := w.waitFor(t, repository.ClusterWatchEventModified, updated.ClusterID)
+		require.NotNil(t, modified.Cluster)
+		assert.True(t, modified.Cluster.CapacityExhausted)
+		// and the changes on the clusters which were decommissioned since then are skipped
+		w.waitFor(t, repository.ClusterWatchEventDeleted, decommissioned.ClusterID)
+		for _, e := range w.received {
+			assert.NotEqual(t, decommissioned.ClusterID, e.ClusterID, "unexpected %s event", e.Type)
+		}
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.ToolChainOperator)
+			require.NoError(t, err)
+			// when
+			err = cs.WatchClusters(ctx, nil, func(repository.ClusterWatchEvent) error { return nil })
+			// then
+			testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to clusters info")
+		})
+
+		t.Run("invalid resource version", func(t *testing.T) {
+			// given
+			resourceVersion := "foo"
+			// when
+			err := cs.WatchClusters(ctx, &resourceVersion, func(repository.ClusterWatchEvent) error { return nil })
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "invalid resource version: 'foo'")
+		})
+
+		t.Run("expired resource version", func(t *testing.T) {
+			// given the events of a cluster purged from the outbox
+			purged := test.CreateCluster(t, s.DB)
+			err := s.DB.Exec(`UPDATE outbox_event SET dispatched_at = now() - interval '2 hours'
+				WHERE audit_id IN (SELECT audit_id FROM audit_log WHERE cluster_id = ?)`, purged.ClusterID).Error
+			require.NoError(t, err)
+			err = s.Application.Outbox().Purge(context.Background(), time.Now().Add(-time.Hour))
+			require.NoError(t, err)
+			resourceVersion := bookmark.Position.String()
+			// when
+			err = cs.WatchClusters(ctx, &resourceVersion, func(repository.ClusterWatchEvent) error { return nil })
+			// then
+			require.Error(t, err)
+			assert.IsType(t, repository.OutboxPositionExpiredError{}, err)
+		})
+	})
+}
+
+// clusterWatch a watch of the clusters running in the background
+type clusterWatch struct {
+	events   chan repository.ClusterWatchEvent
+	done     chan error
+	cancel   func()
+	received []repository.ClusterWatchEvent
+}
+
+// startWatch starts watching the clusters in the background
+func startWatch(ctx context.Context, cs service.ClusterService, resourceVersion *string) *clusterWatch {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &clusterWatch{
+		events: make(chan repository.ClusterWatchEvent, 1000),
+		done:   make(chan error, 1),
+		cancel: cancel,
+	}
+	go func() {
+		w.done <- cs.WatchClusters(ctx, resourceVersion, func(e repository.ClusterWatchEvent) error {
+			w.events <- e
+			return nil
+		})
+	}()
+	return w
+}
+
+// waitFor returns the next event of the given type on the given cluster (or the next bookmark, with `uuid.Nil`),
+// after recording the events received until then
+func (w *clusterWatch) waitFor(t *testing.T, eventType string, clusterID uuid.UUID) repository.ClusterWatchEvent {
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case e := <-w.events:
+			if e.Type == eventType && e.ClusterID == clusterID {
+				return e
+			}
+			w.received = append(w.received, e)
+		case err := <-w.done:
+			w.done <- err // for `stop`
+			require.FailNow(t, "watch ended", "error: %v", err)
+		case <-timeout:
+			require.FailNow(t, "event not received within 10s", "%s event on cluster %s", eventType, clusterID)
+		}
+	}
+}
+
+// stop stops the watch, and waits until it ended
+func (w *clusterWatch) stop() {
+	w.cancel()
+	<-w.done
+}
+
+func (s *ClusterServiceTestSuite) TestListAudit() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		since := time.Now()
+		ctx, err := createContext(auth.ToolChainOperator)
+		require.NoError(t, err)
+		c := newTestCluster()
+		err = s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		authCtx, err := createContext(auth.Auth)
+		require.NoError(t, err)
+		err = s.Application.ClusterService().LinkIdentityToCluster(authCtx, uuid.NewV4(), c.URL, false)
+		require.NoError(t, err)
+		err = s.Application.ClusterService().Delete(ctx, c.ClusterID)
+		require.NoError(t, err)
+
+		t.Run("for cluster", func(t *testing.T) {
+			// when
+			entries, err := s.Application.ClusterService().ListAudit(ctx, c.ClusterID)
+			// then
+			require.NoError(t, err)
+			require.Len(t, entries, 3)
+			assert.Equal(t, repository.AuditOperationClusterCreate, entries[0].Operation)
+			assert.Equal(t, repository.AuditOperationIdentityLink, entries[1].Operation)
+			// the cluster was decommissioned
+			assert.Equal(t, repository.AuditOperationClusterUpdate, entries[2].Operation)
+			assert.Equal(t, repository.AuditChanges{
+				"state": {Old: repository.ClusterStateActive, New: repository.ClusterStateDecommissioned},
+			}, entries[2].Changes)
+			assert.Equal(t, auth.ToolChainOperator, entries[0].Actor)
+			assert.Equal(t, auth.Auth, entries[1].Actor)
+			assert.Equal(t, auth.ToolChainOperator, entries[2].Actor)
+		})
+
+		t.Run("since", func(t *testing.T) {
+			// when
+			entries, err := s.Application.ClusterService().ListAuditSince(ctx, since)
+			// then
+			require.NoError(t, err)
+			operations := []string{}
+			for _, e := range entries {
+				if e.ClusterID == c.ClusterID {
+					operations = append(operations, e.Operation)
+				}
+			}
+			assert.Equal(t, []string{repository.AuditOperationClusterCreate, repository.AuditOperationIdentityLink, repository.AuditOperationClusterUpdate}, operations)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			c := test.CreateCluster(t, s.DB)
+
+			for _, username := range []string{auth.Auth, auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, "other"} {
+				t.Run(username, func(t *testing.T) {
+					// given
+					ctx, err := createContext(username)
+					require.NoError(t, err)
+					// when
+					_, err = s.Application.ClusterService().ListAudit(ctx, c.ClusterID)
+					// then
+					testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to the audit log")
+					// when
+					_, err = s.Application.ClusterService().ListAuditSince(ctx, time.Now())
+					// then
+					testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to the audit log")
+				})
+			}
+		})
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestListClustersForIdentities() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		idCluster1 := test.CreateIdentityCluster(t, s.DB)
+		idCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithIdentityID(idCluster1.IdentityID))
+		idCluster3 := test.CreateIdentityCluster(t, s.DB)
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(idCluster1.Cluster)) // noise
+
+		for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				ctx, err := createContext(username)
+				require.NoError(t, err)
+
+				t.Run("single identity", func(t *testing.T) {
+					// when
+					identityClusters, err := s.Application.ClusterService().ListClustersForIdentities(ctx, idCluster1.IdentityID)
+					// then
+					require.NoError(t, err)
+					require.Len(t, identityClusters, 2)
+					clusters := []repository.Cluster{}
+					for _, identityCluster := range identityClusters {
+						assert.Equal(t, idCluster1.IdentityID, identityCluster.IdentityID)
+						assert.False(t, identityCluster.CreatedAt.IsZero())
+						clusters = append(clusters, identityCluster.Cluster)
+					}
+					test.AssertContainsClusters(t, []repository.Cluster{idCluster1.Cluster, idCluster2.Cluster}, clusters, false)
+				})
+
+				t.Run("many identities", func(t *testing.T) {
+					// when
+					identityClusters, err := s.Application.ClusterService().ListClustersForIdentities(ctx, idCluster1.IdentityID, idCluster3.IdentityID, uuid.NewV4())
+					// then
+					require.NoError(t, err)
+					require.Len(t, identityClusters, 3)
+					for _, identityCluster := range identityClusters {
+						assert.Empty(t, identityCluster.Cluster.SAToken)
+						assert.Empty(t, identityCluster.Cluster.AuthClientSecret)
+					}
+				})
+			})
+		}
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		for _, username := range []string{auth.ToolChainOperator, "other"} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				ctx, err := createContext(username)
+				require.NoError(t, err)
+				// when
+				_, err = s.Application.ClusterService().ListClustersForIdentities(ctx, uuid.NewV4())
+				// then
+				testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to cluster info")
+			})
+		}
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestListIdentities() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		idCluster1 := test.CreateIdentityCluster(t, s.DB)
+		idCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(idCluster1.Cluster))
+		test.CreateIdentityCluster(t, s.DB) // noise
+
+		for _, username := range []string{auth.Auth, auth.Tenant} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				ctx, err := createContext(username)
+				require.NoError(t, err)
+
+				t.Run("list", func(t *testing.T) {
+					// when
+					identityClusters, total, err := s.Application.ClusterService().ListIdentities(ctx, idCluster1.ClusterID, 0, 1)
+					// then
+					require.NoError(t, err)
+					assert.Equal(t, 2, total)
+					require.Len(t, identityClusters, 1)
+					assert.Contains(t, []uuid.UUID{idCluster1.IdentityID, idCluster2.IdentityID}, identityClusters[0].IdentityID)
+				})
+
+				t.Run("stream", func(t *testing.T) {
+					// given
+					identityIDs := []uuid.UUID{}
+					// when
+					err := s.Application.ClusterService().StreamIdentities(ctx, idCluster1.ClusterID, func(identityCluster repository.IdentityCluster) error {
+						identityIDs = append(identityIDs, identityCluster.IdentityID)
+						return nil
+					})
+					// then
+					require.NoError(t, err)
+					assert.ElementsMatch(t, []uuid.UUID{idCluster1.IdentityID, idCluster2.IdentityID}, identityIDs)
+				})
+			})
+		}
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			c := test.CreateCluster(t, s.DB)
+
+			for _, username := range []string{auth.OsoProxy, auth.ToolChainOperator, auth.JenkinsIdler, auth.JenkinsProxy, "other"} {
+				t.Run(username, func(t *testing.T) {
+					// given
+					ctx, err := createContext(username)
+					require.NoError(t, err)
+					// when
+					_, _, err = s.Application.ClusterService().ListIdentities(ctx, c.ClusterID, 0, 0)
+					// then
+					testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to the identities of the cluster")
+					// when
+					err = s.Application.ClusterService().StreamIdentities(ctx, c.ClusterID, func(repository.IdentityCluster) error {
+						return nil
+					})
+					// then
+					testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to the identities of the cluster")
+				})
+			}
+		})
+
+		t.Run("unknown cluster", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Auth)
+			require.NoError(t, err)
+			clusterID := uuid.NewV4()
+			// when
+			_, _, err = s.Application.ClusterService().ListIdentities(ctx, clusterID, 0, 0)
+			// then
+			testsupport.AssertError(t, err, errors.NotFoundError{}, "cluster with id '%s' not found", clusterID)
+			// when
+			err = s.Application.ClusterService().StreamIdentities(ctx, clusterID, func(repository.IdentityCluster) error {
+				return nil
+			})
+			// then
+			testsupport.AssertError(t, err, errors.NotFoundError{}, "cluster with id '%s' not found", clusterID)
+		})
+
+		t.Run("invalid pagination", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Auth)
+			require.NoError(t, err)
+			c := test.CreateCluster(t, s.DB)
+			// when
+			_, _, err = s.Application.ClusterService().ListIdentities(ctx, c.ClusterID, -1, 0)
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "invalid offset: -1 (expected a positive value)")
+		})
+	})
+}
+
+func newTestCluster() *repository.Cluster {
+	name := uuid.NewV4().String()
+	return &repository.Cluster{
+		Name:              name,
+		Type:              cluster.OCP,
+		URL:               fmt.Sprintf("https://api.cluster.%s", name),
+		AppDNS:            fmt.Sprintf("https://cluster.%s", name),
+		ConsoleURL:        fmt.Sprintf("https://console.cluster.%s", name),
+		LoggingURL:        fmt.Sprintf("https://logging.cluster.%s", name),
+		MetricsURL:        fmt.Sprintf("https://metrics.cluster.%s", name),
+		CapacityExhausted: true,
+		SAToken:           "ServiceAccountToken",
+		SAUsername:        "ServiceAccountUsername",
+		SATokenEncrypted:  true,
+		TokenProviderID:   "TokenProviderID",
+		AuthClientID:      "AuthClientID",
+		AuthClientSecret:  "AuthClientSecret",
+		AuthDefaultScope:  "AuthClientDefaultScope",
+	}
 }
 
 func (s *ClusterServiceTestSuite) TestClusterConfigurationWatcher() {
@@ -953,7 +2392,7 @@ func (s *ClusterServiceTestSuite) TestClusterConfigurationWatcher() {
 	// initialize application with new config
 	application := gormapplication.NewGormDB(s.DB, config)
 	// Start watching
-	haltWatcher, err := application.ClusterService().InitializeClusterWatcher()
+	haltWatcher, err := application.ClusterService().InitializeClusterWatcher(context.Background())
 	require.NoError(t, err)
 	defer func() {
 		haltWatcher()
@@ -983,198 +2422,1521 @@ func (s *ClusterServiceTestSuite) TestClusterConfigurationWatcher() {
 	waitForConfigUpdate(t, config, !original)
 }
 
-func (s *ClusterServiceTestSuite) TestClusterConfigurationWatcherNoErrorForDefaultConfig() {
-	s.Application = gormapplication.NewGormDB(s.DB, s.Configuration)
-	haltWatcher, err := s.Application.ClusterService().InitializeClusterWatcher()
-	require.NoError(s.T(), err)
+func (s *ClusterServiceTestSuite) TestClusterConfigurationWatcherWithDirectory() {
+	t := s.T()
+	// Create a temp directory with the files from ./conf-files/tests/oso-clusters.d
+	tmpDir, err := ioutil.TempDir("", "oso-clusters.d")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{"us-east-2.json", "us-east-2a.yaml", "us-east-3a.yml"} {
+		updateClusterConfigFile(t, filepath.Join(tmpDir, name), "./configuration/conf-files/tests/oso-clusters.d/"+name)
+	}
+
+	// Load configuration from the temp directory
+	config, err := configuration.NewConfigurationData("", tmpDir)
+	require.NoError(t, err)
+	c := config.GetClusterByURL("https://api.starter-us-east-2a.openshift.com")
+	require.NotNil(t, c)
+	require.False(t, c.CapacityExhausted)
+
+	// initialize application with new config
+	application := gormapplication.NewGormDB(s.DB, config)
+	// Start watching
+	haltWatcher, err := application.ClusterService().InitializeClusterWatcher(context.Background())
+	require.NoError(t, err)
 	defer func() {
 		haltWatcher()
 	}()
+
+	// Update a file of the directory
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "us-east-2a.yaml"), []byte(strings.Replace(readClusterConfigFile(t, "./configuration/conf-files/tests/oso-clusters.d/us-east-2a.yaml"),
+		"capacity-exhausted: false", "capacity-exhausted: true", 1)), 0666)
+	require.NoError(t, err)
+	// Check if it has been updated
+	waitForConfigUpdate(t, config, true)
+
+	// Add a file which declares the same cluster
+	updateClusterConfigFile(t, filepath.Join(tmpDir, "duplicate.yaml"), "./configuration/conf-files/tests/oso-clusters.d/us-east-2a.yaml")
+	// The configuration should not change
+	waitForConfigUpdate(t, config, true)
+
+	// Remove the duplicate file and restore the original file
+	err = os.Remove(filepath.Join(tmpDir, "duplicate.yaml"))
+	require.NoError(t, err)
+	updateClusterConfigFile(t, filepath.Join(tmpDir, "us-east-2a.yaml"), "./configuration/conf-files/tests/oso-clusters.d/us-east-2a.yaml")
+	// Now configuration should be updated
+	waitForConfigUpdate(t, config, false)
+}
+
+func (s *ClusterServiceTestSuite) TestClusterConfigurationWatcherWithSecretFiles() {
+	t := s.T()
+	// do not decommission the clusters created by the other tests
+	existingPolicy := os.Getenv("F8_CLUSTER_CONFIG_SYNC_POLICY")
+	existingToken := os.Getenv("F8_TEST_EAST2A_SA_TOKEN")
+	existingSecret := os.Getenv("F8_TEST_EAST2A_AUTH_CLIENT_SECRET")
+	defer func() {
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", existingPolicy)
+		os.Setenv("F8_TEST_EAST2A_SA_TOKEN", existingToken)
+		os.Setenv("F8_TEST_EAST2A_AUTH_CLIENT_SECRET", existingSecret)
+	}()
+	os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", configuration.ClusterConfigSyncPolicyMergeOnly)
+	os.Setenv("F8_TEST_EAST2A_SA_TOKEN", "east2a-sa-token-from-env")
+	os.Setenv("F8_TEST_EAST2A_AUTH_CLIENT_SECRET", "autheast2asecretfromenv")
+
+	// Create a temp directory with the secret files, and a config file which references them
+	tmpDir, err := ioutil.TempDir("", "oso-clusters-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	secretsDir := filepath.Join(tmpDir, "us-east-2")
+	err = os.Mkdir(secretsDir, 0755)
+	require.NoError(t, err)
+	tokenFile := filepath.Join(secretsDir, "service-account-token")
+	for _, name := range []string{"service-account-token", "auth-client-secret"} {
+		updateClusterConfigFile(t, filepath.Join(secretsDir, name), "./configuration/conf-files/tests/secrets/us-east-2/"+name)
+	}
+	configFile := filepath.Join(tmpDir, "oso-clusters.yaml")
+	err = ioutil.WriteFile(configFile, []byte(strings.Replace(readClusterConfigFile(t, "./configuration/conf-files/tests/oso-clusters-with-secret-references.yaml"),
+		"./conf-files/tests/secrets", tmpDir, -1)), 0666)
+	require.NoError(t, err)
+
+	// Load configuration from the temp file
+	config, err := configuration.NewConfigurationData("", configFile)
+	require.NoError(t, err)
+	waitForConfigSAToken(t, config, "eyJhbGciOiJSUzI1NiIsImtpZCI6IiJ9.us-east-2-sa-token")
+
+	// initialize application with new config
+	application := gormapplication.NewGormDB(s.DB, config)
+	// Start watching
+	haltWatcher, err := application.ClusterService().InitializeClusterWatcher(context.Background())
+	require.NoError(t, err)
+	defer func() {
+		haltWatcher()
+	}()
+
+	// Update the secret file
+	err = ioutil.WriteFile(tokenFile, []byte("updated-sa-token\n"), 0666)
+	require.NoError(t, err)
+	// Check if the config has been reloaded
+	waitForConfigSAToken(t, config, "updated-sa-token")
+
+	// Empty the secret file
+	err = ioutil.WriteFile(tokenFile, []byte(""), 0666)
+	require.NoError(t, err)
+	// The configuration should not change
+	waitForConfigSAToken(t, config, "updated-sa-token")
+
+	// Replace the secret file, as Kubernetes does when a Secret is updated
+	err = ioutil.WriteFile(filepath.Join(secretsDir, ".service-account-token.tmp"), []byte("replaced-sa-token"), 0666)
+	require.NoError(t, err)
+	err = os.Rename(filepath.Join(secretsDir, ".service-account-token.tmp"), tokenFile)
+	require.NoError(t, err)
+	// Now configuration should be updated
+	waitForConfigSAToken(t, config, "replaced-sa-token")
+}
+
+func (s *ClusterServiceTestSuite) TestClusterConfigurationWatcherNoErrorForDefaultConfig() {
+	s.Application = gormapplication.NewGormDB(s.DB, s.Configuration)
+	haltWatcher, err := s.Application.ClusterService().InitializeClusterWatcher(context.Background())
+	require.NoError(s.T(), err)
+	defer func() {
+		haltWatcher()
+	}()
+}
+
+func (s *ClusterServiceTestSuite) TestLinkIdentityToCluster() {
+
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("ignore if exists", func(t *testing.T) {
+			// given
+			identityCluster := test.CreateIdentityCluster(t, s.DB)
+
+			// when
+			err := s.Application.ClusterService().LinkIdentityToCluster(ctx, identityCluster.IdentityID, identityCluster.Cluster.URL, true)
+			require.NoError(t, err)
+
+			// then
+			loaded1, err := s.Application.IdentityClusters().Load(ctx, identityCluster.IdentityID, identityCluster.Cluster.ClusterID)
+			require.NoError(t, err)
+			test.AssertEqualCluster(t, identityCluster.Cluster, loaded1.Cluster, true)
+			test.AssertEqualIdentityClusters(t, identityCluster, *loaded1)
+
+			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster.IdentityID)
+			require.NoError(t, err)
+			assert.Len(t, clusters, 1)
+			test.AssertEqualCluster(t, identityCluster.Cluster, clusters[0], true)
+		})
+
+		t.Run("do not ignore if exists", func(t *testing.T) {
+			// given
+			identityCluster := test.CreateIdentityCluster(t, s.DB)
+
+			// when
+			err := s.Application.ClusterService().LinkIdentityToCluster(ctx, identityCluster.IdentityID, identityCluster.Cluster.URL, false)
+			testsupport.AssertError(t, err, errors.InternalError{}, "failed to link identity '%s' with cluster '%s': pq: duplicate key value violates unique constraint \"identity_cluster_pkey\"", identityCluster.IdentityID, identityCluster.Cluster.ClusterID)
+
+			// then
+			loaded1, err := s.Application.IdentityClusters().Load(ctx, identityCluster.IdentityID, identityCluster.Cluster.ClusterID)
+			require.NoError(t, err)
+			test.AssertEqualCluster(t, identityCluster.Cluster, loaded1.Cluster, true)
+			test.AssertEqualIdentityClusters(t, identityCluster, *loaded1)
+
+			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster.IdentityID)
+			require.NoError(t, err)
+
+			assert.Len(t, clusters, 1)
+			test.AssertEqualCluster(t, identityCluster.Cluster, clusters[0], true)
+		})
+
+		t.Run("link multiple clusters to single identity", func(t *testing.T) {
+			// given
+			identityID := uuid.NewV4()
+			c1 := test.CreateCluster(t, s.DB)
+			identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c1), test.WithIdentityID(identityID))
+			c2 := test.CreateCluster(t, s.DB)
+			identityCluster2 := repository.IdentityCluster{
+				IdentityID: identityID,
+				ClusterID:  c2.ClusterID,
+			}
+			// when
+			err := s.Application.ClusterService().LinkIdentityToCluster(ctx, identityID, c2.URL, true)
+			require.NoError(t, err)
+			// then
+			loaded1, err := s.Application.IdentityClusters().Load(ctx, identityID, c1.ClusterID)
+			require.NoError(t, err)
+			test.AssertEqualCluster(t, c1, loaded1.Cluster, true)
+			test.AssertEqualIdentityClusters(t, identityCluster1, *loaded1)
+			loaded2, err := s.Application.IdentityClusters().Load(ctx, identityID, c2.ClusterID)
+			require.NoError(t, err)
+			test.AssertEqualCluster(t, c2, loaded2.Cluster, true)
+			test.AssertEqualIdentityClusters(t, identityCluster2, *loaded2)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("random cluster url", func(t *testing.T) {
+			// given
+			clusterURL := "http://random.url"
+			// when
+			err := s.Application.ClusterService().LinkIdentityToCluster(ctx, uuid.NewV4(), clusterURL, true)
+			// then
+			test.AssertError(t, err, errors.NotFoundError{}, "cluster with url '%s' not found", clusterURL)
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			ctx, err := createContext("foo")
+			require.NoError(t, err)
+			identityCluster := test.CreateIdentityCluster(t, s.DB)
+			// when
+			err = s.Application.ClusterService().LinkIdentityToCluster(ctx, uuid.NewV4(), identityCluster.Cluster.URL, true)
+			// then
+			test.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to create identity cluster relationship")
+		})
+
+		t.Run("cluster not active", func(t *testing.T) {
+			for _, state := range []string{repository.ClusterStateProvisioning, repository.ClusterStateDraining, repository.ClusterStateDecommissioned} {
+				t.Run(state, func(t *testing.T) {
+					// given
+					c := test.CreateCluster(t, s.DB, test.WithState(state))
+					identityID := uuid.NewV4()
+					// when
+					err := s.Application.ClusterService().LinkIdentityToCluster(ctx, identityID, c.URL, true)
+					// then
+					test.AssertError(t, err, errors.BadParameterError{}, "cluster with url '%s' does not accept new identities (state: '%s')", c.URL, state)
+					_, err = s.Application.IdentityClusters().Load(ctx, identityID, c.ClusterID)
+					test.AssertError(t, err, errors.NotFoundError{}, "identity_cluster with identity ID %s and cluster ID %s not found", identityID, c.ClusterID)
+				})
+			}
+		})
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestLinkIdentitiesToClusters() {
+
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+
+	s.T().Run("per item", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		existing := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		links := []repository.IdentityClusterLink{
+			{IdentityID: uuid.NewV4(), ClusterURL: c.URL},
+			{IdentityID: existing.IdentityID, ClusterURL: c.URL, IgnoreIfAlreadyExists: true},
+			{IdentityID: existing.IdentityID, ClusterURL: c.URL, IgnoreIfAlreadyExists: false},
+			{IdentityID: uuid.NewV4(), ClusterURL: "http://unknown.cluster"},
+			{IdentityID: uuid.NewV4(), ClusterURL: c.URL, IgnoreIfAlreadyExists: true},
+		}
+		// when
+		results, err := s.Application.ClusterService().LinkIdentitiesToClusters(ctx, links, false)
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+		assertIdentityClusterLinkResult(t, links[0], repository.IdentityClusterLinkCreated, results[0])
+		assertIdentityClusterLinkResult(t, links[1], repository.IdentityClusterLinkAlreadyExists, results[1])
+		assertIdentityClusterLinkResult(t, links[2], repository.IdentityClusterLinkFailed, results[2])
+		testsupport.AssertError(t, results[2].Error, errors.InternalError{}, "failed to link identity '%s' with cluster '%s': pq: duplicate key value violates unique constraint \"identity_cluster_pkey\"", existing.IdentityID, c.ClusterID)
+		assertIdentityClusterLinkResult(t, links[3], repository.IdentityClusterLinkFailed, results[3])
+		testsupport.AssertError(t, results[3].Error, errors.NotFoundError{}, "cluster with url 'http://unknown.cluster' not found")
+		assertIdentityClusterLinkResult(t, links[4], repository.IdentityClusterLinkCreated, results[4])
+		// the failures did not prevent the other links from being created
+		count, err := s.Application.IdentityClusters().CountIdentities(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	s.T().Run("transactional", func(t *testing.T) {
+
+		t.Run("ok", func(t *testing.T) {
+			// given
+			c := test.CreateCluster(t, s.DB)
+			existing := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+			links := []repository.IdentityClusterLink{
+				{IdentityID: uuid.NewV4(), ClusterURL: c.URL},
+				{IdentityID: existing.IdentityID, ClusterURL: c.URL, IgnoreIfAlreadyExists: true},
+			}
+			// when
+			results, err := s.Application.ClusterService().LinkIdentitiesToClusters(ctx, links, true)
+			// then
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assertIdentityClusterLinkResult(t, links[0], repository.IdentityClusterLinkCreated, results[0])
+			assertIdentityClusterLinkResult(t, links[1], repository.IdentityClusterLinkAlreadyExists, results[1])
+			count, err := s.Application.IdentityClusters().CountIdentities(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+		})
+
+		t.Run("rolled back", func(t *testing.T) {
+			// given
+			c := test.CreateCluster(t, s.DB)
+			links := []repository.IdentityClusterLink{
+				{IdentityID: uuid.NewV4(), ClusterURL: c.URL},
+				{IdentityID: uuid.NewV4(), ClusterURL: "http://unknown.cluster"},
+				{IdentityID: uuid.NewV4(), ClusterURL: c.URL},
+			}
+			// when
+			results, err := s.Application.ClusterService().LinkIdentitiesToClusters(ctx, links, true)
+			// then
+			require.NoError(t, err)
+			require.Len(t, results, 3)
+			assertIdentityClusterLinkResult(t, links[0], repository.IdentityClusterLinkRolledBack, results[0])
+			assertIdentityClusterLinkResult(t, links[1], repository.IdentityClusterLinkFailed, results[1])
+			testsupport.AssertError(t, results[1].Error, errors.NotFoundError{}, "cluster with url 'http://unknown.cluster' not found")
+			assertIdentityClusterLinkResult(t, links[2], repository.IdentityClusterLinkSkipped, results[2])
+			count, err := s.Application.IdentityClusters().CountIdentities(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+		})
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.ToolChainOperator} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				ctx, err := createContext(username)
+				require.NoError(t, err)
+				c := test.CreateCluster(t, s.DB)
+				// when
+				_, err = s.Application.ClusterService().LinkIdentitiesToClusters(ctx, []repository.IdentityClusterLink{{IdentityID: uuid.NewV4(), ClusterURL: c.URL}}, false)
+				// then
+				testsupport.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to create identity cluster relationship")
+			})
+		}
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestRemoveIdentitiesToClustersLinks() {
+
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+
+	s.T().Run("per item", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		identityCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		links := []repository.IdentityClusterLink{
+			{IdentityID: identityCluster1.IdentityID, ClusterURL: c.URL},
+			{IdentityID: uuid.NewV4(), ClusterURL: c.URL},
+			{IdentityID: identityCluster2.IdentityID, ClusterURL: c.URL},
+		}
+		// when
+		results, err := s.Application.ClusterService().RemoveIdentitiesToClustersLinks(ctx, links, false)
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assertIdentityClusterLinkResult(t, links[0], repository.IdentityClusterLinkDeleted, results[0])
+		assertIdentityClusterLinkResult(t, links[1], repository.IdentityClusterLinkFailed, results[1])
+		testsupport.AssertError(t, results[1].Error, errors.NotFoundError{}, "nothing to delete: identity cluster not found (identity-id:'%s', cluster-url:'%s')", links[1].IdentityID, c.URL)
+		assertIdentityClusterLinkResult(t, links[2], repository.IdentityClusterLinkDeleted, results[2])
+		count, err := s.Application.IdentityClusters().CountIdentities(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	s.T().Run("transactional", func(t *testing.T) {
+
+		t.Run("ok", func(t *testing.T) {
+			// given
+			c := test.CreateCluster(t, s.DB)
+			identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+			identityCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+			links := []repository.IdentityClusterLink{
+				{IdentityID: identityCluster1.IdentityID, ClusterURL: c.URL},
+				{IdentityID: identityCluster2.IdentityID, ClusterURL: c.URL},
+			}
+			// when
+			results, err := s.Application.ClusterService().RemoveIdentitiesToClustersLinks(ctx, links, true)
+			// then
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assertIdentityClusterLinkResult(t, links[0], repository.IdentityClusterLinkDeleted, results[0])
+			assertIdentityClusterLinkResult(t, links[1], repository.IdentityClusterLinkDeleted, results[1])
+			count, err := s.Application.IdentityClusters().CountIdentities(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+		})
+
+		t.Run("rolled back", func(t *testing.T) {
+			// given
+			c := test.CreateCluster(t, s.DB)
+			identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+			identityCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+			links := []repository.IdentityClusterLink{
+				{IdentityID: identityCluster1.IdentityID, ClusterURL: c.URL},
+				{IdentityID: uuid.NewV4(), ClusterURL: c.URL},
+				{IdentityID: identityCluster2.IdentityID, ClusterURL: c.URL},
+			}
+			// when
+			results, err := s.Application.ClusterService().RemoveIdentitiesToClustersLinks(ctx, links, true)
+			// then
+			require.NoError(t, err)
+			require.Len(t, results, 3)
+			assertIdentityClusterLinkResult(t, links[0], repository.IdentityClusterLinkRolledBack, results[0])
+			assertIdentityClusterLinkResult(t, links[1], repository.IdentityClusterLinkFailed, results[1])
+			assertIdentityClusterLinkResult(t, links[2], repository.IdentityClusterLinkSkipped, results[2])
+			count, err := s.Application.IdentityClusters().CountIdentities(ctx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+		})
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.ToolChainOperator} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				ctx, err := createContext(username)
+				require.NoError(t, err)
+				identityCluster := test.CreateIdentityCluster(t, s.DB)
+				// when
+				_, err = s.Application.ClusterService().RemoveIdentitiesToClustersLinks(ctx, []repository.IdentityClusterLink{{IdentityID: identityCluster.IdentityID, ClusterURL: identityCluster.Cluster.URL}}, false)
+				// then
+				testsupport.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to remove identity cluster relationship")
+			})
+		}
+	})
+}
+
+func assertIdentityClusterLinkResult(t *testing.T, expectedLink repository.IdentityClusterLink, expectedStatus string, actual repository.IdentityClusterLinkResult) {
+	assert.Equal(t, expectedLink, actual.IdentityClusterLink)
+	assert.Equal(t, expectedStatus, actual.Status)
+	if expectedStatus == repository.IdentityClusterLinkFailed {
+		assert.Error(t, actual.Error)
+	} else {
+		assert.NoError(t, actual.Error)
+	}
+}
+
+func (s *ClusterServiceTestSuite) TestRemoveIdentityToClusterLink() {
+
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("unlink completely", func(t *testing.T) {
+			// given
+			identityCluster := test.CreateIdentityCluster(t, s.DB)
+			// when
+			err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityCluster.IdentityID, identityCluster.Cluster.URL)
+			// then
+			require.NoError(t, err)
+			_, err = s.Application.IdentityClusters().Load(ctx, identityCluster.IdentityID, identityCluster.Cluster.ClusterID)
+			test.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf("identity_cluster with identity ID %s and cluster ID %s not found", identityCluster.IdentityID, identityCluster.Cluster.ClusterID))
+			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster.IdentityID)
+			require.NoError(t, err)
+			assert.Empty(t, clusters)
+		})
+
+		t.Run("unlink single cluster", func(t *testing.T) {
+			// given
+			identityID := uuid.NewV4()
+			identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithIdentityID(identityID))
+			identityCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithIdentityID(identityID))
+
+			// when
+			err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityID, identityCluster2.Cluster.URL)
+			require.NoError(t, err)
+
+			// then
+			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityID)
+			require.NoError(t, err)
+			require.Len(t, clusters, 1)
+			test.AssertEqualCluster(t, identityCluster1.Cluster, clusters[0], true)
+
+			loaded1, err := s.Application.IdentityClusters().Load(ctx, identityID, identityCluster1.Cluster.ClusterID)
+			require.NoError(t, err)
+			test.AssertEqualCluster(t, identityCluster1.Cluster, loaded1.Cluster, true)
+			test.AssertEqualIdentityClusters(t, identityCluster1, *loaded1)
+
+			_, err = s.Application.IdentityClusters().Load(ctx, identityID, identityCluster2.Cluster.ClusterID)
+			test.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf("identity_cluster with identity ID %s and cluster ID %s not found", identityID, identityCluster2.Cluster.ClusterID))
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		// given
+		identityCluster := test.CreateIdentityCluster(t, s.DB)
+
+		t.Run("not found", func(t *testing.T) {
+
+			t.Run("random cluster url", func(t *testing.T) {
+				// given
+				clusterURL := "http://random.url"
+				// when
+				err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityCluster.IdentityID, clusterURL)
+				// then
+				test.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf(`nothing to delete: identity cluster not found (cluster with URL '%s' not found)`, clusterURL))
+			})
+
+			t.Run("random identity id", func(t *testing.T) {
+				// given
+				identityID := uuid.NewV4()
+				// when
+				err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityID, identityCluster.Cluster.URL)
+				// then
+				test.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf(`nothing to delete: identity cluster not found (identity-id:'%s', cluster-url:'%s')`, identityID.String(), identityCluster.Cluster.URL))
+			})
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			ctx, err := createContext("other")
+			require.NoError(t, err)
+			// when
+			err = s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityCluster.IdentityID, identityCluster.Cluster.URL)
+			// then
+			test.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to remove identity cluster relationship")
+		})
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestMigrateIdentities() {
+
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("batch", func(t *testing.T) {
+			// given
+			source := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			target := test.CreateCluster(t, s.DB, test.WithType("OSO"), test.WithMaxIdentities(4, 75))
+			identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			identityCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			identityCluster3 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source)) // not migrated
+			test.CreateIdentityCluster(t, s.DB, test.WithCluster(target))
+			identityIDs := []uuid.UUID{identityCluster1.IdentityID, identityCluster2.IdentityID, identityCluster1.IdentityID}
+			// when
+			result, err := s.Application.ClusterService().MigrateIdentities(ctx, identityIDs, source.URL, target.URL, false)
+			// then
+			require.NoError(t, err)
+			assert.False(t, result.DryRun)
+			assert.Equal(t, []uuid.UUID{identityCluster1.IdentityID, identityCluster2.IdentityID}, result.IdentityIDs)
+			assert.Equal(t, source.ClusterID, result.SourceCluster.ClusterID)
+			assert.Equal(t, 1, result.SourceCluster.IdentitiesCount)
+			assert.Equal(t, target.ClusterID, result.TargetCluster.ClusterID)
+			assert.Equal(t, 3, result.TargetCluster.IdentitiesCount)
+			assert.True(t, result.TargetCluster.CapacityExhausted)
+			assert.Empty(t, result.TargetCluster.SAToken)
+			for _, identityID := range []uuid.UUID{identityCluster1.IdentityID, identityCluster2.IdentityID} {
+				clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityID)
+				require.NoError(t, err)
+				require.Len(t, clusters, 1)
+				assert.Equal(t, target.ClusterID, clusters[0].ClusterID)
+			}
+			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster3.IdentityID)
+			require.NoError(t, err)
+			require.Len(t, clusters, 1)
+			assert.Equal(t, source.ClusterID, clusters[0].ClusterID)
+		})
+
+		t.Run("dry run", func(t *testing.T) {
+			// given
+			source := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			target := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			identityCluster := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			// when
+			result, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{identityCluster.IdentityID}, source.URL, target.URL, true)
+			// then
+			require.NoError(t, err)
+			assert.True(t, result.DryRun)
+			assert.Equal(t, []uuid.UUID{identityCluster.IdentityID}, result.IdentityIDs)
+			assert.Equal(t, 0, result.SourceCluster.IdentitiesCount)
+			assert.Equal(t, 1, result.TargetCluster.IdentitiesCount)
+			// verify that nothing changed
+			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster.IdentityID)
+			require.NoError(t, err)
+			require.Len(t, clusters, 1)
+			assert.Equal(t, source.ClusterID, clusters[0].ClusterID)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Tenant)
+			require.NoError(t, err)
+			// when
+			_, err = s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{uuid.NewV4()}, "https://foo", "https://bar", false)
+			// then
+			test.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to migrate identities")
+		})
+
+		t.Run("no identity", func(t *testing.T) {
+			// when
+			_, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{}, "https://foo", "https://bar", false)
+			// then
+			test.AssertError(t, err, errors.BadParameterError{}, "no identity to migrate")
+		})
+
+		t.Run("unknown cluster", func(t *testing.T) {
+			// given
+			source := test.CreateCluster(t, s.DB)
+			clusterURL := "http://random.url"
+			// when
+			_, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{uuid.NewV4()}, source.URL, clusterURL, false)
+			// then
+			test.AssertError(t, err, errors.NotFoundError{}, "cluster with url '%s' not found", clusterURL)
+		})
+
+		t.Run("same cluster", func(t *testing.T) {
+			// given
+			identityCluster := test.CreateIdentityCluster(t, s.DB)
+			// when
+			_, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{identityCluster.IdentityID}, identityCluster.Cluster.URL, identityCluster.Cluster.URL, false)
+			// then
+			test.AssertError(t, err, errors.BadParameterError{}, "source and target clusters must be different")
+		})
+
+		t.Run("target not active", func(t *testing.T) {
+			// given
+			source := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			target := test.CreateCluster(t, s.DB, test.WithType("OSO"), test.WithState(repository.ClusterStateDraining))
+			identityCluster := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			// when
+			_, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{identityCluster.IdentityID}, source.URL, target.URL, false)
+			// then
+			test.AssertError(t, err, errors.BadParameterError{}, "cluster with url '%s' does not accept new identities (state: 'draining')", target.URL)
+		})
+
+		t.Run("different type", func(t *testing.T) {
+			// given
+			source := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			target := test.CreateCluster(t, s.DB, test.WithType("OSD"))
+			identityCluster := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			// when
+			_, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{identityCluster.IdentityID}, source.URL, target.URL, true)
+			// then
+			test.AssertError(t, err, errors.BadParameterError{}, "cluster with url '%s' is of type 'OSD' (expected 'OSO')", target.URL)
+		})
+
+		t.Run("not enough capacity", func(t *testing.T) {
+			// given
+			source := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			target := test.CreateCluster(t, s.DB, test.WithType("OSO"), test.WithMaxIdentities(2, 100))
+			identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			identityCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			test.CreateIdentityCluster(t, s.DB, test.WithCluster(target))
+			// when
+			_, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{identityCluster1.IdentityID, identityCluster2.IdentityID}, source.URL, target.URL, true)
+			// then
+			test.AssertError(t, err, errors.BadParameterError{}, "cluster with url '%s' does not have enough capacity: 2 identities to migrate, 1 identities linked out of 2", target.URL)
+		})
+
+		t.Run("identity not linked to source", func(t *testing.T) {
+			// given
+			source := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			target := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			identityCluster := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			identityID := uuid.NewV4()
+			// when
+			_, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{identityCluster.IdentityID, identityID}, source.URL, target.URL, false)
+			// then
+			test.AssertError(t, err, errors.NotFoundError{}, "identity_cluster with identity ID %s and cluster ID %s not found", identityID, source.ClusterID)
+			// verify that no identity was migrated
+			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster.IdentityID)
+			require.NoError(t, err)
+			require.Len(t, clusters, 1)
+			assert.Equal(t, source.ClusterID, clusters[0].ClusterID)
+		})
+
+		t.Run("identity already linked to target", func(t *testing.T) {
+			// given
+			source := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			target := test.CreateCluster(t, s.DB, test.WithType("OSO"))
+			identityCluster := test.CreateIdentityCluster(t, s.DB, test.WithCluster(source))
+			test.CreateIdentityCluster(t, s.DB, test.WithCluster(target), test.WithIdentityID(identityCluster.IdentityID))
+			// when
+			_, err := s.Application.ClusterService().MigrateIdentities(ctx, []uuid.UUID{identityCluster.IdentityID}, source.URL, target.URL, false)
+			// then
+			test.AssertError(t, err, errors.BadParameterError{}, "identity '%s' is already linked to cluster with url '%s'", identityCluster.IdentityID, target.URL)
+		})
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestCapacityManagement() {
+
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+
+	s.T().Run("capacity exhausted when threshold is reached", func(t *testing.T) {
+		// given a cluster with 4 identities max and a threshold at 75%
+		c := test.CreateCluster(t, s.DB, test.WithMaxIdentities(4, 75))
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		// when
+		err := s.Application.ClusterService().LinkIdentityToCluster(ctx, uuid.NewV4(), c.URL, true)
+		// then still below the threshold
+		require.NoError(t, err)
+		result, err := s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.False(t, result.CapacityExhausted)
+		assert.Equal(t, 2, result.IdentitiesCount)
+		// when
+		identityID := uuid.NewV4()
+		err = s.Application.ClusterService().LinkIdentityToCluster(ctx, identityID, c.URL, true)
+		// then the threshold is reached
+		require.NoError(t, err)
+		result, err = s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.True(t, result.CapacityExhausted)
+		assert.Equal(t, 3, result.IdentitiesCount)
+		// when
+		err = s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityID, c.URL)
+		// then back below the threshold
+		require.NoError(t, err)
+		result, err = s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.False(t, result.CapacityExhausted)
+		assert.Equal(t, 2, result.IdentitiesCount)
+	})
+
+	s.T().Run("default threshold", func(t *testing.T) {
+		// given a cluster with 10 identities max and the default threshold (90%)
+		c := test.CreateCluster(t, s.DB, test.WithMaxIdentities(10, 0))
+		for i := 0; i < 8; i++ {
+			test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		}
+		// when
+		err := s.Application.ClusterService().LinkIdentityToCluster(ctx, uuid.NewV4(), c.URL, true)
+		// then
+		require.NoError(t, err)
+		result, err := s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.True(t, result.CapacityExhausted)
+		assert.Equal(t, 9, result.IdentitiesCount)
+	})
+
+	s.T().Run("capacity exhausted when max-identities is lowered", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		ctx, err := createContext(auth.ToolChainOperator)
+		require.NoError(t, err)
+		// when
+		c.MaxIdentities = 2
+		c.CapacityThreshold = 100
+		err = s.Application.ClusterService().CreateOrSaveCluster(ctx, &c)
+		// then
+		require.NoError(t, err)
+		result, err := s.Application.Clusters().Load(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.True(t, result.CapacityExhausted)
+	})
+
+	s.T().Run("capacity unchanged without max-identities", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB, test.WithCapacityExhausted(true))
+		identityID := uuid.NewV4()
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(c), test.WithIdentityID(identityID))
+		// when
+		err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityID, c.URL)
+		// then
+		require.NoError(t, err)
+		result, err := s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.True(t, result.CapacityExhausted)
+		assert.Equal(t, 0, result.IdentitiesCount)
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestProbeClusters() {
+	// given
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	// clusters are identified by their URL, so each test cluster needs a distinct API URL on the same test server
+	healthyCluster := test.CreateCluster(s.T(), s.DB, withURLs(ok.URL+"/"+uuid.NewV4().String(), ok.URL))
+	unhealthyCluster := test.CreateCluster(s.T(), s.DB, withURLs(ok.URL+"/"+uuid.NewV4().String(), failing.URL))
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+	// when
+	err = s.Application.ClusterService().ProbeClusters(context.Background())
+	// then
+	require.NoError(s.T(), err)
+	result, err := s.Application.ClusterService().LoadForAuth(ctx, healthyCluster.ClusterID)
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), result.Health)
+	assert.Equal(s.T(), repository.HealthStateHealthy, result.Health.State)
+	require.NotNil(s.T(), result.Health.LastSuccessAt)
+	result, err = s.Application.ClusterService().LoadForAuth(ctx, unhealthyCluster.ClusterID)
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), result.Health)
+	assert.Equal(s.T(), repository.HealthStateUnhealthy, result.Health.State)
+	assert.Nil(s.T(), result.Health.LastSuccessAt)
+	assert.Contains(s.T(), result.Health.LastError, failing.URL)
+}
+
+func (s *ClusterServiceTestSuite) TestProbeClustersConcurrency() {
+	// given
+	existingConcurrency, found := os.LookupEnv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY")
+	defer func() {
+		if found {
+			os.Setenv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY", existingConcurrency)
+		} else {
+			os.Unsetenv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY")
+		}
+	}()
+	os.Setenv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY", "2")
+	config, err := configuration.GetConfigurationData()
+	require.NoError(s.T(), err)
+	cs := gormapplication.NewGormDB(s.DB, config).ClusterService()
+	// a server which records the maximum number of requests it handled at the same time
+	var lock sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		lock.Lock()
+		inFlight--
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+	var clusters []repository.Cluster
+	for i := 0; i < 6; i++ {
+		clusters = append(clusters, test.CreateCluster(s.T(), s.DB, withURLs(slow.URL+"/"+uuid.NewV4().String(), slow.URL)))
+	}
+	ctx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+	// when
+	err = cs.ProbeClusters(context.Background())
+	// then all the clusters were probed, but no more than 2 at the same time
+	require.NoError(s.T(), err)
+	for _, c := range clusters {
+		result, err := s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), result.Health)
+		assert.Equal(s.T(), repository.HealthStateHealthy, result.Health.State)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	assert.True(s.T(), maxInFlight >= 1)
+	assert.True(s.T(), maxInFlight <= 2, "%d clusters probed at the same time", maxInFlight)
+}
+
+// withURLs sets the API URL and all the other URLs of the cluster to create
+func withURLs(apiURL, otherURLs string) func(*repository.Cluster) {
+	return func(c *repository.Cluster) {
+		c.URL = apiURL
+		c.ConsoleURL = otherURLs
+		c.MetricsURL = otherURLs
+		c.LoggingURL = otherURLs
+	}
+}
+
+func (s *ClusterServiceTestSuite) TestPlaceIdentity() {
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		for _, username := range []string{auth.Auth, auth.Tenant} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				ctx, err := createContext(username)
+				require.NoError(t, err)
+
+				t.Run("least loaded", func(t *testing.T) {
+					// given
+					clusterType := uuid.NewV4().String()
+					c1 := test.CreateCluster(t, s.DB, test.WithType(clusterType))
+					c2 := test.CreateCluster(t, s.DB, test.WithType(clusterType))
+					test.CreateCluster(t, s.DB, test.WithType(clusterType), test.WithCapacityExhausted(true)) // no identity but exhausted
+					test.CreateIdentityCluster(t, s.DB, test.WithCluster(c1))
+					test.CreateIdentityCluster(t, s.DB, test.WithCluster(c1))
+					test.CreateIdentityCluster(t, s.DB, test.WithCluster(c2))
+					// when
+					result, err := s.Application.ClusterService().PlaceIdentity(ctx, uuid.NewV4(), &clusterType, nil)
+					// then
+					require.NoError(t, err)
+					require.NotNil(t, result)
+					test.AssertEqualCluster(t, c2, *result, false)
+				})
+
+				t.Run("already linked", func(t *testing.T) {
+					// given
+					clusterType := uuid.NewV4().String()
+					c1 := test.CreateCluster(t, s.DB, test.WithType(clusterType))
+					test.CreateCluster(t, s.DB, test.WithType(clusterType)) // empty cluster
+					ic := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c1))
+					// when
+					result, err := s.Application.ClusterService().PlaceIdentity(ctx, ic.IdentityID, &clusterType, nil)
+					// then
+					require.NoError(t, err)
+					require.NotNil(t, result)
+					test.AssertEqualCluster(t, c1, *result, false)
+				})
+
+				t.Run("already linked to an exhausted cluster", func(t *testing.T) {
+					// given
+					clusterType := uuid.NewV4().String()
+					c1 := test.CreateCluster(t, s.DB, test.WithType(clusterType), test.WithCapacityExhausted(true))
+					test.CreateCluster(t, s.DB, test.WithType(clusterType)) // empty cluster
+					ic := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c1))
+					// when
+					result, err := s.Application.ClusterService().PlaceIdentity(ctx, ic.IdentityID, &clusterType, nil)
+					// then the identity remains on its cluster
+					require.NoError(t, err)
+					require.NotNil(t, result)
+					test.AssertEqualCluster(t, c1, *result, false)
+				})
+
+				t.Run("already linked to a cluster which is not active", func(t *testing.T) {
+					for _, state := range []string{repository.ClusterStateProvisioning, repository.ClusterStateDraining, repository.ClusterStateDecommissioned} {
+						t.Run(state, func(t *testing.T) {
+							// given
+							clusterType := uuid.NewV4().String()
+							c1 := test.CreateCluster(t, s.DB, test.WithType(clusterType), test.WithState(state))
+							c2 := test.CreateCluster(t, s.DB, test.WithType(clusterType))
+							ic := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c1))
+							// when
+							result, err := s.Application.ClusterService().PlaceIdentity(ctx, ic.IdentityID, &clusterType, nil)
+							// then another cluster is selected
+							require.NoError(t, err)
+							require.NotNil(t, result)
+							test.AssertEqualCluster(t, c2, *result, false)
+						})
+					}
+				})
+
+				t.Run("weighted round-robin", func(t *testing.T) {
+					// given
+					clusterType := uuid.NewV4().String()
+					c1 := test.CreateCluster(t, s.DB, test.WithType(clusterType))
+					c2 := test.CreateCluster(t, s.DB, test.WithType(clusterType))
+					strategy := "weighted-round-robin"
+					// when
+					result1, err := s.Application.ClusterService().PlaceIdentity(ctx, uuid.NewV4(), &clusterType, &strategy)
+					require.NoError(t, err)
+					result2, err := s.Application.ClusterService().PlaceIdentity(ctx, uuid.NewV4(), &clusterType, &strategy)
+					require.NoError(t, err)
+					// then both clusters were selected
+					test.AssertEqualClusters(t, []repository.Cluster{c1, c2}, []repository.Cluster{*result1, *result2}, false)
+				})
+			})
+		}
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		ctx, err := createContext(auth.Tenant)
+		require.NoError(t, err)
+
+		t.Run("no available cluster", func(t *testing.T) {
+			// given
+			clusterType := uuid.NewV4().String()
+			test.CreateCluster(t, s.DB, test.WithType(clusterType), test.WithCapacityExhausted(true))
+			// when
+			_, err := s.Application.ClusterService().PlaceIdentity(ctx, uuid.NewV4(), &clusterType, nil)
+			// then
+			testsupport.AssertError(t, err, errors.NotFoundError{}, "no cluster with available capacity was found")
+		})
+
+		t.Run("unknown strategy", func(t *testing.T) {
+			// given
+			strategy := "foo"
+			// when
+			_, err := s.Application.ClusterService().PlaceIdentity(ctx, uuid.NewV4(), nil, &strategy)
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "unknown placement strategy: 'foo' (expected 'least-loaded' or 'weighted-round-robin')")
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			for _, username := range []string{auth.OsoProxy, auth.JenkinsIdler, auth.JenkinsProxy, auth.ToolChainOperator, "other"} {
+				t.Run(username, func(t *testing.T) {
+					// given
+					ctx, err := createContext(username)
+					require.NoError(t, err)
+					// when
+					_, err = s.Application.ClusterService().PlaceIdentity(ctx, uuid.NewV4(), nil, nil)
+					// then
+					testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to cluster placement")
+				})
+			}
+		})
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestSecretsEncryption() {
+	// given
+	ctx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
+	authCtx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		// when
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		// then the caller keeps the secrets in clear
+		require.NoError(t, err)
+		assert.Equal(t, "ServiceAccountToken", c.SAToken)
+		assert.Equal(t, "AuthClientSecret", c.AuthClientSecret)
+		// but they are encrypted in the DB
+		stored, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, "dev", stored.SecretsKeyID)
+		assert.NotEqual(t, "ServiceAccountToken", stored.SAToken)
+		assert.NotEqual(t, "AuthClientSecret", stored.AuthClientSecret)
+		// and decrypted for the Auth service
+		loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, *c, *loaded, true)
+		found, err := s.Application.ClusterService().FindByURLForAuth(authCtx, c.URL)
+		require.NoError(t, err)
+		test.AssertEqualCluster(t, *c, *found, true)
+	})
+
+	s.T().Run("save with same secrets", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		stored, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		// when
+		again := *c
+		err = s.Application.ClusterService().CreateOrSaveCluster(ctx, &again)
+		// then the encrypted secrets were not changed
+		require.NoError(t, err)
+		updated, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, stored.SAToken, updated.SAToken)
+		assert.Equal(t, stored.AuthClientSecret, updated.AuthClientSecret)
+		assert.Equal(t, stored.SecretsKeyID, updated.SecretsKeyID)
+	})
+
+	s.T().Run("unknown key", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		updated := c
+		updated.SecretsKeyID = "unknown"
+		err := s.Application.Clusters().UpdateSecrets(context.Background(), &updated, "")
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+		// then
+		testsupport.AssertError(t, err, errors.InternalError{}, fmt.Sprintf("unable to decrypt the secrets of cluster with url '%s': unknown encryption key: 'unknown'", c.URL))
+		// restore the secrets in clear, so they can be re-encrypted in other tests
+		err = s.Application.Clusters().UpdateSecrets(context.Background(), &c, "unknown")
+		require.NoError(t, err)
+	})
+}
+
+func (s *ClusterServiceTestSuite) TestReencryptSecrets() {
+	// given a cluster whose secrets are stored in clear and another one whose secrets are encrypted with the "dev" key
+	ctx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
+	authCtx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+	c1 := test.CreateCluster(s.T(), s.DB)
+	c2 := newTestCluster()
+	err = s.Application.ClusterService().CreateOrSaveCluster(ctx, c2)
+	require.NoError(s.T(), err)
+	// and a webhook subscription whose secret is stored in clear and another one whose secret is encrypted with the "dev" key
+	sub1 := test.CreateSubscription(s.T(), s.DB, auth.Tenant, "https://tenant.example.com/webhooks")
+	sub2 := &webhookrepository.Subscription{URL: "https://tenant.example.com/webhooks"}
+	err = s.Application.WebhookService().CreateSubscription(ctx, sub2)
+	require.NoError(s.T(), err)
+	// and a new key
+	keys := map[string][]byte{
+		"dev":     []byte("dev-mode-cluster-encryption-key!"),
+		"2018-12": []byte("0123456789abcdef0123456789abcdef"),
+	}
+	encryptor, err := clusterservice.NewEncryptor(keys, "2018-12")
+	require.NoError(s.T(), err)
+	app := gormapplication.NewGormDB(s.DB, s.Configuration, factory.WithEncryptor(encryptor))
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		count, err := app.ClusterService().ReencryptSecrets(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.True(t, count >= 4)
+		for _, c := range []repository.Cluster{c1, *c2} {
+			stored, err := app.Clusters().Load(context.Background(), c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, "2018-12", stored.SecretsKeyID)
+			assert.NotEqual(t, c.SAToken, stored.SAToken)
+			loaded, err := app.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, c.SAToken, loaded.SAToken)
+			assert.Equal(t, c.AuthClientSecret, loaded.AuthClientSecret)
+		}
+		for _, sub := range []webhookrepository.Subscription{sub1, *sub2} {
+			stored, err := app.Subscriptions().Load(context.Background(), sub.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "2018-12", stored.SecretKeyID)
+			assert.NotEqual(t, sub.Secret, stored.Secret)
+			secret, err := encryptor.Decrypt(stored.SecretKeyID, stored.Secret)
+			require.NoError(t, err)
+			assert.Equal(t, sub.Secret, secret)
+		}
+	})
+
+	s.T().Run("nothing to re-encrypt", func(t *testing.T) {
+		// when
+		count, err := app.ClusterService().ReencryptSecrets(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	s.T().Run("previous key removed", func(t *testing.T) {
+		// given
+		delete(keys, "dev")
+		encryptor, err := clusterservice.NewEncryptor(keys, "2018-12")
+		require.NoError(t, err)
+		app := gormapplication.NewGormDB(s.DB, s.Configuration, factory.WithEncryptor(encryptor))
+		// when
+		loaded, err := app.ClusterService().LoadForAuth(authCtx, c2.ClusterID)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, c2.SAToken, loaded.SAToken)
+	})
 }
 
-func (s *ClusterServiceTestSuite) TestLinkIdentityToCluster() {
-
-	ctx, err := createContext(auth.Auth)
+func (s *ClusterServiceTestSuite) TestExpectedVersions() {
+	// given
+	ctx, err := createContext(auth.ToolChainOperator)
 	require.NoError(s.T(), err)
 
-	s.T().Run("ok", func(t *testing.T) {
-
-		t.Run("ignore if exists", func(t *testing.T) {
-			// given
-			identityCluster := test.CreateIdentityCluster(t, s.DB)
+	s.T().Run("create or save", func(t *testing.T) {
+		// given
+		c := newTestCluster()
 
+		t.Run("unknown cluster", func(t *testing.T) {
 			// when
-			err := s.Application.ClusterService().LinkIdentityToCluster(ctx, identityCluster.IdentityID, identityCluster.Cluster.URL, true)
-			require.NoError(t, err)
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c, 0)
+			// then
+			testsupport.AssertError(t, err, errors.VersionConflictError{}, fmt.Sprintf("cluster with url '%s' does not exist", c.URL))
+		})
 
+		t.Run("ok", func(t *testing.T) {
+			// given
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+			require.NoError(t, err)
+			require.Equal(t, 0, c.Version)
+			// when
+			again := *c
+			again.Name = uuid.NewV4().String()
+			err = s.Application.ClusterService().CreateOrSaveCluster(ctx, &again, 0)
 			// then
-			loaded1, err := s.Application.IdentityClusters().Load(ctx, identityCluster.IdentityID, identityCluster.Cluster.ClusterID)
 			require.NoError(t, err)
-			test.AssertEqualCluster(t, identityCluster.Cluster, loaded1.Cluster, true)
-			test.AssertEqualIdentityClusters(t, identityCluster, *loaded1)
+			assert.Equal(t, 1, again.Version)
+		})
 
-			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster.IdentityID)
-			require.NoError(t, err)
-			assert.Len(t, clusters, 1)
-			test.AssertEqualCluster(t, identityCluster.Cluster, clusters[0], true)
+		t.Run("outdated version", func(t *testing.T) {
+			// when
+			again := *c
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, &again, 0)
+			// then
+			testsupport.AssertError(t, err, errors.VersionConflictError{}, fmt.Sprintf("cluster with id '%s' does not match the expected version (current version is 1)", c.ClusterID))
 		})
+	})
 
-		t.Run("do not ignore if exists", func(t *testing.T) {
-			// given
-			identityCluster := test.CreateIdentityCluster(t, s.DB)
+	s.T().Run("patch", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		name := uuid.NewV4().String()
+		patch := repository.ClusterPatch{
+			Name: &name,
+		}
 
+		t.Run("ok", func(t *testing.T) {
 			// when
-			err := s.Application.ClusterService().LinkIdentityToCluster(ctx, identityCluster.IdentityID, identityCluster.Cluster.URL, false)
-			testsupport.AssertError(t, err, errors.InternalError{}, "failed to link identity '%s' with cluster '%s': pq: duplicate key value violates unique constraint \"identity_cluster_pkey\"", identityCluster.IdentityID, identityCluster.Cluster.ClusterID)
-
+			result, err := s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, patch, 0)
 			// then
-			loaded1, err := s.Application.IdentityClusters().Load(ctx, identityCluster.IdentityID, identityCluster.Cluster.ClusterID)
-			require.NoError(t, err)
-			test.AssertEqualCluster(t, identityCluster.Cluster, loaded1.Cluster, true)
-			test.AssertEqualIdentityClusters(t, identityCluster, *loaded1)
-
-			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster.IdentityID)
 			require.NoError(t, err)
-
-			assert.Len(t, clusters, 1)
-			test.AssertEqualCluster(t, identityCluster.Cluster, clusters[0], true)
+			assert.Equal(t, 1, result.Version)
 		})
 
-		t.Run("link multiple clusters to single identity", func(t *testing.T) {
+		t.Run("one of the expected versions", func(t *testing.T) {
 			// given
-			identityID := uuid.NewV4()
-			c1 := test.CreateCluster(t, s.DB)
-			identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithCluster(c1), test.WithIdentityID(identityID))
-			c2 := test.CreateCluster(t, s.DB)
-			identityCluster2 := repository.IdentityCluster{
-				IdentityID: identityID,
-				ClusterID:  c2.ClusterID,
+			other := uuid.NewV4().String()
+			patch := repository.ClusterPatch{
+				Name: &other,
 			}
 			// when
-			err := s.Application.ClusterService().LinkIdentityToCluster(ctx, identityID, c2.URL, true)
-			require.NoError(t, err)
+			result, err := s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, patch, 0, 1)
 			// then
-			loaded1, err := s.Application.IdentityClusters().Load(ctx, identityID, c1.ClusterID)
-			require.NoError(t, err)
-			test.AssertEqualCluster(t, c1, loaded1.Cluster, true)
-			test.AssertEqualIdentityClusters(t, identityCluster1, *loaded1)
-			loaded2, err := s.Application.IdentityClusters().Load(ctx, identityID, c2.ClusterID)
 			require.NoError(t, err)
-			test.AssertEqualCluster(t, c2, loaded2.Cluster, true)
-			test.AssertEqualIdentityClusters(t, identityCluster2, *loaded2)
+			assert.Equal(t, 2, result.Version)
+		})
+
+		t.Run("outdated version", func(t *testing.T) {
+			// when
+			_, err := s.Application.ClusterService().PatchCluster(ctx, c.ClusterID, patch, 1)
+			// then
+			testsupport.AssertError(t, err, errors.VersionConflictError{}, fmt.Sprintf("cluster with id '%s' does not match the expected version (current version is 2)", c.ClusterID))
 		})
 	})
 
-	s.T().Run("failures", func(t *testing.T) {
+	s.T().Run("delete", func(t *testing.T) {
+		// given
+		c := test.CreateCluster(t, s.DB)
+		err := s.Application.Clusters().UpdateState(context.Background(), c.ClusterID, repository.ClusterStateDraining)
+		require.NoError(t, err)
 
-		t.Run("random cluster url", func(t *testing.T) {
-			// given
-			clusterURL := "http://random.url"
+		t.Run("outdated version", func(t *testing.T) {
 			// when
-			err := s.Application.ClusterService().LinkIdentityToCluster(ctx, uuid.NewV4(), clusterURL, true)
+			err := s.Application.ClusterService().Delete(ctx, c.ClusterID, 0)
 			// then
-			test.AssertError(t, err, errors.NotFoundError{}, "cluster with url '%s' not found", clusterURL)
+			testsupport.AssertError(t, err, errors.VersionConflictError{}, fmt.Sprintf("cluster with id '%s' does not match the expected version (current version is 1)", c.ClusterID))
+			loaded, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterStateDraining, loaded.State)
 		})
 
-		t.Run("unauthorized", func(t *testing.T) {
-			// given
-			ctx, err := createContext("foo")
-			require.NoError(t, err)
-			identityCluster := test.CreateIdentityCluster(t, s.DB)
+		t.Run("ok", func(t *testing.T) {
 			// when
-			err = s.Application.ClusterService().LinkIdentityToCluster(ctx, uuid.NewV4(), identityCluster.Cluster.URL, true)
+			err := s.Application.ClusterService().Delete(ctx, c.ClusterID, 1)
 			// then
-			test.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to create identity cluster relationship")
+			require.NoError(t, err)
+			loaded, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, repository.ClusterStateDecommissioned, loaded.State)
+			assert.Equal(t, 2, loaded.Version)
 		})
-
 	})
 }
 
-func (s *ClusterServiceTestSuite) TestRemoveIdentityToClusterLink() {
-
-	ctx, err := createContext(auth.Auth)
+func (s *ClusterServiceTestSuite) TestRotateSAToken() {
+	// given
+	ctx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
+	authCtx, err := createContext(auth.Auth)
 	require.NoError(s.T(), err)
+	gracePeriod := time.Hour
 
 	s.T().Run("ok", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		before, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+		require.NoError(t, err)
+		require.NotNil(t, before.SATokenCreatedAt)
+		// when
+		result, err := s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "NewServiceAccountToken", false, &gracePeriod)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, result.SAToken)
+		assert.Empty(t, result.PendingSAToken)
+		// both tokens are returned to the Auth service, with their timestamps
+		loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, "ServiceAccountToken", loaded.SAToken)
+		assert.True(t, loaded.SATokenEncrypted)
+		require.NotNil(t, loaded.SATokenCreatedAt)
+		assert.Equal(t, before.SATokenCreatedAt.Unix(), loaded.SATokenCreatedAt.Unix())
+		assert.Equal(t, "NewServiceAccountToken", loaded.PendingSAToken)
+		assert.False(t, loaded.PendingSATokenEncrypted)
+		require.NotNil(t, loaded.PendingSATokenCreatedAt)
+		require.NotNil(t, loaded.PendingSATokenPromotionAt)
+		assert.True(t, loaded.PendingSATokenCreatedAt.After(*loaded.SATokenCreatedAt) || loaded.PendingSATokenCreatedAt.Equal(*loaded.SATokenCreatedAt))
+		assert.Equal(t, gracePeriod, loaded.PendingSATokenPromotionAt.Sub(*loaded.PendingSATokenCreatedAt).Round(time.Second))
+		// the pending token is encrypted in the DB
+		stored, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.NotEqual(t, "NewServiceAccountToken", stored.PendingSAToken)
+	})
 
-		t.Run("unlink completely", func(t *testing.T) {
-			// given
-			identityCluster := test.CreateIdentityCluster(t, s.DB)
+	s.T().Run("default grace period", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "NewServiceAccountToken", false, nil)
+		// then
+		require.NoError(t, err)
+		loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+		require.NoError(t, err)
+		require.NotNil(t, loaded.PendingSATokenPromotionAt)
+		assert.Equal(t, s.Configuration.GetClusterSATokenRotationGracePeriod(), loaded.PendingSATokenPromotionAt.Sub(*loaded.PendingSATokenCreatedAt).Round(time.Second))
+	})
+
+	s.T().Run("no grace period", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		noGracePeriod := time.Duration(0)
+		// when
+		_, err = s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "NewServiceAccountToken", false, &noGracePeriod)
+		// then the token is replaced immediately
+		require.NoError(t, err)
+		loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, "NewServiceAccountToken", loaded.SAToken)
+		assert.False(t, loaded.SATokenEncrypted)
+		assert.Empty(t, loaded.PendingSAToken)
+		assert.Nil(t, loaded.PendingSATokenCreatedAt)
+		assert.Nil(t, loaded.PendingSATokenPromotionAt)
+	})
+
+	s.T().Run("encrypted token", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		noGracePeriod := time.Duration(0)
+
+		t.Run("with grace period", func(t *testing.T) {
 			// when
-			err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityCluster.IdentityID, identityCluster.Cluster.URL)
-			// then
+			_, err := s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "NewServiceAccountToken", true, &gracePeriod)
+			// then the flag applies to the pending token only
 			require.NoError(t, err)
-			_, err = s.Application.IdentityClusters().Load(ctx, identityCluster.IdentityID, identityCluster.Cluster.ClusterID)
-			test.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf("identity_cluster with identity ID %s and cluster ID %s not found", identityCluster.IdentityID, identityCluster.Cluster.ClusterID))
-			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityCluster.IdentityID)
+			loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
 			require.NoError(t, err)
-			assert.Empty(t, clusters)
+			assert.Equal(t, "ServiceAccountToken", loaded.SAToken)
+			assert.True(t, loaded.SATokenEncrypted)
+			assert.Equal(t, "NewServiceAccountToken", loaded.PendingSAToken)
+			assert.True(t, loaded.PendingSATokenEncrypted)
 		})
 
-		t.Run("unlink single cluster", func(t *testing.T) {
-			// given
-			identityID := uuid.NewV4()
-			identityCluster1 := test.CreateIdentityCluster(t, s.DB, test.WithIdentityID(identityID))
-			identityCluster2 := test.CreateIdentityCluster(t, s.DB, test.WithIdentityID(identityID))
-
+		t.Run("without grace period", func(t *testing.T) {
 			// when
-			err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityID, identityCluster2.Cluster.URL)
+			_, err := s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "OtherServiceAccountToken", true, &noGracePeriod)
+			// then the flag applies to the new token
+			require.NoError(t, err)
+			loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
 			require.NoError(t, err)
+			assert.Equal(t, "OtherServiceAccountToken", loaded.SAToken)
+			assert.True(t, loaded.SATokenEncrypted)
+			assert.Empty(t, loaded.PendingSAToken)
+			assert.False(t, loaded.PendingSATokenEncrypted)
+		})
+	})
+
+	s.T().Run("rotation in progress", func(t *testing.T) {
+		// given
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
+		_, err = s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "NewServiceAccountToken", false, &gracePeriod)
+		require.NoError(t, err)
 
+		t.Run("replaced with another rotation", func(t *testing.T) {
+			// when
+			_, err = s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "OtherServiceAccountToken", false, &gracePeriod)
 			// then
-			clusters, err := s.Application.IdentityClusters().ListClustersForIdentity(ctx, identityID)
 			require.NoError(t, err)
-			require.Len(t, clusters, 1)
-			test.AssertEqualCluster(t, identityCluster1.Cluster, clusters[0], true)
+			loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, "ServiceAccountToken", loaded.SAToken)
+			assert.Equal(t, "OtherServiceAccountToken", loaded.PendingSAToken)
+		})
 
-			loaded1, err := s.Application.IdentityClusters().Load(ctx, identityID, identityCluster1.Cluster.ClusterID)
+		t.Run("kept when cluster is saved with same token", func(t *testing.T) {
+			// when
+			again := *c
+			err := s.Application.ClusterService().CreateOrSaveCluster(ctx, &again)
+			// then
 			require.NoError(t, err)
-			test.AssertEqualCluster(t, identityCluster1.Cluster, loaded1.Cluster, true)
-			test.AssertEqualIdentityClusters(t, identityCluster1, *loaded1)
+			loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, "ServiceAccountToken", loaded.SAToken)
+			assert.Equal(t, "OtherServiceAccountToken", loaded.PendingSAToken)
+			assert.NotNil(t, loaded.PendingSATokenPromotionAt)
+		})
 
-			_, err = s.Application.IdentityClusters().Load(ctx, identityID, identityCluster2.Cluster.ClusterID)
-			test.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf("identity_cluster with identity ID %s and cluster ID %s not found", identityID, identityCluster2.Cluster.ClusterID))
+		t.Run("completed when cluster is saved with pending token", func(t *testing.T) {
+			// given
+			pending, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+			require.NoError(t, err)
+			// when
+			again := *c
+			again.SAToken = "OtherServiceAccountToken"
+			err = s.Application.ClusterService().CreateOrSaveCluster(ctx, &again)
+			// then
+			require.NoError(t, err)
+			loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, c.ClusterID)
+			require.NoError(t, err)
+			assert.Equal(t, "OtherServiceAccountToken", loaded.SAToken)
+			require.NotNil(t, loaded.SATokenCreatedAt)
+			assert.Equal(t, pending.PendingSATokenCreatedAt.Unix(), loaded.SATokenCreatedAt.Unix())
+			assert.Empty(t, loaded.PendingSAToken)
+			assert.Nil(t, loaded.PendingSATokenPromotionAt)
 		})
 	})
 
 	s.T().Run("failures", func(t *testing.T) {
-
 		// given
-		identityCluster := test.CreateIdentityCluster(t, s.DB)
+		c := newTestCluster()
+		err := s.Application.ClusterService().CreateOrSaveCluster(ctx, c)
+		require.NoError(t, err)
 
-		t.Run("not found", func(t *testing.T) {
+		t.Run("empty token", func(t *testing.T) {
+			// when
+			_, err := s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, " ", false, &gracePeriod)
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "empty field 'service-account-token' is not allowed")
+		})
 
-			t.Run("random cluster url", func(t *testing.T) {
-				// given
-				clusterURL := "http://random.url"
-				// when
-				err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityCluster.IdentityID, clusterURL)
-				// then
-				test.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf(`nothing to delete: identity cluster not found (cluster with URL '%s' not found)`, clusterURL))
-			})
+		t.Run("same token", func(t *testing.T) {
+			// when
+			_, err := s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "ServiceAccountToken", false, &gracePeriod)
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "the new service-account-token must differ from the current one")
+		})
 
-			t.Run("random identity id", func(t *testing.T) {
-				// given
-				identityID := uuid.NewV4()
-				// when
-				err := s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityID, identityCluster.Cluster.URL)
-				// then
-				test.AssertError(t, err, errors.NotFoundError{}, fmt.Sprintf(`nothing to delete: identity cluster not found (identity-id:'%s', cluster-url:'%s')`, identityID.String(), identityCluster.Cluster.URL))
-			})
+		t.Run("negative grace period", func(t *testing.T) {
+			// given
+			negative := -1 * time.Minute
+			// when
+			_, err := s.Application.ClusterService().RotateSAToken(ctx, c.ClusterID, "NewServiceAccountToken", false, &negative)
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "invalid grace-period: -1m0s (expected a positive value, or 0 to replace the token immediately)")
 		})
 
-		t.Run("unauthorized", func(t *testing.T) {
+		t.Run("not found", func(t *testing.T) {
 			// given
-			ctx, err := createContext("other")
-			require.NoError(t, err)
+			id := uuid.NewV4()
 			// when
-			err = s.Application.ClusterService().RemoveIdentityToClusterLink(ctx, identityCluster.IdentityID, identityCluster.Cluster.URL)
+			_, err := s.Application.ClusterService().RotateSAToken(ctx, id, "NewServiceAccountToken", false, &gracePeriod)
 			// then
-			test.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to remove identity cluster relationship")
+			testsupport.AssertError(t, err, errors.NotFoundError{}, errors.NewNotFoundError("cluster", id.String()).Error())
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// when
+			_, err := s.Application.ClusterService().RotateSAToken(authCtx, c.ClusterID, "NewServiceAccountToken", false, &gracePeriod)
+			// then
+			testsupport.AssertError(t, err, errors.UnauthorizedError{}, "unauthorized access to cluster info")
 		})
 	})
 }
 
+func (s *ClusterServiceTestSuite) TestPromoteSATokens() {
+	// given
+	ctx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
+	authCtx, err := createContext(auth.Auth)
+	require.NoError(s.T(), err)
+	expired := newTestCluster()
+	err = s.Application.ClusterService().CreateOrSaveCluster(ctx, expired)
+	require.NoError(s.T(), err)
+	shortGracePeriod := time.Millisecond
+	_, err = s.Application.ClusterService().RotateSAToken(ctx, expired.ClusterID, "NewServiceAccountToken", false, &shortGracePeriod)
+	require.NoError(s.T(), err)
+	expiredEncrypted := newTestCluster()
+	err = s.Application.ClusterService().CreateOrSaveCluster(ctx, expiredEncrypted)
+	require.NoError(s.T(), err)
+	_, err = s.Application.ClusterService().RotateSAToken(ctx, expiredEncrypted.ClusterID, "NewServiceAccountToken", true, &shortGracePeriod)
+	require.NoError(s.T(), err)
+	pending := newTestCluster()
+	err = s.Application.ClusterService().CreateOrSaveCluster(ctx, pending)
+	require.NoError(s.T(), err)
+	gracePeriod := time.Hour
+	_, err = s.Application.ClusterService().RotateSAToken(ctx, pending.ClusterID, "NewServiceAccountToken", false, &gracePeriod)
+	require.NoError(s.T(), err)
+	time.Sleep(10 * time.Millisecond)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		rotated, err := s.Application.ClusterService().LoadForAuth(authCtx, expired.ClusterID)
+		require.NoError(t, err)
+		// when
+		count, err := s.Application.ClusterService().PromoteSATokens(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.True(t, count >= 2)
+		// the pending token of the first cluster replaced the current one
+		loaded, err := s.Application.ClusterService().LoadForAuth(authCtx, expired.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, "NewServiceAccountToken", loaded.SAToken)
+		assert.False(t, loaded.SATokenEncrypted)
+		require.NotNil(t, loaded.SATokenCreatedAt)
+		assert.Equal(t, rotated.PendingSATokenCreatedAt.Unix(), loaded.SATokenCreatedAt.Unix())
+		assert.Empty(t, loaded.PendingSAToken)
+		assert.Nil(t, loaded.PendingSATokenCreatedAt)
+		assert.Nil(t, loaded.PendingSATokenPromotionAt)
+		// the encrypted flag of the pending token was carried over
+		loaded, err = s.Application.ClusterService().LoadForAuth(authCtx, expiredEncrypted.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, "NewServiceAccountToken", loaded.SAToken)
+		assert.True(t, loaded.SATokenEncrypted)
+		assert.Empty(t, loaded.PendingSAToken)
+		assert.False(t, loaded.PendingSATokenEncrypted)
+		// but not the pending token of the second cluster
+		loaded, err = s.Application.ClusterService().LoadForAuth(authCtx, pending.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, "ServiceAccountToken", loaded.SAToken)
+		assert.Equal(t, "NewServiceAccountToken", loaded.PendingSAToken)
+	})
+
+	s.T().Run("nothing to promote", func(t *testing.T) {
+		// when
+		count, err := s.Application.ClusterService().PromoteSATokens(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
 func createTempClusterConfigFile(t *testing.T) string {
 	to, err := ioutil.TempFile("", "oso-clusters.conf")
 	require.NoError(t, err)
@@ -1189,6 +3951,12 @@ func createTempClusterConfigFile(t *testing.T) string {
 	return to.Name()
 }
 
+func readClusterConfigFile(t *testing.T, from string) string {
+	content, err := ioutil.ReadFile("./../../" + from)
+	require.NoError(t, err)
+	return string(content)
+}
+
 func updateClusterConfigFile(t *testing.T, to, from string) {
 	fromFile, err := os.Open("./../../" + from)
 	require.NoError(t, err)
@@ -1217,6 +3985,30 @@ func waitForConfigUpdate(t *testing.T, config *configuration.ConfigurationData,
 	require.Fail(t, "cluster config has not been reloaded within 3s")
 }
 
+// waitForConfigSAToken waits until the SA token of the `us-east-2` cluster in the given config has the expected value
+func waitForConfigSAToken(t *testing.T, config *configuration.ConfigurationData, expected string) {
+	for i := 0; i < 30; i++ {
+		time.Sleep(100 * time.Millisecond)
+		c := config.GetClusterByURL("https://api.starter-us-east-2.openshift.com")
+		require.NotNil(t, c)
+		if expected == c.SAToken {
+			return
+		}
+	}
+	require.Fail(t, "cluster config has not been reloaded within 3s")
+}
+
+// waitForLeadership waits until the given service is the leader
+func waitForLeadership(t *testing.T, cs service.ClusterService) {
+	for i := 0; i < 30; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if cs.IsLeader() {
+			return
+		}
+	}
+	require.Fail(t, "replica did not become the leader within 3s")
+}
+
 func verifyClusters(t *testing.T, expectedClusters map[string]repository.Cluster, actualClusters []repository.Cluster, compareSensitiveInfo bool) {
 	for _, expectedCluster := range expectedClusters {
 		require.NotEqual(t, repository.Cluster{}, expectedCluster, "cluster not found")
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-common/auth"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/lib/pq"
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// outboxEventChannel the channel on which the database notifies the new outbox events
+	outboxEventChannel = "outbox_event"
+	// watchBatchSize the maximum number of changes loaded at once for a watcher
+	watchBatchSize = 100
+)
+
+// changeFeed the feed of the settled cluster changes, shared by all service instances of the current process.
+// Each watcher is signaled through its channel when new changes are settled, and then loads them from the outbox.
+var changeFeed = struct {
+	sync.Mutex
+	running  bool
+	position repository.OutboxPosition
+	watchers map[chan struct{}]struct{}
+}{}
+
+// subscribeToChangeFeed returns a channel which is signaled when new changes are settled, and closed when the feed
+// stops, along with a func to unsubscribe. Returns an error if the feed is not running.
+func subscribeToChangeFeed() (<-chan struct{}, func(), error) {
+	changeFeed.Lock()
+	defer changeFeed.Unlock()
+	if !changeFeed.running {
+		return nil, nil, errors.NewInternalErrorFromString("the feed of the cluster changes is not running")
+	}
+	signals := make(chan struct{}, 1)
+	changeFeed.watchers[signals] = struct{}{}
+	return signals, func() {
+		changeFeed.Lock()
+		defer changeFeed.Unlock()
+		if _, found := changeFeed.watchers[signals]; found {
+			delete(changeFeed.watchers, signals)
+			close(signals)
+		}
+	}, nil
+}
+
+// publishPosition signals the watchers if the given position of the newest settled change is ahead of the last one
+func publishPosition(position repository.OutboxPosition) {
+	changeFeed.Lock()
+	defer changeFeed.Unlock()
+	if !changeFeed.position.Before(position) {
+		return
+	}
+	changeFeed.position = position
+	for signals := range changeFeed.watchers {
+		select {
+		case signals <- struct{}{}:
+		default:
+			// the watcher has not processed the previous signal yet, and will load all the new changes anyway
+		}
+	}
+}
+
+func startChangeFeed() {
+	changeFeed.Lock()
+	defer changeFeed.Unlock()
+	changeFeed.running = true
+	changeFeed.position = repository.OutboxPosition{}
+	changeFeed.watchers = map[chan struct{}]struct{}{}
+}
+
+func stopChangeFeed() {
+	changeFeed.Lock()
+	defer changeFeed.Unlock()
+	changeFeed.running = false
+	for signals := range changeFeed.watchers {
+		close(signals)
+	}
+	changeFeed.watchers = nil
+}
+
+// InitializeChangeFeed starts listening to the notifications of the new outbox events, which the database sends
+// when the transactions recording them commit, whichever the replica of the service which performed the changes.
+// Upon each notification, upon each reconnection and at regular intervals, the position of the newest settled change
+// is checked, and the watchers of the clusters are signaled when it moved ahead. The feed runs on every replica.
+// Returns a func to stop the feed, which ends the ongoing watches.
+func (s clusterService) InitializeChangeFeed() (func(), error) {
+	listener := pq.NewListener(s.config.GetPostgresConfigString(), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Error(context.Background(), map[string]interface{}{
+				"err":   err,
+				"event": event,
+			}, "the connection listening to the outbox notifications failed")
+		}
+	})
+	if err := listener.Listen(outboxEventChannel); err != nil {
+		listener.Close()
+		return nil, errs.Wrap(err, "unable to listen to the outbox notifications")
+	}
+	var ticker *time.Ticker
+	var ticks <-chan time.Time // nil (ie, never ready) if the periodic checks are disabled
+	interval := s.config.GetClusterWatchPollInterval()
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		ticks = ticker.C
+	} else {
+		log.Warn(context.Background(), map[string]interface{}{}, "periodic checks of the cluster changes disabled: the watchers only receive the changes upon notifications")
+	}
+	startChangeFeed()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-listener.Notify:
+				// coalesce the pending notifications (a nil notification means that the connection was
+				// re-established, and that some notifications may have been missed)
+				for len(listener.Notify) > 0 {
+					<-listener.Notify
+				}
+			case <-ticks:
+			}
+			position, err := s.Repositories().Outbox().LastSettledPosition(ctx)
+			if err != nil {
+				// will try again upon the next notification or tick
+				continue
+			}
+			publishPosition(position)
+		}
+	}()
+	log.Info(context.Background(), map[string]interface{}{
+		"interval": interval.String(),
+	}, "cluster change feed initialized")
+	return func() {
+		cancel()
+		<-done
+		listener.Close()
+		stopChangeFeed()
+	}, nil
+}
+
+// WatchClusters sends the clusters which are not decommissioned as `ADDED` events to the given func, then their
+// subsequent changes as `ADDED`, `MODIFIED` or `DELETED` events, in the order in which they were committed, along with
+// a `BOOKMARK` event each time the watch caught up with the changes (hence, right after the initial events), until the
+// given context is done, the maximum duration of a watch elapsed or the feed stops. If a resource version is given, then only the changes after it are sent,
+// so that a client can resume watching where it stopped. The events are sent at least once: a client may receive
+// the same change twice, eg: when it was committed while the clusters were initially listed.
+// Returns a BadParameterError if the resource version is invalid, or an OutboxPositionExpiredError if the changes
+// after it are no longer available (in which case the client must start watching again without resource version).
+// This method is allowed for the following service accounts:
+// - OSO Proxy
+// - Tenant
+// - Jenkins Idler
+// - Jenkins Proxy
+// - Auth
+func (s clusterService) WatchClusters(ctx context.Context, resourceVersion *string, fn func(repository.ClusterWatchEvent) error) error {
+	if !auth.IsSpecificServiceAccount(ctx, auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth) {
+		return errors.NewUnauthorizedError("unauthorized access to clusters info")
+	}
+	var position repository.OutboxPosition
+	if resourceVersion != nil {
+		p, err := repository.ParseOutboxPosition(*resourceVersion)
+		if err != nil {
+			return errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid resource version: '%s'", *resourceVersion))
+		}
+		position = p
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.config.GetClusterWatchTimeout())
+	defer cancel()
+	// subscribe before loading anything, so that no change is missed in the mean time
+	signals, unsubscribe, err := subscribeToChangeFeed()
+	if err != nil {
+		return err
+	}
+	defer unsubscribe()
+	// the position of the latest event sent, if any, and whether the watch already caught up with the changes
+	var sent *repository.OutboxPosition
+	caughtUp := false
+	send := func(event repository.ClusterWatchEvent) error {
+		if err := fn(event); err != nil {
+			return err
+		}
+		sent = &event.Position
+		return nil
+	}
+	if resourceVersion == nil {
+		if position, err = s.Repositories().Outbox().LastSettledPosition(ctx); err != nil {
+			return err
+		}
+		clusters, _, err := s.list(ctx, repository.ClusterListOptions{
+			States: []string{repository.ClusterStateProvisioning, repository.ClusterStateActive, repository.ClusterStateDraining},
+		})
+		if err != nil {
+			return err
+		}
+		for i := range clusters {
+			hideSensitiveInfo(&clusters[i])
+			if err := send(repository.ClusterWatchEvent{
+				Type:      repository.ClusterWatchEventAdded,
+				Position:  position,
+				ClusterID: clusters[i].ClusterID,
+				Cluster:   &clusters[i],
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	for {
+		changes, err := s.Repositories().Outbox().ListSettled(ctx, position, watchBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			position = change.Position()
+			event, err := s.watchEvent(ctx, change)
+			if err != nil {
+				return err
+			}
+			if event == nil {
+				continue
+			}
+			if err := send(*event); err != nil {
+				return err
+			}
+		}
+		if len(changes) == watchBatchSize {
+			// more changes may be settled already
+			continue
+		}
+		if !caughtUp || *sent != position {
+			// let the client know that it is up-to-date, and from which position to resume
+			if err := send(repository.ClusterWatchEvent{
+				Type:     repository.ClusterWatchEventBookmark,
+				Position: position,
+			}); err != nil {
+				return err
+			}
+		}
+		caughtUp = true
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-signals:
+			if !ok {
+				// the feed stopped
+				return nil
+			}
+		}
+	}
+}
+
+// watchEvent returns the watch event of the given change, or nil if the change is not visible to the watchers
+// (eg: the identity/cluster relationship changes, or the changes on decommissioned clusters). The event carries the
+// state of the cluster right after the change, without the sensitive info, so that the watchers receive each state
+// in turn, along with the current number of identities linked to the cluster and its current health (which are not
+// recorded in the outbox). The events recorded before the states of the clusters were kept in the outbox carry the
+// current state of the cluster instead, and are skipped if the cluster was deleted or decommissioned since then.
+func (s clusterService) watchEvent(ctx context.Context, change repository.OutboxChange) (*repository.ClusterWatchEvent, error) {
+	var visibleBefore, visibleAfter bool
+	switch change.Operation {
+	case repository.AuditOperationClusterCreate:
+		visibleAfter = change.Changes["state"].New != repository.ClusterStateDecommissioned
+	case repository.AuditOperationClusterUpdate:
+		if state, found := change.Changes["state"]; found {
+			visibleBefore = state.Old != repository.ClusterStateDecommissioned
+			visibleAfter = state.New != repository.ClusterStateDecommissioned
+		} else {
+			visibleBefore, visibleAfter = true, true
+		}
+	case repository.AuditOperationClusterDelete:
+		visibleBefore = true
+	default:
+		return nil, nil
+	}
+	var clustr *repository.Cluster
+	if change.Cluster != nil {
+		snapshot := repository.Cluster(*change.Cluster)
+		if err := s.loadDetails(ctx, &snapshot); err != nil {
+			return nil, err
+		}
+		clustr = &snapshot
+	} else if change.Operation != repository.AuditOperationClusterDelete {
+		loaded, err := s.load(ctx, change.ClusterID)
+		if err != nil {
+			if ok, _ := errors.IsNotFoundError(err); !ok {
+				return nil, err
+			}
+		} else {
+			clustr = loaded
+		}
+	}
+	event := repository.ClusterWatchEvent{
+		Position:  change.Position(),
+		ClusterID: change.ClusterID,
+		Cluster:   clustr,
+	}
+	switch {
+	case visibleBefore && !visibleAfter:
+		event.Type = repository.ClusterWatchEventDeleted
+	case !visibleAfter || clustr == nil || clustr.State == repository.ClusterStateDecommissioned:
+		// the cluster was never visible, or (without snapshot) it was deleted or decommissioned since then,
+		// which is sent as a subsequent event
+		return nil, nil
+	case visibleBefore:
+		event.Type = repository.ClusterWatchEventModified
+	default:
+		event.Type = repository.ClusterWatchEventAdded
+	}
+	if clustr != nil {
+		hideSensitiveInfo(clustr)
+	}
+	return &event, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+
+	errs "github.com/pkg/errors"
+)
+
+// Encryptor encrypts and decrypts the secrets of the clusters (service account tokens and OAuth client secrets)
+// before they are stored in and after they are read from the DB.
+type Encryptor interface {
+	// KeyID returns the ID of the key used by `Encrypt`. An empty ID means that the secrets are stored in clear.
+	KeyID() string
+	// Encrypt encrypts the given secret with the current key. An empty secret remains empty.
+	Encrypt(secret string) (string, error)
+	// Decrypt decrypts the given secret, which was encrypted with the key identified by `keyID`.
+	// A secret with an empty key ID was stored in clear and is returned as-is.
+	Decrypt(keyID, secret string) (string, error)
+}
+
+// NewEncryptor returns an AES-GCM encryptor using the given keys (indexed by ID) to decrypt the secrets,
+// and the key identified by `keyID` to encrypt them. If no key is given, the returned encryptor keeps the secrets in clear.
+func NewEncryptor(keys map[string][]byte, keyID string) (Encryptor, error) {
+	if len(keys) == 0 {
+		return plaintextEncryptor{}, nil
+	}
+	return NewAESGCMEncryptor(keys, keyID)
+}
+
+// NewAESGCMEncryptor returns an encryptor using AES in Galois/Counter Mode with the given keys (indexed by ID).
+// Each key must be 16, 24 or 32 bytes long, and the keys which are no longer used to encrypt the secrets must be kept
+// until all secrets have been re-encrypted with the current key (identified by `keyID`).
+func NewAESGCMEncryptor(keys map[string][]byte, keyID string) (Encryptor, error) {
+	if keyID == "" {
+		return nil, errs.New("missing ID of the encryption key")
+	}
+	if _, found := keys[keyID]; !found {
+		return nil, errs.Errorf("unknown encryption key: '%s'", keyID)
+	}
+	ciphers := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid encryption key: '%s'", id)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid encryption key: '%s'", id)
+		}
+		ciphers[id] = gcm
+	}
+	return aesGCMEncryptor{
+		keyID:   keyID,
+		ciphers: ciphers,
+	}, nil
+}
+
+// aesGCMEncryptor encrypts the secrets with AES-GCM. The encrypted secrets are base64-encoded and prefixed with
+// their random nonce. The ID of the key is used as additional data, so a secret cannot be decrypted with another key.
+type aesGCMEncryptor struct {
+	keyID   string
+	ciphers map[string]cipher.AEAD
+}
+
+func (e aesGCMEncryptor) KeyID() string {
+	return e.keyID
+}
+
+func (e aesGCMEncryptor) Encrypt(secret string) (string, error) {
+	if secret == "" {
+		return "", nil
+	}
+	gcm := e.ciphers[e.keyID]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errs.Wrap(err, "unable to generate the nonce")
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), []byte(e.keyID))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (e aesGCMEncryptor) Decrypt(keyID, secret string) (string, error) {
+	if keyID == "" || secret == "" {
+		return secret, nil
+	}
+	gcm, found := e.ciphers[keyID]
+	if !found {
+		return "", errs.Errorf("unknown encryption key: '%s'", keyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", errs.Wrap(err, "unable to decode the secret")
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errs.New("unable to decrypt the secret: invalid length")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", errs.Wrap(err, "unable to decrypt the secret")
+	}
+	return string(plaintext), nil
+}
+
+// plaintextEncryptor keeps the secrets in clear, when no encryption key is configured
+type plaintextEncryptor struct{}
+
+func (plaintextEncryptor) KeyID() string {
+	return ""
+}
+
+func (plaintextEncryptor) Encrypt(secret string) (string, error) {
+	return secret, nil
+}
+
+func (plaintextEncryptor) Decrypt(keyID, secret string) (string, error) {
+	if keyID != "" {
+		return "", errs.Errorf("unable to decrypt the secret: no encryption key is configured (expected '%s')", keyID)
+	}
+	return secret, nil
+}
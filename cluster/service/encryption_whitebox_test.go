@@ -0,0 +1,167 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-common/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptor(t *testing.T) {
+
+	resource.Require(t, resource.UnitTest)
+
+	keys := map[string][]byte{
+		"key-1": []byte("0123456789abcdef"),
+		"key-2": []byte("0123456789abcdef0123456789abcdef"),
+	}
+
+	t.Run("aes-gcm", func(t *testing.T) {
+
+		t.Run("encrypt and decrypt", func(t *testing.T) {
+			// given
+			encryptor, err := NewEncryptor(keys, "key-2")
+			require.NoError(t, err)
+			// when
+			encrypted, err := encryptor.Encrypt("secret")
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "key-2", encryptor.KeyID())
+			assert.NotEqual(t, "secret", encrypted)
+			decrypted, err := encryptor.Decrypt("key-2", encrypted)
+			require.NoError(t, err)
+			assert.Equal(t, "secret", decrypted)
+		})
+
+		t.Run("random nonce", func(t *testing.T) {
+			// given
+			encryptor, err := NewEncryptor(keys, "key-2")
+			require.NoError(t, err)
+			// when
+			encrypted1, err := encryptor.Encrypt("secret")
+			require.NoError(t, err)
+			encrypted2, err := encryptor.Encrypt("secret")
+			require.NoError(t, err)
+			// then
+			assert.NotEqual(t, encrypted1, encrypted2)
+		})
+
+		t.Run("decrypt with previous key", func(t *testing.T) {
+			// given
+			previous, err := NewEncryptor(keys, "key-1")
+			require.NoError(t, err)
+			encrypted, err := previous.Encrypt("secret")
+			require.NoError(t, err)
+			encryptor, err := NewEncryptor(keys, "key-2")
+			require.NoError(t, err)
+			// when
+			decrypted, err := encryptor.Decrypt("key-1", encrypted)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "secret", decrypted)
+		})
+
+		t.Run("empty secret", func(t *testing.T) {
+			// given
+			encryptor, err := NewEncryptor(keys, "key-2")
+			require.NoError(t, err)
+			// when
+			encrypted, err := encryptor.Encrypt("")
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "", encrypted)
+		})
+
+		t.Run("secret stored in clear", func(t *testing.T) {
+			// given
+			encryptor, err := NewEncryptor(keys, "key-2")
+			require.NoError(t, err)
+			// when
+			decrypted, err := encryptor.Decrypt("", "secret")
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "secret", decrypted)
+		})
+
+		t.Run("wrong key", func(t *testing.T) {
+			// given
+			encryptor, err := NewEncryptor(keys, "key-2")
+			require.NoError(t, err)
+			encrypted, err := encryptor.Encrypt("secret")
+			require.NoError(t, err)
+			// when
+			_, err = encryptor.Decrypt("key-1", encrypted)
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unable to decrypt the secret")
+		})
+
+		t.Run("unknown key", func(t *testing.T) {
+			// given
+			encryptor, err := NewEncryptor(keys, "key-2")
+			require.NoError(t, err)
+			// when
+			_, err = encryptor.Decrypt("key-3", "secret")
+			// then
+			require.Error(t, err)
+			assert.Equal(t, "unknown encryption key: 'key-3'", err.Error())
+		})
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+
+		t.Run("no key", func(t *testing.T) {
+			// given
+			encryptor, err := NewEncryptor(nil, "")
+			require.NoError(t, err)
+			// when
+			encrypted, err := encryptor.Encrypt("secret")
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "", encryptor.KeyID())
+			assert.Equal(t, "secret", encrypted)
+			decrypted, err := encryptor.Decrypt("", encrypted)
+			require.NoError(t, err)
+			assert.Equal(t, "secret", decrypted)
+		})
+
+		t.Run("secret encrypted with a key", func(t *testing.T) {
+			// given
+			encryptor, err := NewEncryptor(nil, "")
+			require.NoError(t, err)
+			// when
+			_, err = encryptor.Decrypt("key-1", "secret")
+			// then
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+
+		t.Run("missing key ID", func(t *testing.T) {
+			// when
+			_, err := NewEncryptor(keys, "")
+			// then
+			require.Error(t, err)
+			assert.Equal(t, "missing ID of the encryption key", err.Error())
+		})
+
+		t.Run("unknown key ID", func(t *testing.T) {
+			// when
+			_, err := NewEncryptor(keys, "key-3")
+			// then
+			require.Error(t, err)
+			assert.Equal(t, "unknown encryption key: 'key-3'", err.Error())
+		})
+
+		t.Run("invalid key length", func(t *testing.T) {
+			// when
+			_, err := NewEncryptor(map[string][]byte{"key-1": []byte("too-short")}, "key-1")
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid encryption key: 'key-1'")
+		})
+	})
+}
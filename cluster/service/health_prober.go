@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+)
+
+// HealthProber probes the API, console, metrics and logging URLs of the clusters
+type HealthProber struct {
+	client *http.Client
+}
+
+// NewHealthProber returns a new HealthProber whose requests time out after the given duration
+func NewHealthProber(timeout time.Duration) *HealthProber {
+	return &HealthProber{
+		client: &http.Client{
+			Timeout: timeout,
+			// a redirection (eg: to the login page of the console) is enough to consider that the URL responded
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Probe probes the URLs of the given cluster and returns its new health, based on the `previous` one (if any).
+// The cluster is `healthy` if all its URLs responded with a status lower than 500, `unhealthy` otherwise.
+// The latency is the time it took for the cluster API to respond.
+func (p *HealthProber) Probe(ctx context.Context, c repository.Cluster, previous *repository.ClusterHealth) repository.ClusterHealth {
+	now := time.Now()
+	result := repository.ClusterHealth{
+		ClusterID:     c.ClusterID,
+		LastCheckedAt: now,
+	}
+	if previous != nil {
+		result.LastSuccessAt = previous.LastSuccessAt
+		result.LastError = previous.LastError
+		result.LastErrorAt = previous.LastErrorAt
+	}
+	var failures []string
+	for _, endpoint := range []struct {
+		name string
+		url  string
+	}{
+		{name: "API", url: c.URL},
+		{name: "console", url: c.ConsoleURL},
+		{name: "metrics", url: c.MetricsURL},
+		{name: "logging", url: c.LoggingURL},
+	} {
+		if strings.TrimSpace(endpoint.url) == "" {
+			continue
+		}
+		latency, err := p.probeURL(ctx, endpoint.url)
+		if endpoint.name == "API" {
+			result.Latency = int64(latency / time.Millisecond)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s URL '%s': %v", endpoint.name, endpoint.url, err))
+		}
+	}
+	if len(failures) == 0 {
+		result.State = repository.HealthStateHealthy
+		result.LastSuccessAt = &now
+	} else {
+		result.State = repository.HealthStateUnhealthy
+		result.LastError = strings.Join(failures, "; ")
+		result.LastErrorAt = &now
+	}
+	return result
+}
+
+// probeURL sends a GET request on the given URL and returns the time it took to get a response,
+// or an error if the request failed or if the response status was 500 or higher
+func (p *HealthProber) probeURL(ctx context.Context, url string) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := p.client.Do(req.WithContext(ctx))
+	latency := time.Since(start)
+	if err != nil {
+		return latency, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return latency, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return latency, nil
+}
@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-cluster/cluster/service"
+	"github.com/fabric8-services/fabric8-common/resource"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthProber(t *testing.T) {
+
+	resource.Require(t, resource.UnitTest)
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden) // unauthenticated requests on the cluster API are rejected, but the API responded
+	}))
+	defer ok.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://login.example.com/", http.StatusFound)
+	}))
+	defer redirect.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	newCluster := func(apiURL, consoleURL, metricsURL, loggingURL string) repository.Cluster {
+		return repository.Cluster{
+			ClusterID:  uuid.NewV4(),
+			URL:        apiURL,
+			ConsoleURL: consoleURL,
+			MetricsURL: metricsURL,
+			LoggingURL: loggingURL,
+		}
+	}
+	prober := service.NewHealthProber(100 * time.Millisecond)
+
+	t.Run("healthy", func(t *testing.T) {
+		// given
+		c := newCluster(ok.URL, redirect.URL, ok.URL, ok.URL)
+		// when
+		result := prober.Probe(context.Background(), c, nil)
+		// then
+		assert.Equal(t, c.ClusterID, result.ClusterID)
+		assert.Equal(t, repository.HealthStateHealthy, result.State)
+		assert.False(t, result.LastCheckedAt.IsZero())
+		require.NotNil(t, result.LastSuccessAt)
+		assert.Equal(t, result.LastCheckedAt, *result.LastSuccessAt)
+		assert.Empty(t, result.LastError)
+		assert.Nil(t, result.LastErrorAt)
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+
+		t.Run("server error", func(t *testing.T) {
+			// given
+			c := newCluster(ok.URL, ok.URL, failing.URL, ok.URL)
+			// when
+			result := prober.Probe(context.Background(), c, nil)
+			// then
+			assert.Equal(t, repository.HealthStateUnhealthy, result.State)
+			assert.Nil(t, result.LastSuccessAt)
+			assert.Equal(t, "metrics URL '"+failing.URL+"': unexpected response status: 503 Service Unavailable", result.LastError)
+			require.NotNil(t, result.LastErrorAt)
+		})
+
+		t.Run("timeout", func(t *testing.T) {
+			// given
+			c := newCluster(slow.URL, ok.URL, ok.URL, ok.URL)
+			// when
+			result := prober.Probe(context.Background(), c, nil)
+			// then
+			assert.Equal(t, repository.HealthStateUnhealthy, result.State)
+			assert.Contains(t, result.LastError, "API URL '"+slow.URL+"'")
+			assert.True(t, result.Latency >= 100)
+		})
+
+		t.Run("unreachable", func(t *testing.T) {
+			// given
+			closed := httptest.NewServer(http.NotFoundHandler())
+			closed.Close()
+			c := newCluster(ok.URL, ok.URL, ok.URL, closed.URL)
+			// when
+			result := prober.Probe(context.Background(), c, nil)
+			// then
+			assert.Equal(t, repository.HealthStateUnhealthy, result.State)
+			assert.Contains(t, result.LastError, "logging URL '"+closed.URL+"'")
+		})
+	})
+
+	t.Run("previous health retained", func(t *testing.T) {
+		// given
+		lastSuccess := time.Now().Add(-1 * time.Hour)
+		lastError := time.Now().Add(-2 * time.Hour)
+		previous := repository.ClusterHealth{
+			State:         repository.HealthStateHealthy,
+			LastSuccessAt: &lastSuccess,
+			LastError:     "previous error",
+			LastErrorAt:   &lastError,
+		}
+
+		t.Run("last error retained on success", func(t *testing.T) {
+			// given
+			c := newCluster(ok.URL, ok.URL, ok.URL, ok.URL)
+			// when
+			result := prober.Probe(context.Background(), c, &previous)
+			// then
+			assert.Equal(t, repository.HealthStateHealthy, result.State)
+			assert.Equal(t, "previous error", result.LastError)
+			assert.Equal(t, &lastError, result.LastErrorAt)
+			require.NotNil(t, result.LastSuccessAt)
+			assert.True(t, result.LastSuccessAt.After(lastSuccess))
+		})
+
+		t.Run("last success retained on failure", func(t *testing.T) {
+			// given
+			c := newCluster(failing.URL, ok.URL, ok.URL, ok.URL)
+			// when
+			result := prober.Probe(context.Background(), c, &previous)
+			// then
+			assert.Equal(t, repository.HealthStateUnhealthy, result.State)
+			assert.Equal(t, &lastSuccess, result.LastSuccessAt)
+			require.NotNil(t, result.LastErrorAt)
+			assert.True(t, result.LastErrorAt.After(lastError))
+		})
+	})
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// leaderLeaseName the name of the lease held by the leader among the replicas of the service. Only the leader
+// reconciles the clusters with the config file and runs the background jobs (health probes, SA token promotions).
+const leaderLeaseName = "fabric8-cluster"
+
+// leadership the leadership state of the current replica, shared by all service instances of the current process.
+// Until the leader election is initialized (or if it is disabled), the replica acts as the leader.
+var leadership = struct {
+	sync.RWMutex
+	state repository.Leadership
+}{}
+
+// Leadership returns the leadership state of the current replica
+func (s clusterService) Leadership() repository.Leadership {
+	leadership.RLock()
+	defer leadership.RUnlock()
+	return leadership.state
+}
+
+// IsLeader returns `true` if the current replica is the leader, or if the leader election is disabled
+func (s clusterService) IsLeader() bool {
+	return isLeader()
+}
+
+func isLeader() bool {
+	leadership.RLock()
+	defer leadership.RUnlock()
+	return !leadership.state.Enabled || leadership.state.Leader
+}
+
+func setLeadership(state repository.Leadership) {
+	leadership.Lock()
+	defer leadership.Unlock()
+	leadership.state = state
+}
+
+// InitializeLeaderElection starts competing with the other replicas of the service for the leader lease.
+// The first attempt to acquire the lease is made before returning. Then, the lease is renewed at regular intervals
+// if it is held by the current replica, or acquired as soon as it expires otherwise, so that another replica takes
+// over automatically when the leader stops. When a replica takes over, the given `onElected` func (if any) is called
+// in a separate goroutine, so that it does not delay the renewals of the lease (eg: to reconcile the clusters with the
+// config file, since changes may have been missed by the previous leader). Since this func runs concurrently with
+// the election, it must not use the current service instance.
+// Returns a func to stop the leader election, which waits for the pending `onElected` calls and releases the lease
+// if it is held by the current replica.
+func (s clusterService) InitializeLeaderElection(onElected func()) func() {
+	interval := s.config.GetLeaderElectionRenewInterval()
+	if interval <= 0 {
+		log.Warn(context.Background(), map[string]interface{}{}, "leader election disabled: this replica acts as the leader")
+		return func() {}
+	}
+	if duration := s.config.GetLeaderElectionLeaseDuration(); duration <= interval {
+		log.Warn(context.Background(), map[string]interface{}{
+			"lease_duration": duration.String(),
+			"renew_interval": interval.String(),
+		}, "the leader lease duration should be greater than the renew interval, otherwise the leader may lose its lease between two renewals")
+	}
+	identity := leaderIdentity()
+	setLeadership(repository.Leadership{
+		Enabled:  true,
+		Identity: identity,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.electLeader(ctx, identity)
+	var elected sync.WaitGroup
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if s.electLeader(ctx, identity) && onElected != nil {
+					elected.Add(1)
+					go func() {
+						defer elected.Done()
+						onElected()
+					}()
+				}
+			}
+		}
+	}()
+	log.Info(context.Background(), map[string]interface{}{
+		"identity": identity,
+		"interval": interval.String(),
+	}, "leader election initialized")
+	return func() {
+		cancel()
+		<-done
+		elected.Wait()
+		if isLeader() {
+			// let another replica take over without waiting for the lease expiry
+			s.Repositories().LeaderLeases().Release(context.Background(), leaderLeaseName, identity)
+		}
+		setLeadership(repository.Leadership{})
+	}
+}
+
+// electLeader acquires or renews the leader lease on behalf of the current replica, and updates its leadership state.
+// Returns `true` if the replica just became the leader.
+func (s clusterService) electLeader(ctx context.Context, identity string) bool {
+	wasLeader := s.Leadership().Leader
+	state := repository.Leadership{
+		Enabled:  true,
+		Identity: identity,
+	}
+	lease, err := s.Repositories().LeaderLeases().Acquire(ctx, leaderLeaseName, identity, s.config.GetLeaderElectionLeaseDuration())
+	if err != nil {
+		// the replica cannot tell whether it still holds the lease, so it steps down to make sure that
+		// two replicas never act as the leader at the same time
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"identity": identity,
+		}, "unable to acquire or renew the leader lease")
+	} else {
+		state.Leader = lease.Holder == identity
+		state.Holder = lease.Holder
+		expiresAt := lease.ExpiresAt
+		state.ExpiresAt = &expiresAt
+	}
+	setLeadership(state)
+	switch {
+	case state.Leader && !wasLeader:
+		log.Info(ctx, map[string]interface{}{
+			"identity": identity,
+		}, "this replica is now the leader")
+		return true
+	case !state.Leader && wasLeader:
+		log.Warn(ctx, map[string]interface{}{
+			"identity": identity,
+			"leader":   state.Holder,
+		}, "this replica is not the leader anymore")
+	}
+	return false
+}
+
+// checkLeaderLease verifies that the current replica still holds the leader lease, and locks the lease until the end of
+// the current transaction, so that no other replica can take over before the changes made in this transaction are committed.
+// This prevents a replica which lost its lease in the mean time (eg: after a long pause) from applying changes which are
+// reserved to the leader. Does nothing if the leader election is disabled.
+// Must be called within a transaction. Returns a DataConflictError if the lease is not held by the current replica.
+func (s clusterService) checkLeaderLease(ctx context.Context) error {
+	state := s.Leadership()
+	if !state.Enabled {
+		return nil
+	}
+	held, err := s.Repositories().LeaderLeases().LockIfHeldBy(ctx, leaderLeaseName, state.Identity)
+	if err != nil {
+		return err
+	}
+	if !held {
+		log.Warn(ctx, map[string]interface{}{
+			"identity": state.Identity,
+		}, "this replica does not hold the leader lease anymore")
+		return errNotLeader("")
+	}
+	return nil
+}
+
+// errNotLeader returns a DataConflictError indicating that the current replica is not the leader, along with the
+// identity of the leader if it is known
+func errNotLeader(leader string) error {
+	if leader == "" {
+		return errors.NewDataConflictError("this replica is not the leader")
+	}
+	return errors.NewDataConflictError(fmt.Sprintf("this replica is not the leader (the leader is '%s')", leader))
+}
+
+// leaderIdentity returns the identity of the current replica in the leader election: its host name (ie, the name of
+// the pod), followed by a random suffix to distinguish the successive processes on the same host
+func leaderIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%s", hostname, uuid.NewV4().String()[:8])
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// LeastLoadedStrategy the placement strategy which selects the cluster with the fewest linked identities
+	LeastLoadedStrategy = "least-loaded"
+	// WeightedRoundRobinStrategy the placement strategy which cycles through the clusters, according to their weight
+	WeightedRoundRobinStrategy = "weighted-round-robin"
+)
+
+// PlacementStrategy selects a cluster among the given candidates, using the number of identities
+// already linked to each cluster (indexed by cluster ID) if needed.
+// The candidates never contain clusters whose capacity is exhausted.
+type PlacementStrategy interface {
+	Select(candidates []repository.Cluster, loads map[uuid.UUID]int) (*repository.Cluster, error)
+}
+
+// NewPlacementStrategy returns the placement strategy with the given name, or a BadParameterError
+// if the name is unknown. The `weights` are only used by the weighted round-robin strategy and are indexed by cluster name.
+func NewPlacementStrategy(name string, weights map[string]int) (PlacementStrategy, error) {
+	switch name {
+	case LeastLoadedStrategy:
+		return leastLoaded{}, nil
+	case WeightedRoundRobinStrategy:
+		return weightedRoundRobin{weights: weights}, nil
+	default:
+		return nil, errors.NewBadParameterErrorFromString(fmt.Sprintf("unknown placement strategy: '%s' (expected '%s' or '%s')", name, LeastLoadedStrategy, WeightedRoundRobinStrategy))
+	}
+}
+
+// NewTypeRestrictedStrategy returns a placement strategy which only considers the candidates of the given type
+// before delegating the selection to the given strategy
+func NewTypeRestrictedStrategy(clusterType string, delegate PlacementStrategy) PlacementStrategy {
+	return typeRestricted{
+		clusterType: clusterType,
+		delegate:    delegate,
+	}
+}
+
+// leastLoaded selects the cluster with the fewest linked identities. In case of equality,
+// the cluster with the lowest URL is selected to keep the selection stable.
+type leastLoaded struct{}
+
+func (leastLoaded) Select(candidates []repository.Cluster, loads map[uuid.UUID]int) (*repository.Cluster, error) {
+	if len(candidates) == 0 {
+		return nil, errNoClusterAvailable()
+	}
+	var selected *repository.Cluster
+	for i, c := range candidates {
+		if selected == nil ||
+			loads[c.ClusterID] < loads[selected.ClusterID] ||
+			(loads[c.ClusterID] == loads[selected.ClusterID] && c.URL < selected.URL) {
+			selected = &candidates[i]
+		}
+	}
+	return selected, nil
+}
+
+// weightedRoundRobinState the state of the "smooth" weighted round-robin, shared by all service instances
+// of the current process. The state is kept in memory, hence each replica of the service cycles through
+// the clusters on its own: the weights are honoured per replica, not across all replicas.
+// The current weights are indexed by cluster ID, since the cluster names (which the weights are configured
+// for) are not unique.
+var weightedRoundRobinState = struct {
+	sync.Mutex
+	currentWeights map[uuid.UUID]weightedRoundRobinEntry
+}{
+	currentWeights: map[uuid.UUID]weightedRoundRobinEntry{},
+}
+
+// weightedRoundRobinEntry the current weight of a cluster, along with its type
+type weightedRoundRobinEntry struct {
+	clusterType   string
+	currentWeight int
+}
+
+// weightedRoundRobin cycles through the candidates, using the "smooth weighted round-robin" algorithm
+// (as in nginx). Clusters with no configured weight have a default weight of 1.
+type weightedRoundRobin struct {
+	weights map[string]int
+}
+
+func (s weightedRoundRobin) Select(candidates []repository.Cluster, loads map[uuid.UUID]int) (*repository.Cluster, error) {
+	if len(candidates) == 0 {
+		return nil, errNoClusterAvailable()
+	}
+	// sort candidates by URL so the selection does not depend on the order in which they were listed
+	sorted := make([]repository.Cluster, len(candidates))
+	copy(sorted, candidates)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].URL < sorted[j].URL
+	})
+	weightedRoundRobinState.Lock()
+	defer weightedRoundRobinState.Unlock()
+	pruneWeightedRoundRobinState(sorted)
+	total := 0
+	var selected *repository.Cluster
+	for i, c := range sorted {
+		weight := s.weight(c)
+		total += weight
+		entry := weightedRoundRobinState.currentWeights[c.ClusterID]
+		entry.clusterType = c.Type
+		entry.currentWeight += weight
+		weightedRoundRobinState.currentWeights[c.ClusterID] = entry
+		if selected == nil || entry.currentWeight > weightedRoundRobinState.currentWeights[selected.ClusterID].currentWeight {
+			selected = &sorted[i]
+		}
+	}
+	entry := weightedRoundRobinState.currentWeights[selected.ClusterID]
+	entry.currentWeight -= total
+	weightedRoundRobinState.currentWeights[selected.ClusterID] = entry
+	return selected, nil
+}
+
+// pruneWeightedRoundRobinState drops the current weight of the clusters which are not among the given candidates
+// any more (ie, removed, no longer active or whose capacity is exhausted), so the state does not grow with the
+// clusters that were removed over time. Since the candidates may have been restricted to a given type beforehand,
+// only the clusters of the same types as the candidates are considered.
+// Must be called while holding the lock on the state
+func pruneWeightedRoundRobinState(candidates []repository.Cluster) {
+	ids := make(map[uuid.UUID]bool, len(candidates))
+	types := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		ids[c.ClusterID] = true
+		types[c.Type] = true
+	}
+	for id, entry := range weightedRoundRobinState.currentWeights {
+		if types[entry.clusterType] && !ids[id] {
+			delete(weightedRoundRobinState.currentWeights, id)
+		}
+	}
+}
+
+// weight returns the weight configured for the name of the given cluster, or 1 by default
+func (s weightedRoundRobin) weight(c repository.Cluster) int {
+	if w, found := s.weights[c.Name]; found && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// typeRestricted filters the candidates by type before delegating the selection
+type typeRestricted struct {
+	clusterType string
+	delegate    PlacementStrategy
+}
+
+func (s typeRestricted) Select(candidates []repository.Cluster, loads map[uuid.UUID]int) (*repository.Cluster, error) {
+	filtered := make([]repository.Cluster, 0, len(candidates))
+	for _, c := range candidates {
+		if c.Type == s.clusterType {
+			filtered = append(filtered, c)
+		}
+	}
+	return s.delegate.Select(filtered, loads)
+}
+
+func errNoClusterAvailable() error {
+	return errors.NewNotFoundErrorFromString("no cluster with available capacity was found")
+}
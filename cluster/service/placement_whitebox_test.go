@@ -0,0 +1,173 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster"
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/resource"
+	testsupport "github.com/fabric8-services/fabric8-common/test"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlacementStrategies(t *testing.T) {
+
+	resource.Require(t, resource.UnitTest)
+
+	c1 := repository.Cluster{ClusterID: uuid.NewV4(), Name: "c1", URL: "https://api.c1/", Type: cluster.OSO}
+	c2 := repository.Cluster{ClusterID: uuid.NewV4(), Name: "c2", URL: "https://api.c2/", Type: cluster.OSO}
+	c3 := repository.Cluster{ClusterID: uuid.NewV4(), Name: "c3", URL: "https://api.c3/", Type: cluster.OSD}
+
+	t.Run("least loaded", func(t *testing.T) {
+
+		t.Run("fewest identities", func(t *testing.T) {
+			// given
+			strategy, err := NewPlacementStrategy(LeastLoadedStrategy, nil)
+			require.NoError(t, err)
+			loads := map[uuid.UUID]int{c1.ClusterID: 10, c2.ClusterID: 3, c3.ClusterID: 5}
+			// when
+			selected, err := strategy.Select([]repository.Cluster{c1, c2, c3}, loads)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, c2.ClusterID, selected.ClusterID)
+		})
+
+		t.Run("cluster without identity", func(t *testing.T) {
+			// given
+			strategy, err := NewPlacementStrategy(LeastLoadedStrategy, nil)
+			require.NoError(t, err)
+			loads := map[uuid.UUID]int{c1.ClusterID: 10, c2.ClusterID: 3}
+			// when
+			selected, err := strategy.Select([]repository.Cluster{c1, c2, c3}, loads)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, c3.ClusterID, selected.ClusterID)
+		})
+
+		t.Run("same load", func(t *testing.T) {
+			// given
+			strategy, err := NewPlacementStrategy(LeastLoadedStrategy, nil)
+			require.NoError(t, err)
+			// when
+			selected, err := strategy.Select([]repository.Cluster{c3, c2, c1}, map[uuid.UUID]int{})
+			// then the cluster with the lowest URL is selected
+			require.NoError(t, err)
+			assert.Equal(t, c1.ClusterID, selected.ClusterID)
+		})
+	})
+
+	t.Run("weighted round-robin", func(t *testing.T) {
+
+		t.Run("weights", func(t *testing.T) {
+			// given
+			a := repository.Cluster{ClusterID: uuid.NewV4(), Name: uuid.NewV4().String(), URL: "https://api." + uuid.NewV4().String() + "/"}
+			b := repository.Cluster{ClusterID: uuid.NewV4(), Name: uuid.NewV4().String(), URL: "https://api." + uuid.NewV4().String() + "/"}
+			strategy, err := NewPlacementStrategy(WeightedRoundRobinStrategy, map[string]int{a.Name: 2})
+			require.NoError(t, err)
+			// when
+			selections := map[uuid.UUID]int{}
+			for i := 0; i < 9; i++ {
+				selected, err := strategy.Select([]repository.Cluster{a, b}, map[uuid.UUID]int{})
+				require.NoError(t, err)
+				selections[selected.ClusterID]++
+			}
+			// then
+			assert.Equal(t, 6, selections[a.ClusterID])
+			assert.Equal(t, 3, selections[b.ClusterID])
+		})
+
+		t.Run("clusters with the same name", func(t *testing.T) {
+			// given
+			name := uuid.NewV4().String()
+			a := repository.Cluster{ClusterID: uuid.NewV4(), Name: name, URL: "https://api." + uuid.NewV4().String() + "/"}
+			b := repository.Cluster{ClusterID: uuid.NewV4(), Name: name, URL: "https://api." + uuid.NewV4().String() + "/"}
+			c := repository.Cluster{ClusterID: uuid.NewV4(), Name: uuid.NewV4().String(), URL: "https://api." + uuid.NewV4().String() + "/"}
+			strategy, err := NewPlacementStrategy(WeightedRoundRobinStrategy, map[string]int{name: 2})
+			require.NoError(t, err)
+			// when
+			selections := map[uuid.UUID]int{}
+			for i := 0; i < 10; i++ {
+				selected, err := strategy.Select([]repository.Cluster{a, b, c}, map[uuid.UUID]int{})
+				require.NoError(t, err)
+				selections[selected.ClusterID]++
+			}
+			// then each cluster gets the weight configured for its name, with its own current weight
+			assert.Equal(t, 4, selections[a.ClusterID])
+			assert.Equal(t, 4, selections[b.ClusterID])
+			assert.Equal(t, 2, selections[c.ClusterID])
+		})
+
+		t.Run("state of removed candidates is dropped", func(t *testing.T) {
+			// given
+			a := repository.Cluster{ClusterID: uuid.NewV4(), Name: uuid.NewV4().String(), URL: "https://api." + uuid.NewV4().String() + "/", Type: cluster.OSO}
+			b := repository.Cluster{ClusterID: uuid.NewV4(), Name: uuid.NewV4().String(), URL: "https://api." + uuid.NewV4().String() + "/", Type: cluster.OSO}
+			c := repository.Cluster{ClusterID: uuid.NewV4(), Name: uuid.NewV4().String(), URL: "https://api." + uuid.NewV4().String() + "/", Type: cluster.OSD}
+			strategy, err := NewPlacementStrategy(WeightedRoundRobinStrategy, nil)
+			require.NoError(t, err)
+			_, err = strategy.Select([]repository.Cluster{a, b, c}, map[uuid.UUID]int{})
+			require.NoError(t, err)
+			// when `b` is not a candidate any more, and the candidates are restricted to the `OSO` type
+			_, err = NewTypeRestrictedStrategy(cluster.OSO, strategy).Select([]repository.Cluster{a, c}, map[uuid.UUID]int{})
+			// then
+			require.NoError(t, err)
+			weightedRoundRobinState.Lock()
+			defer weightedRoundRobinState.Unlock()
+			assert.Contains(t, weightedRoundRobinState.currentWeights, a.ClusterID)
+			assert.NotContains(t, weightedRoundRobinState.currentWeights, b.ClusterID)
+			// state of the clusters of other types is retained
+			assert.Contains(t, weightedRoundRobinState.currentWeights, c.ClusterID)
+		})
+	})
+
+	t.Run("type restricted", func(t *testing.T) {
+		// given
+		delegate, err := NewPlacementStrategy(LeastLoadedStrategy, nil)
+		require.NoError(t, err)
+		strategy := NewTypeRestrictedStrategy(cluster.OSD, delegate)
+		loads := map[uuid.UUID]int{c1.ClusterID: 0, c2.ClusterID: 0, c3.ClusterID: 100}
+		// when
+		selected, err := strategy.Select([]repository.Cluster{c1, c2, c3}, loads)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, c3.ClusterID, selected.ClusterID)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+
+		t.Run("no candidate", func(t *testing.T) {
+			for _, name := range []string{LeastLoadedStrategy, WeightedRoundRobinStrategy} {
+				t.Run(name, func(t *testing.T) {
+					// given
+					strategy, err := NewPlacementStrategy(name, nil)
+					require.NoError(t, err)
+					// when
+					_, err = strategy.Select([]repository.Cluster{}, map[uuid.UUID]int{})
+					// then
+					testsupport.AssertError(t, err, errors.NotFoundError{}, "no cluster with available capacity was found")
+				})
+			}
+		})
+
+		t.Run("no candidate with matching type", func(t *testing.T) {
+			// given
+			delegate, err := NewPlacementStrategy(LeastLoadedStrategy, nil)
+			require.NoError(t, err)
+			strategy := NewTypeRestrictedStrategy(cluster.OCP, delegate)
+			// when
+			_, err = strategy.Select([]repository.Cluster{c1, c2, c3}, map[uuid.UUID]int{})
+			// then
+			testsupport.AssertError(t, err, errors.NotFoundError{}, "no cluster with available capacity was found")
+		})
+
+		t.Run("unknown strategy", func(t *testing.T) {
+			// when
+			_, err := NewPlacementStrategy("foo", nil)
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "unknown placement strategy: 'foo' (expected 'least-loaded' or 'weighted-round-robin')")
+		})
+	})
+}
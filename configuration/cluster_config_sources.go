@@ -0,0 +1,136 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-common/httpsupport"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// clusterConfigFileTypes the config types of the cluster config files, indexed by file extension.
+// Files with another extension are ignored when the clusters are read from a directory.
+var clusterConfigFileTypes = map[string]string{
+	".conf": "json",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+}
+
+// readClusterConfigPath reads the clusters declared in the given file, or in all the `*.conf`, `*.json`, `*.yaml`
+// and `*.yml` files of the given directory (in lexical order, ignoring hidden files and sub-directories).
+// Returns an error if two clusters have the same API URL or the same name, even if they are declared in different files.
+func readClusterConfigPath(path string) (map[string]repository.Cluster, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the cluster config directory (%s)", path)
+		}
+		for _, entry := range entries {
+			// skip the hidden files, such as the `..data` entries of the directories in which Kubernetes mounts ConfigMaps
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			file := filepath.Join(path, entry.Name())
+			// follow the symbolic links (ConfigMap keys are mounted as such)
+			if fileInfo, err := os.Stat(file); err != nil || fileInfo.IsDir() {
+				continue
+			}
+			if _, ok := clusterConfigFileTypes[filepath.Ext(entry.Name())]; ok {
+				files = append(files, file)
+			}
+		}
+	} else {
+		files = []string{path}
+	}
+	sources := newClusterConfigSources()
+	for _, file := range files {
+		clusters, err := readClusterConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := sources.add(file, clusters); err != nil {
+			return nil, err
+		}
+	}
+	return sources.clusters, nil
+}
+
+// readClusterConfigFile reads the clusters declared in the given JSON or YAML file. The file format is inferred
+// from its extension (JSON by default)
+func readClusterConfigFile(file string) ([]repository.Cluster, error) {
+	configType, ok := clusterConfigFileTypes[filepath.Ext(file)]
+	if !ok {
+		configType = "json"
+	}
+	fileViper := viper.New()
+	fileViper.SetTypeByDefaultValue(true)
+	fileViper.SetConfigType(configType)
+	fileViper.SetConfigFile(file)
+	if err := fileViper.ReadInConfig(); err != nil {
+		return nil, errors.Errorf("failed to load the %s config file (%s): %s \n", strings.ToUpper(configType), file, err)
+	}
+	clusters, err := decodeClusterConfig(fileViper)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid cluster config file (%s)", file)
+	}
+	return clusters, nil
+}
+
+// decodeClusterConfig decodes the clusters in the given config, which either declares a list of `clusters`,
+// or a single cluster at the top-level
+func decodeClusterConfig(clusterViper *viper.Viper) ([]repository.Cluster, error) {
+	if clusterViper.IsSet("clusters") {
+		return decodeClusters(clusterViper.Get("clusters"))
+	}
+	settings := clusterViper.AllSettings()
+	if len(settings) == 0 {
+		return nil, nil
+	}
+	return decodeClusters([]interface{}{settings})
+}
+
+// clusterConfigSources the clusters read from one or more config files
+type clusterConfigSources struct {
+	// the clusters, indexed by API URL
+	clusters map[string]repository.Cluster
+	// the files in which the clusters were declared, indexed by (normalized) API URL and by name
+	filesByURL  map[string]string
+	filesByName map[string]string
+}
+
+func newClusterConfigSources() *clusterConfigSources {
+	return &clusterConfigSources{
+		clusters:    map[string]repository.Cluster{},
+		filesByURL:  map[string]string{},
+		filesByName: map[string]string{},
+	}
+}
+
+// add adds the clusters declared in the given file. Returns an error if a cluster with the same API URL
+// or the same name was already declared, in the same file or in another one.
+func (s *clusterConfigSources) add(file string, clusters []repository.Cluster) error {
+	for _, c := range clusters {
+		clusterURL := httpsupport.AddTrailingSlashToURL(c.URL)
+		if other, found := s.filesByURL[clusterURL]; found && c.URL != "" {
+			return errors.Errorf("duplicate cluster with url '%s' in %s and %s", c.URL, other, file)
+		}
+		if other, found := s.filesByName[c.Name]; found && c.Name != "" {
+			return errors.Errorf("duplicate cluster with name '%s' in %s and %s", c.Name, other, file)
+		}
+		s.filesByURL[clusterURL] = file
+		s.filesByName[c.Name] = file
+		s.clusters[c.URL] = c
+	}
+	return nil
+}
@@ -2,10 +2,13 @@ package configuration
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"net/url"
 	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -69,6 +72,63 @@ const (
 	// sentry
 	varEnvironment = "environment"
 	varSentryDSN   = "sentry.dsn"
+
+	// Cluster placement
+	varClusterPlacementStrategy = "cluster.placement.strategy"
+	varClusterPlacementWeights  = "cluster.placement.weights"
+
+	// Cluster capacity
+	varClusterCapacityThreshold = "cluster.capacity.threshold"
+
+	// Cluster health
+	varClusterHealthProbeInterval    = "cluster.health.probe.interval"
+	varClusterHealthProbeTimeout     = "cluster.health.probe.timeout"
+	varClusterHealthProbeConcurrency = "cluster.health.probe.concurrency"
+
+	// Cluster secrets encryption
+	varClusterEncryptionKeys  = "cluster.encryption.keys"
+	varClusterEncryptionKeyID = "cluster.encryption.key.id"
+
+	// Cluster SA token rotation
+	varClusterSATokenRotationGracePeriod = "cluster.sa.token.rotation.grace.period"
+	varClusterSATokenPromotionInterval   = "cluster.sa.token.promotion.interval"
+
+	// Cluster API representation
+	varClusterAPICompatibilityModeEnabled = "cluster.api.compatibility.mode.enabled"
+
+	// Cluster configuration file synchronization
+	varClusterConfigSyncPolicy                 = "cluster.config.sync.policy"
+	varClusterConfigSyncMaxDecommissions       = "cluster.config.sync.max.decommissions"
+	varClusterConfigSyncMaxIdentityLinksLosses = "cluster.config.sync.max.identity.links.losses"
+	varClusterConfigWatcherDebounce            = "cluster.config.watcher.debounce"
+
+	// Watch of the cluster changes
+	varClusterWatchPollInterval = "cluster.watch.poll.interval"
+	varClusterWatchTimeout      = "cluster.watch.timeout"
+
+	// Leader election among the replicas of the service
+	varLeaderElectionLeaseDuration = "leader.election.lease.duration"
+	varLeaderElectionRenewInterval = "leader.election.renew.interval"
+
+	// Webhooks
+	varWebhookDispatchInterval    = "webhook.dispatch.interval"
+	varWebhookDispatchTimeout     = "webhook.dispatch.timeout"
+	varWebhookDeliveryTimeout     = "webhook.delivery.timeout"
+	varWebhookDeliveryMaxAttempts = "webhook.delivery.max.attempts"
+	varWebhookDeliveryBackoff     = "webhook.delivery.backoff"
+	varWebhookDeliveryRetention   = "webhook.delivery.retention"
+)
+
+// The policies to synchronize the clusters in the database with the cluster configuration file
+const (
+	// ClusterConfigSyncPolicyAuthoritative the clusters declared in the configuration file are created or updated,
+	// and the clusters which originate from the configuration file but are not declared in it anymore are decommissioned
+	ClusterConfigSyncPolicyAuthoritative = "authoritative"
+	// ClusterConfigSyncPolicyMergeOnly the clusters declared in the configuration file are created or updated,
+	// but no cluster is decommissioned
+	ClusterConfigSyncPolicyMergeOnly = "merge-only"
+	// ClusterConfigSyncPolicyDisabled the clusters in the database are not synchronized with the configuration file
+	ClusterConfigSyncPolicyDisabled = "disabled"
 )
 
 type clusterConfig struct {
@@ -133,6 +193,11 @@ func NewConfigurationData(mainConfigFile string, clusterConfigFile string) (*Con
 	if c.GetSentryDSN() == "" {
 		c.appendDefaultConfigErrorMessage("Sentry DSN is empty")
 	}
+	if len(c.GetClusterEncryptionKeys()) == 0 {
+		c.appendDefaultConfigErrorMessage("cluster secrets encryption key is not set")
+	} else if c.GetClusterEncryptionKeyID() == devModeClusterEncryptionKeyID {
+		c.appendDefaultConfigErrorMessage("default cluster secrets encryption key is used")
+	}
 	if c.defaultConfigurationError != nil {
 		log.WithFields(map[string]interface{}{
 			"default_configuration_error": c.defaultConfigurationError.Error(),
@@ -154,33 +219,40 @@ func (c *ConfigurationData) validateURL(serviceURL, serviceName string) {
 }
 
 func (c *ConfigurationData) initClusterConfig(clusterConfigFile, defaultClusterConfigFile string) (string, error) {
-	clusterViper, defaultConfigErrorMsg, usedClusterConfigFile, err := readFromJSONFile(clusterConfigFile, defaultClusterConfigFile, osoClusterConfigFileName)
+	clusters, defaultConfigErrorMsg, usedClusterConfigFile, err := readClusterConfig(clusterConfigFile, defaultClusterConfigFile)
 	if err != nil {
 		return usedClusterConfigFile, err
 	}
 	if defaultConfigErrorMsg != nil {
 		c.appendDefaultConfigErrorMessage(*defaultConfigErrorMsg)
 	}
-	clusters, err := decodeClusters(clusterViper.Get("clusters"))
+	c.clusters = clusters
+	err = checkClusterConfig(c.clusters)
+	return usedClusterConfigFile, err
+}
+
+// ReadClusterConfigFile reads the clusters declared in the given cluster config file (or directory), without loading
+// them in the configuration. Returns an error if the file does not exist or if there is any missing key, empty value
+// or duplicate cluster in it.
+func ReadClusterConfigFile(clusterConfigFile string) (map[string]repository.Cluster, error) {
+	clusters, err := readClusterConfigPath(clusterConfigFile)
 	if err != nil {
-		return usedClusterConfigFile, err
+		return nil, err
 	}
-	c.clusters = map[string]repository.Cluster{}
-	for _, configCluster := range clusters {
-		c.clusters[configCluster.URL] = configCluster
+	if err := checkClusterConfig(clusters); err != nil {
+		return nil, err
 	}
-	err = c.checkClusterConfig()
-	return usedClusterConfigFile, err
+	return clusters, nil
 }
 
 // checkClusterConfig checks if there is any missing keys or empty values in oso-clusters.conf
-func (c *ConfigurationData) checkClusterConfig() error {
-	if len(c.clusters) == 0 {
+func checkClusterConfig(clusters map[string]repository.Cluster) error {
+	if len(clusters) == 0 {
 		return errors.New("empty cluster config file")
 	}
 	err := errors.New("")
 	ok := true
-	for _, cluster := range c.clusters {
+	for _, cluster := range clusters {
 		iVal := reflect.ValueOf(&cluster).Elem()
 		typ := iVal.Type()
 		for i := 0; i < iVal.NumField(); i++ {
@@ -211,20 +283,20 @@ func (c *ConfigurationData) checkClusterConfig() error {
 	return nil
 }
 
-func readFromJSONFile(configFilePath, defaultConfigFilePath, configFileName string) (*viper.Viper, *string, string, error) {
-	jsonViper := viper.New()
-	jsonViper.SetTypeByDefaultValue(true)
-
+// readClusterConfig reads the clusters declared in the given cluster config file (or directory). If no file is given,
+// then the clusters are read from the default config file, or from the built-in config file (used in dev mode) if the
+// default config file does not exist.
+func readClusterConfig(configFilePath, defaultConfigFilePath string) (map[string]repository.Cluster, *string, string, error) {
 	var err error
 	var etcJSONConfigUsed bool
 	var defaultConfigErrorMsg *string
 	if configFilePath != "" {
-		// If a JSON configuration file has been specified, check if it exists
+		// If a configuration file has been specified, check if it exists
 		if _, err := os.Stat(configFilePath); err != nil {
 			return nil, nil, configFilePath, err
 		}
 	} else {
-		// If the JSON configuration file has not been specified
+		// If the configuration file has not been specified
 		// then we default to <defaultConfigFile>
 		configFilePath, err = PathExists(defaultConfigFilePath)
 		if err != nil {
@@ -239,24 +311,32 @@ func readFromJSONFile(configFilePath, defaultConfigFilePath, configFileName stri
 	}
 	usedFile := configFilePath
 
-	jsonViper.SetConfigType("json")
 	if configFilePath == "" {
 		// Load the built-in config file (used in dev mode)
-		usedFile = "./configuration/conf-files/" + configFileName
-		data, err := Asset(configFileName)
+		usedFile = "./configuration/conf-files/" + osoClusterConfigFileName
+		data, err := Asset(osoClusterConfigFileName)
 		if err != nil {
 			return nil, nil, usedFile, err
 		}
+		jsonViper := viper.New()
+		jsonViper.SetTypeByDefaultValue(true)
+		jsonViper.SetConfigType("json")
 		jsonViper.ReadConfig(bytes.NewBuffer(data))
-	} else {
-		jsonViper.SetConfigFile(configFilePath)
-		err := jsonViper.ReadInConfig()
+		clusters, err := decodeClusterConfig(jsonViper)
 		if err != nil {
-			return nil, nil, usedFile, errors.Errorf("failed to load the JSON config file (%s): %s \n", configFilePath, err)
+			return nil, nil, usedFile, err
+		}
+		sources := newClusterConfigSources()
+		if err := sources.add(usedFile, clusters); err != nil {
+			return nil, nil, usedFile, err
 		}
+		return sources.clusters, defaultConfigErrorMsg, usedFile, nil
 	}
-
-	return jsonViper, defaultConfigErrorMsg, usedFile, nil
+	clusters, err := readClusterConfigPath(configFilePath)
+	if err != nil {
+		return nil, nil, usedFile, err
+	}
+	return clusters, defaultConfigErrorMsg, usedFile, nil
 }
 
 func (c *ConfigurationData) appendDefaultConfigErrorMessage(message string) {
@@ -291,11 +371,39 @@ func getOSOClusterConfigFile() string {
 
 // ReloadClusterConfig reloads the cluster from the config file
 func (c *ConfigurationData) ReloadClusterConfig() error {
+	clusters, err := c.ReadClusterConfig()
+	if err != nil {
+		return err
+	}
+	c.LoadClusterConfig(clusters)
+	return nil
+}
+
+// ReadClusterConfig reads the clusters declared in the cluster config file (or directory) of the configuration,
+// without loading them. Returns an error if there is any missing key, empty value or duplicate cluster in it.
+// The clusters can then be loaded with `LoadClusterConfig`, so that the loaded clusters are the ones which were read
+// (and validated), even if the file changed in the mean time.
+func (c *ConfigurationData) ReadClusterConfig() (map[string]repository.Cluster, error) {
+	c.mux.RLock()
+	clusterConfigFilePath := c.clusterConfigFilePath
+	c.mux.RUnlock()
+
+	clusters, _, _, err := readClusterConfig("", clusterConfigFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClusterConfig(clusters); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+// LoadClusterConfig loads the given clusters in the configuration, in place of the current ones
+func (c *ConfigurationData) LoadClusterConfig(clusters map[string]repository.Cluster) {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
-	_, err := c.initClusterConfig("", c.clusterConfigFilePath)
-	return err
+	c.clusters = clusters
 }
 
 // DefaultConfigurationError returns an error if the default values is used
@@ -349,6 +457,28 @@ func (c *ConfigurationData) GetClusters() map[string]repository.Cluster {
 	return c.clusters
 }
 
+// GetClusterConfigSecretFiles returns the paths of the files which contain the secrets referenced in the cluster
+// configuration (`service-account-token-file` and `auth-client-secret-file`), in lexical order
+func (c *ConfigurationData) GetClusterConfigSecretFiles() []string {
+	// Lock for reading because config file watcher can update cluster configuration
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	files := map[string]bool{}
+	for _, cluster := range c.clusters {
+		for _, file := range []string{cluster.SATokenFile, cluster.AuthClientSecretFile} {
+			if file != "" {
+				files[file] = true
+			}
+		}
+	}
+	result := make([]string, 0, len(files))
+	for file := range files {
+		result = append(result, file)
+	}
+	sort.Strings(result)
+	return result
+}
+
 // GetClusterByURL returns a cluster configurations by matching URL
 // Regardless of trailing slashes if cluster API URL == "https://api.openshift.com"
 // or "https://api.openshift.com/" it will match any "https://api.openshift.com*"
@@ -434,6 +564,71 @@ func (c *ConfigurationData) setConfigDefaults() {
 	c.v.SetDefault(varEnvironment, "local")
 
 	c.v.SetDefault(varAuthKeysPath, "/api/token/keys")
+
+	//------------------
+	// Cluster placement
+	//------------------
+	c.v.SetDefault(varClusterPlacementStrategy, defaultClusterPlacementStrategy)
+	c.v.SetDefault(varClusterPlacementWeights, "")
+
+	//------------------
+	// Cluster capacity
+	//------------------
+	c.v.SetDefault(varClusterCapacityThreshold, defaultClusterCapacityThreshold)
+
+	//------------------
+	// Cluster health
+	//------------------
+	c.v.SetDefault(varClusterHealthProbeInterval, time.Duration(time.Minute))
+	c.v.SetDefault(varClusterHealthProbeTimeout, time.Duration(5*time.Second))
+	c.v.SetDefault(varClusterHealthProbeConcurrency, defaultClusterHealthProbeConcurrency)
+
+	//------------------
+	// Cluster secrets encryption
+	//------------------
+	c.v.SetDefault(varClusterEncryptionKeys, "")
+	c.v.SetDefault(varClusterEncryptionKeyID, "")
+
+	//------------------
+	// Cluster SA token rotation
+	//------------------
+	c.v.SetDefault(varClusterSATokenRotationGracePeriod, time.Duration(time.Hour))
+	c.v.SetDefault(varClusterSATokenPromotionInterval, time.Duration(time.Minute))
+
+	//------------------
+	// Cluster API representation
+	//------------------
+	c.v.SetDefault(varClusterAPICompatibilityModeEnabled, true)
+
+	//------------------
+	// Cluster configuration file synchronization
+	//------------------
+	c.v.SetDefault(varClusterConfigSyncPolicy, defaultClusterConfigSyncPolicy)
+	c.v.SetDefault(varClusterConfigSyncMaxDecommissions, defaultClusterConfigSyncMaxDecommissions)
+	c.v.SetDefault(varClusterConfigSyncMaxIdentityLinksLosses, defaultClusterConfigSyncMaxIdentityLinksLosses)
+	c.v.SetDefault(varClusterConfigWatcherDebounce, time.Duration(500*time.Millisecond))
+
+	//------------------
+	// Cluster watch
+	//------------------
+	c.v.SetDefault(varClusterWatchPollInterval, time.Duration(5*time.Second))
+	c.v.SetDefault(varClusterWatchTimeout, time.Duration(30*time.Minute))
+
+	//------------------
+	// Leader election
+	//------------------
+	c.v.SetDefault(varLeaderElectionLeaseDuration, time.Duration(15*time.Second))
+	c.v.SetDefault(varLeaderElectionRenewInterval, time.Duration(5*time.Second))
+
+	//------------------
+	// Webhooks
+	//------------------
+	c.v.SetDefault(varWebhookDispatchInterval, time.Duration(5*time.Second))
+	c.v.SetDefault(varWebhookDispatchTimeout, time.Duration(time.Minute))
+	c.v.SetDefault(varWebhookDeliveryTimeout, time.Duration(10*time.Second))
+	c.v.SetDefault(varWebhookDeliveryMaxAttempts, 8)
+	c.v.SetDefault(varWebhookDeliveryBackoff, time.Duration(10*time.Second))
+	c.v.SetDefault(varWebhookDeliveryRetention, time.Duration(7*24*time.Hour))
 }
 
 // GetPostgresHost returns the postgres host as set via default, config file, or environment variable
@@ -568,6 +763,223 @@ func (c *ConfigurationData) GetEnvironment() string {
 	return c.v.GetString(varEnvironment)
 }
 
+// GetClusterPlacementStrategy returns the name of the default strategy used to select the cluster on which
+// a new identity should be provisioned (default: "least-loaded")
+func (c *ConfigurationData) GetClusterPlacementStrategy() string {
+	return c.v.GetString(varClusterPlacementStrategy)
+}
+
+// GetClusterPlacementWeights returns the weights used by the "weighted-round-robin" placement strategy, indexed by
+// cluster name. The weights are configured as a comma-separated list of `name=weight` pairs, eg: "us-east-2=3,us-east-2a=1".
+// Invalid entries are ignored.
+func (c *ConfigurationData) GetClusterPlacementWeights() map[string]int {
+	weights := map[string]int{}
+	for _, entry := range strings.Split(c.v.GetString(varClusterPlacementWeights), ",") {
+		kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			log.WithFields(map[string]interface{}{
+				"entry": entry,
+				"err":   err,
+			}).Warningln("ignoring invalid cluster placement weight")
+			continue
+		}
+		weights[strings.TrimSpace(kv[0])] = w
+	}
+	return weights
+}
+
+// GetClusterCapacityThreshold returns the default percentage of the maximum number of identities above which
+// the capacity of a cluster is considered as exhausted (default: 90). This value applies to the clusters
+// which have a `max-identities` limit but no specific `capacity-threshold`.
+// A value outside of the 1-100 range is ignored.
+func (c *ConfigurationData) GetClusterCapacityThreshold() int {
+	threshold := c.v.GetInt(varClusterCapacityThreshold)
+	if threshold < 1 || threshold > 100 {
+		log.WithFields(map[string]interface{}{
+			"threshold": threshold,
+		}).Warningln("ignoring invalid cluster capacity threshold (expected a percentage between 1 and 100)")
+		return defaultClusterCapacityThreshold
+	}
+	return threshold
+}
+
+// GetClusterHealthProbeInterval returns the interval between two health probes of the clusters (default: 1m).
+// A zero or negative value disables the health prober.
+func (c *ConfigurationData) GetClusterHealthProbeInterval() time.Duration {
+	return c.v.GetDuration(varClusterHealthProbeInterval)
+}
+
+// GetClusterHealthProbeTimeout returns the timeout of each request sent to the cluster URLs during a health probe (default: 5s)
+func (c *ConfigurationData) GetClusterHealthProbeTimeout() time.Duration {
+	return c.v.GetDuration(varClusterHealthProbeTimeout)
+}
+
+// GetClusterHealthProbeConcurrency returns the maximum number of clusters probed at the same time during a health
+// probe (default: 10). A zero or negative value is ignored.
+func (c *ConfigurationData) GetClusterHealthProbeConcurrency() int {
+	concurrency := c.v.GetInt(varClusterHealthProbeConcurrency)
+	if concurrency < 1 {
+		log.WithFields(map[string]interface{}{
+			"concurrency": concurrency,
+		}).Warningln("ignoring invalid cluster health probe concurrency (expected a positive number)")
+		return defaultClusterHealthProbeConcurrency
+	}
+	return concurrency
+}
+
+// GetClusterEncryptionKeys returns the keys used to encrypt the secrets of the clusters at rest, indexed by ID.
+// The keys are configured as a comma-separated list of `id=key` pairs, where each key is base64-encoded and
+// 16, 24 or 32 bytes long, eg: "2018-11=<key>,2018-12=<key>". Invalid entries are ignored.
+// In Dev Mode, a default key is returned if none was configured.
+func (c *ConfigurationData) GetClusterEncryptionKeys() map[string][]byte {
+	keys := map[string][]byte{}
+	for _, entry := range strings.Split(c.v.GetString(varClusterEncryptionKeys), ",") {
+		kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(kv[1]))
+		if err != nil {
+			log.WithFields(map[string]interface{}{
+				"key_id": kv[0],
+				"err":    err,
+			}).Warningln("ignoring invalid cluster encryption key")
+			continue
+		}
+		keys[strings.TrimSpace(kv[0])] = key
+	}
+	if len(keys) == 0 && c.DeveloperModeEnabled() {
+		key, _ := base64.StdEncoding.DecodeString(devModeClusterEncryptionKey)
+		keys[devModeClusterEncryptionKeyID] = key
+	}
+	return keys
+}
+
+// GetClusterEncryptionKeyID returns the ID of the key used to encrypt the secrets of the clusters. If not set
+// and a single key is configured, then the ID of this key is returned.
+// Secrets encrypted with other keys remain readable as long as these keys are still configured.
+func (c *ConfigurationData) GetClusterEncryptionKeyID() string {
+	if keyID := c.v.GetString(varClusterEncryptionKeyID); keyID != "" {
+		return keyID
+	}
+	keys := c.GetClusterEncryptionKeys()
+	if len(keys) == 1 {
+		for keyID := range keys {
+			return keyID
+		}
+	}
+	return ""
+}
+
+// GetClusterSATokenRotationGracePeriod returns the default period during which the pending SA token of a cluster
+// is valid along with the current one, before it replaces it (default: 1h)
+func (c *ConfigurationData) GetClusterSATokenRotationGracePeriod() time.Duration {
+	return c.v.GetDuration(varClusterSATokenRotationGracePeriod)
+}
+
+// GetClusterSATokenPromotionInterval returns the interval between two checks for the pending SA tokens whose grace
+// period is over (default: 1m). A zero or negative value disables the automatic promotion of the pending SA tokens.
+func (c *ConfigurationData) GetClusterSATokenPromotionInterval() time.Duration {
+	return c.v.GetDuration(varClusterSATokenPromotionInterval)
+}
+
+// IsClusterAPICompatibilityModeEnabled returns `true` if the cluster resources returned by the API also include the
+// legacy top-level attributes, with the cluster type in the `type` member (default: true).
+// Otherwise, the cluster resources are plain JSON-API resource objects of type `clusters`.
+func (c *ConfigurationData) IsClusterAPICompatibilityModeEnabled() bool {
+	return c.v.GetBool(varClusterAPICompatibilityModeEnabled)
+}
+
+// GetClusterConfigSyncPolicy returns the policy to synchronize the clusters in the database with the cluster configuration
+// file: `authoritative`, `merge-only` or `disabled` (default: "authoritative"). In all cases, the clusters registered
+// through the REST API are left untouched.
+func (c *ConfigurationData) GetClusterConfigSyncPolicy() string {
+	return c.v.GetString(varClusterConfigSyncPolicy)
+}
+
+// GetClusterConfigSyncMaxDecommissions returns the maximum number of clusters which can be decommissioned when
+// the cluster configuration file is applied (default: 3). A negative value means no limit.
+func (c *ConfigurationData) GetClusterConfigSyncMaxDecommissions() int {
+	return c.v.GetInt(varClusterConfigSyncMaxDecommissions)
+}
+
+// GetClusterConfigSyncMaxIdentityLinksLosses returns the maximum number of identities which can be linked to the
+// clusters decommissioned when the cluster configuration file is applied (default: 1000). A negative value means no limit.
+func (c *ConfigurationData) GetClusterConfigSyncMaxIdentityLinksLosses() int {
+	return c.v.GetInt(varClusterConfigSyncMaxIdentityLinksLosses)
+}
+
+// GetClusterConfigWatcherDebounce returns the delay without any change of the cluster config file (or of the secret
+// files referenced in it) after which the configuration is reloaded (default: 500ms)
+func (c *ConfigurationData) GetClusterConfigWatcherDebounce() time.Duration {
+	return c.v.GetDuration(varClusterConfigWatcherDebounce)
+}
+
+// GetClusterWatchPollInterval returns the interval at which each replica of the service checks for new cluster
+// changes to send to its watchers (default: 5s), in addition to the database notifications. This catches the changes
+// which were not settled yet when they were notified, or which were notified while the replica was reconnecting.
+func (c *ConfigurationData) GetClusterWatchPollInterval() time.Duration {
+	return c.v.GetDuration(varClusterWatchPollInterval)
+}
+
+// GetClusterWatchTimeout returns the maximum duration of a watch of the cluster changes (default: 30m), after which
+// the client must resume watching from the last resource version it received
+func (c *ConfigurationData) GetClusterWatchTimeout() time.Duration {
+	return c.v.GetDuration(varClusterWatchTimeout)
+}
+
+// GetLeaderElectionLeaseDuration returns the duration of the lease held by the leader among the replicas of the
+// service (default: 15s). If the leader does not renew its lease within this duration, another replica takes over.
+func (c *ConfigurationData) GetLeaderElectionLeaseDuration() time.Duration {
+	return c.v.GetDuration(varLeaderElectionLeaseDuration)
+}
+
+// GetLeaderElectionRenewInterval returns the interval at which each replica of the service tries to acquire
+// or renew the leader lease (default: 5s). It should be well below the lease duration.
+// `0` disables the leader election, in which case each replica acts as the leader.
+func (c *ConfigurationData) GetLeaderElectionRenewInterval() time.Duration {
+	return c.v.GetDuration(varLeaderElectionRenewInterval)
+}
+
+// GetWebhookDispatchInterval returns the interval at which the cluster change events are dispatched to the webhook
+// subscriptions, and the pending deliveries are attempted (default: 5s). `0` disables the webhooks dispatcher.
+func (c *ConfigurationData) GetWebhookDispatchInterval() time.Duration {
+	return c.v.GetDuration(varWebhookDispatchInterval)
+}
+
+// GetWebhookDispatchTimeout returns the maximum duration of a dispatch of the cluster change events (default: 1m).
+// The deliveries which could not be attempted in time are postponed to the next dispatch.
+func (c *ConfigurationData) GetWebhookDispatchTimeout() time.Duration {
+	return c.v.GetDuration(varWebhookDispatchTimeout)
+}
+
+// GetWebhookDeliveryTimeout returns the duration after which a webhook delivery attempt times out (default: 10s)
+func (c *ConfigurationData) GetWebhookDeliveryTimeout() time.Duration {
+	return c.v.GetDuration(varWebhookDeliveryTimeout)
+}
+
+// GetWebhookDeliveryMaxAttempts returns the number of attempts after which a webhook delivery is given up and
+// moved to the dead letters of its subscription (default: 8)
+func (c *ConfigurationData) GetWebhookDeliveryMaxAttempts() int {
+	return c.v.GetInt(varWebhookDeliveryMaxAttempts)
+}
+
+// GetWebhookDeliveryBackoff returns the delay before the second attempt of a failed webhook delivery (default: 10s).
+// The delay doubles after each failed attempt, up to 1h.
+func (c *ConfigurationData) GetWebhookDeliveryBackoff() time.Duration {
+	return c.v.GetDuration(varWebhookDeliveryBackoff)
+}
+
+// GetWebhookDeliveryRetention returns the duration during which the successful webhook deliveries, and the dispatched
+// outbox events, are kept (default: 168h). The watches of the clusters cannot be resumed from older changes.
+func (c *ConfigurationData) GetWebhookDeliveryRetention() time.Duration {
+	return c.v.GetDuration(varWebhookDeliveryRetention)
+}
+
 // GetDevModePrivateKey returns the private key and its ID used in tests
 func (c *ConfigurationData) GetDevModePrivateKey() []byte {
 	if c.DeveloperModeEnabled() {
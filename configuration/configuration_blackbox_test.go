@@ -3,7 +3,9 @@ package configuration_test
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 
@@ -137,6 +139,475 @@ func (s *ConfigurationBlackboxTestSuite) TestGetSentryDSNOK() {
 	assert.Equal(s.T(), "something", s.config.GetSentryDSN())
 }
 
+func (s *ConfigurationBlackboxTestSuite) TestGetClusterEncryptionKeys() {
+	existingKeys := os.Getenv("F8_CLUSTER_ENCRYPTION_KEYS")
+	existingKeyID := os.Getenv("F8_CLUSTER_ENCRYPTION_KEY_ID")
+	defer func() {
+		os.Setenv("F8_CLUSTER_ENCRYPTION_KEYS", existingKeys)
+		os.Setenv("F8_CLUSTER_ENCRYPTION_KEY_ID", existingKeyID)
+	}()
+
+	s.T().Run("default key in dev mode", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_CLUSTER_ENCRYPTION_KEYS")
+		os.Unsetenv("F8_CLUSTER_ENCRYPTION_KEY_ID")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		keys := config.GetClusterEncryptionKeys()
+		require.Len(t, keys, 1)
+		assert.Len(t, keys["dev"], 32)
+		assert.Equal(t, "dev", config.GetClusterEncryptionKeyID())
+		assert.Contains(t, config.DefaultConfigurationError().Error(), "default cluster secrets encryption key is used")
+	})
+
+	s.T().Run("configured keys", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_ENCRYPTION_KEYS", "2018-11=MDEyMzQ1Njc4OWFiY2RlZg==, 2018-12=ZmVkY2JhOTg3NjU0MzIxMA==,invalid=%%%,foo")
+		os.Setenv("F8_CLUSTER_ENCRYPTION_KEY_ID", "2018-12")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]byte{
+			"2018-11": []byte("0123456789abcdef"),
+			"2018-12": []byte("fedcba9876543210"),
+		}, config.GetClusterEncryptionKeys())
+		assert.Equal(t, "2018-12", config.GetClusterEncryptionKeyID())
+		assert.NotContains(t, config.DefaultConfigurationError().Error(), "cluster secrets encryption key")
+	})
+
+	s.T().Run("single configured key", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_ENCRYPTION_KEYS", "2018-11=MDEyMzQ1Njc4OWFiY2RlZg==")
+		os.Unsetenv("F8_CLUSTER_ENCRYPTION_KEY_ID")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "2018-11", config.GetClusterEncryptionKeyID())
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestIsClusterAPICompatibilityModeEnabled() {
+	existing := os.Getenv("F8_CLUSTER_API_COMPATIBILITY_MODE_ENABLED")
+	defer func() {
+		os.Setenv("F8_CLUSTER_API_COMPATIBILITY_MODE_ENABLED", existing)
+	}()
+
+	s.T().Run("enabled by default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_CLUSTER_API_COMPATIBILITY_MODE_ENABLED")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.True(t, config.IsClusterAPICompatibilityModeEnabled())
+	})
+
+	s.T().Run("disabled", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_API_COMPATIBILITY_MODE_ENABLED", "false")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.False(t, config.IsClusterAPICompatibilityModeEnabled())
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestGetClusterConfigSyncPolicy() {
+	existing := os.Getenv("F8_CLUSTER_CONFIG_SYNC_POLICY")
+	defer func() {
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", existing)
+	}()
+
+	s.T().Run("authoritative by default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_CLUSTER_CONFIG_SYNC_POLICY")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, configuration.ClusterConfigSyncPolicyAuthoritative, config.GetClusterConfigSyncPolicy())
+	})
+
+	s.T().Run("merge-only", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", "merge-only")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, configuration.ClusterConfigSyncPolicyMergeOnly, config.GetClusterConfigSyncPolicy())
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestGetClusterCapacityThreshold() {
+	existingThreshold, found := os.LookupEnv("F8_CLUSTER_CAPACITY_THRESHOLD")
+	defer func() {
+		if found {
+			os.Setenv("F8_CLUSTER_CAPACITY_THRESHOLD", existingThreshold)
+		} else {
+			os.Unsetenv("F8_CLUSTER_CAPACITY_THRESHOLD")
+		}
+	}()
+
+	s.T().Run("default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_CLUSTER_CAPACITY_THRESHOLD")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 90, config.GetClusterCapacityThreshold())
+	})
+
+	s.T().Run("custom", func(t *testing.T) {
+		for _, threshold := range []int{1, 75, 100} {
+			t.Run(strconv.Itoa(threshold), func(t *testing.T) {
+				// given
+				os.Setenv("F8_CLUSTER_CAPACITY_THRESHOLD", strconv.Itoa(threshold))
+				// when
+				config, err := configuration.GetConfigurationData()
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, threshold, config.GetClusterCapacityThreshold())
+			})
+		}
+	})
+
+	s.T().Run("invalid", func(t *testing.T) {
+		for _, threshold := range []int{-10, 0, 101, 150} {
+			t.Run(strconv.Itoa(threshold), func(t *testing.T) {
+				// given
+				os.Setenv("F8_CLUSTER_CAPACITY_THRESHOLD", strconv.Itoa(threshold))
+				// when
+				config, err := configuration.GetConfigurationData()
+				// then the default value applies
+				require.NoError(t, err)
+				assert.Equal(t, 90, config.GetClusterCapacityThreshold())
+			})
+		}
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestGetClusterHealthProbeConcurrency() {
+	existingConcurrency, found := os.LookupEnv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY")
+	defer func() {
+		if found {
+			os.Setenv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY", existingConcurrency)
+		} else {
+			os.Unsetenv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY")
+		}
+	}()
+
+	s.T().Run("default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 10, config.GetClusterHealthProbeConcurrency())
+	})
+
+	s.T().Run("custom", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY", "3")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, config.GetClusterHealthProbeConcurrency())
+	})
+
+	s.T().Run("invalid", func(t *testing.T) {
+		for _, concurrency := range []int{-1, 0} {
+			t.Run(strconv.Itoa(concurrency), func(t *testing.T) {
+				// given
+				os.Setenv("F8_CLUSTER_HEALTH_PROBE_CONCURRENCY", strconv.Itoa(concurrency))
+				// when
+				config, err := configuration.GetConfigurationData()
+				// then the default value applies
+				require.NoError(t, err)
+				assert.Equal(t, 10, config.GetClusterHealthProbeConcurrency())
+			})
+		}
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestGetClusterConfigSyncThresholds() {
+	existingMaxDecommissions := os.Getenv("F8_CLUSTER_CONFIG_SYNC_MAX_DECOMMISSIONS")
+	existingMaxIdentityLinksLosses := os.Getenv("F8_CLUSTER_CONFIG_SYNC_MAX_IDENTITY_LINKS_LOSSES")
+	defer func() {
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_MAX_DECOMMISSIONS", existingMaxDecommissions)
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_MAX_IDENTITY_LINKS_LOSSES", existingMaxIdentityLinksLosses)
+	}()
+
+	s.T().Run("default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_CLUSTER_CONFIG_SYNC_MAX_DECOMMISSIONS")
+		os.Unsetenv("F8_CLUSTER_CONFIG_SYNC_MAX_IDENTITY_LINKS_LOSSES")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, config.GetClusterConfigSyncMaxDecommissions())
+		assert.Equal(t, 1000, config.GetClusterConfigSyncMaxIdentityLinksLosses())
+	})
+
+	s.T().Run("custom", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_MAX_DECOMMISSIONS", "-1")
+		os.Setenv("F8_CLUSTER_CONFIG_SYNC_MAX_IDENTITY_LINKS_LOSSES", "10")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, -1, config.GetClusterConfigSyncMaxDecommissions())
+		assert.Equal(t, 10, config.GetClusterConfigSyncMaxIdentityLinksLosses())
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestGetClusterConfigWatcherDebounce() {
+	existingDebounce := os.Getenv("F8_CLUSTER_CONFIG_WATCHER_DEBOUNCE")
+	defer os.Setenv("F8_CLUSTER_CONFIG_WATCHER_DEBOUNCE", existingDebounce)
+
+	s.T().Run("default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_CLUSTER_CONFIG_WATCHER_DEBOUNCE")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 500*time.Millisecond, config.GetClusterConfigWatcherDebounce())
+	})
+
+	s.T().Run("custom", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_CONFIG_WATCHER_DEBOUNCE", "2s")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Second, config.GetClusterConfigWatcherDebounce())
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestGetLeaderElectionSettings() {
+	existingDuration := os.Getenv("F8_LEADER_ELECTION_LEASE_DURATION")
+	existingInterval := os.Getenv("F8_LEADER_ELECTION_RENEW_INTERVAL")
+	defer func() {
+		os.Setenv("F8_LEADER_ELECTION_LEASE_DURATION", existingDuration)
+		os.Setenv("F8_LEADER_ELECTION_RENEW_INTERVAL", existingInterval)
+	}()
+
+	s.T().Run("default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_LEADER_ELECTION_LEASE_DURATION")
+		os.Unsetenv("F8_LEADER_ELECTION_RENEW_INTERVAL")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 15*time.Second, config.GetLeaderElectionLeaseDuration())
+		assert.Equal(t, 5*time.Second, config.GetLeaderElectionRenewInterval())
+	})
+
+	s.T().Run("custom", func(t *testing.T) {
+		// given
+		os.Setenv("F8_LEADER_ELECTION_LEASE_DURATION", "1m")
+		os.Setenv("F8_LEADER_ELECTION_RENEW_INTERVAL", "0")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, config.GetLeaderElectionLeaseDuration())
+		assert.Equal(t, time.Duration(0), config.GetLeaderElectionRenewInterval())
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestGetClusterWatchSettings() {
+	existingPollInterval := os.Getenv("F8_CLUSTER_WATCH_POLL_INTERVAL")
+	existingTimeout := os.Getenv("F8_CLUSTER_WATCH_TIMEOUT")
+	defer func() {
+		os.Setenv("F8_CLUSTER_WATCH_POLL_INTERVAL", existingPollInterval)
+		os.Setenv("F8_CLUSTER_WATCH_TIMEOUT", existingTimeout)
+	}()
+
+	s.T().Run("default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_CLUSTER_WATCH_POLL_INTERVAL")
+		os.Unsetenv("F8_CLUSTER_WATCH_TIMEOUT")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Second, config.GetClusterWatchPollInterval())
+		assert.Equal(t, 30*time.Minute, config.GetClusterWatchTimeout())
+	})
+
+	s.T().Run("custom", func(t *testing.T) {
+		// given
+		os.Setenv("F8_CLUSTER_WATCH_POLL_INTERVAL", "1s")
+		os.Setenv("F8_CLUSTER_WATCH_TIMEOUT", "5m")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, time.Second, config.GetClusterWatchPollInterval())
+		assert.Equal(t, 5*time.Minute, config.GetClusterWatchTimeout())
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestGetWebhookSettings() {
+	existingInterval := os.Getenv("F8_WEBHOOK_DISPATCH_INTERVAL")
+	existingTimeout := os.Getenv("F8_WEBHOOK_DISPATCH_TIMEOUT")
+	existingMaxAttempts := os.Getenv("F8_WEBHOOK_DELIVERY_MAX_ATTEMPTS")
+	existingBackoff := os.Getenv("F8_WEBHOOK_DELIVERY_BACKOFF")
+	defer func() {
+		os.Setenv("F8_WEBHOOK_DISPATCH_INTERVAL", existingInterval)
+		os.Setenv("F8_WEBHOOK_DISPATCH_TIMEOUT", existingTimeout)
+		os.Setenv("F8_WEBHOOK_DELIVERY_MAX_ATTEMPTS", existingMaxAttempts)
+		os.Setenv("F8_WEBHOOK_DELIVERY_BACKOFF", existingBackoff)
+	}()
+
+	s.T().Run("default", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_WEBHOOK_DISPATCH_INTERVAL")
+		os.Unsetenv("F8_WEBHOOK_DISPATCH_TIMEOUT")
+		os.Unsetenv("F8_WEBHOOK_DELIVERY_MAX_ATTEMPTS")
+		os.Unsetenv("F8_WEBHOOK_DELIVERY_BACKOFF")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Second, config.GetWebhookDispatchInterval())
+		assert.Equal(t, time.Minute, config.GetWebhookDispatchTimeout())
+		assert.Equal(t, 10*time.Second, config.GetWebhookDeliveryTimeout())
+		assert.Equal(t, 8, config.GetWebhookDeliveryMaxAttempts())
+		assert.Equal(t, 10*time.Second, config.GetWebhookDeliveryBackoff())
+		assert.Equal(t, 7*24*time.Hour, config.GetWebhookDeliveryRetention())
+	})
+
+	s.T().Run("custom", func(t *testing.T) {
+		// given
+		os.Setenv("F8_WEBHOOK_DISPATCH_INTERVAL", "0")
+		os.Setenv("F8_WEBHOOK_DISPATCH_TIMEOUT", "30s")
+		os.Setenv("F8_WEBHOOK_DELIVERY_MAX_ATTEMPTS", "3")
+		os.Setenv("F8_WEBHOOK_DELIVERY_BACKOFF", "1m")
+		// when
+		config, err := configuration.GetConfigurationData()
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, time.Duration(0), config.GetWebhookDispatchInterval())
+		assert.Equal(t, 30*time.Second, config.GetWebhookDispatchTimeout())
+		assert.Equal(t, 3, config.GetWebhookDeliveryMaxAttempts())
+		assert.Equal(t, time.Minute, config.GetWebhookDeliveryBackoff())
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestReadClusterConfigFile() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		clusters, err := configuration.ReadClusterConfigFile("./conf-files/tests/oso-clusters-with-removed-clusters.conf")
+		// then
+		require.NoError(t, err)
+		assert.Len(t, clusters, 3)
+		assert.Contains(t, clusters, "https://api.starter-us-east-2a.openshift.com")
+		// and the loaded configuration is unchanged
+		assert.Len(t, s.config.GetClusters(), 4)
+	})
+
+	s.T().Run("missing keys", func(t *testing.T) {
+		// when
+		_, err := configuration.ReadClusterConfigFile("./conf-files/tests/oso-clusters-missing-keys.conf")
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "key name is missing")
+	})
+
+	s.T().Run("directory", func(t *testing.T) {
+		// when
+		clusters, err := configuration.ReadClusterConfigFile("./conf-files/tests/oso-clusters.d")
+		// then
+		require.NoError(t, err)
+		assert.Len(t, clusters, 3)
+	})
+
+	s.T().Run("duplicate urls", func(t *testing.T) {
+		// when
+		_, err := configuration.ReadClusterConfigFile("./conf-files/tests/oso-clusters-duplicate-urls.conf")
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate cluster with url 'https://api.starter-us-east-4a.openshift.com")
+	})
+
+	s.T().Run("duplicate names in different files", func(t *testing.T) {
+		// when
+		_, err := configuration.ReadClusterConfigFile("./conf-files/tests/oso-clusters-duplicate-names.d")
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "duplicate cluster with name 'us-east-2' in conf-files/tests/oso-clusters-duplicate-names.d/a.json and conf-files/tests/oso-clusters-duplicate-names.d/b.yaml")
+	})
+
+	s.T().Run("unknown file", func(t *testing.T) {
+		// when
+		_, err := configuration.ReadClusterConfigFile("./conf-files/tests/unknown.conf")
+		// then
+		require.Error(t, err)
+	})
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestReadAndLoadClusterConfig() {
+	// given
+	config, err := configuration.NewConfigurationData("", "./conf-files/oso-clusters.conf")
+	require.NoError(s.T(), err)
+	other, err := configuration.ReadClusterConfigFile("./conf-files/tests/oso-clusters-with-removed-clusters.conf")
+	require.NoError(s.T(), err)
+	// when
+	clusters, err := config.ReadClusterConfig()
+	// then
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), clusters, 4)
+	assert.Contains(s.T(), clusters, "https://api.starter-us-east-1a.openshift.com")
+	// when another snapshot is loaded
+	config.LoadClusterConfig(other)
+	// then the loaded clusters are the ones of this snapshot
+	assert.Equal(s.T(), other, config.GetClusters())
+	assert.Nil(s.T(), config.GetClusterByURL("https://api.starter-us-east-1a.openshift.com"))
+}
+
+func (s *ConfigurationBlackboxTestSuite) TestLoadClusterConfigurationFromDirectory() {
+	// when
+	config, err := configuration.NewConfigurationData("", "./conf-files/tests/oso-clusters.d")
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "./conf-files/tests/oso-clusters.d", config.GetClusterConfigurationFilePath())
+	clusters := config.GetClusters()
+	require.Len(s.T(), clusters, 3)
+	// cluster declared in a list in a JSON file
+	c := config.GetClusterByURL("https://api.starter-us-east-2.openshift.com")
+	require.NotNil(s.T(), c)
+	assert.Equal(s.T(), "us-east-2", c.Name)
+	assert.Equal(s.T(), "OSO", c.Type)
+	// cluster declared alone in a YAML file
+	c = config.GetClusterByURL("https://api.starter-us-east-2a.openshift.com")
+	require.NotNil(s.T(), c)
+	assert.Equal(s.T(), "us-east-2a", c.Name)
+	assert.Equal(s.T(), "autheast2asecret", c.AuthClientSecret)
+	assert.False(s.T(), c.CapacityExhausted)
+	// cluster declared in a list in a YAML file
+	c = config.GetClusterByURL("https://api.starter-us-east-3a.openshift.com")
+	require.NotNil(s.T(), c)
+	assert.Equal(s.T(), "OSD", c.Type)
+	assert.True(s.T(), c.CapacityExhausted)
+	assert.True(s.T(), c.SATokenEncrypted)
+}
+
 func (s *ConfigurationBlackboxTestSuite) TestLoadDefaultClusterConfiguration() {
 	// when
 	clusters := s.config.GetClusters()
@@ -159,6 +630,59 @@ func (s *ConfigurationBlackboxTestSuite) TestLoadClusterConfigurationFromFile()
 	checkClusterConfiguration(s.T(), clusters)
 }
 
+func (s *ConfigurationBlackboxTestSuite) TestClusterConfigurationWithSecretReferences() {
+	// given
+	existingToken := os.Getenv("F8_TEST_EAST2A_SA_TOKEN")
+	existingSecret := os.Getenv("F8_TEST_EAST2A_AUTH_CLIENT_SECRET")
+	defer func() {
+		os.Setenv("F8_TEST_EAST2A_SA_TOKEN", existingToken)
+		os.Setenv("F8_TEST_EAST2A_AUTH_CLIENT_SECRET", existingSecret)
+	}()
+	os.Setenv("F8_TEST_EAST2A_SA_TOKEN", "east2a-sa-token-from-env")
+	os.Setenv("F8_TEST_EAST2A_AUTH_CLIENT_SECRET", "autheast2asecretfromenv")
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		config, err := configuration.NewConfigurationData("", "./conf-files/tests/oso-clusters-with-secret-references.yaml")
+		// then
+		require.NoError(t, err)
+		require.Len(t, config.GetClusters(), 2)
+		// secrets read from files
+		c := config.GetClusterByURL("https://api.starter-us-east-2.openshift.com")
+		require.NotNil(t, c)
+		assert.Equal(t, "eyJhbGciOiJSUzI1NiIsImtpZCI6IiJ9.us-east-2-sa-token", c.SAToken)
+		assert.Equal(t, "autheast2secretfromfile", c.AuthClientSecret)
+		// secrets read from environment variables
+		c = config.GetClusterByURL("https://api.starter-us-east-2a.openshift.com")
+		require.NotNil(t, c)
+		assert.Equal(t, "east2a-sa-token-from-env", c.SAToken)
+		assert.Equal(t, "autheast2asecretfromenv", c.AuthClientSecret)
+		assert.Equal(t, []string{
+			"./conf-files/tests/secrets/us-east-2/auth-client-secret",
+			"./conf-files/tests/secrets/us-east-2/service-account-token",
+		}, config.GetClusterConfigSecretFiles())
+	})
+
+	s.T().Run("missing environment variable", func(t *testing.T) {
+		// given
+		os.Unsetenv("F8_TEST_EAST2A_AUTH_CLIENT_SECRET")
+		defer os.Setenv("F8_TEST_EAST2A_AUTH_CLIENT_SECRET", "autheast2asecretfromenv")
+		// when
+		_, err := configuration.ReadClusterConfigFile("./conf-files/tests/oso-clusters-with-secret-references.yaml")
+		// then
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "environment variable 'F8_TEST_EAST2A_AUTH_CLIENT_SECRET' referenced by the auth-client-secret of cluster 'us-east-2a' is not set")
+	})
+
+	s.T().Run("no secret file", func(t *testing.T) {
+		// when
+		config, err := configuration.NewConfigurationData("", "./conf-files/tests/oso-clusters-custom-urls.conf")
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, config.GetClusterConfigSecretFiles())
+	})
+}
+
 func (s *ConfigurationBlackboxTestSuite) TestClusterConfigurationWithMissingKeys() {
 	_, err := configuration.NewConfigurationData("", "./conf-files/tests/oso-clusters-missing-keys.conf")
 	require.Error(s.T(), err)
@@ -63,7 +63,10 @@ func decodeClusters(data interface{}) ([]repository.Cluster, error) {
 			clusters[i] = c
 		}
 	}
-
+	// replace the secrets which reference a file or an environment variable with their actual value
+	if err := resolveSecretReferences(clusters); err != nil {
+		return nil, errors.Wrap(err, "unable to resolve the secrets of config clusters")
+	}
 	return clusters, nil
 }
 
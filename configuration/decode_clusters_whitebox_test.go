@@ -1,6 +1,8 @@
 package configuration
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	uuid "github.com/satori/go.uuid"
@@ -92,6 +94,118 @@ func TestDecodeClusters(t *testing.T) {
 		}, result[1])
 	})
 
+	t.Run("secret references", func(t *testing.T) {
+		// given
+		tokenFile := writeSecretFile(t, "token-from-file\n")
+		defer os.Remove(tokenFile)
+		secretFile := writeSecretFile(t, "secret-from-file")
+		defer os.Remove(secretFile)
+		existingToken := os.Getenv("F8_TEST_CLUSTER_SA_TOKEN")
+		defer os.Setenv("F8_TEST_CLUSTER_SA_TOKEN", existingToken)
+		os.Setenv("F8_TEST_CLUSTER_SA_TOKEN", "token-from-env")
+
+		t.Run("files", func(t *testing.T) {
+			// given
+			data := []map[string]interface{}{
+				{
+					"name":                       "cluster1",
+					"service-account-token-file": tokenFile,
+					"auth-client-secret-file":    secretFile,
+				},
+			}
+			// when
+			result, err := decodeClusters(data)
+			// then
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			assert.Equal(t, "token-from-file", result[0].SAToken)
+			assert.Equal(t, tokenFile, result[0].SATokenFile)
+			assert.Equal(t, "secret-from-file", result[0].AuthClientSecret)
+			assert.Equal(t, secretFile, result[0].AuthClientSecretFile)
+		})
+
+		t.Run("environment variable", func(t *testing.T) {
+			// given
+			data := []map[string]interface{}{
+				{
+					"name":                  "cluster1",
+					"service-account-token": "${env:F8_TEST_CLUSTER_SA_TOKEN}",
+					"auth-client-secret":    "authclientsecret1",
+				},
+			}
+			// when
+			result, err := decodeClusters(data)
+			// then
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			assert.Equal(t, "token-from-env", result[0].SAToken)
+			assert.Equal(t, "authclientsecret1", result[0].AuthClientSecret)
+		})
+
+		t.Run("missing environment variable", func(t *testing.T) {
+			// given
+			data := []map[string]interface{}{
+				{
+					"name":                  "cluster1",
+					"service-account-token": "${env:F8_TEST_CLUSTER_UNKNOWN_SA_TOKEN}",
+				},
+			}
+			// when
+			_, err := decodeClusters(data)
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "environment variable 'F8_TEST_CLUSTER_UNKNOWN_SA_TOKEN' referenced by the service-account-token of cluster 'cluster1' is not set")
+		})
+
+		t.Run("missing file", func(t *testing.T) {
+			// given
+			data := []map[string]interface{}{
+				{
+					"name":                    "cluster1",
+					"auth-client-secret-file": tokenFile + ".missing",
+				},
+			}
+			// when
+			_, err := decodeClusters(data)
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "unable to read the auth-client-secret-file of cluster 'cluster1'")
+		})
+
+		t.Run("empty file", func(t *testing.T) {
+			// given
+			emptyFile := writeSecretFile(t, " \n")
+			defer os.Remove(emptyFile)
+			data := []map[string]interface{}{
+				{
+					"name":                       "cluster1",
+					"service-account-token-file": emptyFile,
+				},
+			}
+			// when
+			_, err := decodeClusters(data)
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "the service-account-token-file of cluster 'cluster1' is empty")
+		})
+
+		t.Run("both value and file", func(t *testing.T) {
+			// given
+			data := []map[string]interface{}{
+				{
+					"name":                       "cluster1",
+					"service-account-token":      "token1",
+					"service-account-token-file": tokenFile,
+				},
+			}
+			// when
+			_, err := decodeClusters(data)
+			// then
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "both service-account-token and service-account-token-file are set for cluster 'cluster1'")
+		})
+	})
+
 	t.Run("errors", func(t *testing.T) {
 
 		t.Run("invalid type", func(t *testing.T) {
@@ -110,3 +224,12 @@ func TestDecodeClusters(t *testing.T) {
 	})
 
 }
+
+func writeSecretFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "cluster-secret")
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	return f.Name()
+}
@@ -10,4 +10,19 @@ const (
 
 	prodEnvironment        = "production"
 	prodPreviewEnvironment = "prod-preview"
+
+	defaultClusterPlacementStrategy = "least-loaded"
+
+	defaultClusterCapacityThreshold = 90
+
+	defaultClusterHealthProbeConcurrency = 10
+
+	defaultClusterConfigSyncPolicy = ClusterConfigSyncPolicyAuthoritative
+
+	defaultClusterConfigSyncMaxDecommissions       = 3
+	defaultClusterConfigSyncMaxIdentityLinksLosses = 1000
+
+	// devModeClusterEncryptionKey is the base64-encoded key used to encrypt the secrets of the clusters in Dev Mode only
+	devModeClusterEncryptionKey   = "ZGV2LW1vZGUtY2x1c3Rlci1lbmNyeXB0aW9uLWtleSE="
+	devModeClusterEncryptionKeyID = "dev"
 )
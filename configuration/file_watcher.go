@@ -0,0 +1,199 @@
+package configuration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// FileWatcher watches a set of files and directories, and calls a handler when their content changes.
+//
+// The parent directories of the files are watched rather than the files themselves, along with the directories
+// of their symbolic link targets. This way, the files which are replaced rather than modified are not lost, such as
+// the keys of the Kubernetes ConfigMaps and Secrets, which are updated by swapping the `..data` symbolic link of
+// the directory in which they are mounted.
+// Bursts of events are debounced, and the handler is only called if the checksum of the content of the watched
+// files and directories changed since the last call.
+type FileWatcher struct {
+	watcher  *fsnotify.Watcher
+	paths    func() []string
+	debounce time.Duration
+	onChange func()
+	// the watched directories
+	dirs map[string]bool
+	// the checksum of the content of the watched paths when the handler was last called
+	checksum string
+}
+
+// NewFileWatcher returns a new watcher of the given paths, which calls the `onChange` handler when their content changes.
+// The paths are re-evaluated after each change, since the files to watch may depend on the content of the watched ones.
+func NewFileWatcher(paths func() []string, debounce time.Duration, onChange func()) (*FileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the file watcher")
+	}
+	w := &FileWatcher{
+		watcher:  watcher,
+		paths:    paths,
+		debounce: debounce,
+		onChange: onChange,
+		dirs:     map[string]bool{},
+	}
+	w.checksum = checksum(paths())
+	if err := w.watchDirs(); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Run processes the file system events until the given context is done. The underlying watcher is closed on return.
+func (w *FileWatcher) Run(ctx context.Context) {
+	defer w.watcher.Close()
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+			log.WithField("file", event.Name).WithField("op", event.Op.String()).Debug("file event received")
+			// (re)start the debounce period
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(w.debounce)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.WithField("err", err).Error("file watcher error")
+		case <-timer.C:
+			w.checkChanges()
+		}
+	}
+}
+
+// checkChanges calls the handler if the content of the watched paths changed, then updates the watched directories
+func (w *FileWatcher) checkChanges() {
+	sum := checksum(w.paths())
+	if sum == w.checksum {
+		log.Debug("content of the watched files unchanged, skipping")
+	} else {
+		w.checksum = sum
+		w.onChange()
+	}
+	// the paths may have changed, and so may have the targets of their symbolic links
+	if err := w.watchDirs(); err != nil {
+		log.WithField("err", err).Error("unable to update the watched directories")
+	}
+}
+
+// watchDirs adds the directories of the watched paths to the underlying watcher, and removes the ones which are
+// not needed anymore
+func (w *FileWatcher) watchDirs() error {
+	dirs := map[string]bool{}
+	for _, path := range w.paths() {
+		for _, dir := range watchedDirs(path) {
+			dirs[dir] = true
+		}
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			// the directory may not exist anymore (eg: the previous target of a swapped symbolic link)
+			w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+	for dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			return errors.Wrapf(err, "unable to watch the '%s' directory", dir)
+		}
+		w.dirs[dir] = true
+		log.WithField("dir", dir).Info("watching directory")
+	}
+	return nil
+}
+
+// watchedDirs returns the directories to watch for the given path: the path itself if it is a directory, its parent
+// directory otherwise, along with the directory of its symbolic link target, if any
+func watchedDirs(path string) []string {
+	var dirs []string
+	for _, p := range []string{path, resolveSymlinks(path)} {
+		dir := filepath.Dir(p)
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			dir = filepath.Clean(p)
+		}
+		if len(dirs) == 0 || dirs[0] != dir {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// resolveSymlinks returns the target of the given path if it is a symbolic link, or the path itself otherwise
+func resolveSymlinks(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+	return resolved
+}
+
+// checksum returns the checksum of the content of the given files and directories (all the files in the directories,
+// ignoring the hidden files and the sub-directories). A missing file contributes to the checksum as an empty one.
+func checksum(paths []string) string {
+	h := sha256.New()
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+	for _, path := range sorted {
+		files := []string{path}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			files = nil
+			entries, err := ioutil.ReadDir(path)
+			if err == nil {
+				for _, entry := range entries {
+					if !strings.HasPrefix(entry.Name(), ".") {
+						files = append(files, filepath.Join(path, entry.Name()))
+					}
+				}
+			}
+		}
+		for _, file := range files {
+			io.WriteString(h, file)
+			h.Write([]byte{0})
+			if f, err := os.Open(file); err == nil {
+				if info, err := f.Stat(); err == nil && !info.IsDir() {
+					io.Copy(h, f)
+				}
+				f.Close()
+			}
+			h.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,197 @@
+package configuration_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/configuration"
+	"github.com/fabric8-services/fabric8-common/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWatcher(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	t.Run("debounced changes", func(t *testing.T) {
+		// given
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		file := writeFile(t, filepath.Join(dir, "oso-clusters.conf"), "content")
+		changes, stop := startFileWatcher(t, func() []string { return []string{file} })
+		defer stop()
+		// when
+		for _, content := range []string{"content1", "content2", "content3"} {
+			writeFile(t, file, content)
+		}
+		// then
+		assertChanges(t, changes, 1)
+	})
+
+	t.Run("unchanged content", func(t *testing.T) {
+		// given
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		file := writeFile(t, filepath.Join(dir, "oso-clusters.conf"), "content")
+		other := writeFile(t, filepath.Join(dir, "other.conf"), "content")
+		changes, stop := startFileWatcher(t, func() []string { return []string{file} })
+		defer stop()
+		// when
+		writeFile(t, file, "content")
+		writeFile(t, other, "other content")
+		// then
+		assertChanges(t, changes, 0)
+	})
+
+	t.Run("replaced file", func(t *testing.T) {
+		// given
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		file := writeFile(t, filepath.Join(dir, "oso-clusters.conf"), "content")
+		changes, stop := startFileWatcher(t, func() []string { return []string{file} })
+		defer stop()
+		// when
+		tmp := writeFile(t, filepath.Join(dir, ".oso-clusters.conf.tmp"), "new content")
+		err := os.Rename(tmp, file)
+		require.NoError(t, err)
+		// then
+		assertChanges(t, changes, 1)
+	})
+
+	t.Run("swapped configmap symlink", func(t *testing.T) {
+		// given a directory organized like the ones in which Kubernetes mounts the ConfigMaps
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..2019_01_01"), 0755))
+		writeFile(t, filepath.Join(dir, "..2019_01_01", "oso-clusters.conf"), "content")
+		require.NoError(t, os.Symlink("..2019_01_01", filepath.Join(dir, "..data")))
+		file := filepath.Join(dir, "oso-clusters.conf")
+		require.NoError(t, os.Symlink(filepath.Join("..data", "oso-clusters.conf"), file))
+		changes, stop := startFileWatcher(t, func() []string { return []string{file} })
+		defer stop()
+		// when the ConfigMap is updated
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..2019_01_02"), 0755))
+		writeFile(t, filepath.Join(dir, "..2019_01_02", "oso-clusters.conf"), "new content")
+		require.NoError(t, os.Symlink("..2019_01_02", filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, "..2019_01_01")))
+		// then
+		assertChanges(t, changes, 1)
+		// when the ConfigMap is updated again
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..2019_01_03"), 0755))
+		writeFile(t, filepath.Join(dir, "..2019_01_03", "oso-clusters.conf"), "newer content")
+		require.NoError(t, os.Symlink("..2019_01_03", filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+		// then
+		assertChanges(t, changes, 1)
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		// given
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		writeFile(t, filepath.Join(dir, "us-east-2.json"), "content")
+		changes, stop := startFileWatcher(t, func() []string { return []string{dir} })
+		defer stop()
+		// when
+		writeFile(t, filepath.Join(dir, "us-east-3.json"), "content")
+		// then
+		assertChanges(t, changes, 1)
+	})
+
+	t.Run("paths updated after a change", func(t *testing.T) {
+		// given
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		secretsDir := filepath.Join(dir, "secrets")
+		require.NoError(t, os.Mkdir(secretsDir, 0755))
+		file := writeFile(t, filepath.Join(dir, "oso-clusters.conf"), "content")
+		secret := writeFile(t, filepath.Join(secretsDir, "token"), "token")
+		var lock sync.Mutex
+		paths := []string{file}
+		changes, stop := startFileWatcher(t, func() []string {
+			lock.Lock()
+			defer lock.Unlock()
+			return paths
+		})
+		defer stop()
+		// when the file starts referencing the secret
+		lock.Lock()
+		paths = []string{file, secret}
+		lock.Unlock()
+		writeFile(t, file, "new content")
+		assertChanges(t, changes, 1)
+		writeFile(t, secret, "new token")
+		// then
+		assertChanges(t, changes, 1)
+	})
+
+	t.Run("stopped with the context", func(t *testing.T) {
+		// given
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		file := writeFile(t, filepath.Join(dir, "oso-clusters.conf"), "content")
+		w, err := configuration.NewFileWatcher(func() []string { return []string{file} }, 10*time.Millisecond, func() {})
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			w.Run(ctx)
+			close(done)
+		}()
+		// when
+		cancel()
+		// then
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			assert.Fail(t, "file watcher did not stop within 1s")
+		}
+	})
+}
+
+// startFileWatcher starts watching the given paths, and returns a channel which receives a value on each change,
+// along with a func to stop the watcher
+func startFileWatcher(t *testing.T, paths func() []string) (chan struct{}, func()) {
+	changes := make(chan struct{}, 10)
+	w, err := configuration.NewFileWatcher(paths, 100*time.Millisecond, func() {
+		changes <- struct{}{}
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	go w.Run(ctx)
+	return changes, cancel
+}
+
+// assertChanges asserts that the given number of changes are received within 1s
+func assertChanges(t *testing.T, changes chan struct{}, expected int) {
+	timeout := time.After(1 * time.Second)
+	count := 0
+	for {
+		select {
+		case <-changes:
+			count++
+		case <-timeout:
+			assert.Equal(t, expected, count)
+			return
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "file-watcher")
+	require.NoError(t, err)
+	return dir
+}
+
+func writeFile(t *testing.T, file, content string) string {
+	err := ioutil.WriteFile(file, []byte(content), 0644)
+	require.NoError(t, err)
+	return file
+}
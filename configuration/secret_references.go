@@ -0,0 +1,62 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+
+	"github.com/pkg/errors"
+)
+
+// envSecretReference matches the secrets which reference an environment variable, eg: `${env:EAST2_SA_TOKEN}`
+var envSecretReference = regexp.MustCompile(`^\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// resolveSecretReferences replaces the secrets of the given clusters which are references with their actual value.
+// A secret can either be read from a file (eg: `"service-account-token-file": "/var/run/secrets/east-2/token"`)
+// or from an environment variable (eg: `"service-account-token": "${env:EAST2_SA_TOKEN}"`).
+// Returns an error if a referenced file or environment variable does not exist or is empty.
+func resolveSecretReferences(clusters []repository.Cluster) error {
+	for i, c := range clusters {
+		saToken, err := resolveSecret(c.Name, "service-account-token", c.SAToken, c.SATokenFile)
+		if err != nil {
+			return err
+		}
+		authClientSecret, err := resolveSecret(c.Name, "auth-client-secret", c.AuthClientSecret, c.AuthClientSecretFile)
+		if err != nil {
+			return err
+		}
+		clusters[i].SAToken = saToken
+		clusters[i].AuthClientSecret = authClientSecret
+	}
+	return nil
+}
+
+// resolveSecret returns the content of the given file if it is set, the value of the environment variable referenced
+// by the given value if it is a reference, or the given value otherwise
+func resolveSecret(clusterName, key, value, file string) (string, error) {
+	if file != "" {
+		if value != "" {
+			return "", errors.Errorf("both %s and %s-file are set for cluster '%s'", key, key, clusterName)
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to read the %s-file of cluster '%s'", key, clusterName)
+		}
+		secret := strings.TrimSpace(string(content))
+		if secret == "" {
+			return "", errors.Errorf("the %s-file of cluster '%s' is empty (%s)", key, clusterName, file)
+		}
+		return secret, nil
+	}
+	if m := envSecretReference.FindStringSubmatch(value); m != nil {
+		secret, found := os.LookupEnv(m[1])
+		if !found || secret == "" {
+			return "", errors.Errorf("environment variable '%s' referenced by the %s of cluster '%s' is not set", m[1], key, clusterName)
+		}
+		return secret, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fabric8-services/fabric8-cluster/app"
+	"github.com/fabric8-services/fabric8-cluster/application"
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+
+	"github.com/goadesign/goa"
+)
+
+// AdminController implements the admin resource.
+type AdminController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewAdminController creates an admin controller.
+func NewAdminController(service *goa.Service, app application.Application) *AdminController {
+	return &AdminController{Controller: service.NewController("AdminController"), app: app}
+}
+
+// Reload runs the reload action: the cluster config file is reloaded and the clusters are synchronized with it.
+func (c *AdminController) Reload(ctx *app.ReloadAdminContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	result, err := c.app.ClusterService().ReloadClusterConfig(ctx, repository.ClusterConfigReloadTriggerAPI)
+	if err != nil {
+		if result == nil {
+			return app.JSONErrorResponse(ctx, err)
+		}
+		return reloadErrorResponse(ctx, err, *result)
+	}
+	return ctx.OK(&app.ClusterConfigSyncSingle{
+		Data: convertToClusterConfigSyncStatus(*result),
+	})
+}
+
+// reloadErrorResponse responds with the given error, along with the outcome of the synchronization of the clusters
+// with the reloaded config file in the `cluster-config-sync` entry of its meta object
+func reloadErrorResponse(ctx *app.ReloadAdminContext, err error, result repository.ClusterConfigSyncResult) error {
+	jsonErr := convertToJSONAPIError(err)
+	jsonErr.Meta = map[string]interface{}{
+		"cluster-config-sync": convertToClusterConfigSyncStatus(result),
+	}
+	jsonErrs := &app.JSONAPIErrors{
+		Errors: []*app.JSONAPIError{jsonErr},
+	}
+	switch *jsonErr.Status {
+	case strconv.Itoa(http.StatusBadRequest):
+		return ctx.BadRequest(jsonErrs)
+	case strconv.Itoa(http.StatusUnauthorized):
+		return ctx.Unauthorized(jsonErrs)
+	case strconv.Itoa(http.StatusConflict):
+		return ctx.Conflict(jsonErrs)
+	default:
+		return ctx.InternalServerError(jsonErrs)
+	}
+}
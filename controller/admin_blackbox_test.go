@@ -0,0 +1,96 @@
+package controller_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/app"
+	"github.com/fabric8-services/fabric8-cluster/app/test"
+	"github.com/fabric8-services/fabric8-cluster/configuration"
+	. "github.com/fabric8-services/fabric8-cluster/controller"
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	"github.com/fabric8-services/fabric8-common/auth"
+	authtestsupport "github.com/fabric8-services/fabric8-common/test/auth"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AdminControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestAdminController(t *testing.T) {
+	suite.Run(t, &AdminControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *AdminControllerTestSuite) newSecuredControllerWithServiceAccount(username string) (*goa.Service, *AdminController) {
+	svc, err := authtestsupport.ServiceAsServiceAccountUser("Token-Service", &authtestsupport.Identity{
+		Username: username,
+		ID:       uuid.NewV4(),
+	})
+	require.NoError(s.T(), err)
+	return svc, NewAdminController(svc, s.Application)
+}
+
+func (s *AdminControllerTestSuite) TestReload() {
+	// do not decommission the clusters created by the other tests
+	existingPolicy := os.Getenv("F8_CLUSTER_CONFIG_SYNC_POLICY")
+	defer os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", existingPolicy)
+	os.Setenv("F8_CLUSTER_CONFIG_SYNC_POLICY", configuration.ClusterConfigSyncPolicyMergeOnly)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.ToolChainOperator)
+		// when
+		_, result := test.ReloadAdminOK(t, svc.Context, svc, ctrl)
+		// then
+		require.NotNil(t, result)
+		require.NotNil(t, result.Data)
+		assert.Equal(t, configuration.ClusterConfigSyncPolicyMergeOnly, result.Data.Policy)
+		assert.True(t, result.Data.Applied)
+		assert.Nil(t, result.Data.Error)
+		assert.Len(t, append(result.Data.Creates, result.Data.Updates...), len(s.Configuration.GetClusters()))
+		assert.Empty(t, result.Data.Decommissions)
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
+				// when/then
+				test.ReloadAdminUnauthorized(t, svc.Context, svc, ctrl)
+			})
+		}
+	})
+
+	s.T().Run("not the leader", func(t *testing.T) {
+		// given a lease held by another replica
+		_, err := s.Application.LeaderLeases().Acquire(context.Background(), "fabric8-cluster", "other-replica", time.Minute)
+		require.NoError(t, err)
+		defer s.Application.LeaderLeases().Release(context.Background(), "fabric8-cluster", "other-replica")
+		halt := s.Application.ClusterService().InitializeLeaderElection(nil)
+		defer halt()
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.ToolChainOperator)
+		// when
+		_, result := test.ReloadAdminConflict(t, svc.Context, svc, ctrl)
+		// then the outcome of the reload is returned along with the error
+		require.NotNil(t, result)
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, "this replica is not the leader (the leader is 'other-replica')", result.Errors[0].Detail)
+		require.NotNil(t, result.Errors[0].Meta)
+		status, ok := result.Errors[0].Meta["cluster-config-sync"].(*app.ClusterConfigSyncStatus)
+		require.True(t, ok)
+		assert.Equal(t, configuration.ClusterConfigSyncPolicyMergeOnly, status.Policy)
+		assert.False(t, status.Applied)
+		require.NotNil(t, status.Error)
+		assert.Equal(t, "this replica is not the leader (the leader is 'other-replica')", *status.Error)
+		assert.Len(t, append(status.Creates, status.Updates...), len(s.Configuration.GetClusters()))
+	})
+}
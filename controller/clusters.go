@@ -8,23 +8,34 @@ import (
 	"github.com/fabric8-services/fabric8-common/httpsupport"
 	"github.com/fabric8-services/fabric8-common/log"
 
+	"context"
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
 )
 
+type clustersConfiguration interface {
+	IsClusterAPICompatibilityModeEnabled() bool
+}
+
 // ClustersController implements the clusters resource.
 type ClustersController struct {
 	*goa.Controller
-	app application.Application
+	app    application.Application
+	config clustersConfiguration
 }
 
 // NewClustersController creates a clusters controller.
-func NewClustersController(service *goa.Service, app application.Application) *ClustersController {
+func NewClustersController(service *goa.Service, app application.Application, config clustersConfiguration) *ClustersController {
 	return &ClustersController{
 		Controller: service.NewController("ClustersController"),
 		app:        app,
+		config:     config,
 	}
 }
 
@@ -39,29 +50,91 @@ func (c *ClustersController) List(ctx *app.ListClustersContext) error {
 				// no result found, return an empty array
 				return ctx.OK(&app.ClusterList{
 					Data: []*app.ClusterData{},
+					Meta: &app.ListMeta{TotalCount: 0},
 				})
 			}
 			// something wrong happened, return the error
 			return app.JSONErrorResponse(ctx, err)
 		}
 		return ctx.OK(&app.ClusterList{
-			Data: []*app.ClusterData{convertToClusterData(*clustr)},
+			Data: []*app.ClusterData{convertToClusterData(ctx.RequestData, *clustr, c.config.IsClusterAPICompatibilityModeEnabled())},
+			Meta: &app.ListMeta{TotalCount: 1},
 		})
 	}
-	// otherwise, list all clusters
-	clusters, err := c.app.ClusterService().List(ctx, ctx.Type)
+	// otherwise, list all clusters matching the filters
+	options := repository.ClusterListOptions{
+		Type:              ctx.Type,
+		Name:              ctx.Name,
+		AppDNS:            ctx.AppDNS,
+		CapacityExhausted: ctx.CapacityExhausted,
+		Healthy:           ctx.Healthy,
+		CreatedAfter:      ctx.CreatedAfter,
+		CreatedBefore:     ctx.CreatedBefore,
+	}
+	if ctx.State != nil {
+		options.States = []string{*ctx.State}
+	}
+	setPagination(&options, ctx.Sort, ctx.PageOffset, ctx.PageLimit)
+	clusters, total, err := c.app.ClusterService().List(ctx, options)
 	if err != nil {
 		return app.JSONErrorResponse(ctx, err)
 	}
-	var data []*app.ClusterData
+	data := make([]*app.ClusterData, 0, len(clusters))
 	for _, clustr := range clusters {
-		data = append(data, convertToClusterData(clustr))
+		data = append(data, convertToClusterData(ctx.RequestData, clustr, c.config.IsClusterAPICompatibilityModeEnabled()))
 	}
 	return ctx.OK(&app.ClusterList{
-		Data: data,
+		Data:  data,
+		Links: newPagingLinks(ctx.RequestData, options.Offset, options.Limit, total),
+		Meta:  &app.ListMeta{TotalCount: total},
 	})
 }
 
+// Watch streams the changes on the clusters as newline-delimited JSON events, starting with the current clusters
+// unless the client resumes watching from a resource version.
+func (c *ClustersController) Watch(ctx *app.WatchClustersContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	var watchCtx context.Context = ctx
+	if ctx.Timeout != nil {
+		var cancelTimeout context.CancelFunc
+		watchCtx, cancelTimeout = context.WithTimeout(watchCtx, time.Duration(*ctx.Timeout)*time.Second)
+		defer cancelTimeout()
+	}
+	watchCtx, cancel := context.WithCancel(watchCtx)
+	defer cancel()
+	// stop watching as soon as the client disconnects, rather than upon the next event
+	go func() {
+		select {
+		case <-ctx.Request.Context().Done():
+			cancel()
+		case <-watchCtx.Done():
+		}
+	}()
+	w := newNDJSONWriter(ctx.ResponseData)
+	err := c.app.ClusterService().WatchClusters(watchCtx, ctx.ResourceVersion, func(event repository.ClusterWatchEvent) error {
+		return w.Write(convertToClusterWatchEvent(ctx.RequestData, event, c.config.IsClusterAPICompatibilityModeEnabled()))
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while watching the clusters")
+		if w.Started() {
+			// too late to respond with an error status: the error is reported in a last event
+			w.Write(&app.ClusterWatchEvent{
+				Type:  "ERROR",
+				Error: convertToJSONAPIError(err),
+			})
+			return nil
+		}
+		if _, ok := errs.Cause(err).(repository.OutboxPositionExpiredError); ok {
+			return gone(ctx, err)
+		}
+		return app.JSONErrorResponse(ctx, err)
+	}
+	w.Close()
+	return nil
+}
+
 // ListForAuthClient returns the list of available clusters with full configuration including Auth client data.
 // To be used by Auth service only
 func (c *ClustersController) ListForAuthClient(ctx *app.ListForAuthClientClustersContext) error {
@@ -74,41 +147,64 @@ func (c *ClustersController) ListForAuthClient(ctx *app.ListForAuthClientCluster
 				// no result found, return an empty array
 				return ctx.OK(&app.FullClusterList{
 					Data: []*app.FullClusterData{},
+					Meta: &app.ListMeta{TotalCount: 0},
 				})
 			}
 			// something wrong happened, return the error
 			return app.JSONErrorResponse(ctx, err)
 		}
 		return ctx.OK(&app.FullClusterList{
-			Data: []*app.FullClusterData{convertToFullClusterData(*clustr)},
+			Data: []*app.FullClusterData{convertToFullClusterData(ctx.RequestData, *clustr, c.config.IsClusterAPICompatibilityModeEnabled())},
+			Meta: &app.ListMeta{TotalCount: 1},
 		})
 	}
-	clusters, err := c.app.ClusterService().ListForAuth(ctx, ctx.Type)
+	// otherwise, list all clusters matching the filters
+	options := repository.ClusterListOptions{
+		Type:              ctx.Type,
+		Name:              ctx.Name,
+		AppDNS:            ctx.AppDNS,
+		CapacityExhausted: ctx.CapacityExhausted,
+		CreatedAfter:      ctx.CreatedAfter,
+		CreatedBefore:     ctx.CreatedBefore,
+	}
+	if ctx.State != nil {
+		options.States = []string{*ctx.State}
+	}
+	setPagination(&options, ctx.Sort, ctx.PageOffset, ctx.PageLimit)
+	clusters, total, err := c.app.ClusterService().ListForAuth(ctx, options)
 	if err != nil {
 		return app.JSONErrorResponse(ctx, err)
 	}
-	var data []*app.FullClusterData
+	data := make([]*app.FullClusterData, 0, len(clusters))
 	for _, clustr := range clusters {
-		data = append(data, convertToFullClusterData(clustr))
+		data = append(data, convertToFullClusterData(ctx.RequestData, clustr, c.config.IsClusterAPICompatibilityModeEnabled()))
 	}
 	return ctx.OK(&app.FullClusterList{
-		Data: data,
+		Data:  data,
+		Links: newPagingLinks(ctx.RequestData, options.Offset, options.Limit, total),
+		Meta:  &app.ListMeta{TotalCount: total},
 	})
 }
 
-// Show returns a single cluster.
+// Show returns a single cluster, unless the client already has its current version (`If-None-Match` header).
 func (c *ClustersController) Show(ctx *app.ShowClustersContext) error {
 	// authorization is checked at the service level for more consistency accross the codebase.
 	clustr, err := c.app.ClusterService().Load(ctx, ctx.ClusterID)
 	if err != nil {
 		return app.JSONErrorResponse(ctx, err)
 	}
+	etag := clusterETag(*clustr)
+	ctx.ResponseData.Header().Set("ETag", etag)
+	if matchesIfNoneMatch(ctx.IfNoneMatch, etag) {
+		return ctx.NotModified()
+	}
 	return ctx.OK(&app.ClusterSingle{
-		Data: convertToClusterData(*clustr),
+		Data: convertToClusterData(ctx.RequestData, *clustr, c.config.IsClusterAPICompatibilityModeEnabled()),
 	})
 }
 
-// ShowForAuthClient returns the cluster with full configuration including Auth client data.
+// ShowForAuthClient returns the cluster with full configuration including Auth client data, unless the client
+// already has its current version (`If-None-Match` header).
 // To be used by Auth service only
 func (c *ClustersController) ShowForAuthClient(ctx *app.ShowForAuthClientClustersContext) error {
 	// authorization is checked at the service level for more consistency accross the codebase.
@@ -116,13 +212,23 @@ func (c *ClustersController) ShowForAuthClient(ctx *app.ShowForAuthClientCluster
 	if err != nil {
 		return app.JSONErrorResponse(ctx, err)
 	}
+	etag := clusterETag(*clustr)
+	ctx.ResponseData.Header().Set("ETag", etag)
+	if matchesIfNoneMatch(ctx.IfNoneMatch, etag) {
+		return ctx.NotModified()
+	}
 	return ctx.OK(&app.FullClusterSingle{
-		Data: convertToFullClusterData(*clustr),
+		Data: convertToFullClusterData(ctx.RequestData, *clustr, c.config.IsClusterAPICompatibilityModeEnabled()),
 	})
 }
 
-// Create creates a new cluster configuration for later use
+// Create creates a new cluster configuration for later use, or replaces the configuration of the cluster with the same URL.
+// If the `If-Match` header is set, then the cluster must exist and have the matching version.
 func (c *ClustersController) Create(ctx *app.CreateClustersContext) error {
+	versions, err := expectedVersions(ctx.IfMatch)
+	if err != nil {
+		return preconditionFailed(ctx, err)
+	}
 	clustr := repository.Cluster{
 		Name:             ctx.Payload.Data.Name,
 		Type:             ctx.Payload.Data.Type,
@@ -149,30 +255,231 @@ func (c *ClustersController) Create(ctx *app.CreateClustersContext) error {
 	if ctx.Payload.Data.TokenProviderID != nil {
 		clustr.TokenProviderID = *ctx.Payload.Data.TokenProviderID
 	}
+	if ctx.Payload.Data.MaxIdentities != nil {
+		clustr.MaxIdentities = *ctx.Payload.Data.MaxIdentities
+	}
+	if ctx.Payload.Data.CapacityThreshold != nil {
+		clustr.CapacityThreshold = *ctx.Payload.Data.CapacityThreshold
+	}
+	if ctx.Payload.Data.State != nil {
+		clustr.State = *ctx.Payload.Data.State
+	}
 	clusterSvc := c.app.ClusterService()
-	err := clusterSvc.CreateOrSaveCluster(ctx, &clustr)
+	err = clusterSvc.CreateOrSaveCluster(ctx, &clustr, versions...)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"error": err,
 		}, "error while creating new cluster configuration")
+		if ctx.IfMatch != nil && isVersionConflict(err) {
+			return preconditionFailed(ctx, err)
+		}
 		return app.JSONErrorResponse(ctx, err)
 	}
 	ctx.ResponseData.Header().Set("Location", app.ClustersHref(clustr.ClusterID.String()))
+	ctx.ResponseData.Header().Set("ETag", clusterETag(clustr))
 	return ctx.Created()
 }
 
-// Delete deletes the cluster identified by the `clusterID` param
+// Update applies the changes on the cluster identified by the `clusterID` param. Attributes which are not set
+// in the payload are left unchanged. If the `If-Match` header is set, then the cluster must have the matching version.
+func (c *ClustersController) Update(ctx *app.UpdateClustersContext) error {
+	versions, err := expectedVersions(ctx.IfMatch)
+	if err != nil {
+		return preconditionFailed(ctx, err)
+	}
+	data := ctx.Payload.Data
+	patch := repository.ClusterPatch{
+		Name:              data.Name,
+		URL:               data.APIURL,
+		ConsoleURL:        data.ConsoleURL,
+		MetricsURL:        data.MetricsURL,
+		LoggingURL:        data.LoggingURL,
+		AppDNS:            data.AppDNS,
+		Type:              data.Type,
+		CapacityExhausted: data.CapacityExhausted,
+		MaxIdentities:     data.MaxIdentities,
+		CapacityThreshold: data.CapacityThreshold,
+		State:             data.State,
+		SAToken:           data.ServiceAccountToken,
+		SATokenEncrypted:  data.SaTokenEncrypted,
+		SAUsername:        data.ServiceAccountUsername,
+		TokenProviderID:   data.TokenProviderID,
+		AuthClientID:      data.AuthClientID,
+		AuthClientSecret:  data.AuthClientSecret,
+		AuthDefaultScope:  data.AuthClientDefaultScope,
+	}
+	// authorization is checked at the service level for more consistency accross the codebase.
+	clustr, err := c.app.ClusterService().PatchCluster(ctx, ctx.ClusterID, patch, versions...)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error":      err,
+			"cluster_id": ctx.ClusterID.String(),
+		}, "error while updating a cluster configuration")
+		if ctx.IfMatch != nil && isVersionConflict(err) {
+			return preconditionFailed(ctx, err)
+		}
+		return app.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("ETag", clusterETag(*clustr))
+	return ctx.OK(&app.ClusterSingle{
+		Data: convertToClusterData(ctx.RequestData, *clustr, c.config.IsClusterAPICompatibilityModeEnabled()),
+	})
+}
+
+// RotateServiceAccountToken starts the rotation of the service account token of the cluster identified by the `clusterID` param
+func (c *ClustersController) RotateServiceAccountToken(ctx *app.RotateServiceAccountTokenClustersContext) error {
+	var gracePeriod *time.Duration
+	if ctx.Payload.GracePeriod != nil {
+		d := time.Duration(*ctx.Payload.GracePeriod) * time.Second
+		gracePeriod = &d
+	}
+	saTokenEncrypted := false
+	if ctx.Payload.SaTokenEncrypted != nil {
+		saTokenEncrypted = *ctx.Payload.SaTokenEncrypted
+	}
+	// authorization is checked at the service level for more consistency accross the codebase.
+	clustr, err := c.app.ClusterService().RotateSAToken(ctx, ctx.ClusterID, ctx.Payload.ServiceAccountToken, saTokenEncrypted, gracePeriod)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error":      err,
+			"cluster_id": ctx.ClusterID.String(),
+		}, "error while rotating the service account token of a cluster")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.ClusterSingle{
+		Data: convertToClusterData(ctx.RequestData, *clustr, c.config.IsClusterAPICompatibilityModeEnabled()),
+	})
+}
+
+// Delete deletes the cluster identified by the `clusterID` param. If the `If-Match` header is set, then the cluster
+// must have the matching version.
 func (c *ClustersController) Delete(ctx *app.DeleteClustersContext) error {
-	err := c.app.ClusterService().Delete(ctx, ctx.ClusterID)
+	versions, err := expectedVersions(ctx.IfMatch)
+	if err != nil {
+		return preconditionFailed(ctx, err)
+	}
+	err = c.app.ClusterService().Delete(ctx, ctx.ClusterID, versions...)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"error": err,
 		}, "error while deleting a cluster configuration")
+		if ctx.IfMatch != nil && isVersionConflict(err) {
+			return preconditionFailed(ctx, err)
+		}
 		return app.JSONErrorResponse(ctx, err)
 	}
 	return ctx.NoContent()
 }
 
+// ListClusterAudit returns the audit entries of the cluster identified by the `clusterID` param
+func (c *ClustersController) ListClusterAudit(ctx *app.ListClusterAuditClustersContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	entries, err := c.app.ClusterService().ListAudit(ctx, ctx.ClusterID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while listing the audit entries of cluster %s", ctx.ClusterID)
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertToAuditEntryList(entries))
+}
+
+// ListAudit returns the audit entries of all clusters since the time given in the `since` param
+func (c *ClustersController) ListAudit(ctx *app.ListAuditClustersContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	entries, err := c.app.ClusterService().ListAuditSince(ctx, ctx.Since)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while listing the audit entries since %s", ctx.Since)
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertToAuditEntryList(entries))
+}
+
+// ListIdentities returns the identities linked to the cluster identified by the `clusterID` param. If the `Accept` header
+// contains the NDJSON media type, then all the identities are streamed as newline-delimited JSON resource objects.
+func (c *ClustersController) ListIdentities(ctx *app.ListIdentitiesClustersContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	if acceptsNDJSON(ctx.Accept) {
+		w := newNDJSONWriter(ctx.ResponseData)
+		err := c.app.ClusterService().StreamIdentities(ctx, ctx.ClusterID, func(identityCluster repository.IdentityCluster) error {
+			return w.Write(convertToIdentityClusterData(identityCluster))
+		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"error": err,
+			}, "error while streaming the identities of cluster %s", ctx.ClusterID)
+			if w.Started() {
+				// too late to respond with an error: the client will notice that the response is truncated
+				return nil
+			}
+			return app.JSONErrorResponse(ctx, err)
+		}
+		w.Close()
+		return nil
+	}
+	offset, limit := 0, 0
+	if ctx.PageOffset != nil {
+		offset = *ctx.PageOffset
+	}
+	if ctx.PageLimit != nil {
+		limit = *ctx.PageLimit
+	}
+	identityClusters, total, err := c.app.ClusterService().ListIdentities(ctx, ctx.ClusterID, offset, limit)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while listing the identities of cluster %s", ctx.ClusterID)
+		return app.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.IdentityClusterData, len(identityClusters))
+	for i, identityCluster := range identityClusters {
+		data[i] = convertToIdentityClusterData(identityCluster)
+	}
+	return ctx.OK(&app.IdentityClusterList{
+		Data:  data,
+		Links: newPagingLinks(ctx.RequestData, offset, limit, total),
+		Meta: &app.ListMeta{
+			TotalCount: total,
+		},
+	})
+}
+
+// ListClustersForIdentity returns the clusters to which the identity identified by the `identityID` param is linked
+func (c *ClustersController) ListClustersForIdentity(ctx *app.ListClustersForIdentityClustersContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	identityClusters, err := c.app.ClusterService().ListClustersForIdentities(ctx, ctx.IdentityID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while listing the clusters of identity %s", ctx.IdentityID)
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(c.convertToIdentityClusterLinkList(ctx.RequestData, identityClusters))
+}
+
+// ListClustersForIdentities returns the clusters to which each of the identities in the payload is linked
+func (c *ClustersController) ListClustersForIdentities(ctx *app.ListClustersForIdentitiesClustersContext) error {
+	identityIDs := make([]uuid.UUID, len(ctx.Payload.IdentityIds))
+	for i, id := range ctx.Payload.IdentityIds {
+		identityID, err := uuid.FromString(id)
+		if err != nil {
+			return app.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString(fmt.Sprintf("identity-id %s is not a valid UUID", id)))
+		}
+		identityIDs[i] = identityID
+	}
+	// authorization is checked at the service level for more consistency accross the codebase.
+	identityClusters, err := c.app.ClusterService().ListClustersForIdentities(ctx, identityIDs...)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while listing the clusters of %d identities", len(identityIDs))
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(c.convertToIdentityClusterLinkList(ctx.RequestData, identityClusters))
+}
+
 // LinkIdentityToCluster populates Identity Cluster relationship
 func (c *ClustersController) LinkIdentityToCluster(ctx *app.LinkIdentityToClusterClustersContext) error {
 	identityID, err := uuid.FromString(ctx.Payload.IdentityID)
@@ -210,36 +517,399 @@ func (c *ClustersController) RemoveIdentityToClusterLink(ctx *app.RemoveIdentity
 	return ctx.NoContent()
 }
 
-func convertToClusterData(clustr repository.Cluster) *app.ClusterData {
-	return &app.ClusterData{
+// LinkIdentitiesToClusters populates Identity Cluster relationships in bulk
+func (c *ClustersController) LinkIdentitiesToClusters(ctx *app.LinkIdentitiesToClustersClustersContext) error {
+	links := make([]repository.IdentityClusterLink, len(ctx.Payload.Links))
+	for i, l := range ctx.Payload.Links {
+		identityID, err := uuid.FromString(l.IdentityID)
+		if err != nil {
+			return app.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString(fmt.Sprintf("identity-id %s is not a valid UUID", l.IdentityID)))
+		}
+		// ignoreIfAlreadyExisted by default true
+		ignore := true
+		if l.IgnoreIfAlreadyExists != nil {
+			ignore = *l.IgnoreIfAlreadyExists
+		}
+		links[i] = repository.IdentityClusterLink{
+			IdentityID:            identityID,
+			ClusterURL:            l.ClusterURL,
+			IgnoreIfAlreadyExists: ignore,
+		}
+	}
+	transactional := ctx.Payload.Transactional != nil && *ctx.Payload.Transactional
+	// authorization is checked at the service level for more consistency accross the codebase.
+	results, err := c.app.ClusterService().LinkIdentitiesToClusters(ctx, links, transactional)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while linking %d identities to clusters", len(links))
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertToIdentityClusterBulkReport(results, transactional))
+}
+
+// RemoveIdentitiesToClustersLinks removes Identity Cluster relationships in bulk
+func (c *ClustersController) RemoveIdentitiesToClustersLinks(ctx *app.RemoveIdentitiesToClustersLinksClustersContext) error {
+	links := make([]repository.IdentityClusterLink, len(ctx.Payload.Links))
+	for i, l := range ctx.Payload.Links {
+		identityID, err := uuid.FromString(l.IdentityID)
+		if err != nil {
+			return app.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString(fmt.Sprintf("identity-id %s is not a valid UUID", l.IdentityID)))
+		}
+		links[i] = repository.IdentityClusterLink{
+			IdentityID: identityID,
+			ClusterURL: l.ClusterURL,
+		}
+	}
+	transactional := ctx.Payload.Transactional != nil && *ctx.Payload.Transactional
+	// authorization is checked at the service level for more consistency accross the codebase.
+	results, err := c.app.ClusterService().RemoveIdentitiesToClustersLinks(ctx, links, transactional)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while removing links of %d identities to clusters", len(links))
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertToIdentityClusterBulkReport(results, transactional))
+}
+
+// MigrateIdentities moves identities from a cluster to another
+func (c *ClustersController) MigrateIdentities(ctx *app.MigrateIdentitiesClustersContext) error {
+	identityIDs := make([]uuid.UUID, len(ctx.Payload.IdentityIds))
+	for i, id := range ctx.Payload.IdentityIds {
+		identityID, err := uuid.FromString(id)
+		if err != nil {
+			return app.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString(fmt.Sprintf("identity-id %s is not a valid UUID", id)))
+		}
+		identityIDs[i] = identityID
+	}
+	dryRun := false
+	if ctx.Payload.DryRun != nil {
+		dryRun = *ctx.Payload.DryRun
+	}
+	// authorization is checked at the service level for more consistency accross the codebase.
+	migration, err := c.app.ClusterService().MigrateIdentities(ctx, identityIDs, ctx.Payload.SourceClusterURL, ctx.Payload.TargetClusterURL, dryRun)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while migrating identities from cluster with url '%s' to cluster with url '%s'", ctx.Payload.SourceClusterURL, ctx.Payload.TargetClusterURL)
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.IdentityMigrationSingle{
+		Data: &app.IdentityMigrationData{
+			IdentityIds:   migration.IdentityIDs,
+			SourceCluster: convertToClusterData(ctx.RequestData, migration.SourceCluster, c.config.IsClusterAPICompatibilityModeEnabled()),
+			TargetCluster: convertToClusterData(ctx.RequestData, migration.TargetCluster, c.config.IsClusterAPICompatibilityModeEnabled()),
+			DryRun:        migration.DryRun,
+		},
+	})
+}
+
+// PlaceIdentity returns the cluster on which the identity should be provisioned
+func (c *ClustersController) PlaceIdentity(ctx *app.PlaceIdentityClustersContext) error {
+	identityID, err := uuid.FromString(ctx.Payload.IdentityID)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString(fmt.Sprintf("identity-id %s is not a valid UUID", ctx.Payload.IdentityID)))
+	}
+	// authorization is checked at the service level for more consistency accross the codebase.
+	clustr, err := c.app.ClusterService().PlaceIdentity(ctx, identityID, ctx.Payload.Type, ctx.Payload.Strategy)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while selecting a cluster for identity-id %s", identityID)
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.ClusterSingle{
+		Data: convertToClusterData(ctx.RequestData, *clustr, c.config.IsClusterAPICompatibilityModeEnabled()),
+	})
+}
+
+// resourceTypeClusters the type of the cluster resource objects
+const resourceTypeClusters = "clusters"
+
+// convertToClusterData converts the given cluster into a JSON-API resource object. In compatibility mode, the attributes
+// are also set at the top level of the resource object, and the `type` member holds the cluster type.
+func convertToClusterData(req *goa.RequestData, clustr repository.Cluster, compatibilityMode bool) *app.ClusterData {
+	maxIdentities := clustr.MaxIdentities
+	capacityThreshold := clustr.CapacityThreshold
+	identitiesCount := clustr.IdentitiesCount
+	attributes := &app.ClusterAttributes{
 		Name:              clustr.Name,
 		APIURL:            httpsupport.AddTrailingSlashToURL(clustr.URL),
 		ConsoleURL:        httpsupport.AddTrailingSlashToURL(clustr.ConsoleURL),
 		MetricsURL:        httpsupport.AddTrailingSlashToURL(clustr.MetricsURL),
 		LoggingURL:        httpsupport.AddTrailingSlashToURL(clustr.LoggingURL),
 		AppDNS:            clustr.AppDNS,
-		Type:              clustr.Type,
+		ClusterType:       clustr.Type,
 		CapacityExhausted: clustr.CapacityExhausted,
+		State:             clustr.State,
+		MaxIdentities:     &maxIdentities,
+		CapacityThreshold: &capacityThreshold,
+		IdentitiesCount:   &identitiesCount,
+		Health:            convertToClusterHealthData(clustr.Health),
+	}
+	selfLink := clusterSelfLink(req, clustr.ClusterID)
+	result := &app.ClusterData{
+		ID:         clustr.ClusterID,
+		Type:       resourceTypeClusters,
+		Attributes: attributes,
+		Links: &app.GenericLinks{
+			Self: &selfLink,
+		},
+	}
+	if compatibilityMode {
+		result.Type = attributes.ClusterType
+		result.Name = &attributes.Name
+		result.APIURL = &attributes.APIURL
+		result.ConsoleURL = &attributes.ConsoleURL
+		result.MetricsURL = &attributes.MetricsURL
+		result.LoggingURL = &attributes.LoggingURL
+		result.AppDNS = &attributes.AppDNS
+		result.CapacityExhausted = &attributes.CapacityExhausted
+		result.State = &attributes.State
+		result.MaxIdentities = attributes.MaxIdentities
+		result.CapacityThreshold = attributes.CapacityThreshold
+		result.IdentitiesCount = attributes.IdentitiesCount
+		result.Health = attributes.Health
 	}
+	return result
 }
 
-func convertToFullClusterData(clustr repository.Cluster) *app.FullClusterData {
+// convertToFullClusterData converts the given cluster into a JSON-API resource object, including its auth data.
+// See `convertToClusterData` for the compatibility mode.
+func convertToFullClusterData(req *goa.RequestData, clustr repository.Cluster, compatibilityMode bool) *app.FullClusterData {
 	encrypted := clustr.SATokenEncrypted
-	return &app.FullClusterData{
-		Name:                   clustr.Name,
-		APIURL:                 httpsupport.AddTrailingSlashToURL(clustr.URL),
-		ConsoleURL:             httpsupport.AddTrailingSlashToURL(clustr.ConsoleURL),
-		MetricsURL:             httpsupport.AddTrailingSlashToURL(clustr.MetricsURL),
-		LoggingURL:             httpsupport.AddTrailingSlashToURL(clustr.LoggingURL),
-		AppDNS:                 clustr.AppDNS,
-		Type:                   clustr.Type,
-		CapacityExhausted:      clustr.CapacityExhausted,
-		AuthClientDefaultScope: clustr.AuthDefaultScope,
-		AuthClientID:           clustr.AuthClientID,
-		AuthClientSecret:       clustr.AuthClientSecret,
-		SaTokenEncrypted:       &encrypted,
-		ServiceAccountToken:    clustr.SAToken,
-		ServiceAccountUsername: clustr.SAUsername,
-		TokenProviderID:        clustr.TokenProviderID,
+	maxIdentities := clustr.MaxIdentities
+	capacityThreshold := clustr.CapacityThreshold
+	identitiesCount := clustr.IdentitiesCount
+	var pendingSAToken *string
+	var pendingSATokenEncrypted *bool
+	if clustr.PendingSAToken != "" {
+		pendingSAToken = &clustr.PendingSAToken
+		pendingSATokenEncrypted = &clustr.PendingSATokenEncrypted
+	}
+	attributes := &app.FullClusterAttributes{
+		Name:                                  clustr.Name,
+		APIURL:                                httpsupport.AddTrailingSlashToURL(clustr.URL),
+		ConsoleURL:                            httpsupport.AddTrailingSlashToURL(clustr.ConsoleURL),
+		MetricsURL:                            httpsupport.AddTrailingSlashToURL(clustr.MetricsURL),
+		LoggingURL:                            httpsupport.AddTrailingSlashToURL(clustr.LoggingURL),
+		AppDNS:                                clustr.AppDNS,
+		ClusterType:                           clustr.Type,
+		CapacityExhausted:                     clustr.CapacityExhausted,
+		State:                                 clustr.State,
+		MaxIdentities:                         &maxIdentities,
+		CapacityThreshold:                     &capacityThreshold,
+		IdentitiesCount:                       &identitiesCount,
+		Health:                                convertToClusterHealthData(clustr.Health),
+		AuthClientDefaultScope:                clustr.AuthDefaultScope,
+		AuthClientID:                          clustr.AuthClientID,
+		AuthClientSecret:                      clustr.AuthClientSecret,
+		SaTokenEncrypted:                      &encrypted,
+		ServiceAccountToken:                   clustr.SAToken,
+		ServiceAccountUsername:                clustr.SAUsername,
+		TokenProviderID:                       clustr.TokenProviderID,
+		ServiceAccountTokenCreatedAt:          clustr.SATokenCreatedAt,
+		PendingServiceAccountToken:            pendingSAToken,
+		PendingSaTokenEncrypted:               pendingSATokenEncrypted,
+		PendingServiceAccountTokenCreatedAt:   clustr.PendingSATokenCreatedAt,
+		PendingServiceAccountTokenPromotionAt: clustr.PendingSATokenPromotionAt,
+	}
+	selfLink := clusterSelfLink(req, clustr.ClusterID)
+	result := &app.FullClusterData{
+		ID:         clustr.ClusterID,
+		Type:       resourceTypeClusters,
+		Attributes: attributes,
+		Links: &app.GenericLinks{
+			Self: &selfLink,
+		},
+	}
+	if compatibilityMode {
+		result.Type = attributes.ClusterType
+		result.Name = &attributes.Name
+		result.APIURL = &attributes.APIURL
+		result.ConsoleURL = &attributes.ConsoleURL
+		result.MetricsURL = &attributes.MetricsURL
+		result.LoggingURL = &attributes.LoggingURL
+		result.AppDNS = &attributes.AppDNS
+		result.CapacityExhausted = &attributes.CapacityExhausted
+		result.State = &attributes.State
+		result.MaxIdentities = attributes.MaxIdentities
+		result.CapacityThreshold = attributes.CapacityThreshold
+		result.IdentitiesCount = attributes.IdentitiesCount
+		result.Health = attributes.Health
+		result.AuthClientDefaultScope = &attributes.AuthClientDefaultScope
+		result.AuthClientID = &attributes.AuthClientID
+		result.AuthClientSecret = &attributes.AuthClientSecret
+		result.SaTokenEncrypted = attributes.SaTokenEncrypted
+		result.ServiceAccountToken = &attributes.ServiceAccountToken
+		result.ServiceAccountUsername = &attributes.ServiceAccountUsername
+		result.TokenProviderID = &attributes.TokenProviderID
+		result.ServiceAccountTokenCreatedAt = attributes.ServiceAccountTokenCreatedAt
+		result.PendingServiceAccountToken = attributes.PendingServiceAccountToken
+		result.PendingSaTokenEncrypted = attributes.PendingSaTokenEncrypted
+		result.PendingServiceAccountTokenCreatedAt = attributes.PendingServiceAccountTokenCreatedAt
+		result.PendingServiceAccountTokenPromotionAt = attributes.PendingServiceAccountTokenPromotionAt
+	}
+	return result
+}
+
+// clusterSelfLink returns the absolute URL of the cluster resource, based on the scheme and host of the given request
+func clusterSelfLink(req *goa.RequestData, clusterID uuid.UUID) string {
+	return absoluteURL(req, app.ClustersHref(clusterID.String()))
+}
+
+func convertToClusterHealthData(health *repository.ClusterHealth) *app.ClusterHealthData {
+	if health == nil {
+		return nil
+	}
+	result := &app.ClusterHealthData{
+		State:         health.State,
+		Latency:       int(health.Latency),
+		LastCheckedAt: health.LastCheckedAt,
+		LastSuccessAt: health.LastSuccessAt,
+		LastErrorAt:   health.LastErrorAt,
+	}
+	if health.LastError != "" {
+		lastError := health.LastError
+		result.LastError = &lastError
+	}
+	return result
+}
+
+// resourceTypeIdentities the type of the identity resource objects
+const resourceTypeIdentities = "identities"
+
+func convertToIdentityClusterData(identityCluster repository.IdentityCluster) *app.IdentityClusterData {
+	return &app.IdentityClusterData{
+		ID:   identityCluster.IdentityID,
+		Type: resourceTypeIdentities,
+		Attributes: &app.IdentityClusterAttributes{
+			ClusterID: identityCluster.ClusterID,
+			CreatedAt: identityCluster.CreatedAt,
+		},
+	}
+}
+
+func (c *ClustersController) convertToIdentityClusterLinkList(req *goa.RequestData, identityClusters []repository.IdentityCluster) *app.IdentityClusterLinkList {
+	data := make([]*app.IdentityClusterLinkData, len(identityClusters))
+	for i, identityCluster := range identityClusters {
+		data[i] = &app.IdentityClusterLinkData{
+			IdentityID: identityCluster.IdentityID,
+			CreatedAt:  identityCluster.CreatedAt,
+			Cluster:    convertToClusterData(req, identityCluster.Cluster, c.config.IsClusterAPICompatibilityModeEnabled()),
+		}
+	}
+	return &app.IdentityClusterLinkList{
+		Data: data,
+	}
+}
+
+func convertToIdentityClusterBulkReport(results []repository.IdentityClusterLinkResult, transactional bool) *app.IdentityClusterBulkReportSingle {
+	items := make([]*app.IdentityClusterBulkItemData, len(results))
+	for i, r := range results {
+		items[i] = &app.IdentityClusterBulkItemData{
+			IdentityID: r.IdentityID.String(),
+			ClusterURL: r.ClusterURL,
+			Status:     r.Status,
+		}
+		if r.Error != nil {
+			items[i].Error = convertToJSONAPIError(r.Error)
+		}
+	}
+	return &app.IdentityClusterBulkReportSingle{
+		Data: &app.IdentityClusterBulkReportData{
+			Transactional: transactional,
+			Items:         items,
+		},
+	}
+}
+
+// convertToJSONAPIError converts the given error into a JSON-API error object, whose status depends on the type of the error
+func convertToJSONAPIError(err error) *app.JSONAPIError {
+	var code string
+	var status int
+	switch errs.Cause(err).(type) {
+	case errors.BadParameterError:
+		code, status = "bad_parameter", http.StatusBadRequest
+	case errors.NotFoundError:
+		code, status = "not_found", http.StatusNotFound
+	case errors.DataConflictError:
+		code, status = "data_conflict", http.StatusConflict
+	case errors.UnauthorizedError:
+		code, status = "unauthorized", http.StatusUnauthorized
+	case repository.OutboxPositionExpiredError:
+		code, status = "gone", http.StatusGone
+	default:
+		code, status = "unknown_error", http.StatusInternalServerError
+	}
+	id := uuid.NewV4().String()
+	statusText := strconv.Itoa(status)
+	title := http.StatusText(status)
+	return &app.JSONAPIError{
+		ID:     &id,
+		Status: &statusText,
+		Code:   &code,
+		Title:  &title,
+		Detail: errs.Cause(err).Error(),
+	}
+}
+
+// goneContext the context of the actions which respond with a '410 Gone' status
+type goneContext interface {
+	Gone(*app.JSONAPIErrors) error
+}
+
+// gone responds with a '410 Gone' status, when the requested resource version is no longer available
+func gone(ctx goneContext, err error) error {
+	return ctx.Gone(&app.JSONAPIErrors{
+		Errors: []*app.JSONAPIError{convertToJSONAPIError(err)},
+	})
+}
+
+// convertToClusterWatchEvent converts the given watch event, whose cluster is converted as in the other responses
+func convertToClusterWatchEvent(req *goa.RequestData, event repository.ClusterWatchEvent, compatibilityMode bool) *app.ClusterWatchEvent {
+	resourceVersion := event.Position.String()
+	result := &app.ClusterWatchEvent{
+		Type:            event.Type,
+		ResourceVersion: &resourceVersion,
+	}
+	if event.Type != repository.ClusterWatchEventBookmark {
+		clusterID := event.ClusterID
+		result.ClusterID = &clusterID
+	}
+	if event.Cluster != nil {
+		result.Object = convertToClusterData(req, *event.Cluster, compatibilityMode)
+	}
+	return result
+}
+
+func convertToAuditEntryList(entries []repository.AuditEntry) *app.AuditEntryList {
+	data := make([]*app.AuditEntryData, len(entries))
+	for i, e := range entries {
+		data[i] = &app.AuditEntryData{
+			ID:         e.ID,
+			CreatedAt:  e.CreatedAt,
+			ClusterID:  e.ClusterID,
+			IdentityID: e.IdentityID,
+			Operation:  e.Operation,
+			Actor:      e.Actor,
+		}
+		if e.RequestID != "" {
+			requestID := e.RequestID
+			data[i].RequestID = &requestID
+		}
+		if len(e.Changes) > 0 {
+			data[i].Changes = make(map[string]*app.AuditChangeData, len(e.Changes))
+			for name, change := range e.Changes {
+				data[i].Changes[name] = &app.AuditChangeData{
+					Old: change.Old,
+					New: change.New,
+				}
+			}
+		}
+	}
+	return &app.AuditEntryList{
+		Data: data,
 	}
 }
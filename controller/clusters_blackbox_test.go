@@ -2,9 +2,14 @@ package controller_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-common/errors"
 
@@ -13,6 +18,8 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/fabric8-services/fabric8-cluster/app/test"
+	"github.com/fabric8-services/fabric8-cluster/cluster"
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
 	"github.com/fabric8-services/fabric8-cluster/controller"
 	. "github.com/fabric8-services/fabric8-cluster/controller"
 	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
@@ -39,17 +46,33 @@ func TestClustersController(t *testing.T) {
 func (s *ClustersControllerTestSuite) SetupSuite() {
 	s.DBTestSuite.SetupSuite()
 	// save clusters from config in DB
-	err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+	_, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
 	require.NoError(s.T(), err)
 }
 
 func (s *ClustersControllerTestSuite) newSecuredControllerWithServiceAccount(username string) (*goa.Service, *controller.ClustersController) {
+	return s.newSecuredControllerWithConfig(username, s.Configuration)
+}
+
+func (s *ClustersControllerTestSuite) newSecuredControllerWithConfig(username string, config clustersConfiguration) (*goa.Service, *controller.ClustersController) {
 	svc, err := authtestsupport.ServiceAsServiceAccountUser("Token-Service", &authtestsupport.Identity{
 		Username: username,
 		ID:       uuid.NewV4(),
 	})
 	require.NoError(s.T(), err)
-	return svc, NewClustersController(svc, s.Application)
+	return svc, NewClustersController(svc, s.Application, config)
+}
+
+type clustersConfiguration interface {
+	IsClusterAPICompatibilityModeEnabled() bool
+}
+
+type clustersConfigurationStub struct {
+	compatibilityMode bool
+}
+
+func (c clustersConfigurationStub) IsClusterAPICompatibilityModeEnabled() bool {
+	return c.compatibilityMode
 }
 
 func (s *ClustersControllerTestSuite) TestShow() {
@@ -57,7 +80,7 @@ func (s *ClustersControllerTestSuite) TestShow() {
 	// given
 	clusterPayload := newCreateClusterPayload()
 	svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
-	resp := test.CreateClustersCreated(s.T(), svc.Context, svc, ctrl, &clusterPayload)
+	resp := test.CreateClustersCreated(s.T(), svc.Context, svc, ctrl, nil, &clusterPayload)
 	location := resp.Header().Get("location")
 	require.NotEmpty(s.T(), location)
 	splits := strings.Split(location, "/")
@@ -69,19 +92,19 @@ func (s *ClustersControllerTestSuite) TestShow() {
 			t.Run(username, func(t *testing.T) {
 				// when accessing the created cluster with another identity
 				svc, ctrl = s.newSecuredControllerWithServiceAccount(username)
-				_, result := test.ShowClustersOK(t, svc.Context, svc, ctrl, clusterID)
+				_, result := test.ShowClustersOK(t, svc.Context, svc, ctrl, clusterID, nil, nil)
 				// then
 				require.NotNil(t, result)
 				require.NotNil(t, result.Data)
-				assert.Equal(t, clusterPayload.Data.Name, result.Data.Name)
-				name := result.Data.Name
-				assert.Equal(t, httpsupport.AddTrailingSlashToURL(clusterPayload.Data.APIURL), result.Data.APIURL)
-				assert.Equal(t, httpsupport.AddTrailingSlashToURL(clusterPayload.Data.AppDNS), result.Data.AppDNS)
-				assert.Equal(t, false, result.Data.CapacityExhausted)
-				assert.Equal(t, fmt.Sprintf("https://console.cluster.%s/console/", name), result.Data.ConsoleURL)
-				assert.Equal(t, fmt.Sprintf("https://metrics.cluster.%s/", name), result.Data.MetricsURL)
-				assert.Equal(t, fmt.Sprintf("https://console.cluster.%s/console/", name), result.Data.LoggingURL)
-				assert.Equal(t, clusterPayload.Data.Type, result.Data.Type)
+				assert.Equal(t, clusterPayload.Data.Name, result.Data.Attributes.Name)
+				name := result.Data.Attributes.Name
+				assert.Equal(t, httpsupport.AddTrailingSlashToURL(clusterPayload.Data.APIURL), result.Data.Attributes.APIURL)
+				assert.Equal(t, httpsupport.AddTrailingSlashToURL(clusterPayload.Data.AppDNS), result.Data.Attributes.AppDNS)
+				assert.Equal(t, false, result.Data.Attributes.CapacityExhausted)
+				assert.Equal(t, fmt.Sprintf("https://console.cluster.%s/console/", name), result.Data.Attributes.ConsoleURL)
+				assert.Equal(t, fmt.Sprintf("https://metrics.cluster.%s/", name), result.Data.Attributes.MetricsURL)
+				assert.Equal(t, fmt.Sprintf("https://console.cluster.%s/console/", name), result.Data.Attributes.LoggingURL)
+				assert.Equal(t, clusterPayload.Data.Type, result.Data.Attributes.ClusterType)
 			})
 		}
 	})
@@ -92,14 +115,14 @@ func (s *ClustersControllerTestSuite) TestShow() {
 			// given
 			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
 			// when/then
-			test.ShowClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4())
+			test.ShowClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), nil, nil)
 		})
 
 		t.Run("unauthorized", func(t *testing.T) {
 			// given
 			svc, ctrl := s.newSecuredControllerWithServiceAccount("foo")
 			// when/then
-			test.ShowClustersUnauthorized(t, svc.Context, svc, ctrl, uuid.NewV4())
+			test.ShowClustersUnauthorized(t, svc.Context, svc, ctrl, uuid.NewV4(), nil, nil)
 		})
 	})
 }
@@ -112,7 +135,7 @@ func (s *ClustersControllerTestSuite) TestShowForAuthClient() {
 	s.T().Run("ok", func(t *testing.T) {
 		// when accessing the created cluster with another identity
 		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
-		_, result := test.ShowForAuthClientClustersOK(t, svc.Context, svc, ctrl, c.ClusterID)
+		_, result := test.ShowForAuthClientClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
 		// then
 		require.NotNil(t, result)
 		require.NotNil(t, result.Data)
@@ -125,7 +148,7 @@ func (s *ClustersControllerTestSuite) TestShowForAuthClient() {
 			// given
 			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
 			// when/then
-			test.ShowForAuthClientClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4())
+			test.ShowForAuthClientClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), nil, nil)
 		})
 
 		t.Run("unauthorized", func(t *testing.T) {
@@ -134,7 +157,7 @@ func (s *ClustersControllerTestSuite) TestShowForAuthClient() {
 					// given
 					svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
 					// when/then
-					test.ShowForAuthClientClustersUnauthorized(t, svc.Context, svc, ctrl, c.ClusterID)
+					test.ShowForAuthClientClustersUnauthorized(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
 				})
 			}
 		})
@@ -158,11 +181,11 @@ func (s *ClustersControllerTestSuite) TestList() {
 					// given
 					svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
 					// when
-					_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil)
+					_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 					// then
 					require.NotNil(t, result)
 					require.NotNil(t, result.Data)
-					expected, err := s.Application.ClusterService().List(svc.Context, nil) // also needs SA in context to list the expected clusters
+					expected, _, err := s.Application.ClusterService().List(svc.Context, repository.ClusterListOptions{}) // also needs SA in context to list the expected clusters
 					require.NoError(t, err)
 					testsupport.AssertEqualClustersData(t, expected, result.Data)
 				})
@@ -175,17 +198,61 @@ func (s *ClustersControllerTestSuite) TestList() {
 					// given
 					svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
 					// when
-					_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, &c.Type)
+					_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &c.Type)
 					// then
 					require.NotNil(t, result)
 					require.NotNil(t, result.Data)
-					expected, err := s.Application.ClusterService().List(svc.Context, &c.Type) // also needs SA in context to list the expected clusters
+					expected, _, err := s.Application.ClusterService().List(svc.Context, repository.ClusterListOptions{Type: &c.Type}) // also needs SA in context to list the expected clusters
 					require.NoError(t, err)
 					testsupport.AssertEqualClustersData(t, expected, result.Data)
 				})
 			}
 		})
 
+		t.Run("healthy", func(t *testing.T) {
+			// given
+			healthyCluster := testsupport.CreateCluster(t, s.DB)
+			testsupport.CreateClusterHealth(t, s.DB, healthyCluster, repository.HealthStateHealthy)
+			unhealthyCluster := testsupport.CreateCluster(t, s.DB)
+			testsupport.CreateClusterHealth(t, s.DB, unhealthyCluster, repository.HealthStateUnhealthy)
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+			healthy := true
+			// when
+			_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, &healthy, nil, nil, nil, nil, nil, nil)
+			// then
+			require.NotNil(t, result)
+			expected, _, err := s.Application.ClusterService().List(svc.Context, repository.ClusterListOptions{Healthy: &healthy}) // also needs SA in context to list the expected clusters
+			require.NoError(t, err)
+			require.NotEmpty(t, expected)
+			testsupport.AssertEqualClustersData(t, expected, result.Data)
+			for _, data := range result.Data {
+				assert.NotEqual(t, httpsupport.AddTrailingSlashToURL(unhealthyCluster.URL), data.APIURL)
+				require.NotNil(t, data.Health)
+				assert.Equal(t, repository.HealthStateHealthy, data.Health.State)
+			}
+		})
+
+		t.Run("by state", func(t *testing.T) {
+			// given
+			drainingCluster := testsupport.CreateCluster(t, s.DB, testsupport.WithState(repository.ClusterStateDraining))
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+			state := repository.ClusterStateDraining
+			// when
+			_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &state, nil)
+			// then
+			require.NotNil(t, result)
+			expected, _, err := s.Application.ClusterService().List(svc.Context, repository.ClusterListOptions{States: []string{state}}) // also needs SA in context to list the expected clusters
+			require.NoError(t, err)
+			require.NotEmpty(t, expected)
+			testsupport.AssertEqualClustersData(t, expected, result.Data)
+			found := false
+			for _, data := range result.Data {
+				assert.Equal(t, repository.ClusterStateDraining, data.State)
+				found = found || data.APIURL == httpsupport.AddTrailingSlashToURL(drainingCluster.URL)
+			}
+			assert.True(t, found)
+		})
+
 		t.Run("failures", func(t *testing.T) {
 
 			t.Run("unauthorized", func(t *testing.T) {
@@ -194,7 +261,7 @@ func (s *ClustersControllerTestSuite) TestList() {
 						// given
 						svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
 						// when/then
-						test.ListClustersUnauthorized(t, svc.Context, svc, ctrl, nil, nil)
+						test.ListClustersUnauthorized(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 					})
 				}
 			})
@@ -210,19 +277,19 @@ func (s *ClustersControllerTestSuite) TestList() {
 					t.Run(username, func(t *testing.T) {
 						// when accessing the created cluster with another identity
 						svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
-						_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, &c.URL, nil)
+						_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, &c.URL, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 						// then
 						require.NotNil(t, result)
 						require.NotNil(t, result.Data)
 						require.Len(t, result.Data, 1)
-						assert.Equal(t, c.Name, result.Data[0].Name)
-						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.URL), result.Data[0].APIURL)
-						assert.Equal(t, c.AppDNS, result.Data[0].AppDNS)
-						assert.Equal(t, false, result.Data[0].CapacityExhausted)
-						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.ConsoleURL), result.Data[0].ConsoleURL)
-						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.MetricsURL), result.Data[0].MetricsURL)
-						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.LoggingURL), result.Data[0].LoggingURL)
-						assert.Equal(t, c.Type, result.Data[0].Type)
+						assert.Equal(t, c.Name, result.Data[0].Attributes.Name)
+						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.URL), result.Data[0].Attributes.APIURL)
+						assert.Equal(t, c.AppDNS, result.Data[0].Attributes.AppDNS)
+						assert.Equal(t, false, result.Data[0].Attributes.CapacityExhausted)
+						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.ConsoleURL), result.Data[0].Attributes.ConsoleURL)
+						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.MetricsURL), result.Data[0].Attributes.MetricsURL)
+						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.LoggingURL), result.Data[0].Attributes.LoggingURL)
+						assert.Equal(t, c.Type, result.Data[0].Attributes.ClusterType)
 					})
 				}
 			})
@@ -232,7 +299,7 @@ func (s *ClustersControllerTestSuite) TestList() {
 				svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
 				clusterURL := "http://foo.com"
 				// when
-				_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, &clusterURL, nil)
+				_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, &clusterURL, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 				// then expect an empty array (see https://jsonapi.org/format/#fetching-resources-responses)
 				require.NotNil(t, result)
 				require.NotNil(t, result.Data)
@@ -248,7 +315,7 @@ func (s *ClustersControllerTestSuite) TestList() {
 				svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
 				clusterURL := "foo.com"
 				// when/then
-				test.ListClustersBadRequest(t, svc.Context, svc, ctrl, &clusterURL, nil) // missing scheme
+				test.ListClustersBadRequest(t, svc.Context, svc, ctrl, nil, nil, &clusterURL, nil, nil, nil, nil, nil, nil, nil, nil, nil) // missing scheme
 			})
 
 			t.Run("unauthorized", func(t *testing.T) {
@@ -257,7 +324,7 @@ func (s *ClustersControllerTestSuite) TestList() {
 						// given
 						svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
 						// when/then
-						test.ListClustersUnauthorized(t, svc.Context, svc, ctrl, &c.URL, nil)
+						test.ListClustersUnauthorized(t, svc.Context, svc, ctrl, nil, nil, &c.URL, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 					})
 				}
 			})
@@ -265,6 +332,72 @@ func (s *ClustersControllerTestSuite) TestList() {
 	})
 }
 
+func (s *ClustersControllerTestSuite) TestListPaginated() {
+	// given
+	name := uuid.NewV4().String() // the name of all clusters in this test, to ignore the other clusters in the DB
+	c1 := testsupport.CreateCluster(s.T(), s.DB, testsupport.WithName(name))
+	c2 := testsupport.CreateCluster(s.T(), s.DB, testsupport.WithName(name))
+	c3 := testsupport.CreateCluster(s.T(), s.DB, testsupport.WithName(name))
+	svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
+
+	s.T().Run("first page", func(t *testing.T) {
+		// given
+		limit := 2
+		// when
+		_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, &name, &limit, nil, nil, nil, nil)
+		// then
+		require.NotNil(t, result)
+		testsupport.AssertEqualClustersData(t, []repository.Cluster{c1, c2}, result.Data)
+		require.NotNil(t, result.Meta)
+		assert.Equal(t, 3, result.Meta.TotalCount)
+		require.NotNil(t, result.Links)
+		assert.Nil(t, result.Links.Prev)
+		require.NotNil(t, result.Links.Next)
+		assert.Contains(t, *result.Links.Next, "page%5Boffset%5D=2")
+		assert.Contains(t, *result.Links.Next, "page%5Blimit%5D=2")
+		assert.Contains(t, *result.Links.Next, "name="+name)
+	})
+
+	s.T().Run("last page", func(t *testing.T) {
+		// given
+		limit := 2
+		offset := 2
+		// when
+		_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, &name, &limit, &offset, nil, nil, nil)
+		// then
+		require.NotNil(t, result)
+		testsupport.AssertEqualClustersData(t, []repository.Cluster{c3}, result.Data)
+		require.NotNil(t, result.Meta)
+		assert.Equal(t, 3, result.Meta.TotalCount)
+		require.NotNil(t, result.Links)
+		require.NotNil(t, result.Links.Prev)
+		assert.Contains(t, *result.Links.Prev, "page%5Boffset%5D=0")
+		assert.Nil(t, result.Links.Next)
+	})
+
+	s.T().Run("sorted by creation time", func(t *testing.T) {
+		// given
+		sort := "-created-at"
+		limit := 1
+		// when
+		_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, &name, &limit, nil, &sort, nil, nil)
+		// then
+		require.NotNil(t, result)
+		testsupport.AssertEqualClustersData(t, []repository.Cluster{c3}, result.Data)
+	})
+
+	s.T().Run("not paginated", func(t *testing.T) {
+		// when
+		_, result := test.ListForAuthClientClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, &name, nil, nil, nil, nil, nil)
+		// then
+		require.NotNil(t, result)
+		testsupport.AssertEqualFullClustersData(t, []repository.Cluster{c1, c2, c3}, result.Data)
+		require.NotNil(t, result.Meta)
+		assert.Equal(t, 3, result.Meta.TotalCount)
+		assert.Nil(t, result.Links)
+	})
+}
+
 func (s *ClustersControllerTestSuite) TestListForAuth() {
 	// given
 	require.NotEmpty(s.T(), s.Configuration.GetClusters())
@@ -283,11 +416,11 @@ func (s *ClustersControllerTestSuite) TestListForAuth() {
 						// given
 						svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
 						// when
-						_, result := test.ListForAuthClientClustersOK(t, svc.Context, svc, ctrl, nil, nil)
+						_, result := test.ListForAuthClientClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 						// then
 						require.NotNil(t, result)
 						require.NotNil(t, result.Data)
-						expected, err := s.Application.ClusterService().ListForAuth(svc.Context, nil) // also needs SA in context to list the expected clusters
+						expected, _, err := s.Application.ClusterService().ListForAuth(svc.Context, repository.ClusterListOptions{}) // also needs SA in context to list the expected clusters
 						require.NoError(t, err)
 						testsupport.AssertEqualFullClustersData(t, expected, result.Data)
 					})
@@ -300,11 +433,11 @@ func (s *ClustersControllerTestSuite) TestListForAuth() {
 						// given
 						svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
 						// when
-						_, result := test.ListForAuthClientClustersOK(t, svc.Context, svc, ctrl, nil, &c.Type)
+						_, result := test.ListForAuthClientClustersOK(t, svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &c.Type)
 						// then
 						require.NotNil(t, result)
 						require.NotNil(t, result.Data)
-						expected, err := s.Application.ClusterService().ListForAuth(svc.Context, &c.Type) // also needs SA in context to list the expected clusters
+						expected, _, err := s.Application.ClusterService().ListForAuth(svc.Context, repository.ClusterListOptions{Type: &c.Type}) // also needs SA in context to list the expected clusters
 						require.NoError(t, err)
 						testsupport.AssertEqualFullClustersData(t, expected, result.Data)
 					})
@@ -319,7 +452,7 @@ func (s *ClustersControllerTestSuite) TestListForAuth() {
 					t.Run(username, func(t *testing.T) {
 						// given
 						svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
-						test.ListForAuthClientClustersUnauthorized(s.T(), svc.Context, svc, ctrl, nil, nil)
+						test.ListForAuthClientClustersUnauthorized(s.T(), svc.Context, svc, ctrl, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 					})
 				}
 			})
@@ -336,27 +469,27 @@ func (s *ClustersControllerTestSuite) TestListForAuth() {
 					t.Run(username, func(t *testing.T) {
 						// when accessing the created cluster with another identity
 						svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
-						_, result := test.ListForAuthClientClustersOK(t, svc.Context, svc, ctrl, &c.URL, nil)
+						_, result := test.ListForAuthClientClustersOK(t, svc.Context, svc, ctrl, nil, nil, &c.URL, nil, nil, nil, nil, nil, nil, nil, nil)
 						// then
 						require.NotNil(t, result)
 						require.NotNil(t, result.Data)
 						require.Len(t, result.Data, 1)
-						assert.Equal(t, c.Name, result.Data[0].Name)
-						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.URL), result.Data[0].APIURL)
-						assert.Equal(t, c.AppDNS, result.Data[0].AppDNS)
-						assert.Equal(t, false, result.Data[0].CapacityExhausted)
-						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.ConsoleURL), result.Data[0].ConsoleURL)
-						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.MetricsURL), result.Data[0].MetricsURL)
-						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.LoggingURL), result.Data[0].LoggingURL)
-						assert.Equal(t, c.Type, result.Data[0].Type)
-						assert.Equal(t, c.AuthDefaultScope, result.Data[0].AuthClientDefaultScope)
-						assert.Equal(t, c.AuthClientID, result.Data[0].AuthClientID)
-						assert.Equal(t, c.AuthClientSecret, result.Data[0].AuthClientSecret)
-						require.NotNil(t, result.Data[0].SaTokenEncrypted)
-						assert.Equal(t, c.SATokenEncrypted, *result.Data[0].SaTokenEncrypted)
-						assert.Equal(t, c.SAToken, result.Data[0].ServiceAccountToken)
-						assert.Equal(t, c.SAUsername, result.Data[0].ServiceAccountUsername)
-						assert.Equal(t, c.TokenProviderID, result.Data[0].TokenProviderID)
+						assert.Equal(t, c.Name, result.Data[0].Attributes.Name)
+						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.URL), result.Data[0].Attributes.APIURL)
+						assert.Equal(t, c.AppDNS, result.Data[0].Attributes.AppDNS)
+						assert.Equal(t, false, result.Data[0].Attributes.CapacityExhausted)
+						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.ConsoleURL), result.Data[0].Attributes.ConsoleURL)
+						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.MetricsURL), result.Data[0].Attributes.MetricsURL)
+						assert.Equal(t, httpsupport.AddTrailingSlashToURL(c.LoggingURL), result.Data[0].Attributes.LoggingURL)
+						assert.Equal(t, c.Type, result.Data[0].Attributes.ClusterType)
+						assert.Equal(t, c.AuthDefaultScope, result.Data[0].Attributes.AuthClientDefaultScope)
+						assert.Equal(t, c.AuthClientID, result.Data[0].Attributes.AuthClientID)
+						assert.Equal(t, c.AuthClientSecret, result.Data[0].Attributes.AuthClientSecret)
+						require.NotNil(t, result.Data[0].Attributes.SaTokenEncrypted)
+						assert.Equal(t, c.SATokenEncrypted, *result.Data[0].Attributes.SaTokenEncrypted)
+						assert.Equal(t, c.SAToken, result.Data[0].Attributes.ServiceAccountToken)
+						assert.Equal(t, c.SAUsername, result.Data[0].Attributes.ServiceAccountUsername)
+						assert.Equal(t, c.TokenProviderID, result.Data[0].Attributes.TokenProviderID)
 					})
 				}
 			})
@@ -366,7 +499,7 @@ func (s *ClustersControllerTestSuite) TestListForAuth() {
 				svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
 				clusterURL := "http://foo.com"
 				// when
-				_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, &clusterURL, nil)
+				_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, &clusterURL, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 				// then expect an empty array (see https://jsonapi.org/format/#fetching-resources-responses)
 				require.NotNil(t, result)
 				require.NotNil(t, result.Data)
@@ -381,7 +514,7 @@ func (s *ClustersControllerTestSuite) TestListForAuth() {
 				for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.ToolChainOperator, "other"} {
 					t.Run(username, func(t *testing.T) {
 						svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
-						test.ListForAuthClientClustersUnauthorized(s.T(), svc.Context, svc, ctrl, &c.URL, nil)
+						test.ListForAuthClientClustersUnauthorized(s.T(), svc.Context, svc, ctrl, nil, nil, &c.URL, nil, nil, nil, nil, nil, nil, nil, nil)
 					})
 				}
 			})
@@ -391,7 +524,7 @@ func (s *ClustersControllerTestSuite) TestListForAuth() {
 				svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
 				clusterURL := "foo.com"
 				// when/then
-				test.ListForAuthClientClustersBadRequest(t, svc.Context, svc, ctrl, &clusterURL, nil) // missing scheme
+				test.ListForAuthClientClustersBadRequest(t, svc.Context, svc, ctrl, nil, nil, &clusterURL, nil, nil, nil, nil, nil, nil, nil, nil) // missing scheme
 			})
 		})
 	})
@@ -409,7 +542,7 @@ func (s *ClustersControllerTestSuite) TestCreate() {
 		clusterPayload := newCreateClusterPayload()
 		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
 		// when
-		resp := test.CreateClustersCreated(t, svc.Context, svc, ctrl, &clusterPayload)
+		resp := test.CreateClustersCreated(t, svc.Context, svc, ctrl, nil, &clusterPayload)
 		//then
 		location := resp.Header().Get("location")
 		require.NotEmpty(t, location)
@@ -424,7 +557,7 @@ func (s *ClustersControllerTestSuite) TestCreate() {
 					clusterPayload := newCreateClusterPayload()
 					svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
 					// when/then
-					test.CreateClustersUnauthorized(t, svc.Context, svc, ctrl, &clusterPayload)
+					test.CreateClustersUnauthorized(t, svc.Context, svc, ctrl, nil, &clusterPayload)
 				})
 			}
 		})
@@ -435,7 +568,233 @@ func (s *ClustersControllerTestSuite) TestCreate() {
 			clusterPayload.Data.APIURL = " "
 			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
 			// when/then
-			test.CreateClustersBadRequest(t, svc.Context, svc, ctrl, &clusterPayload)
+			test.CreateClustersBadRequest(t, svc.Context, svc, ctrl, nil, &clusterPayload)
+		})
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestUpdate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("flip capacity-exhausted", func(t *testing.T) {
+			// given
+			c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+			exhausted := true
+			payload := app.UpdateClustersPayload{
+				Data: &app.UpdateClusterData{
+					CapacityExhausted: &exhausted,
+				},
+			}
+			// when
+			_, result := test.UpdateClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, &payload)
+			// then
+			require.NotNil(t, result)
+			require.NotNil(t, result.Data)
+			assert.True(t, result.Data.Attributes.CapacityExhausted)
+			// verify that all other fields, including secrets, were left unchanged
+			ctx, err := authtestsupport.EmbedServiceAccountTokenInContext(context.Background(), &authtestsupport.Identity{
+				Username: auth.Auth, // need another SA to load the secrets
+				ID:       uuid.NewV4(),
+			})
+			require.NoError(t, err)
+			loaded, err := s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+			require.NoError(t, err)
+			c.CapacityExhausted = true
+			testsupport.AssertEqualCluster(t, c, *loaded, true)
+		})
+
+		t.Run("rotate secrets", func(t *testing.T) {
+			// given
+			c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+			token := uuid.NewV4().String()
+			secret := uuid.NewV4().String()
+			payload := app.UpdateClustersPayload{
+				Data: &app.UpdateClusterData{
+					ServiceAccountToken: &token,
+					AuthClientSecret:    &secret,
+				},
+			}
+			// when
+			_, result := test.UpdateClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, &payload)
+			// then
+			require.NotNil(t, result)
+			ctx, err := authtestsupport.EmbedServiceAccountTokenInContext(context.Background(), &authtestsupport.Identity{
+				Username: auth.Auth, // need another SA to load the secrets
+				ID:       uuid.NewV4(),
+			})
+			require.NoError(t, err)
+			loaded, err := s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+			require.NoError(t, err)
+			c.SAToken = token
+			c.SATokenEncrypted = false
+			c.AuthClientSecret = secret
+			testsupport.AssertEqualCluster(t, c, *loaded, true)
+		})
+
+		t.Run("rotate encrypted SA token", func(t *testing.T) {
+			// given
+			c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+			token := uuid.NewV4().String()
+			encrypted := true
+			payload := app.UpdateClustersPayload{
+				Data: &app.UpdateClusterData{
+					ServiceAccountToken: &token,
+					SaTokenEncrypted:    &encrypted,
+				},
+			}
+			// when
+			_, result := test.UpdateClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, &payload)
+			// then
+			require.NotNil(t, result)
+			ctx, err := authtestsupport.EmbedServiceAccountTokenInContext(context.Background(), &authtestsupport.Identity{
+				Username: auth.Auth, // need another SA to load the secrets
+				ID:       uuid.NewV4(),
+			})
+			require.NoError(t, err)
+			loaded, err := s.Application.ClusterService().LoadForAuth(ctx, c.ClusterID)
+			require.NoError(t, err)
+			c.SAToken = token
+			c.SATokenEncrypted = true
+			testsupport.AssertEqualCluster(t, c, *loaded, true)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			c := testsupport.CreateCluster(t, s.DB)
+			name := "foo"
+			payload := app.UpdateClustersPayload{
+				Data: &app.UpdateClusterData{
+					Name: &name,
+				},
+			}
+			for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth} {
+				t.Run(username, func(t *testing.T) {
+					// given
+					svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
+					// when/then
+					test.UpdateClustersUnauthorized(t, svc.Context, svc, ctrl, c.ClusterID, nil, &payload)
+				})
+			}
+		})
+
+		t.Run("bad request", func(t *testing.T) {
+			// given
+			c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+			apiURL := " "
+			payload := app.UpdateClustersPayload{
+				Data: &app.UpdateClusterData{
+					APIURL: &apiURL,
+				},
+			}
+			// when/then
+			test.UpdateClustersBadRequest(t, svc.Context, svc, ctrl, c.ClusterID, nil, &payload)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+			name := "foo"
+			payload := app.UpdateClustersPayload{
+				Data: &app.UpdateClusterData{
+					Name: &name,
+				},
+			}
+			// when/then
+			test.UpdateClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), nil, &payload)
+		})
+
+		t.Run("conflict", func(t *testing.T) {
+			// given
+			c1 := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+			c2 := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+			payload := app.UpdateClustersPayload{
+				Data: &app.UpdateClusterData{
+					APIURL: &c1.URL,
+				},
+			}
+			// when/then
+			test.UpdateClustersConflict(t, svc.Context, svc, ctrl, c2.ClusterID, nil, &payload)
+		})
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestRotateServiceAccountToken() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+		gracePeriod := 3600
+		encrypted := true
+		payload := &app.ServiceAccountTokenRotationPayload{
+			ServiceAccountToken: uuid.NewV4().String(),
+			SaTokenEncrypted:    &encrypted,
+			GracePeriod:         &gracePeriod,
+		}
+		// when
+		_, result := test.RotateServiceAccountTokenClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, payload)
+		// then
+		require.NotNil(t, result)
+		require.NotNil(t, result.Data)
+		// verify that both tokens are returned to the Auth service
+		authSvc, authCtrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
+		_, loaded := test.ShowForAuthClientClustersOK(t, authSvc.Context, authSvc, authCtrl, c.ClusterID, nil, nil)
+		require.NotNil(t, loaded)
+		require.NotNil(t, loaded.Data)
+		c.PendingSAToken = payload.ServiceAccountToken
+		c.PendingSATokenEncrypted = true
+		testsupport.AssertEqualFullClusterData(t, c, *loaded.Data)
+		require.NotNil(t, loaded.Data.Attributes.PendingServiceAccountTokenCreatedAt)
+		require.NotNil(t, loaded.Data.Attributes.PendingServiceAccountTokenPromotionAt)
+		assert.Equal(t, time.Hour, loaded.Data.Attributes.PendingServiceAccountTokenPromotionAt.Sub(*loaded.Data.Attributes.PendingServiceAccountTokenCreatedAt).Round(time.Second))
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			c := testsupport.CreateCluster(t, s.DB)
+			payload := &app.ServiceAccountTokenRotationPayload{
+				ServiceAccountToken: uuid.NewV4().String(),
+			}
+			for _, username := range []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth} {
+				t.Run(username, func(t *testing.T) {
+					// given
+					svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
+					// when/then
+					test.RotateServiceAccountTokenClustersUnauthorized(t, svc.Context, svc, ctrl, c.ClusterID, payload)
+				})
+			}
+		})
+
+		t.Run("bad request", func(t *testing.T) {
+			// given
+			c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+			payload := &app.ServiceAccountTokenRotationPayload{
+				ServiceAccountToken: c.SAToken,
+			}
+			// when/then
+			test.RotateServiceAccountTokenClustersBadRequest(t, svc.Context, svc, ctrl, c.ClusterID, payload)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+			payload := &app.ServiceAccountTokenRotationPayload{
+				ServiceAccountToken: uuid.NewV4().String(),
+			}
+			// when/then
+			test.RotateServiceAccountTokenClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), payload)
 		})
 	})
 }
@@ -447,15 +806,16 @@ func (s *ClustersControllerTestSuite) TestDelete() {
 		c := testsupport.CreateCluster(t, s.DB)
 		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
 		// when
-		test.DeleteClustersNoContent(t, svc.Context, svc, ctrl, c.ClusterID)
-		// then
+		test.DeleteClustersNoContent(t, svc.Context, svc, ctrl, c.ClusterID, nil)
+		// then the cluster was decommissioned
 		ctx, err := authtestsupport.EmbedServiceAccountTokenInContext(context.Background(), &authtestsupport.Identity{
 			Username: auth.Auth, // need another SA to load the data
 			ID:       uuid.NewV4(),
 		})
 		require.NoError(t, err)
-		_, err = s.Application.ClusterService().Load(ctx, c.ClusterID)
-		testsupport.AssertError(t, err, errors.NotFoundError{}, errors.NewNotFoundError("cluster", c.ClusterID.String()).Error())
+		loaded, err := s.Application.ClusterService().Load(ctx, c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterStateDecommissioned, loaded.State)
 	})
 
 	s.T().Run("failures", func(t *testing.T) {
@@ -468,7 +828,7 @@ func (s *ClustersControllerTestSuite) TestDelete() {
 					// given
 					svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
 					// when/then
-					test.DeleteClustersUnauthorized(t, svc.Context, svc, ctrl, c.ClusterID)
+					test.DeleteClustersUnauthorized(t, svc.Context, svc, ctrl, c.ClusterID, nil)
 				})
 			}
 		})
@@ -477,8 +837,514 @@ func (s *ClustersControllerTestSuite) TestDelete() {
 			// given
 			svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
 			// when/then
-			test.DeleteClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4())
+			test.DeleteClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), nil)
+		})
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestConditionalRequests() {
+
+	s.T().Run("show", func(t *testing.T) {
+		// given
+		c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Auth)
+		resp, _ := test.ShowClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
+		etag := resp.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+
+		t.Run("not modified", func(t *testing.T) {
+			// when/then
+			resp := test.ShowClustersNotModified(t, svc.Context, svc, ctrl, c.ClusterID, nil, &etag)
+			assert.Equal(t, etag, resp.Header().Get("ETag"))
+			test.ShowForAuthClientClustersNotModified(t, svc.Context, svc, ctrl, c.ClusterID, nil, &etag)
+			wildcard := "*"
+			test.ShowClustersNotModified(t, svc.Context, svc, ctrl, c.ClusterID, nil, &wildcard)
+		})
+
+		t.Run("modified", func(t *testing.T) {
+			// given
+			err := s.Application.Clusters().UpdateState(context.Background(), c.ClusterID, repository.ClusterStateDraining)
+			require.NoError(t, err)
+			// when
+			resp, result := test.ShowClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, &etag)
+			// then
+			require.NotNil(t, result)
+			assert.Equal(t, repository.ClusterStateDraining, result.Data.Attributes.State)
+			assert.NotEqual(t, etag, resp.Header().Get("ETag"))
+		})
+	})
+
+	s.T().Run("update", func(t *testing.T) {
+		// given
+		c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(cluster.OSO), testsupport.WithValidURLs())
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+		resp, _ := test.ShowClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
+		etag := resp.Header().Get("ETag")
+		name := "foo"
+		payload := app.UpdateClustersPayload{
+			Data: &app.UpdateClusterData{
+				Name: &name,
+			},
+		}
+
+		t.Run("ok", func(t *testing.T) {
+			// when
+			resp, result := test.UpdateClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, &etag, &payload)
+			// then
+			require.NotNil(t, result)
+			assert.Equal(t, "foo", result.Data.Attributes.Name)
+			assert.NotEmpty(t, resp.Header().Get("ETag"))
+			assert.NotEqual(t, etag, resp.Header().Get("ETag"))
+		})
+
+		t.Run("stale version", func(t *testing.T) {
+			// when the same ETag is used again
+			_, result := test.UpdateClustersPreconditionFailed(t, svc.Context, svc, ctrl, c.ClusterID, &etag, &payload)
+			// then
+			require.NotNil(t, result)
+			require.Len(t, result.Errors, 1)
+			assert.Contains(t, result.Errors[0].Detail, "does not match the expected version")
+		})
+
+		t.Run("invalid etag", func(t *testing.T) {
+			// given
+			weak := "W/" + etag
+			// when/then
+			test.UpdateClustersPreconditionFailed(t, svc.Context, svc, ctrl, c.ClusterID, &weak, &payload)
+		})
+	})
+
+	s.T().Run("create", func(t *testing.T) {
+		// given
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+		clusterPayload := newCreateClusterPayload()
+		etag := `"0"`
+
+		t.Run("unknown cluster", func(t *testing.T) {
+			// when/then
+			test.CreateClustersPreconditionFailed(t, svc.Context, svc, ctrl, &etag, &clusterPayload)
+		})
+
+		t.Run("ok", func(t *testing.T) {
+			// given
+			resp := test.CreateClustersCreated(t, svc.Context, svc, ctrl, nil, &clusterPayload)
+			etag := resp.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+			clusterPayload.Data.Name = "foo"
+			// when
+			resp = test.CreateClustersCreated(t, svc.Context, svc, ctrl, &etag, &clusterPayload)
+			// then
+			assert.NotEqual(t, etag, resp.Header().Get("ETag"))
+			// and the same ETag cannot be used again
+			test.CreateClustersPreconditionFailed(t, svc.Context, svc, ctrl, &etag, &clusterPayload)
+		})
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+		// given
+		c := testsupport.CreateCluster(t, s.DB)
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+		resp, _ := test.ShowClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
+		etag := resp.Header().Get("ETag")
+		err := s.Application.Clusters().UpdateState(context.Background(), c.ClusterID, repository.ClusterStateDraining)
+		require.NoError(t, err)
+		// when/then
+		test.DeleteClustersPreconditionFailed(t, svc.Context, svc, ctrl, c.ClusterID, &etag)
+		loaded, err := s.Application.Clusters().Load(context.Background(), c.ClusterID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.ClusterStateDraining, loaded.State)
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestResourceObjects() {
+	// given
+	c := testsupport.CreateCluster(s.T(), s.DB, testsupport.WithType(cluster.OSO))
+
+	s.T().Run("compatibility mode", func(t *testing.T) {
+		// given
+		svc, ctrl := s.newSecuredControllerWithConfig(authsupport.Auth, clustersConfigurationStub{compatibilityMode: true})
+
+		t.Run("show", func(t *testing.T) {
+			// when
+			_, result := test.ShowClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
+			// then
+			require.NotNil(t, result)
+			require.NotNil(t, result.Data)
+			assert.Equal(t, cluster.OSO, result.Data.Type)
+			require.NotNil(t, result.Data.Name)
+			assert.Equal(t, c.Name, *result.Data.Name)
+			testsupport.AssertEqualClusterData(t, c, *result.Data)
+		})
+
+		t.Run("show for auth client", func(t *testing.T) {
+			// when
+			_, result := test.ShowForAuthClientClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
+			// then
+			require.NotNil(t, result)
+			require.NotNil(t, result.Data)
+			assert.Equal(t, cluster.OSO, result.Data.Type)
+			require.NotNil(t, result.Data.ServiceAccountToken)
+			assert.Equal(t, c.SAToken, *result.Data.ServiceAccountToken)
+			testsupport.AssertEqualFullClusterData(t, c, *result.Data)
+		})
+	})
+
+	s.T().Run("json-api mode", func(t *testing.T) {
+		// given
+		svc, ctrl := s.newSecuredControllerWithConfig(authsupport.Auth, clustersConfigurationStub{compatibilityMode: false})
+
+		t.Run("show", func(t *testing.T) {
+			// when
+			_, result := test.ShowClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
+			// then
+			require.NotNil(t, result)
+			require.NotNil(t, result.Data)
+			assert.Equal(t, "clusters", result.Data.Type)
+			assert.Equal(t, c.ClusterID, result.Data.ID)
+			require.NotNil(t, result.Data.Links)
+			require.NotNil(t, result.Data.Links.Self)
+			assert.True(t, strings.HasSuffix(*result.Data.Links.Self, "/api/clusters/"+c.ClusterID.String()))
+			assert.Nil(t, result.Data.Name)
+			assert.Nil(t, result.Data.APIURL)
+			assert.Nil(t, result.Data.State)
+			testsupport.AssertEqualClusterData(t, c, *result.Data)
+		})
+
+		t.Run("list", func(t *testing.T) {
+			// when
+			_, result := test.ListClustersOK(t, svc.Context, svc, ctrl, nil, nil, &c.URL, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+			// then
+			require.NotNil(t, result)
+			require.Len(t, result.Data, 1)
+			assert.Equal(t, "clusters", result.Data[0].Type)
+			assert.Nil(t, result.Data[0].Name)
+			testsupport.AssertEqualClustersData(t, []repository.Cluster{c}, result.Data)
+		})
+
+		t.Run("show for auth client", func(t *testing.T) {
+			// when
+			_, result := test.ShowForAuthClientClustersOK(t, svc.Context, svc, ctrl, c.ClusterID, nil, nil)
+			// then
+			require.NotNil(t, result)
+			require.NotNil(t, result.Data)
+			assert.Equal(t, "clusters", result.Data.Type)
+			assert.Nil(t, result.Data.Name)
+			assert.Nil(t, result.Data.ServiceAccountToken)
+			assert.Nil(t, result.Data.AuthClientSecret)
+			testsupport.AssertEqualFullClusterData(t, c, *result.Data)
+		})
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestListAudit() {
+
+	// given
+	since := time.Now()
+	c := testsupport.CreateCluster(s.T(), s.DB)
+	svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+	test.DeleteClustersNoContent(s.T(), svc.Context, svc, ctrl, c.ClusterID, nil)
+
+	s.T().Run("for cluster", func(t *testing.T) {
+		// when
+		_, result := test.ListClusterAuditClustersOK(t, svc.Context, svc, ctrl, c.ClusterID)
+		// then
+		require.Len(t, result.Data, 2)
+		assert.Equal(t, repository.AuditOperationClusterCreate, result.Data[0].Operation)
+		assert.Equal(t, repository.SystemActor, result.Data[0].Actor)
+		assert.Equal(t, c.ClusterID, result.Data[0].ClusterID)
+		require.Contains(t, result.Data[0].Changes, "auth-client-secret")
+		assert.Equal(t, "********", result.Data[0].Changes["auth-client-secret"].New)
+		// the cluster was decommissioned
+		assert.Equal(t, repository.AuditOperationClusterUpdate, result.Data[1].Operation)
+		assert.Equal(t, authsupport.ToolChainOperator, result.Data[1].Actor)
+		require.Contains(t, result.Data[1].Changes, "state")
+		assert.Equal(t, repository.ClusterStateActive, result.Data[1].Changes["state"].Old)
+		assert.Equal(t, repository.ClusterStateDecommissioned, result.Data[1].Changes["state"].New)
+	})
+
+	s.T().Run("since", func(t *testing.T) {
+		// when
+		_, result := test.ListAuditClustersOK(t, svc.Context, svc, ctrl, since)
+		// then
+		operations := []string{}
+		for _, e := range result.Data {
+			assert.False(t, e.CreatedAt.Before(since))
+			if e.ClusterID == c.ClusterID {
+				operations = append(operations, e.Operation)
+			}
+		}
+		assert.Equal(t, []string{repository.AuditOperationClusterCreate, repository.AuditOperationClusterUpdate}, operations)
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		for _, username := range []string{auth.Auth, auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
+				// when/then
+				test.ListClusterAuditClustersUnauthorized(t, svc.Context, svc, ctrl, c.ClusterID)
+				test.ListAuditClustersUnauthorized(t, svc.Context, svc, ctrl, since)
+			})
+		}
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestListIdentities() {
+	// given
+	idCluster1 := testsupport.CreateIdentityCluster(s.T(), s.DB)
+	idCluster2 := testsupport.CreateIdentityCluster(s.T(), s.DB, testsupport.WithCluster(idCluster1.Cluster))
+	idCluster3 := testsupport.CreateIdentityCluster(s.T(), s.DB, testsupport.WithCluster(idCluster1.Cluster))
+	testsupport.CreateIdentityCluster(s.T(), s.DB) // noise
+	identityIDs := []uuid.UUID{idCluster1.IdentityID, idCluster2.IdentityID, idCluster3.IdentityID}
+	svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.Tenant)
+
+	s.T().Run("all", func(t *testing.T) {
+		// when
+		_, result := test.ListIdentitiesClustersOK(t, svc.Context, svc, ctrl, idCluster1.ClusterID, nil, nil, nil)
+		// then
+		require.NotNil(t, result)
+		require.Len(t, result.Data, 3)
+		for _, data := range result.Data {
+			assert.Contains(t, identityIDs, data.ID)
+			assert.Equal(t, "identities", data.Type)
+			assert.Equal(t, idCluster1.ClusterID, data.Attributes.ClusterID)
+		}
+		require.NotNil(t, result.Meta)
+		assert.Equal(t, 3, result.Meta.TotalCount)
+		assert.Nil(t, result.Links)
+	})
+
+	s.T().Run("first page", func(t *testing.T) {
+		// given
+		limit := 2
+		// when
+		_, result := test.ListIdentitiesClustersOK(t, svc.Context, svc, ctrl, idCluster1.ClusterID, &limit, nil, nil)
+		// then
+		require.NotNil(t, result)
+		require.Len(t, result.Data, 2)
+		require.NotNil(t, result.Meta)
+		assert.Equal(t, 3, result.Meta.TotalCount)
+		require.NotNil(t, result.Links)
+		assert.Nil(t, result.Links.Prev)
+		require.NotNil(t, result.Links.Next)
+		assert.Contains(t, *result.Links.Next, "page%5Boffset%5D=2")
+	})
+
+	s.T().Run("ndjson", func(t *testing.T) {
+		// given
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", fmt.Sprintf("/clusters/%s/identities", idCluster1.ClusterID), nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/x-ndjson")
+		prms := url.Values{"clusterID": []string{idCluster1.ClusterID.String()}}
+		goaCtx := goa.NewContext(goa.WithAction(svc.Context, "ClustersTest"), rw, req, prms)
+		ctx, err := app.NewListIdentitiesClustersContext(goaCtx, req, svc)
+		require.NoError(t, err)
+		// when
+		err = ctrl.ListIdentities(ctx)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "application/x-ndjson", rw.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(rw.Body.String()), "\n")
+		require.Len(t, lines, 3)
+		for _, line := range lines {
+			var data app.IdentityClusterData
+			require.NoError(t, json.Unmarshal([]byte(line), &data))
+			assert.Contains(t, identityIDs, data.ID)
+			assert.Equal(t, "identities", data.Type)
+			require.NotNil(t, data.Attributes)
+			assert.Equal(t, idCluster1.ClusterID, data.Attributes.ClusterID)
+		}
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		test.ListIdentitiesClustersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), nil, nil, nil)
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		for _, username := range []string{auth.OsoProxy, auth.ToolChainOperator, auth.JenkinsIdler, auth.JenkinsProxy} {
+			t.Run(username, func(t *testing.T) {
+				// given
+				svc, ctrl := s.newSecuredControllerWithServiceAccount(username)
+				// when/then
+				test.ListIdentitiesClustersUnauthorized(t, svc.Context, svc, ctrl, idCluster1.ClusterID, nil, nil, nil)
+			})
+		}
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestWatch() {
+	// given
+	halt, err := s.Application.ClusterService().InitializeChangeFeed()
+	require.NoError(s.T(), err)
+	defer halt()
+	c := testsupport.CreateCluster(s.T(), s.DB)
+	svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+	// watch returns the events streamed during a 1s watch from the given resource version
+	watch := func(t *testing.T, resourceVersion *string) []app.ClusterWatchEvent {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/clusters/watch", nil)
+		require.NoError(t, err)
+		prms := url.Values{"timeout": []string{"1"}}
+		if resourceVersion != nil {
+			prms.Set("resource-version", *resourceVersion)
+		}
+		goaCtx := goa.NewContext(goa.WithAction(svc.Context, "ClustersTest"), rw, req, prms)
+		ctx, err := app.NewWatchClustersContext(goaCtx, req, svc)
+		require.NoError(t, err)
+		err = ctrl.Watch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.Equal(t, "application/x-ndjson", rw.Header().Get("Content-Type"))
+		var events []app.ClusterWatchEvent
+		for _, line := range strings.Split(strings.TrimSpace(rw.Body.String()), "\n") {
+			var event app.ClusterWatchEvent
+			require.NoError(t, json.Unmarshal([]byte(line), &event))
+			events = append(events, event)
+		}
+		return events
+	}
+	var bookmark app.ClusterWatchEvent
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		events := watch(t, nil)
+		// then the current clusters are sent first, followed by a bookmark
+		require.NotEmpty(t, events)
+		bookmark = events[len(events)-1]
+		assert.Equal(t, "BOOKMARK", bookmark.Type)
+		require.NotNil(t, bookmark.ResourceVersion)
+		assert.Nil(t, bookmark.ClusterID)
+		var ids []uuid.UUID
+		for _, event := range events[:len(events)-1] {
+			assert.Equal(t, "ADDED", event.Type)
+			assert.Equal(t, *bookmark.ResourceVersion, *event.ResourceVersion)
+			require.NotNil(t, event.ClusterID)
+			require.NotNil(t, event.Object)
+			assert.Equal(t, *event.ClusterID, event.Object.ID)
+			ids = append(ids, *event.ClusterID)
+		}
+		assert.Contains(t, ids, c.ClusterID)
+	})
+
+	s.T().Run("resume", func(t *testing.T) {
+		// given
+		operatorSvc, operatorCtrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+		test.DeleteClustersNoContent(t, operatorSvc.Context, operatorSvc, operatorCtrl, c.ClusterID, nil)
+		// when
+		events := watch(t, bookmark.ResourceVersion)
+		// then
+		require.Len(t, events, 2)
+		assert.Equal(t, "DELETED", events[0].Type)
+		require.NotNil(t, events[0].ClusterID)
+		assert.Equal(t, c.ClusterID, *events[0].ClusterID)
+		require.NotNil(t, events[0].Object)
+		assert.Equal(t, repository.ClusterStateDecommissioned, events[0].Object.Attributes.State)
+		assert.Equal(t, "BOOKMARK", events[1].Type)
+		assert.Equal(t, *events[0].ResourceVersion, *events[1].ResourceVersion)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.ToolChainOperator)
+			// when/then
+			test.WatchClustersUnauthorized(t, svc.Context, svc, ctrl, nil, nil)
+		})
+
+		t.Run("bad request", func(t *testing.T) {
+			// given
+			resourceVersion := "foo"
+			// when/then
+			test.WatchClustersBadRequest(t, svc.Context, svc, ctrl, &resourceVersion, nil)
 		})
+
+		t.Run("gone", func(t *testing.T) {
+			// given the events of a cluster purged from the outbox
+			purged := testsupport.CreateCluster(t, s.DB)
+			err := s.DB.Exec(`UPDATE outbox_event SET dispatched_at = now() - interval '2 hours'
+				WHERE audit_id IN (SELECT audit_id FROM audit_log WHERE cluster_id = ?)`, purged.ClusterID).Error
+			require.NoError(t, err)
+			err = s.Application.Outbox().Purge(context.Background(), time.Now().Add(-time.Hour))
+			require.NoError(t, err)
+			// when
+			_, result := test.WatchClustersGone(t, svc.Context, svc, ctrl, bookmark.ResourceVersion, nil)
+			// then
+			require.NotNil(t, result)
+			require.Len(t, result.Errors, 1)
+			assert.Equal(t, "410", *result.Errors[0].Status)
+			assert.Equal(t, "gone", *result.Errors[0].Code)
+		})
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestListClustersForIdentities() {
+	// given
+	idCluster1 := testsupport.CreateIdentityCluster(s.T(), s.DB)
+	idCluster2 := testsupport.CreateIdentityCluster(s.T(), s.DB, testsupport.WithIdentityID(idCluster1.IdentityID))
+	idCluster3 := testsupport.CreateIdentityCluster(s.T(), s.DB)
+	svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.JenkinsIdler)
+
+	s.T().Run("single identity", func(t *testing.T) {
+		// when
+		_, result := test.ListClustersForIdentityClustersOK(t, svc.Context, svc, ctrl, idCluster1.IdentityID)
+		// then
+		require.NotNil(t, result)
+		require.Len(t, result.Data, 2)
+		clusters := make([]*app.ClusterData, len(result.Data))
+		for i, data := range result.Data {
+			assert.Equal(t, idCluster1.IdentityID, data.IdentityID)
+			assert.False(t, data.CreatedAt.IsZero())
+			clusters[i] = data.Cluster
+		}
+		testsupport.AssertEqualClustersData(t, []repository.Cluster{idCluster1.Cluster, idCluster2.Cluster}, clusters)
+	})
+
+	s.T().Run("unknown identity", func(t *testing.T) {
+		// when
+		_, result := test.ListClustersForIdentityClustersOK(t, svc.Context, svc, ctrl, uuid.NewV4())
+		// then
+		require.NotNil(t, result)
+		assert.Empty(t, result.Data)
+	})
+
+	s.T().Run("many identities", func(t *testing.T) {
+		// given
+		payload := &app.IdentityClustersLookupPayload{
+			IdentityIds: []string{idCluster1.IdentityID.String(), idCluster3.IdentityID.String(), uuid.NewV4().String()},
+		}
+		// when
+		_, result := test.ListClustersForIdentitiesClustersOK(t, svc.Context, svc, ctrl, payload)
+		// then
+		require.NotNil(t, result)
+		require.Len(t, result.Data, 3)
+		count := map[uuid.UUID]int{}
+		for _, data := range result.Data {
+			count[data.IdentityID]++
+		}
+		assert.Equal(t, map[uuid.UUID]int{idCluster1.IdentityID: 2, idCluster3.IdentityID: 1}, count)
+	})
+
+	s.T().Run("invalid identity ID", func(t *testing.T) {
+		// given
+		payload := &app.IdentityClustersLookupPayload{
+			IdentityIds: []string{idCluster1.IdentityID.String(), "foo"},
+		}
+		// when/then
+		test.ListClustersForIdentitiesClustersBadRequest(t, svc.Context, svc, ctrl, payload)
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		// given
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(authsupport.ToolChainOperator)
+		payload := &app.IdentityClustersLookupPayload{
+			IdentityIds: []string{idCluster1.IdentityID.String()},
+		}
+		// when/then
+		test.ListClustersForIdentityClustersUnauthorized(t, svc.Context, svc, ctrl, idCluster1.IdentityID)
+		test.ListClustersForIdentitiesClustersUnauthorized(t, svc.Context, svc, ctrl, payload)
 	})
 }
 
@@ -639,6 +1505,265 @@ func (s *ClustersControllerTestSuite) TestRemoveIdentityToClustersLink() {
 	})
 }
 
+func (s *ClustersControllerTestSuite) TestBulkIdentityClusterLinks() {
+	// given
+	svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Auth)
+	c := testsupport.CreateCluster(s.T(), s.DB)
+	existing := testsupport.CreateIdentityCluster(s.T(), s.DB, testsupport.WithCluster(c))
+	doNotIgnore := false
+	newIdentityID := uuid.NewV4().String()
+
+	s.T().Run("link", func(t *testing.T) {
+
+		t.Run("per item", func(t *testing.T) {
+			// given
+			payload := &app.BulkLinkIdentitiesToClustersData{
+				Links: []*app.LinkIdentityToClusterData{
+					createLinkIdentityClusterPayload(c.URL, newIdentityID, nil),
+					createLinkIdentityClusterPayload(c.URL, existing.IdentityID.String(), nil),
+					createLinkIdentityClusterPayload("http://unknown.cluster", uuid.NewV4().String(), nil),
+				},
+			}
+			// when
+			_, result := test.LinkIdentitiesToClustersClustersOK(t, svc.Context, svc, ctrl, payload)
+			// then
+			require.NotNil(t, result)
+			assert.False(t, result.Data.Transactional)
+			require.Len(t, result.Data.Items, 3)
+			assert.Equal(t, newIdentityID, result.Data.Items[0].IdentityID)
+			assert.Equal(t, c.URL, result.Data.Items[0].ClusterURL)
+			assert.Equal(t, repository.IdentityClusterLinkCreated, result.Data.Items[0].Status)
+			assert.Nil(t, result.Data.Items[0].Error)
+			assert.Equal(t, repository.IdentityClusterLinkAlreadyExists, result.Data.Items[1].Status)
+			assert.Equal(t, repository.IdentityClusterLinkFailed, result.Data.Items[2].Status)
+			require.NotNil(t, result.Data.Items[2].Error)
+			require.NotNil(t, result.Data.Items[2].Error.Status)
+			assert.Equal(t, "404", *result.Data.Items[2].Error.Status)
+			assert.Equal(t, "cluster with url 'http://unknown.cluster' not found", result.Data.Items[2].Error.Detail)
+		})
+
+		t.Run("transactional", func(t *testing.T) {
+			// given
+			transactional := true
+			payload := &app.BulkLinkIdentitiesToClustersData{
+				Links: []*app.LinkIdentityToClusterData{
+					createLinkIdentityClusterPayload(c.URL, uuid.NewV4().String(), nil),
+					createLinkIdentityClusterPayload(c.URL, existing.IdentityID.String(), &doNotIgnore),
+				},
+				Transactional: &transactional,
+			}
+			// when
+			_, result := test.LinkIdentitiesToClustersClustersOK(t, svc.Context, svc, ctrl, payload)
+			// then
+			require.NotNil(t, result)
+			assert.True(t, result.Data.Transactional)
+			require.Len(t, result.Data.Items, 2)
+			assert.Equal(t, repository.IdentityClusterLinkRolledBack, result.Data.Items[0].Status)
+			assert.Equal(t, repository.IdentityClusterLinkFailed, result.Data.Items[1].Status)
+			require.NotNil(t, result.Data.Items[1].Error)
+			require.NotNil(t, result.Data.Items[1].Error.Status)
+			assert.Equal(t, "500", *result.Data.Items[1].Error.Status)
+		})
+
+		t.Run("invalid uuid", func(t *testing.T) {
+			// given
+			payload := &app.BulkLinkIdentitiesToClustersData{
+				Links: []*app.LinkIdentityToClusterData{
+					createLinkIdentityClusterPayload(c.URL, "foo", nil),
+				},
+			}
+			// when/then
+			test.LinkIdentitiesToClustersClustersBadRequest(t, svc.Context, svc, ctrl, payload)
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+			payload := &app.BulkLinkIdentitiesToClustersData{
+				Links: []*app.LinkIdentityToClusterData{
+					createLinkIdentityClusterPayload(c.URL, uuid.NewV4().String(), nil),
+				},
+			}
+			// when/then
+			test.LinkIdentitiesToClustersClustersUnauthorized(t, svc.Context, svc, ctrl, payload)
+		})
+	})
+
+	s.T().Run("unlink", func(t *testing.T) {
+
+		t.Run("per item", func(t *testing.T) {
+			// given
+			unknownIdentityID := uuid.NewV4().String()
+			payload := &app.BulkUnLinkIdentitiesToClustersData{
+				Links: []*app.UnLinkIdentityToClusterdata{
+					{ClusterURL: c.URL, IdentityID: newIdentityID},
+					{ClusterURL: c.URL, IdentityID: unknownIdentityID},
+				},
+			}
+			// when
+			_, result := test.RemoveIdentitiesToClustersLinksClustersOK(t, svc.Context, svc, ctrl, payload)
+			// then
+			require.NotNil(t, result)
+			require.Len(t, result.Data.Items, 2)
+			assert.Equal(t, repository.IdentityClusterLinkDeleted, result.Data.Items[0].Status)
+			assert.Equal(t, repository.IdentityClusterLinkFailed, result.Data.Items[1].Status)
+			require.NotNil(t, result.Data.Items[1].Error)
+			require.NotNil(t, result.Data.Items[1].Error.Status)
+			assert.Equal(t, "404", *result.Data.Items[1].Error.Status)
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+			payload := &app.BulkUnLinkIdentitiesToClustersData{
+				Links: []*app.UnLinkIdentityToClusterdata{
+					{ClusterURL: c.URL, IdentityID: existing.IdentityID.String()},
+				},
+			}
+			// when/then
+			test.RemoveIdentitiesToClustersLinksClustersUnauthorized(t, svc.Context, svc, ctrl, payload)
+		})
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestPlaceIdentity() {
+
+	// there is no OCP cluster in the config file
+	clusterType := cluster.OCP
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given a single OCP cluster, whose capacity is exhausted
+		testsupport.CreateCluster(t, s.DB, testsupport.WithType(clusterType), testsupport.WithCapacityExhausted(true))
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+		payload := &app.PlacementData{IdentityID: uuid.NewV4().String(), Type: &clusterType}
+		// when/then
+		test.PlaceIdentityClustersNotFound(t, svc.Context, svc, ctrl, payload)
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given another OCP cluster, with available capacity
+		c := testsupport.CreateCluster(t, s.DB, testsupport.WithType(clusterType))
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+		payload := &app.PlacementData{IdentityID: uuid.NewV4().String(), Type: &clusterType}
+		// when
+		_, result := test.PlaceIdentityClustersOK(t, svc.Context, svc, ctrl, payload)
+		// then
+		require.NotNil(t, result)
+		require.NotNil(t, result.Data)
+		testsupport.AssertEqualClusterData(t, c, *result.Data)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("bad request", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+			payload := &app.PlacementData{IdentityID: "foo"}
+			// when/then
+			test.PlaceIdentityClustersBadRequest(t, svc.Context, svc, ctrl, payload)
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount("foo")
+			payload := &app.PlacementData{IdentityID: uuid.NewV4().String()}
+			// when/then
+			test.PlaceIdentityClustersUnauthorized(t, svc.Context, svc, ctrl, payload)
+		})
+	})
+}
+
+func (s *ClustersControllerTestSuite) TestMigrateIdentities() {
+
+	// given
+	source := testsupport.CreateCluster(s.T(), s.DB, testsupport.WithType(cluster.OSD))
+	target := testsupport.CreateCluster(s.T(), s.DB, testsupport.WithType(cluster.OSD))
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("dry run", func(t *testing.T) {
+			// given
+			ic := testsupport.CreateIdentityCluster(t, s.DB, testsupport.WithCluster(source))
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Auth)
+			dryRun := true
+			payload := &app.IdentityMigrationPayload{
+				IdentityIds:      []string{ic.IdentityID.String()},
+				SourceClusterURL: source.URL,
+				TargetClusterURL: target.URL,
+				DryRun:           &dryRun,
+			}
+			// when
+			_, result := test.MigrateIdentitiesClustersOK(t, svc.Context, svc, ctrl, payload)
+			// then
+			require.NotNil(t, result)
+			require.NotNil(t, result.Data)
+			assert.True(t, result.Data.DryRun)
+			assert.Equal(t, []uuid.UUID{ic.IdentityID}, result.Data.IdentityIds)
+			assert.Equal(t, httpsupport.AddTrailingSlashToURL(target.URL), result.Data.TargetCluster.Attributes.APIURL)
+			_, err := s.Application.IdentityClusters().Load(context.Background(), ic.IdentityID, source.ClusterID)
+			require.NoError(t, err)
+		})
+
+		t.Run("migration", func(t *testing.T) {
+			// given
+			ic := testsupport.CreateIdentityCluster(t, s.DB, testsupport.WithCluster(source))
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Auth)
+			payload := &app.IdentityMigrationPayload{
+				IdentityIds:      []string{ic.IdentityID.String()},
+				SourceClusterURL: source.URL,
+				TargetClusterURL: target.URL,
+			}
+			// when
+			_, result := test.MigrateIdentitiesClustersOK(t, svc.Context, svc, ctrl, payload)
+			// then
+			require.NotNil(t, result)
+			require.NotNil(t, result.Data)
+			assert.False(t, result.Data.DryRun)
+			_, err := s.Application.IdentityClusters().Load(context.Background(), ic.IdentityID, target.ClusterID)
+			require.NoError(t, err)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("bad request", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Auth)
+			payload := &app.IdentityMigrationPayload{
+				IdentityIds:      []string{"foo"},
+				SourceClusterURL: source.URL,
+				TargetClusterURL: target.URL,
+			}
+			// when/then
+			test.MigrateIdentitiesClustersBadRequest(t, svc.Context, svc, ctrl, payload)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Auth)
+			payload := &app.IdentityMigrationPayload{
+				IdentityIds:      []string{uuid.NewV4().String()},
+				SourceClusterURL: source.URL,
+				TargetClusterURL: target.URL,
+			}
+			// when/then
+			test.MigrateIdentitiesClustersNotFound(t, svc.Context, svc, ctrl, payload)
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount("foo")
+			payload := &app.IdentityMigrationPayload{
+				IdentityIds:      []string{uuid.NewV4().String()},
+				SourceClusterURL: source.URL,
+				TargetClusterURL: target.URL,
+			}
+			// when/then
+			test.MigrateIdentitiesClustersUnauthorized(t, svc.Context, svc, ctrl, payload)
+		})
+	})
+}
+
 func createUnLinkIdentityToClusterData(clusterURL, identityID string) *app.UnLinkIdentityToClusterdata {
 	return &app.UnLinkIdentityToClusterdata{ClusterURL: clusterURL, IdentityID: identityID}
 }
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-cluster/app"
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// clusterETag returns the (strong) entity tag of the given cluster, derived from its version
+func clusterETag(clustr repository.Cluster) string {
+	return strconv.Quote(strconv.Itoa(clustr.Version))
+}
+
+// matchesIfNoneMatch returns `true` if the given entity tag matches the `If-None-Match` header,
+// i.e, if the client already has the current representation of the resource.
+// Entity tags are compared with the weak comparison function (see https://tools.ietf.org/html/rfc7232#section-3.2)
+func matchesIfNoneMatch(ifNoneMatch *string, etag string) bool {
+	if ifNoneMatch == nil {
+		return false
+	}
+	for _, tag := range strings.Split(*ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersions returns the cluster versions listed in the `If-Match` header, or `nil` if the header is missing
+// or if it matches any version (`*`). Weak entity tags are ignored, since they never match the `If-Match` header
+// (see https://tools.ietf.org/html/rfc7232#section-3.1).
+// Returns an error if the header is set but none of its entity tags can match a cluster version.
+func expectedVersions(ifMatch *string) ([]int, error) {
+	if ifMatch == nil {
+		return nil, nil
+	}
+	var versions []int
+	for _, tag := range strings.Split(*ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, nil
+		}
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		if v, err := strconv.Unquote(tag); err == nil {
+			if version, err := strconv.Atoi(v); err == nil {
+				versions = append(versions, version)
+			}
+		}
+	}
+	if len(versions) == 0 {
+		return nil, errors.NewVersionConflictError("none of the entity tags in the 'If-Match' header matches a cluster version")
+	}
+	return versions, nil
+}
+
+// isVersionConflict returns `true` if the given error (or its cause) is a VersionConflictError
+func isVersionConflict(err error) bool {
+	_, ok := errs.Cause(err).(errors.VersionConflictError)
+	return ok
+}
+
+// preconditionFailedContext the context of the actions which respond with a '412 Precondition Failed' status
+type preconditionFailedContext interface {
+	PreconditionFailed(*app.JSONAPIErrors) error
+}
+
+// preconditionFailed responds with a '412 Precondition Failed' status, when the condition in the `If-Match`
+// header of the request is not met
+func preconditionFailed(ctx preconditionFailedContext, err error) error {
+	id := uuid.NewV4().String()
+	status := strconv.Itoa(http.StatusPreconditionFailed)
+	code := "precondition_failed"
+	title := http.StatusText(http.StatusPreconditionFailed)
+	return ctx.PreconditionFailed(&app.JSONAPIErrors{
+		Errors: []*app.JSONAPIError{
+			{
+				ID:     &id,
+				Status: &status,
+				Code:   &code,
+				Title:  &title,
+				Detail: err.Error(),
+			},
+		},
+	})
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/fabric8-services/fabric8-cluster/app"
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+
+	"github.com/goadesign/goa"
+)
+
+// absoluteURL returns the absolute URL of the given path, based on the scheme and host of the given request
+func absoluteURL(req *goa.RequestData, path string) string {
+	scheme := "http"
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, req.Host, path)
+}
+
+// setPagination sets the sort order, offset and limit of the given options from the optional query parameters
+func setPagination(options *repository.ClusterListOptions, sort *string, offset, limit *int) {
+	if sort != nil {
+		options.Sort = *sort
+	}
+	if offset != nil {
+		options.Offset = *offset
+	}
+	if limit != nil {
+		options.Limit = *limit
+	}
+}
+
+// newPagingLinks returns the links to the previous and next pages of a list, or `nil` if the list is not paginated
+// (i.e, if no limit was set in the request). The links keep all the other query parameters of the request.
+func newPagingLinks(req *goa.RequestData, offset, limit, total int) *app.PagingLinks {
+	if limit <= 0 {
+		return nil
+	}
+	pageLink := func(offset int) *string {
+		query := req.URL.Query()
+		query.Set("page[offset]", strconv.Itoa(offset))
+		query.Set("page[limit]", strconv.Itoa(limit))
+		link := absoluteURL(req, req.URL.Path+"?"+query.Encode())
+		return &link
+	}
+	links := &app.PagingLinks{}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links.Prev = pageLink(prev)
+	}
+	if offset+limit < total {
+		links.Next = pageLink(offset + limit)
+	}
+	return links
+}
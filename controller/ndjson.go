@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+)
+
+// ndjsonMediaType the media type of the newline-delimited JSON responses (see http://ndjson.org)
+const ndjsonMediaType = "application/x-ndjson"
+
+// acceptsNDJSON returns `true` if the given `Accept` header contains the NDJSON media type
+func acceptsNDJSON(accept *string) bool {
+	if accept == nil {
+		return false
+	}
+	for _, mediaType := range strings.Split(*accept, ",") {
+		if strings.TrimSpace(strings.Split(mediaType, ";")[0]) == ndjsonMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// ndjsonWriter writes JSON values in the response body, one per line. The response status and headers are
+// only written along with the first value, so that an error occurring before can still be returned as
+// a regular JSON-API error response.
+type ndjsonWriter struct {
+	resp    *goa.ResponseData
+	encoder *json.Encoder
+}
+
+// newNDJSONWriter returns a new writer on the given response
+func newNDJSONWriter(resp *goa.ResponseData) *ndjsonWriter {
+	return &ndjsonWriter{
+		resp: resp,
+	}
+}
+
+// Write writes the given value on a new line, and flushes the response so that the client receives it immediately
+func (w *ndjsonWriter) Write(value interface{}) error {
+	if !w.Started() {
+		w.resp.Header().Set("Content-Type", ndjsonMediaType)
+		w.resp.WriteHeader(http.StatusOK)
+		w.encoder = json.NewEncoder(w.resp)
+	}
+	if err := w.encoder.Encode(value); err != nil {
+		return errs.Wrap(err, "unable to write the value in the response")
+	}
+	if flusher, ok := w.resp.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// Close writes the response status and headers if no value was written
+func (w *ndjsonWriter) Close() {
+	if !w.Started() {
+		w.resp.Header().Set("Content-Type", ndjsonMediaType)
+		w.resp.WriteHeader(http.StatusOK)
+	}
+}
+
+// Started returns `true` if the response status and headers were already written
+func (w *ndjsonWriter) Started() bool {
+	return w.encoder != nil
+}
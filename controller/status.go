@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/fabric8-services/fabric8-cluster/app"
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
 	"github.com/fabric8-services/fabric8-common/log"
 
 	"github.com/goadesign/goa"
@@ -30,19 +31,33 @@ type DBChecker interface {
 	Ping() error
 }
 
+// ClusterConfigSyncChecker is to be used to retrieve the result of the last synchronization of the clusters with the config file
+type ClusterConfigSyncChecker interface {
+	LastClusterConfigSync() *repository.ClusterConfigSyncResult
+}
+
+// LeadershipChecker is to be used to retrieve the leadership state of the current replica
+type LeadershipChecker interface {
+	Leadership() repository.Leadership
+}
+
 // StatusController implements the status resource.
 type StatusController struct {
 	*goa.Controller
-	dbChecker DBChecker
-	config    statusConfiguration
+	dbChecker         DBChecker
+	syncChecker       ClusterConfigSyncChecker
+	leadershipChecker LeadershipChecker
+	config            statusConfiguration
 }
 
 // NewStatusController creates a status controller.
-func NewStatusController(service *goa.Service, dbChecker DBChecker, config statusConfiguration) *StatusController {
+func NewStatusController(service *goa.Service, dbChecker DBChecker, syncChecker ClusterConfigSyncChecker, leadershipChecker LeadershipChecker, config statusConfiguration) *StatusController {
 	return &StatusController{
-		Controller: service.NewController("StatusController"),
-		dbChecker:  dbChecker,
-		config:     config,
+		Controller:        service.NewController("StatusController"),
+		dbChecker:         dbChecker,
+		syncChecker:       syncChecker,
+		leadershipChecker: leadershipChecker,
+		config:            config,
 	}
 }
 
@@ -79,12 +94,56 @@ func (c *StatusController) Show(ctx *app.ShowStatusContext) error {
 		res.ConfigurationStatus = "OK"
 	}
 
+	if result := c.syncChecker.LastClusterConfigSync(); result != nil {
+		res.ClusterConfigSync = convertToClusterConfigSyncStatus(*result)
+	}
+	res.Leadership = convertToLeadershipStatus(c.leadershipChecker.Leadership())
+
 	if dbErr != nil || (configErr != nil && !devMode) {
 		return ctx.ServiceUnavailable(res)
 	}
 	return ctx.OK(res)
 }
 
+func convertToClusterConfigSyncStatus(result repository.ClusterConfigSyncResult) *app.ClusterConfigSyncStatus {
+	status := &app.ClusterConfigSyncStatus{
+		Policy:        result.Policy,
+		Time:          result.Time,
+		Applied:       result.Applied,
+		Creates:       clusterConfigSyncURLs(result.Plan.Creates),
+		Updates:       clusterConfigSyncURLs(result.Plan.Updates),
+		Decommissions: clusterConfigSyncURLs(result.Plan.Decommissions),
+	}
+	if result.Error != nil {
+		msg := result.Error.Error()
+		status.Error = &msg
+	}
+	return status
+}
+
+func convertToLeadershipStatus(leadership repository.Leadership) *app.LeadershipStatus {
+	status := &app.LeadershipStatus{
+		Enabled:   leadership.Enabled,
+		Leader:    !leadership.Enabled || leadership.Leader,
+		ExpiresAt: leadership.ExpiresAt,
+	}
+	if leadership.Identity != "" {
+		status.Identity = &leadership.Identity
+	}
+	if leadership.Holder != "" {
+		status.Holder = &leadership.Holder
+	}
+	return status
+}
+
+func clusterConfigSyncURLs(changes []repository.ClusterConfigSyncChange) []string {
+	urls := make([]string, len(changes))
+	for i, c := range changes {
+		urls[i] = c.URL
+	}
+	return urls
+}
+
 // GormDBChecker implements DB checker
 type GormDBChecker struct {
 	db *gorm.DB
@@ -1,11 +1,13 @@
 package controller_test
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/fabric8-services/fabric8-cluster/app/test"
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
 	"github.com/fabric8-services/fabric8-cluster/configuration"
 	. "github.com/fabric8-services/fabric8-cluster/controller"
 	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
@@ -32,12 +34,12 @@ func TestStatusController(t *testing.T) {
 
 func (s *StatusControllerTestSuite) UnSecuredController() (*goa.Service, *StatusController) {
 	svc := goa.New("Status-Service")
-	return svc, NewStatusController(svc, NewGormDBChecker(s.DB), s.Configuration)
+	return svc, NewStatusController(svc, NewGormDBChecker(s.DB), s.Application.ClusterService(), s.Application.ClusterService(), s.Configuration)
 }
 
 func (s *StatusControllerTestSuite) UnSecuredControllerWithUnreachableDB() (*goa.Service, *StatusController) {
 	svc := goa.New("Status-Service")
-	return svc, NewStatusController(svc, &dummyDBChecker{}, s.Configuration)
+	return svc, NewStatusController(svc, &dummyDBChecker{}, s.Application.ClusterService(), s.Application.ClusterService(), s.Configuration)
 }
 
 func (s *StatusControllerTestSuite) TestShowStatusInDevModeOK() {
@@ -57,6 +59,67 @@ func (s *StatusControllerTestSuite) TestShowStatusInDevModeOK() {
 	assert.True(t, *res.DevMode)
 }
 
+func (s *StatusControllerTestSuite) TestShowStatusWithClusterConfigSync() {
+	// given
+	result, err := s.Application.ClusterService().CreateOrSaveClusterFromConfig(context.Background())
+	require.NoError(s.T(), err)
+	svc, ctrl := s.UnSecuredController()
+	// when
+	_, res := test.ShowStatusOK(s.T(), svc.Context, svc, ctrl)
+	// then
+	require.NotNil(s.T(), res.ClusterConfigSync)
+	assert.Equal(s.T(), result.Policy, res.ClusterConfigSync.Policy)
+	assert.True(s.T(), res.ClusterConfigSync.Applied)
+	assert.Nil(s.T(), res.ClusterConfigSync.Error)
+	assert.Len(s.T(), res.ClusterConfigSync.Creates, len(result.Plan.Creates))
+	assert.Len(s.T(), res.ClusterConfigSync.Updates, len(result.Plan.Updates))
+	assert.Len(s.T(), res.ClusterConfigSync.Decommissions, len(result.Plan.Decommissions))
+}
+
+func (s *StatusControllerTestSuite) TestShowStatusWithLeadership() {
+
+	s.T().Run("leader election disabled", func(t *testing.T) {
+		// given
+		svc := goa.New("Status-Service")
+		ctrl := NewStatusController(svc, NewGormDBChecker(s.DB), s.Application.ClusterService(), &dummyLeadershipChecker{}, s.Configuration)
+		// when
+		_, res := test.ShowStatusOK(t, svc.Context, svc, ctrl)
+		// then
+		require.NotNil(t, res.Leadership)
+		assert.False(t, res.Leadership.Enabled)
+		assert.True(t, res.Leadership.Leader)
+		assert.Nil(t, res.Leadership.Holder)
+		assert.Nil(t, res.Leadership.ExpiresAt)
+	})
+
+	s.T().Run("follower", func(t *testing.T) {
+		// given
+		expiresAt := time.Now().Add(time.Minute)
+		svc := goa.New("Status-Service")
+		ctrl := NewStatusController(svc, NewGormDBChecker(s.DB), s.Application.ClusterService(), &dummyLeadershipChecker{
+			leadership: repository.Leadership{
+				Enabled:   true,
+				Identity:  "replica2",
+				Leader:    false,
+				Holder:    "replica1",
+				ExpiresAt: &expiresAt,
+			},
+		}, s.Configuration)
+		// when
+		_, res := test.ShowStatusOK(t, svc.Context, svc, ctrl)
+		// then
+		require.NotNil(t, res.Leadership)
+		assert.True(t, res.Leadership.Enabled)
+		assert.False(t, res.Leadership.Leader)
+		require.NotNil(t, res.Leadership.Identity)
+		assert.Equal(t, "replica2", *res.Leadership.Identity)
+		require.NotNil(t, res.Leadership.Holder)
+		assert.Equal(t, "replica1", *res.Leadership.Holder)
+		require.NotNil(t, res.Leadership.ExpiresAt)
+		assert.Equal(t, expiresAt.Unix(), res.Leadership.ExpiresAt.Unix())
+	})
+}
+
 func (s *StatusControllerTestSuite) TestShowStatusWithoutDBFails() {
 	svc, ctrl := s.UnSecuredControllerWithUnreachableDB()
 	_, res := test.ShowStatusServiceUnavailable(s.T(), svc.Context, svc, ctrl)
@@ -98,3 +161,11 @@ type dummyDBChecker struct {
 func (c *dummyDBChecker) Ping() error {
 	return errors.New("DB is unreachable")
 }
+
+type dummyLeadershipChecker struct {
+	leadership repository.Leadership
+}
+
+func (c *dummyLeadershipChecker) Leadership() repository.Leadership {
+	return c.leadership
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-cluster/app"
+	"github.com/fabric8-services/fabric8-cluster/application"
+	"github.com/fabric8-services/fabric8-cluster/webhook/repository"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+)
+
+// resourceTypeSubscriptions the type of the webhook subscription resource objects
+const resourceTypeSubscriptions = "subscriptions"
+
+// SubscriptionsController implements the subscriptions resource.
+type SubscriptionsController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewSubscriptionsController creates a subscriptions controller.
+func NewSubscriptionsController(service *goa.Service, app application.Application) *SubscriptionsController {
+	return &SubscriptionsController{Controller: service.NewController("SubscriptionsController"), app: app}
+}
+
+// Create creates a webhook subscription on behalf of the service account of the request. The response includes the
+// secret of the subscription, which is not returned afterwards.
+func (c *SubscriptionsController) Create(ctx *app.CreateSubscriptionsContext) error {
+	subscription := repository.Subscription{
+		URL:        ctx.Payload.Data.URL,
+		EventTypes: ctx.Payload.Data.EventTypes,
+	}
+	if ctx.Payload.Data.Secret != nil {
+		subscription.Secret = *ctx.Payload.Data.Secret
+	}
+	// authorization is checked at the service level for more consistency accross the codebase.
+	err := c.app.WebhookService().CreateSubscription(ctx, &subscription)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while creating a webhook subscription")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	data := convertToSubscriptionData(ctx.RequestData, subscription)
+	data.Attributes.Secret = &subscription.Secret
+	ctx.ResponseData.Header().Set("Location", app.SubscriptionsHref(subscription.ID.String()))
+	return ctx.Created(&app.SubscriptionSingle{
+		Data: data,
+	})
+}
+
+// List returns the webhook subscriptions of the service account of the request
+func (c *SubscriptionsController) List(ctx *app.ListSubscriptionsContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	subscriptions, err := c.app.WebhookService().ListSubscriptions(ctx)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.SubscriptionData, len(subscriptions))
+	for i, subscription := range subscriptions {
+		data[i] = convertToSubscriptionData(ctx.RequestData, subscription)
+	}
+	return ctx.OK(&app.SubscriptionList{
+		Data: data,
+	})
+}
+
+// Show returns the webhook subscription identified by the `subscriptionID` param
+func (c *SubscriptionsController) Show(ctx *app.ShowSubscriptionsContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	subscription, err := c.app.WebhookService().LoadSubscription(ctx, ctx.SubscriptionID)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.SubscriptionSingle{
+		Data: convertToSubscriptionData(ctx.RequestData, *subscription),
+	})
+}
+
+// Delete deletes the webhook subscription identified by the `subscriptionID` param
+func (c *SubscriptionsController) Delete(ctx *app.DeleteSubscriptionsContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	err := c.app.WebhookService().DeleteSubscription(ctx, ctx.SubscriptionID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while deleting a webhook subscription")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// ListDeliveries returns the deliveries of the webhook subscription identified by the `subscriptionID` param,
+// in the state given in the `state` param (if specified)
+func (c *SubscriptionsController) ListDeliveries(ctx *app.ListDeliveriesSubscriptionsContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	deliveries, err := c.app.WebhookService().ListDeliveries(ctx, ctx.SubscriptionID, ctx.State)
+	if err != nil {
+		return app.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.DeliveryData, len(deliveries))
+	for i, delivery := range deliveries {
+		data[i] = convertToDeliveryData(delivery)
+	}
+	return ctx.OK(&app.DeliveryList{
+		Data: data,
+	})
+}
+
+// Redeliver moves the dead delivery identified by the `deliveryID` param back to the pending deliveries
+func (c *SubscriptionsController) Redeliver(ctx *app.RedeliverSubscriptionsContext) error {
+	// authorization is checked at the service level for more consistency accross the codebase.
+	delivery, err := c.app.WebhookService().Redeliver(ctx, ctx.SubscriptionID, ctx.DeliveryID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"error": err,
+		}, "error while redelivering a webhook delivery")
+		return app.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.DeliverySingle{
+		Data: convertToDeliveryData(*delivery),
+	})
+}
+
+// convertToSubscriptionData converts the given subscription into a resource object, without its secret
+func convertToSubscriptionData(req *goa.RequestData, subscription repository.Subscription) *app.SubscriptionData {
+	selfLink := absoluteURL(req, app.SubscriptionsHref(subscription.ID.String()))
+	return &app.SubscriptionData{
+		ID:   subscription.ID,
+		Type: resourceTypeSubscriptions,
+		Attributes: &app.SubscriptionAttributes{
+			CreatedAt:  subscription.CreatedAt,
+			Owner:      subscription.Owner,
+			URL:        subscription.URL,
+			EventTypes: []string(subscription.EventTypes),
+		},
+		Links: &app.GenericLinks{
+			Self: &selfLink,
+		},
+	}
+}
+
+func convertToDeliveryData(delivery repository.Delivery) *app.DeliveryData {
+	result := &app.DeliveryData{
+		ID:          delivery.ID,
+		EventID:     int(delivery.EventID),
+		CreatedAt:   delivery.CreatedAt,
+		State:       delivery.State,
+		Attempts:    delivery.Attempts,
+		DeliveredAt: delivery.DeliveredAt,
+	}
+	if delivery.State == repository.DeliveryStatePending {
+		nextAttemptAt := delivery.NextAttemptAt
+		result.NextAttemptAt = &nextAttemptAt
+	}
+	if delivery.LastError != "" {
+		lastError := delivery.LastError
+		result.LastError = &lastError
+	}
+	return result
+}
@@ -0,0 +1,209 @@
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-cluster/app"
+	"github.com/fabric8-services/fabric8-cluster/app/test"
+	. "github.com/fabric8-services/fabric8-cluster/controller"
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-cluster/test"
+	"github.com/fabric8-services/fabric8-cluster/webhook/repository"
+	"github.com/fabric8-services/fabric8-common/auth"
+	authtestsupport "github.com/fabric8-services/fabric8-common/test/auth"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SubscriptionsControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestSubscriptionsController(t *testing.T) {
+	suite.Run(t, &SubscriptionsControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *SubscriptionsControllerTestSuite) newSecuredControllerWithServiceAccount(username string) (*goa.Service, *SubscriptionsController) {
+	svc, err := authtestsupport.ServiceAsServiceAccountUser("Token-Service", &authtestsupport.Identity{
+		Username: username,
+		ID:       uuid.NewV4(),
+	})
+	require.NoError(s.T(), err)
+	return svc, NewSubscriptionsController(svc, s.Application)
+}
+
+func (s *SubscriptionsControllerTestSuite) TestCreate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+		payload := &app.CreateSubscription{
+			Data: &app.CreateSubscriptionData{
+				URL:        "https://tenant.example.com/webhooks",
+				EventTypes: []string{"cluster.update", "cluster.delete"},
+			},
+		}
+		// when
+		resp, result := test.CreateSubscriptionsCreated(t, svc.Context, svc, ctrl, payload)
+		// then
+		require.NotNil(t, result)
+		require.NotNil(t, result.Data)
+		assert.Equal(t, app.SubscriptionsHref(result.Data.ID.String()), resp.Header().Get("Location"))
+		assert.Equal(t, "subscriptions", result.Data.Type)
+		assert.Equal(t, auth.Tenant, result.Data.Attributes.Owner)
+		assert.Equal(t, "https://tenant.example.com/webhooks", result.Data.Attributes.URL)
+		assert.Equal(t, []string{"cluster.update", "cluster.delete"}, result.Data.Attributes.EventTypes)
+		// the generated secret is returned once
+		require.NotNil(t, result.Data.Attributes.Secret)
+		assert.NotEmpty(t, *result.Data.Attributes.Secret)
+		_, shown := test.ShowSubscriptionsOK(t, svc.Context, svc, ctrl, result.Data.ID)
+		assert.Nil(t, shown.Data.Attributes.Secret)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount("other")
+			payload := &app.CreateSubscription{
+				Data: &app.CreateSubscriptionData{
+					URL: "https://tenant.example.com/webhooks",
+				},
+			}
+			// when/then
+			test.CreateSubscriptionsUnauthorized(t, svc.Context, svc, ctrl, payload)
+		})
+
+		t.Run("bad request", func(t *testing.T) {
+			// given
+			svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+			payload := &app.CreateSubscription{
+				Data: &app.CreateSubscriptionData{
+					URL: "tenant.example.com/webhooks",
+				},
+			}
+			// when/then
+			test.CreateSubscriptionsBadRequest(t, svc.Context, svc, ctrl, payload)
+		})
+	})
+}
+
+func (s *SubscriptionsControllerTestSuite) TestListShowAndDelete() {
+	// given
+	tenantSubscription := testsupport.CreateSubscription(s.T(), s.DB, auth.Tenant, "https://tenant.example.com/webhooks")
+	idlerSubscription := testsupport.CreateSubscription(s.T(), s.DB, auth.JenkinsIdler, "https://idler.example.com/webhooks")
+	svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+
+	s.T().Run("list", func(t *testing.T) {
+		// when
+		_, result := test.ListSubscriptionsOK(t, svc.Context, svc, ctrl)
+		// then
+		require.NotNil(t, result)
+		ids := []uuid.UUID{}
+		for _, data := range result.Data {
+			ids = append(ids, data.ID)
+			assert.Nil(t, data.Attributes.Secret)
+		}
+		assert.Contains(t, ids, tenantSubscription.ID)
+		assert.NotContains(t, ids, idlerSubscription.ID)
+	})
+
+	s.T().Run("show", func(t *testing.T) {
+
+		t.Run("ok", func(t *testing.T) {
+			// when
+			_, result := test.ShowSubscriptionsOK(t, svc.Context, svc, ctrl, tenantSubscription.ID)
+			// then
+			require.NotNil(t, result)
+			assert.Equal(t, tenantSubscription.URL, result.Data.Attributes.URL)
+			require.NotNil(t, result.Data.Links)
+			require.NotNil(t, result.Data.Links.Self)
+			assert.Contains(t, *result.Data.Links.Self, app.SubscriptionsHref(tenantSubscription.ID.String()))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			test.ShowSubscriptionsNotFound(t, svc.Context, svc, ctrl, idlerSubscription.ID)
+			test.ShowSubscriptionsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4())
+		})
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+
+		t.Run("not found", func(t *testing.T) {
+			test.DeleteSubscriptionsNotFound(t, svc.Context, svc, ctrl, idlerSubscription.ID)
+		})
+
+		t.Run("ok", func(t *testing.T) {
+			// when
+			test.DeleteSubscriptionsNoContent(t, svc.Context, svc, ctrl, tenantSubscription.ID)
+			// then
+			test.ShowSubscriptionsNotFound(t, svc.Context, svc, ctrl, tenantSubscription.ID)
+		})
+	})
+}
+
+func (s *SubscriptionsControllerTestSuite) TestDeliveries() {
+	// given
+	subscription := testsupport.CreateSubscription(s.T(), s.DB, auth.Tenant, "https://tenant.example.com/webhooks")
+	testsupport.CreateCluster(s.T(), s.DB)
+	testsupport.DispatchOutboxEvents(s.T(), s.DB)
+	svc, ctrl := s.newSecuredControllerWithServiceAccount(auth.Tenant)
+
+	s.T().Run("list", func(t *testing.T) {
+		// when
+		_, result := test.ListDeliveriesSubscriptionsOK(t, svc.Context, svc, ctrl, subscription.ID, nil)
+		// then
+		require.NotNil(t, result)
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, repository.DeliveryStatePending, result.Data[0].State)
+		assert.Equal(t, 0, result.Data[0].Attempts)
+		assert.NotNil(t, result.Data[0].NextAttemptAt)
+		assert.Nil(t, result.Data[0].DeliveredAt)
+	})
+
+	s.T().Run("list dead letters", func(t *testing.T) {
+		// given
+		dead := repository.DeliveryStateDead
+		// when
+		_, result := test.ListDeliveriesSubscriptionsOK(t, svc.Context, svc, ctrl, subscription.ID, &dead)
+		// then
+		require.NotNil(t, result)
+		assert.Empty(t, result.Data)
+	})
+
+	s.T().Run("redeliver", func(t *testing.T) {
+		// given
+		deliveries, err := s.Application.Deliveries().ListForSubscription(svc.Context, subscription.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+
+		t.Run("pending delivery", func(t *testing.T) {
+			test.RedeliverSubscriptionsConflict(t, svc.Context, svc, ctrl, subscription.ID, deliveries[0].ID)
+		})
+
+		t.Run("dead delivery", func(t *testing.T) {
+			// given
+			deliveries[0].State = repository.DeliveryStateDead
+			deliveries[0].Attempts = 8
+			deliveries[0].LastError = "unexpected response status: 503 Service Unavailable"
+			err := s.Application.Deliveries().Update(svc.Context, &deliveries[0])
+			require.NoError(t, err)
+			// when
+			_, result := test.RedeliverSubscriptionsOK(t, svc.Context, svc, ctrl, subscription.ID, deliveries[0].ID)
+			// then
+			require.NotNil(t, result)
+			assert.Equal(t, repository.DeliveryStatePending, result.Data.State)
+			assert.Equal(t, 0, result.Data.Attempts)
+			require.NotNil(t, result.Data.LastError)
+			assert.Equal(t, "unexpected response status: 503 Service Unavailable", *result.Data.LastError)
+		})
+
+		t.Run("unknown delivery", func(t *testing.T) {
+			test.RedeliverSubscriptionsNotFound(t, svc.Context, svc, ctrl, subscription.ID, uuid.NewV4())
+		})
+	})
+}
@@ -3,7 +3,6 @@ package controller
 import (
 	"github.com/fabric8-services/fabric8-cluster/app"
 	"github.com/fabric8-services/fabric8-common/auth"
-	"github.com/fabric8-services/fabric8-common/httpsupport"
 	"github.com/goadesign/goa"
 
 	"github.com/fabric8-services/fabric8-cluster/application"
@@ -39,17 +38,8 @@ func (c *UserController) Clusters(ctx *app.ClustersUserContext) error {
 	}
 	data := make([]*app.ClusterData, 0)
 	for _, c := range clusters {
-		clusterData := &app.ClusterData{
-			Name:              c.Name,
-			APIURL:            httpsupport.AddTrailingSlashToURL(c.URL),
-			ConsoleURL:        httpsupport.AddTrailingSlashToURL(c.ConsoleURL),
-			MetricsURL:        httpsupport.AddTrailingSlashToURL(c.MetricsURL),
-			LoggingURL:        httpsupport.AddTrailingSlashToURL(c.LoggingURL),
-			AppDNS:            c.AppDNS,
-			Type:              c.Type,
-			CapacityExhausted: c.CapacityExhausted,
-		}
-		data = append(data, clusterData)
+		// this endpoint is deprecated, so its consumers always get the clusters in compatibility mode
+		data = append(data, convertToClusterData(ctx.RequestData, c, true))
 	}
 
 	return ctx.OK(&app.ClusterList{Data: data})
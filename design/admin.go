@@ -0,0 +1,30 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// showClusterConfigSync represents the outcome of a synchronization of the clusters with the config file
+var showClusterConfigSync = JSONSingle(
+	"ClusterConfigSync",
+	"Holds the outcome of a synchronization of the clusters with the config file",
+	clusterConfigSyncStatus,
+	nil)
+
+var _ = a.Resource("admin", func() {
+	a.BasePath("/admin")
+
+	a.Action("reload", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/reload"),
+		)
+		a.Description("Reload the cluster config file and synchronize the clusters with it, as when the file is modified. Useful when the changes of the file are not notified, eg: on network filesystems. Returns a conflict if the replica which handles the request is not the leader, since the clusters are synchronized by the leader only (the file is reloaded anyway). The errors carry the outcome of the synchronization in the 'cluster-config-sync' entry of their meta object")
+		a.Response(d.OK, showClusterConfigSync)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
@@ -11,7 +11,7 @@ var _ = a.API("cluster", func() {
 	a.Host("openshift.io")
 	a.Scheme("http")
 	a.BasePath("/api")
-	a.Consumes("application/json")
+	a.Consumes("application/json", "application/merge-patch+json")
 	a.Produces("application/json")
 
 	a.License(func() {
@@ -21,6 +21,17 @@ var createClusterData = a.Type("createClusterData", func() {
 	a.Attribute("app-dns", d.String, "User application domain name in the cluster")
 	a.Attribute("type", d.String, "Cluster type. Such as OSD, OSO, OCP, etc")
 	a.Attribute("capacity-exhausted", d.Boolean, "Cluster is full if set to 'true'")
+	a.Attribute("max-identities", d.Integer, "Maximum number of identities on the cluster. If set, 'capacity-exhausted' is managed automatically", func() {
+		a.Minimum(0)
+	})
+	a.Attribute("capacity-threshold", d.Integer, "Percentage of 'max-identities' above which the cluster is full. If not set, the service default applies", func() {
+		a.Minimum(0)
+		a.Maximum(100)
+	})
+	a.Attribute("state", d.String, func() {
+		a.Enum("provisioning", "active", "draining", "decommissioned")
+		a.Description("Lifecycle state of the cluster. If not set, the state of the existing cluster applies ('active' for a new cluster). It must be set to revive a decommissioned cluster, and it is subject to the same transitions as in a PATCH")
+	})
 	a.Attribute("service-account-token", d.String, "Decrypted cluster wide token")
 	a.Attribute("service-account-username", d.String, "Username of the cluster wide user")
 	a.Attribute("token-provider-id", d.String, "Token provider ID")
@@ -34,56 +45,211 @@ var createClusterData = a.Type("createClusterData", func() {
 		"auth-client-default-scope")
 })
 
+// updateCluster represents the changes to apply on a single cluster object
+var updateCluster = JSONSingle(
+	"UpdateCluster",
+	"Holds the data to update a cluster",
+	updateClusterData,
+	nil)
+
+// updateClusterData the same attributes as `createClusterData`, but all optional: attributes which are not set
+// are left unchanged
+var updateClusterData = a.Type("updateClusterData", func() {
+	a.Attribute("name", d.String, "Cluster name")
+	a.Attribute("api-url", d.String, "API URL")
+	a.Attribute("console-url", d.String, "Web console URL. If empty, the URL is derived from the API URL")
+	a.Attribute("metrics-url", d.String, "Metrics URL. If empty, the URL is derived from the API URL")
+	a.Attribute("logging-url", d.String, "Logging URL. If empty, the URL is derived from the API URL")
+	a.Attribute("app-dns", d.String, "User application domain name in the cluster")
+	a.Attribute("type", d.String, "Cluster type. Such as OSD, OSO, OCP, etc")
+	a.Attribute("capacity-exhausted", d.Boolean, "Cluster is full if set to 'true'")
+	a.Attribute("max-identities", d.Integer, "Maximum number of identities on the cluster. If set, 'capacity-exhausted' is managed automatically", func() {
+		a.Minimum(0)
+	})
+	a.Attribute("capacity-threshold", d.Integer, "Percentage of 'max-identities' above which the cluster is full. If not set, the service default applies", func() {
+		a.Minimum(0)
+		a.Maximum(100)
+	})
+	a.Attribute("state", d.String, func() {
+		a.Enum("provisioning", "active", "draining", "decommissioned")
+		a.Description("Lifecycle state of the cluster. Only the following transitions are allowed: 'provisioning' to 'active', 'active' to 'draining', 'draining' to 'active', 'decommissioned' to 'provisioning' or 'active', and any state to 'decommissioned'")
+	})
+	a.Attribute("service-account-token", d.String, "Decrypted cluster wide token")
+	a.Attribute("sa-token-encrypted", d.Boolean, "'True' if the cluster wide token is encrypted. By default 'False' when a new 'service-account-token' is set")
+	a.Attribute("service-account-username", d.String, "Username of the cluster wide user")
+	a.Attribute("token-provider-id", d.String, "Token provider ID. If empty, the cluster ID is used")
+	a.Attribute("auth-client-id", d.String, "OAuth client ID")
+	a.Attribute("auth-client-secret", d.String, "OAuth client secret")
+	a.Attribute("auth-client-default-scope", d.String, "OAuth client default scope")
+})
+
+// clusterHealthData represents the result of the latest health probe of a cluster
+var clusterHealthData = a.Type("ClusterHealthData", func() {
+	a.Attribute("state", d.String, func() {
+		a.Enum("healthy", "unhealthy")
+		a.Description("'healthy' if all the cluster URLs responded during the latest probe, 'unhealthy' otherwise")
+	})
+	a.Attribute("latency", d.Integer, "Time (in milliseconds) it took for the cluster API to respond during the latest probe")
+	a.Attribute("last-checked-at", d.DateTime, "Time of the latest probe")
+	a.Attribute("last-success-at", d.DateTime, "Time of the latest successful probe")
+	a.Attribute("last-error", d.String, "Error of the latest failed probe")
+	a.Attribute("last-error-at", d.DateTime, "Time of the latest failed probe")
+	a.Required("state", "latency", "last-checked-at")
+})
+
 // clusterList represents an array of cluster objects
 var clusterList = JSONList(
 	"Cluster",
 	"Holds the response to a cluster list request",
 	clusterData,
-	nil,
-	nil)
+	pagingLinks,
+	listMeta)
 
+// clusterData represents a cluster as a JSON-API resource object (see http://jsonapi.org/format/#document-resource-objects).
+// When the compatibility mode is enabled in the service configuration, the cluster attributes are also
+// set at the top level of the resource object, and the `type` member holds the cluster type (OSD, OSO, OCP, etc.)
 var clusterData = a.Type("ClusterData", func() {
+	a.Attribute("id", d.UUID, "ID of the cluster")
+	a.Attribute("type", d.String, "Type of the resource: 'clusters' (or the cluster type in compatibility mode)")
+	a.Attribute("attributes", clusterAttributes, "The cluster attributes")
+	a.Attribute("links", genericLinks, "The link to the cluster resource")
+	// legacy, top-level attributes (compatibility mode only)
+	a.Attribute("name", d.String, "Cluster name (compatibility mode only)")
+	a.Attribute("api-url", d.String, "API URL (compatibility mode only)")
+	a.Attribute("console-url", d.String, "Web console URL (compatibility mode only)")
+	a.Attribute("metrics-url", d.String, "Metrics URL (compatibility mode only)")
+	a.Attribute("logging-url", d.String, "Logging URL (compatibility mode only)")
+	a.Attribute("app-dns", d.String, "User application domain name in the cluster (compatibility mode only)")
+	a.Attribute("capacity-exhausted", d.Boolean, "Cluster is full if set to 'true' (compatibility mode only)")
+	a.Attribute("max-identities", d.Integer, "Maximum number of identities on the cluster ('0' means no limit) (compatibility mode only)")
+	a.Attribute("capacity-threshold", d.Integer, "Percentage of 'max-identities' above which the cluster is full ('0' means the service default) (compatibility mode only)")
+	a.Attribute("state", d.String, "Lifecycle state of the cluster (compatibility mode only)")
+	a.Attribute("identities-count", d.Integer, "Number of identities currently linked to the cluster (compatibility mode only)")
+	a.Attribute("health", clusterHealthData, "Result of the latest health probe of the cluster (compatibility mode only)")
+	a.Required("id", "type", "attributes", "links")
+})
+
+// clusterAttributes the attributes of a cluster resource object
+var clusterAttributes = a.Type("ClusterAttributes", func() {
 	a.Attribute("name", d.String, "Cluster name")
 	a.Attribute("api-url", d.String, "API URL")
 	a.Attribute("console-url", d.String, "Web console URL")
 	a.Attribute("metrics-url", d.String, "Metrics URL")
 	a.Attribute("logging-url", d.String, "Logging URL")
 	a.Attribute("app-dns", d.String, "User application domain name in the cluster")
-	a.Attribute("type", d.String, "Cluster type. Such as OSD, OSO, OCP, etc")
+	a.Attribute("cluster-type", d.String, "Cluster type. Such as OSD, OSO, OCP, etc")
 	a.Attribute("capacity-exhausted", d.Boolean, "Cluster is full if set to 'true'")
-	a.Required("name", "console-url", "metrics-url", "api-url", "logging-url", "app-dns", "type", "capacity-exhausted")
+	a.Attribute("max-identities", d.Integer, "Maximum number of identities on the cluster ('0' means no limit)")
+	a.Attribute("capacity-threshold", d.Integer, "Percentage of 'max-identities' above which the cluster is full ('0' means the service default)")
+	a.Attribute("state", d.String, "Lifecycle state of the cluster ('provisioning', 'active', 'draining' or 'decommissioned')")
+	a.Attribute("identities-count", d.Integer, "Number of identities currently linked to the cluster")
+	a.Attribute("health", clusterHealthData, "Result of the latest health probe of the cluster (if it was already probed)")
+	a.Required("name", "console-url", "metrics-url", "api-url", "logging-url", "app-dns", "cluster-type", "capacity-exhausted", "state")
 })
 
 var fullClusterList = JSONList(
 	"FullCluster",
 	"Holds the response to a full cluster list request",
 	fullClusterData,
-	nil,
-	nil)
+	pagingLinks,
+	listMeta)
 
+// fullClusterData represents a cluster as a JSON-API resource object, including its auth data.
+// See `clusterData` for the compatibility mode.
 var fullClusterData = a.Type("FullClusterData", func() {
+	a.Attribute("id", d.UUID, "ID of the cluster")
+	a.Attribute("type", d.String, "Type of the resource: 'clusters' (or the cluster type in compatibility mode)")
+	a.Attribute("attributes", fullClusterAttributes, "The cluster attributes")
+	a.Attribute("links", genericLinks, "The link to the cluster resource")
+	// legacy, top-level attributes (compatibility mode only)
+	a.Attribute("name", d.String, "Cluster name (compatibility mode only)")
+	a.Attribute("api-url", d.String, "API URL (compatibility mode only)")
+	a.Attribute("console-url", d.String, "Web console URL (compatibility mode only)")
+	a.Attribute("metrics-url", d.String, "Metrics URL (compatibility mode only)")
+	a.Attribute("logging-url", d.String, "Logging URL (compatibility mode only)")
+	a.Attribute("app-dns", d.String, "User application domain name in the cluster (compatibility mode only)")
+	a.Attribute("capacity-exhausted", d.Boolean, "Cluster is full if set to 'true' (compatibility mode only)")
+	a.Attribute("max-identities", d.Integer, "Maximum number of identities on the cluster ('0' means no limit) (compatibility mode only)")
+	a.Attribute("capacity-threshold", d.Integer, "Percentage of 'max-identities' above which the cluster is full ('0' means the service default) (compatibility mode only)")
+	a.Attribute("state", d.String, "Lifecycle state of the cluster (compatibility mode only)")
+	a.Attribute("identities-count", d.Integer, "Number of identities currently linked to the cluster (compatibility mode only)")
+	a.Attribute("health", clusterHealthData, "Result of the latest health probe of the cluster (compatibility mode only)")
+	a.Attribute("service-account-token", d.String, "Decrypted cluster wide token (compatibility mode only)")
+	a.Attribute("service-account-username", d.String, "Username of the cluster wide user (compatibility mode only)")
+	a.Attribute("sa-token-encrypted", d.Boolean, "encrypted Service Account Token set to 'true' (compatibility mode only)")
+	a.Attribute("service-account-token-created-at", d.DateTime, "Time at which the cluster wide token was set (compatibility mode only)")
+	a.Attribute("pending-service-account-token", d.String, "Decrypted cluster wide token which replaces the current one at the end of its rotation grace period (compatibility mode only)")
+	a.Attribute("pending-sa-token-encrypted", d.Boolean, "encrypted pending Service Account Token set to 'true' (compatibility mode only)")
+	a.Attribute("pending-service-account-token-created-at", d.DateTime, "Time at which the pending cluster wide token was set (compatibility mode only)")
+	a.Attribute("pending-service-account-token-promotion-at", d.DateTime, "Time at which the pending cluster wide token replaces the current one (compatibility mode only)")
+	a.Attribute("token-provider-id", d.String, "Token provider ID (compatibility mode only)")
+	a.Attribute("auth-client-id", d.String, "OAuth client ID (compatibility mode only)")
+	a.Attribute("auth-client-secret", d.String, "OAuth client secret (compatibility mode only)")
+	a.Attribute("auth-client-default-scope", d.String, "OAuth client default scope (compatibility mode only)")
+	a.Required("id", "type", "attributes", "links")
+})
+
+// fullClusterAttributes the attributes of a cluster resource object, including the auth data
+var fullClusterAttributes = a.Type("FullClusterAttributes", func() {
 	a.Attribute("name", d.String, "Cluster name")
 	a.Attribute("api-url", d.String, "API URL")
 	a.Attribute("console-url", d.String, "Web console URL")
 	a.Attribute("metrics-url", d.String, "Metrics URL")
 	a.Attribute("logging-url", d.String, "Logging URL")
 	a.Attribute("app-dns", d.String, "User application domain name in the cluster")
-	a.Attribute("type", d.String, "Cluster type. Such as OSD, OSO, OCP, etc")
+	a.Attribute("cluster-type", d.String, "Cluster type. Such as OSD, OSO, OCP, etc")
 	a.Attribute("capacity-exhausted", d.Boolean, "Cluster is full if set to 'true'")
+	a.Attribute("max-identities", d.Integer, "Maximum number of identities on the cluster ('0' means no limit)")
+	a.Attribute("capacity-threshold", d.Integer, "Percentage of 'max-identities' above which the cluster is full ('0' means the service default)")
+	a.Attribute("state", d.String, "Lifecycle state of the cluster ('provisioning', 'active', 'draining' or 'decommissioned')")
+	a.Attribute("identities-count", d.Integer, "Number of identities currently linked to the cluster")
+	a.Attribute("health", clusterHealthData, "Result of the latest health probe of the cluster (if it was already probed)")
 
 	a.Attribute("service-account-token", d.String, "Decrypted cluster wide token")
 	a.Attribute("service-account-username", d.String, "Username of the cluster wide user")
 	a.Attribute("sa-token-encrypted", d.Boolean, "encrypted Service Account Token set to 'true'")
+	a.Attribute("service-account-token-created-at", d.DateTime, "Time at which the cluster wide token was set")
+	a.Attribute("pending-service-account-token", d.String, "Decrypted cluster wide token which replaces the current one at the end of its rotation grace period (if a rotation is in progress)")
+	a.Attribute("pending-sa-token-encrypted", d.Boolean, "encrypted pending Service Account Token set to 'true' (if a rotation is in progress)")
+	a.Attribute("pending-service-account-token-created-at", d.DateTime, "Time at which the pending cluster wide token was set (if a rotation is in progress)")
+	a.Attribute("pending-service-account-token-promotion-at", d.DateTime, "Time at which the pending cluster wide token replaces the current one (if a rotation is in progress)")
 	a.Attribute("token-provider-id", d.String, "Token provider ID")
 	a.Attribute("auth-client-id", d.String, "OAuth client ID")
 	a.Attribute("auth-client-secret", d.String, "OAuth client secret")
 	a.Attribute("auth-client-default-scope", d.String, "OAuth client default scope")
 
-	a.Required("name", "console-url", "metrics-url", "api-url", "logging-url", "app-dns", "type", "capacity-exhausted",
+	a.Required("name", "console-url", "metrics-url", "api-url", "logging-url", "app-dns", "cluster-type", "capacity-exhausted", "state",
 		"service-account-token", "service-account-username", "token-provider-id", "auth-client-id", "auth-client-secret",
 		"auth-client-default-scope")
 })
 
+// auditEntryList represents an array of audit entries
+var auditEntryList = JSONList(
+	"AuditEntry",
+	"Holds the response to an audit log request",
+	auditEntryData,
+	nil,
+	nil)
+
+// auditEntryData represents a mutation on a cluster or on an identity/cluster relationship
+var auditEntryData = a.Type("AuditEntryData", func() {
+	a.Attribute("id", d.UUID, "ID of the audit entry")
+	a.Attribute("created-at", d.DateTime, "Time of the mutation")
+	a.Attribute("cluster-id", d.UUID, "ID of the cluster")
+	a.Attribute("identity-id", d.UUID, "ID of the identity, when the mutation was on an identity/cluster relationship")
+	a.Attribute("operation", d.String, "The mutation. Such as 'cluster.create', 'cluster.update', 'cluster.delete', 'identity_cluster.create' or 'identity_cluster.delete'")
+	a.Attribute("actor", d.String, "Name of the service account (or ID of the identity) on behalf of which the mutation was performed, or 'system'")
+	a.Attribute("request-id", d.String, "ID of the request in which the mutation was performed")
+	a.Attribute("changes", a.HashOf(d.String, auditChangeData), "Changed cluster attributes, with the secrets redacted")
+	a.Required("id", "created-at", "cluster-id", "operation", "actor")
+})
+
+// auditChangeData represents the old and new values of a cluster attribute
+var auditChangeData = a.Type("AuditChangeData", func() {
+	a.Attribute("old", d.Any, "Value before the mutation (not set when the cluster was created)")
+	a.Attribute("new", d.Any, "Value after the mutation (not set when the cluster was deleted)")
+})
+
 // singleCluster represents a single cluster object
 var showSingleCluster = JSONSingle(
 	"Cluster",
@@ -98,6 +264,112 @@ var showSingleFullCluster = JSONSingle(
 	fullClusterData,
 	nil)
 
+// showSingleIdentityMigration represents the report of an identity migration
+var showSingleIdentityMigration = JSONSingle(
+	"IdentityMigration",
+	"Holds the response to an identity migration request",
+	identityMigrationData,
+	nil)
+
+// identityMigrationData represents the report of an identity migration
+var identityMigrationData = a.Type("IdentityMigrationData", func() {
+	a.Attribute("identity-ids", a.ArrayOf(d.UUID), "The ids of the moved identities")
+	a.Attribute("source-cluster", clusterData, "The cluster from which the identities are moved, with its number of identities after the migration")
+	a.Attribute("target-cluster", clusterData, "The cluster to which the identities are moved, with its number of identities after the migration")
+	a.Attribute("dry-run", d.Boolean, "'True' if the migration was only validated")
+	a.Required("identity-ids", "source-cluster", "target-cluster", "dry-run")
+})
+
+// identityClusterList represents an array of identities linked to a cluster
+var identityClusterList = JSONList(
+	"IdentityCluster",
+	"Holds the response to a request to list the identities linked to a cluster",
+	identityClusterData,
+	pagingLinks,
+	listMeta)
+
+// identityClusterData represents an identity linked to a cluster, as a JSON-API resource object
+var identityClusterData = a.Type("IdentityClusterData", func() {
+	a.Attribute("id", d.UUID, "ID of the identity")
+	a.Attribute("type", d.String, "Type of the resource: 'identities'")
+	a.Attribute("attributes", identityClusterAttributes, "The attributes of the identity/cluster relationship")
+	a.Required("id", "type", "attributes")
+})
+
+// identityClusterAttributes the attributes of an identity/cluster relationship
+var identityClusterAttributes = a.Type("IdentityClusterAttributes", func() {
+	a.Attribute("cluster-id", d.UUID, "ID of the cluster")
+	a.Attribute("created-at", d.DateTime, "Time at which the identity was linked to the cluster")
+	a.Required("cluster-id", "created-at")
+})
+
+// identityClusterLinkList represents an array of links between identities and clusters
+var identityClusterLinkList = JSONList(
+	"IdentityClusterLink",
+	"Holds the response to a request to list the clusters of identities",
+	identityClusterLinkData,
+	nil,
+	nil)
+
+// identityClusterLinkData represents the link between an identity and a cluster
+var identityClusterLinkData = a.Type("IdentityClusterLinkData", func() {
+	a.Attribute("identity-id", d.UUID, "ID of the identity")
+	a.Attribute("created-at", d.DateTime, "Time at which the identity was linked to the cluster")
+	a.Attribute("cluster", clusterData, "The cluster to which the identity is linked")
+	a.Required("identity-id", "created-at", "cluster")
+})
+
+// showSingleIdentityClusterBulkReport represents the report of a bulk operation on identity/cluster links
+var showSingleIdentityClusterBulkReport = JSONSingle(
+	"IdentityClusterBulkReport",
+	"Holds the response to a bulk operation on identity/cluster links",
+	identityClusterBulkReportData,
+	nil)
+
+// identityClusterBulkReportData represents the report of a bulk operation on identity/cluster links
+var identityClusterBulkReportData = a.Type("IdentityClusterBulkReportData", func() {
+	a.Attribute("transactional", d.Boolean, "'True' if all the links were processed in a single transaction")
+	a.Attribute("items", a.ArrayOf(identityClusterBulkItemData), "The outcome of each link, in the same order as in the request")
+	a.Required("transactional", "items")
+})
+
+// identityClusterBulkItemData represents the outcome of a link in a bulk operation on identity/cluster links
+var identityClusterBulkItemData = a.Type("IdentityClusterBulkItemData", func() {
+	a.Attribute("identity-id", d.String, "The id of corresponding Identity")
+	a.Attribute("cluster-url", d.String, "Cluster URL")
+	a.Attribute("status", d.String, func() {
+		a.Enum("created", "already-exists", "deleted", "failed", "rolled-back", "skipped")
+		a.Description("The outcome of the link. 'rolled-back' means that the link was processed but that the transaction was rolled back afterwards, and 'skipped' that the link was not processed because of a previous failure in the transaction")
+	})
+	a.Attribute("error", JSONAPIError, "The error which occurred, when the status is 'failed'")
+	a.Required("identity-id", "cluster-url", "status")
+})
+
+// clusterWatchEvent represents an event of the watch of the clusters, streamed as newline-delimited JSON
+var clusterWatchEvent = a.MediaType("application/vnd.clusterwatchevent+json", func() {
+	a.TypeName("ClusterWatchEvent")
+	a.Description("An event of the watch of the clusters")
+	a.Attributes(func() {
+		a.Attribute("type", d.String, func() {
+			a.Enum("ADDED", "MODIFIED", "DELETED", "BOOKMARK", "ERROR")
+			a.Description("The type of event. 'DELETED' means that the cluster was decommissioned or deleted, 'BOOKMARK' that the watch caught up with the changes (this event only carries the resource version), and 'ERROR' that the watch failed and ended")
+		})
+		a.Attribute("resource-version", d.String, "The opaque version from which the watch can be resumed after this event")
+		a.Attribute("cluster-id", d.UUID, "The ID of the cluster")
+		a.Attribute("object", clusterData, "The state of the cluster right after the change (with the current number of identities and health), unless it was deleted")
+		a.Attribute("error", JSONAPIError, "The error which occurred, for the 'ERROR' events")
+		a.Required("type")
+	})
+	a.View("default", func() {
+		a.Attribute("type")
+		a.Attribute("resource-version")
+		a.Attribute("cluster-id")
+		a.Attribute("object")
+		a.Attribute("error")
+		a.Required("type")
+	})
+})
+
 var _ = a.Resource("clusters", func() {
 	a.BasePath("/clusters")
 
@@ -112,14 +384,39 @@ var _ = a.Resource("clusters", func() {
 				a.Description("the type of the clusters to return")
 			})
 			a.Param("cluster-url", d.String, "the URL of the cluster to show")
+			a.Param("healthy", d.Boolean, "if 'true', only the clusters whose latest health probe succeeded are returned. If 'false', only the other clusters are returned")
+			a.Param("state", d.String, func() {
+				a.Enum("provisioning", "active", "draining", "decommissioned")
+				a.Description("the lifecycle state of the clusters to return. If none is specified, only the active clusters are returned")
+			})
+			clusterListParams()
 		})
-		a.Description("Get all cluster configurations. If the 'cluster-url' query parameter is set, then a single cluster is returned. If the 'type' query parameter is set then only the clusters with the matchin type are returned")
+		a.Description("Get all active cluster configurations. If the 'cluster-url' query parameter is set, then a single cluster is returned (whatever its state). If the 'type' query parameter is set then only the clusters with the matchin type are returned. If the 'healthy' query parameter is set then the clusters are filtered by health state. If the 'state' query parameter is set then the clusters in this lifecycle state are returned instead of the active ones. The other query parameters filter, sort and paginate the clusters")
 		a.Response(d.OK, clusterList)
 		a.Response(d.BadRequest, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
 
+	a.Action("watch", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/watch"),
+		)
+		a.Params(func() {
+			a.Param("resource-version", d.String, "the version from which to resume watching, as received in the latest event. If none is specified, the clusters which are not decommissioned are first returned as 'ADDED' events")
+			a.Param("timeout", d.Integer, "the number of seconds after which the watch ends. It cannot exceed the maximum duration set in the service configuration", func() {
+				a.Minimum(1)
+			})
+		})
+		a.Description("Watch the clusters: their changes, on any replica of the service, are streamed as newline-delimited JSON events ('application/x-ndjson'), in the order in which they were committed. A client can resume watching from the version of the latest event it received. Responds with a '410 Gone' status if the changes after this version are no longer available, in which case the client must start watching again without version")
+		a.Response(d.OK, clusterWatchEvent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Gone, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
 	a.Action("listForAuthClient", func() {
 		a.Security("jwt")
 		a.Routing(
@@ -131,8 +428,13 @@ var _ = a.Resource("clusters", func() {
 				a.Description("the type of the clusters to return ('OSD', 'OCP' or 'OSO'). If none is specified, all types of clusters will be returned")
 			})
 			a.Param("cluster-url", d.String, "the URL of the cluster to show")
+			a.Param("state", d.String, func() {
+				a.Enum("provisioning", "active", "draining", "decommissioned")
+				a.Description("the lifecycle state of the clusters to return. If none is specified, only the active clusters are returned")
+			})
+			clusterListParams()
 		})
-		a.Description("Get all cluster configurations. If the 'cluster-url' query parameter is set, then a single cluster is returned. If the 'type' query parameter is set then only the clusters with the matchin type are returned")
+		a.Description("Get all cluster configurations. If the 'cluster-url' query parameter is set, then a single cluster is returned. If the 'type' query parameter is set then only the clusters with the matchin type are returned. If the 'state' query parameter is set then the clusters in this lifecycle state are returned instead of the active ones. The other query parameters filter, sort and paginate the clusters")
 		a.Description("Get all cluster configurations unless the 'cluster-url' is specified. This endpoint returns all sensitive information")
 		a.Response(d.OK, fullClusterList)
 		a.Response(d.BadRequest, JSONAPIErrors)
@@ -149,8 +451,10 @@ var _ = a.Resource("clusters", func() {
 			a.Param("clusterID", d.UUID, "the ID of the cluster to show")
 			a.Required("clusterID")
 		})
-		a.Description("Get single cluster configuration")
+		a.UseTrait("conditional")
+		a.Description("Get single cluster configuration. The response includes an 'ETag' header which identifies the version of the cluster configuration")
 		a.Response(d.OK, showSingleCluster)
+		a.Response(d.NotModified)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.NotFound, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
@@ -165,8 +469,10 @@ var _ = a.Resource("clusters", func() {
 			a.Param("clusterID", d.UUID, "the ID of the cluster to show")
 			a.Required("clusterID")
 		})
-		a.Description("Get single cluster configuration (including Auth information)")
+		a.UseTrait("conditional")
+		a.Description("Get single cluster configuration (including Auth information). The response includes an 'ETag' header which identifies the version of the cluster configuration")
 		a.Response(d.OK, showSingleFullCluster)
+		a.Response(d.NotModified)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.NotFound, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
@@ -178,10 +484,38 @@ var _ = a.Resource("clusters", func() {
 			a.POST("/"),
 		)
 		a.Payload(createCluster)
-		a.Description("Add a cluster configuration")
+		a.Headers(func() {
+			a.Header("If-Match", d.String, "ETag of the cluster version on which the change is based. The change is rejected with a '412 Precondition Failed' status if the cluster was modified since then")
+		})
+		a.Description("Add a cluster configuration, or replace the configuration of the cluster with the same API URL")
 		a.Response(d.Created)
 		a.Response(d.BadRequest, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.PreconditionFailed, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("update", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/:clusterID"),
+		)
+		a.Params(func() {
+			a.Param("clusterID", d.UUID, "the ID of the cluster to update")
+			a.Required("clusterID")
+		})
+		a.Payload(updateCluster)
+		a.Headers(func() {
+			a.Header("If-Match", d.String, "ETag of the cluster version on which the change is based. The change is rejected with a '412 Precondition Failed' status if the cluster was modified since then")
+		})
+		a.Description("Partially update a cluster configuration, using the JSON merge-patch semantics: attributes which are not set in the payload are left unchanged")
+		a.Response(d.OK, showSingleCluster)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.PreconditionFailed, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
 
@@ -194,10 +528,118 @@ var _ = a.Resource("clusters", func() {
 			a.Param("clusterID", d.UUID, "the ID of the cluster to delete")
 			a.Required("clusterID")
 		})
-		a.Description("Delete a cluster configuration")
+		a.Headers(func() {
+			a.Header("If-Match", d.String, "ETag of the cluster version on which the change is based. The change is rejected with a '412 Precondition Failed' status if the cluster was modified since then")
+		})
+		a.Description("Decommission a cluster. The cluster configuration and the identities linked to it are kept")
 		a.Response(d.NoContent)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.PreconditionFailed, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("rotateServiceAccountToken", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:clusterID/service-account-token/rotations"),
+		)
+		a.Params(func() {
+			a.Param("clusterID", d.UUID, "the ID of the cluster whose service account token to rotate")
+			a.Required("clusterID")
+		})
+		a.Payload(serviceAccountTokenRotationPayload)
+		a.Description("Start the rotation of the service account token of a cluster: the new token is returned along with the current one until the end of the grace period, then it replaces the current one")
+		a.Response(d.OK, showSingleCluster)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listClusterAudit", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:clusterID/audit"),
+		)
+		a.Params(func() {
+			a.Param("clusterID", d.UUID, "the ID of the cluster whose audit log to show")
+			a.Required("clusterID")
+		})
+		a.Description("List the audit entries of a cluster, from the oldest to the newest. Entries are kept after the cluster was deleted.")
+		a.Response(d.OK, auditEntryList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listAudit", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/audit"),
+		)
+		a.Params(func() {
+			a.Param("since", d.DateTime, "the time from which to list the audit entries")
+			a.Required("since")
+		})
+		a.Description("List the audit entries of all clusters since the given time, from the oldest to the newest")
+		a.Response(d.OK, auditEntryList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listIdentities", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:clusterID/identities"),
+		)
+		a.Params(func() {
+			a.Param("clusterID", d.UUID, "the ID of the cluster whose identities to list")
+			a.Param("page[offset]", d.Integer, "the number of identities to skip", func() {
+				a.Minimum(0)
+			})
+			a.Param("page[limit]", d.Integer, "the maximum number of identities to return. If none is specified, all the remaining identities are returned", func() {
+				a.Minimum(1)
+			})
+			a.Required("clusterID")
+		})
+		a.Headers(func() {
+			a.Header("Accept", d.String, "If it contains 'application/x-ndjson', the identities are streamed as newline-delimited JSON resource objects, without pagination")
+		})
+		a.Description("List the identities linked to a cluster, ordered by identity ID")
+		a.Response(d.OK, identityClusterList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listClustersForIdentity", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/identities/:identityID"),
+		)
+		a.Params(func() {
+			a.Param("identityID", d.UUID, "the ID of the identity whose clusters to list")
+			a.Required("identityID")
+		})
+		a.Description("List the clusters to which an identity is linked, along with the link creation time")
+		a.Response(d.OK, identityClusterLinkList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listClustersForIdentities", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/identities/clusters"),
+		)
+		a.Payload(identityClustersLookupPayload)
+		a.Description("List the clusters to which each of the given identities is linked, along with the link creation time. Identities which are not linked to any cluster are ignored")
+		a.Response(d.OK, identityClusterLinkList)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
 
@@ -228,8 +670,81 @@ var _ = a.Resource("clusters", func() {
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
 	})
+
+	a.Action("linkIdentitiesToClusters", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/identities/bulk"),
+		)
+		a.Payload(bulkLinkIdentitiesToClustersData)
+		a.Description("Create identity/cluster relationships in bulk using a service account. The response reports the outcome of each relationship")
+		a.Response(d.OK, showSingleIdentityClusterBulkReport)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("removeIdentitiesToClustersLinks", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/identities/bulk"),
+		)
+		a.Payload(bulkUnLinkIdentitiesToClustersData)
+		a.Description("Remove identity/cluster relationships in bulk using a service account. The response reports the outcome of each relationship")
+		a.Response(d.OK, showSingleIdentityClusterBulkReport)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("migrateIdentities", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/identities/migrations"),
+		)
+		a.Payload(identityMigrationPayload)
+		a.Description("Move identities from a source cluster to a target cluster in a single transaction, or only validate the migration if 'dry-run' is set")
+		a.Response(d.OK, showSingleIdentityMigration)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("placeIdentity", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/placements"),
+		)
+		a.Payload(placementData)
+		a.Description("Select the cluster on which the given identity should be provisioned. If the identity is already linked to an active cluster, this cluster is returned. Otherwise, clusters which are not active or whose capacity is exhausted are never selected.")
+		a.Response(d.OK, showSingleCluster)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
 })
 
+// clusterListParams declares the query parameters to filter, sort and paginate the clusters to list
+func clusterListParams() {
+	a.Param("name", d.String, "the name of the clusters to return")
+	a.Param("app-dns", d.String, "the application domain name of the clusters to return")
+	a.Param("capacity-exhausted", d.Boolean, "if 'true', only the clusters whose capacity is exhausted are returned. If 'false', only the other clusters are returned")
+	a.Param("created-after", d.DateTime, "the time after which the clusters to return were created (inclusive)")
+	a.Param("created-before", d.DateTime, "the time before which the clusters to return were created (exclusive)")
+	a.Param("sort", d.String, func() {
+		a.Enum("name", "-name", "created-at", "-created-at")
+		a.Description("the order of the clusters to return. A leading '-' means a descending order. By default, the clusters are sorted by creation time")
+	})
+	a.Param("page[offset]", d.Integer, "the number of clusters to skip", func() {
+		a.Minimum(0)
+	})
+	a.Param("page[limit]", d.Integer, "the maximum number of clusters to return. If none is specified, all the remaining clusters are returned", func() {
+		a.Minimum(1)
+	})
+}
+
 // linkIdentityToClusterData represents the data of an identified IdentityCluster object to create
 var linkIdentityToClusterData = a.Type("linkIdentityToClusterData", func() {
 	a.Attribute("identity-id", d.String, "The id of corresponding Identity")
@@ -246,3 +761,73 @@ var unLinkIdentityToClusterdata = a.Type("unLinkIdentityToClusterdata", func() {
 
 	a.Required("cluster-url", "identity-id")
 })
+
+// bulkLinkIdentitiesToClustersData represents the data of many IdentityCluster objects to create
+var bulkLinkIdentitiesToClustersData = a.Type("bulkLinkIdentitiesToClustersData", func() {
+	a.Attribute("links", a.ArrayOf(linkIdentityToClusterData), "The identity/cluster relationships to create", func() {
+		a.MinLength(1)
+		a.MaxLength(1000)
+	})
+	a.Attribute("transactional", d.Boolean, "Create all the relationships in a single transaction, which is rolled back at the first failure. Otherwise, a failure does not prevent the other relationships from being created. By default 'False'")
+
+	a.Required("links")
+})
+
+// bulkUnLinkIdentitiesToClustersData represents the data of many IdentityCluster objects to remove
+var bulkUnLinkIdentitiesToClustersData = a.Type("bulkUnLinkIdentitiesToClustersData", func() {
+	a.Attribute("links", a.ArrayOf(unLinkIdentityToClusterdata), "The identity/cluster relationships to remove", func() {
+		a.MinLength(1)
+		a.MaxLength(1000)
+	})
+	a.Attribute("transactional", d.Boolean, "Remove all the relationships in a single transaction, which is rolled back at the first failure. Otherwise, a failure does not prevent the other relationships from being removed. By default 'False'")
+
+	a.Required("links")
+})
+
+// identityMigrationPayload represents a request to move identities from a cluster to another
+var identityMigrationPayload = a.Type("identityMigrationPayload", func() {
+	a.Attribute("identity-ids", a.ArrayOf(d.String), "The ids of the identities to move", func() {
+		a.MinLength(1)
+	})
+	a.Attribute("source-cluster-url", d.String, "URL of the cluster to which the identities are currently linked")
+	a.Attribute("target-cluster-url", d.String, "URL of the cluster to which the identities are moved")
+	a.Attribute("dry-run", d.Boolean, "Only validate the migration and report what would be done. By default 'False'")
+
+	a.Required("identity-ids", "source-cluster-url", "target-cluster-url")
+})
+
+// identityClustersLookupPayload represents a request to list the clusters of many identities at once
+var identityClustersLookupPayload = a.Type("identityClustersLookupPayload", func() {
+	a.Attribute("identity-ids", a.ArrayOf(d.String), "The ids of the identities whose clusters to list", func() {
+		a.MinLength(1)
+		a.MaxLength(1000)
+	})
+
+	a.Required("identity-ids")
+})
+
+// serviceAccountTokenRotationPayload represents a request to rotate the service account token of a cluster
+var serviceAccountTokenRotationPayload = a.Type("serviceAccountTokenRotationPayload", func() {
+	a.Attribute("service-account-token", d.String, "The new cluster wide token")
+	a.Attribute("sa-token-encrypted", d.Boolean, "'True' if the new cluster wide token is encrypted. By default 'False'")
+	a.Attribute("grace-period", d.Integer, "Time (in seconds) during which the current token remains valid. If not set, the service default applies. '0' replaces the current token immediately", func() {
+		a.Minimum(0)
+	})
+
+	a.Required("service-account-token")
+})
+
+// placementData represents the data of a request to select the cluster for an identity
+var placementData = a.Type("placementData", func() {
+	a.Attribute("identity-id", d.String, "The id of corresponding Identity")
+	a.Attribute("type", d.String, func() {
+		a.Enum("OCP", "OSD", "OSO")
+		a.Description("the type of the cluster to select. If none is specified, all types of clusters are considered")
+	})
+	a.Attribute("strategy", d.String, func() {
+		a.Enum("least-loaded", "weighted-round-robin")
+		a.Description("the placement strategy to use. If none is specified, the strategy configured in the service is used. Note that each replica of the service cycles through the clusters on its own when the 'weighted-round-robin' strategy is used")
+	})
+
+	a.Required("identity-id")
+})
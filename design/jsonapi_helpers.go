@@ -95,6 +95,18 @@ var genericLinks = a.Type("GenericLinks", func() {
 	a.Attribute("meta", a.HashOf(d.String, d.Any))
 })
 
+// pagingLinks defines the links to the previous and next pages of a paginated list
+var pagingLinks = a.Type("PagingLinks", func() {
+	a.Attribute("prev", d.String, "Link to the previous page (if any)")
+	a.Attribute("next", d.String, "Link to the next page (if any)")
+})
+
+// listMeta defines the meta-information of a paginated list
+var listMeta = a.Type("ListMeta", func() {
+	a.Attribute("totalCount", d.Integer, "Total number of items matching the request, regardless of the pagination")
+	a.Required("totalCount")
+})
+
 // JSONResourceObject creates a single resource object
 func JSONResourceObject(name string, attributes *d.UserTypeDefinition, relationships *d.UserTypeDefinition) *d.UserTypeDefinition {
 	return a.Type(name, func() {
@@ -15,6 +15,8 @@ var Status = a.MediaType("application/vnd.status+json", func() {
 		a.Attribute("devMode", d.Boolean, "'True' if the Developer Mode is enabled")
 		a.Attribute("databaseStatus", d.String, "The status of Database connection. 'OK' or an error message is displayed.")
 		a.Attribute("configurationStatus", d.String, "The status of the used configuration. 'OK' or an error message if there is something wrong with the configuration used by service.")
+		a.Attribute("clusterConfigSync", clusterConfigSyncStatus, "The result of the last synchronization of the clusters with the config file")
+		a.Attribute("leadership", leadershipStatus, "The leadership state of the current running instance among the replicas of the service")
 		a.Required("commit", "buildTime", "startTime", "databaseStatus", "configurationStatus")
 	})
 	a.View("default", func() {
@@ -24,9 +26,35 @@ var Status = a.MediaType("application/vnd.status+json", func() {
 		a.Attribute("devMode")
 		a.Attribute("databaseStatus")
 		a.Attribute("configurationStatus")
+		a.Attribute("clusterConfigSync")
+		a.Attribute("leadership")
 	})
 })
 
+// clusterConfigSyncStatus the result of the last synchronization of the clusters with the config file
+var clusterConfigSyncStatus = a.Type("ClusterConfigSyncStatus", func() {
+	a.Attribute("policy", d.String, "The sync policy in use", func() {
+		a.Enum("authoritative", "merge-only", "disabled")
+	})
+	a.Attribute("time", d.DateTime, "The time of the synchronization")
+	a.Attribute("applied", d.Boolean, "'True' if the plan was applied, 'False' if the synchronization is disabled or if it failed (in which case none of the changes were applied)")
+	a.Attribute("error", d.String, "The error which caused the plan to be rolled back, if any")
+	a.Attribute("creates", a.ArrayOf(d.String), "The URLs of the clusters created from the config file")
+	a.Attribute("updates", a.ArrayOf(d.String), "The URLs of the clusters updated from the config file")
+	a.Attribute("decommissions", a.ArrayOf(d.String), "The URLs of the clusters decommissioned because they were removed from the config file")
+	a.Required("policy", "time", "applied", "creates", "updates", "decommissions")
+})
+
+// leadershipStatus the leadership state of the current running instance among the replicas of the service
+var leadershipStatus = a.Type("LeadershipStatus", func() {
+	a.Attribute("enabled", d.Boolean, "'True' if the leader election is enabled, 'False' otherwise (in which case the instance acts as the leader)")
+	a.Attribute("leader", d.Boolean, "'True' if the instance is the leader, ie, if it reconciles the clusters with the config file and runs the background jobs")
+	a.Attribute("identity", d.String, "The identity of the instance in the leader election")
+	a.Attribute("holder", d.String, "The identity of the instance which holds the leader lease, if known")
+	a.Attribute("expiresAt", d.DateTime, "The time at which the leader lease expires, if known")
+	a.Required("enabled", "leader")
+})
+
 var _ = a.Resource("status", func() {
 
 	a.DefaultMedia(Status)
@@ -0,0 +1,194 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// webhookEventTypes the types of the cluster change events which can be delivered to the webhook subscriptions
+var webhookEventTypes = []interface{}{"cluster.create", "cluster.update", "cluster.delete", "identity_cluster.create", "identity_cluster.delete"}
+
+// createSubscription represents a new webhook subscription
+var createSubscription = JSONSingle(
+	"CreateSubscription",
+	"Holds the data to create a webhook subscription",
+	createSubscriptionData,
+	nil)
+
+var createSubscriptionData = a.Type("createSubscriptionData", func() {
+	a.Attribute("url", d.String, "URL to which the events are delivered with a 'POST' request. Must be an absolute 'http' or 'https' URL")
+	a.Attribute("event-types", a.ArrayOf(d.String, func() {
+		a.Enum(webhookEventTypes...)
+	}), "Types of the events to deliver. If empty, all the events are delivered")
+	a.Attribute("secret", d.String, "Secret with which the events are signed (at least 16 characters). If not set, a random secret is generated", func() {
+		a.MinLength(16)
+	})
+	a.Required("url")
+})
+
+// showSingleSubscription represents a single webhook subscription
+var showSingleSubscription = JSONSingle(
+	"Subscription",
+	"Holds the response to a webhook subscription request",
+	subscriptionData,
+	nil)
+
+// subscriptionList represents an array of webhook subscriptions
+var subscriptionList = JSONList(
+	"Subscription",
+	"Holds the response to a webhook subscription list request",
+	subscriptionData,
+	nil,
+	nil)
+
+// subscriptionData represents a webhook subscription as a JSON-API resource object
+var subscriptionData = a.Type("SubscriptionData", func() {
+	a.Attribute("id", d.UUID, "ID of the subscription")
+	a.Attribute("type", d.String, "Type of the resource: 'subscriptions'")
+	a.Attribute("attributes", subscriptionAttributes, "The subscription attributes")
+	a.Attribute("links", genericLinks, "The link to the subscription resource")
+	a.Required("id", "type", "attributes", "links")
+})
+
+// subscriptionAttributes the attributes of a webhook subscription resource object
+var subscriptionAttributes = a.Type("SubscriptionAttributes", func() {
+	a.Attribute("created-at", d.DateTime, "Time at which the subscription was created")
+	a.Attribute("owner", d.String, "Name of the service account which created the subscription")
+	a.Attribute("url", d.String, "URL to which the events are delivered")
+	a.Attribute("event-types", a.ArrayOf(d.String), "Types of the delivered events. If empty, all the events are delivered")
+	a.Attribute("secret", d.String, "Secret with which the events are signed (only returned when the subscription is created)")
+	a.Required("created-at", "owner", "url")
+})
+
+// showSingleDelivery represents a single webhook delivery
+var showSingleDelivery = JSONSingle(
+	"Delivery",
+	"Holds the response to a webhook delivery request",
+	deliveryData,
+	nil)
+
+// deliveryList represents an array of webhook deliveries
+var deliveryList = JSONList(
+	"Delivery",
+	"Holds the response to a webhook delivery list request",
+	deliveryData,
+	nil,
+	nil)
+
+// deliveryData represents the delivery of an event to a webhook subscription
+var deliveryData = a.Type("DeliveryData", func() {
+	a.Attribute("id", d.UUID, "ID of the delivery. Sent in the 'X-Fabric8-Cluster-Delivery' header")
+	a.Attribute("event-id", d.Integer, "ID of the delivered event")
+	a.Attribute("created-at", d.DateTime, "Time at which the event was dispatched to the subscription")
+	a.Attribute("state", d.String, func() {
+		a.Enum("pending", "delivered", "dead")
+		a.Description("State of the delivery. Dead deliveries failed too many times and are not attempted again unless they are redelivered")
+	})
+	a.Attribute("attempts", d.Integer, "Number of delivery attempts")
+	a.Attribute("next-attempt-at", d.DateTime, "Time of the next attempt, for the pending deliveries")
+	a.Attribute("last-error", d.String, "Error of the latest failed attempt")
+	a.Attribute("delivered-at", d.DateTime, "Time at which the event was delivered")
+	a.Required("id", "event-id", "created-at", "state", "attempts")
+})
+
+var _ = a.Resource("subscriptions", func() {
+	a.BasePath("/subscriptions")
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/"),
+		)
+		a.Payload(createSubscription)
+		a.Description(`Subscribe to the cluster change events. Each event is delivered in a 'POST' request whose JSON body
+		is signed with the secret of the subscription: the 'X-Fabric8-Cluster-Signature' header holds 'sha256=' followed
+		by the hex-encoded HMAC-SHA256 of the value of the 'X-Fabric8-Cluster-Timestamp' header, a '.' and the body.
+		Failed deliveries are attempted again with an exponential backoff, until they are moved to the dead letters of
+		the subscription. The response includes the secret of the subscription, which is not returned afterwards`)
+		a.Response(d.Created, showSingleSubscription)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/"),
+		)
+		a.Description("Get the webhook subscriptions of the service account of the request")
+		a.Response(d.OK, subscriptionList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:subscriptionID"),
+		)
+		a.Params(func() {
+			a.Param("subscriptionID", d.UUID, "the ID of the subscription to show")
+			a.Required("subscriptionID")
+		})
+		a.Description("Get a single webhook subscription")
+		a.Response(d.OK, showSingleSubscription)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:subscriptionID"),
+		)
+		a.Params(func() {
+			a.Param("subscriptionID", d.UUID, "the ID of the subscription to delete")
+			a.Required("subscriptionID")
+		})
+		a.Description("Delete a webhook subscription, along with its pending and dead deliveries")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listDeliveries", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:subscriptionID/deliveries"),
+		)
+		a.Params(func() {
+			a.Param("subscriptionID", d.UUID, "the ID of the subscription")
+			a.Param("state", d.String, func() {
+				a.Enum("pending", "delivered", "dead")
+				a.Description("the state of the deliveries to return ('dead' for the dead letters). If none is specified, all the deliveries are returned")
+			})
+			a.Required("subscriptionID")
+		})
+		a.Description("Get the deliveries of a webhook subscription, in the order of their events")
+		a.Response(d.OK, deliveryList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("redeliver", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:subscriptionID/deliveries/:deliveryID/redeliver"),
+		)
+		a.Params(func() {
+			a.Param("subscriptionID", d.UUID, "the ID of the subscription")
+			a.Param("deliveryID", d.UUID, "the ID of the dead delivery to attempt again")
+			a.Required("subscriptionID", "deliveryID")
+		})
+		a.Description("Move a dead delivery back to the pending ones, so that it is attempted again during the next dispatch")
+		a.Response(d.OK, showSingleDelivery)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
@@ -13,7 +13,7 @@ var _ = a.Resource("user", func() {
 		a.Routing(
 			a.GET("/clusters"),
 		)
-		a.Description("Get clusters available to user")
+		a.Description("Get clusters available to user. DEPRECATED: service accounts should use 'GET /clusters/identities/:identityID' instead")
 		a.Response(d.OK, clusterList)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
@@ -10,6 +10,7 @@ import (
 	"github.com/fabric8-services/fabric8-cluster/application/transaction"
 	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
 	"github.com/fabric8-services/fabric8-cluster/configuration"
+	webhookrepository "github.com/fabric8-services/fabric8-cluster/webhook/repository"
 
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
@@ -80,10 +81,44 @@ func (g *GormBase) IdentityClusters() repository.IdentityClusterRepository {
 	return repository.NewIdentityClusterRepository(g.db)
 }
 
+// ClusterHealth creates new ClusterHealth repository
+func (g *GormBase) ClusterHealth() repository.ClusterHealthRepository {
+	return repository.NewClusterHealthRepository(g.db)
+}
+
+// Audit creates new Audit repository
+func (g *GormBase) Audit() repository.AuditRepository {
+	return repository.NewAuditRepository(g.db)
+}
+
+// LeaderLeases creates new LeaderLeases repository
+func (g *GormBase) LeaderLeases() repository.LeaderLeaseRepository {
+	return repository.NewLeaderLeaseRepository(g.db)
+}
+
+// Outbox creates new Outbox repository
+func (g *GormBase) Outbox() repository.OutboxRepository {
+	return repository.NewOutboxRepository(g.db)
+}
+
+// Subscriptions creates new Subscriptions repository
+func (g *GormBase) Subscriptions() webhookrepository.SubscriptionRepository {
+	return webhookrepository.NewSubscriptionRepository(g.db)
+}
+
+// Deliveries creates new Deliveries repository
+func (g *GormBase) Deliveries() webhookrepository.DeliveryRepository {
+	return webhookrepository.NewDeliveryRepository(g.db)
+}
+
 func (g *GormDB) ClusterService() service.ClusterService {
 	return g.serviceFactory.ClusterService()
 }
 
+func (g *GormDB) WebhookService() service.WebhookService {
+	return g.serviceFactory.WebhookService()
+}
+
 func (g *GormBase) DB() *gorm.DB {
 	return g.db
 }
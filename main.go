@@ -2,21 +2,27 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"os/user"
 	"runtime"
+	"sort"
+	"syscall"
 	"time"
 
 	"context"
 
 	"github.com/fabric8-services/fabric8-cluster/app"
 	"github.com/fabric8-services/fabric8-cluster/application/transaction"
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
 	"github.com/fabric8-services/fabric8-cluster/configuration"
 	"github.com/fabric8-services/fabric8-cluster/controller"
 	"github.com/fabric8-services/fabric8-cluster/gormapplication"
 	"github.com/fabric8-services/fabric8-cluster/migration"
 	"github.com/fabric8-services/fabric8-common/auth"
+	"github.com/fabric8-services/fabric8-common/errors"
 	"github.com/fabric8-services/fabric8-common/goamiddleware"
 	"github.com/fabric8-services/fabric8-common/log"
 	"github.com/goadesign/goa"
@@ -26,6 +32,7 @@ import (
 	"github.com/goadesign/goa/middleware/security/jwt"
 	"github.com/jinzhu/gorm"
 	_ "github.com/lib/pq"
+	errs "github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -38,11 +45,15 @@ func main() {
 	var clusterConfigFile string
 	var printConfig bool
 	var migrateDB bool
+	var reencryptSecrets bool
+	var validateClusterConfig string
 	flag.StringVar(&configFile, "config", "", "Path to the config file to read")
 	flag.StringVar(&serviceAccountConfigFile, "serviceAccountConfig", "", "Path to the service account configuration file")
-	flag.StringVar(&clusterConfigFile, "osoClusterConfigFile", "", "Path to the OSO cluster configuration file")
+	flag.StringVar(&clusterConfigFile, "osoClusterConfigFile", "", "Path to the OSO cluster configuration file, or to a directory of cluster configuration files")
 	flag.BoolVar(&printConfig, "printConfig", false, "Prints the config (including merged environment variables) and exits")
 	flag.BoolVar(&migrateDB, "migrateDatabase", false, "Migrates the database to the newest version and exits.")
+	flag.BoolVar(&reencryptSecrets, "reencryptClusterSecrets", false, "Re-encrypts the secrets of the clusters and of the webhook subscriptions with the current encryption key and exits.")
+	flag.StringVar(&validateClusterConfig, "validateClusterConfig", "", "Validates the given cluster configuration file, prints the changes it would apply on the clusters and exits.")
 	flag.Parse()
 
 	// Override default -config switch with environment variable only if -config switch was
@@ -139,20 +150,106 @@ func main() {
 	// Create DB
 	appDB := gormapplication.NewGormDB(db, config)
 
-	// Create cluster from config for the first time
-	if err := appDB.ClusterService().CreateOrSaveClusterFromConfig(context.Background()); err != nil {
-		log.Panic(context.TODO(), map[string]interface{}{
-			"err": err,
-		}, "failed to create or save cluster")
+	// Re-encrypt the cluster and webhook subscription secrets (eg: after a key rotation) while the other instances keep on serving requests
+	if reencryptSecrets {
+		count, err := appDB.ClusterService().ReencryptSecrets(context.Background())
+		if err != nil {
+			log.Panic(context.TODO(), map[string]interface{}{
+				"err": err,
+			}, "failed to re-encrypt the cluster and webhook subscription secrets")
+		}
+		log.Logger().Infof("Re-encrypted the secrets of %d cluster(s) and webhook subscription(s)", count)
+		os.Exit(0)
+	}
+
+	// Validate a new cluster config file and print the changes which would be applied, without applying them
+	if validateClusterConfig != "" {
+		plan, err := appDB.ClusterService().ValidateClusterConfig(context.Background(), validateClusterConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		printClusterConfigSyncPlan(*plan)
+		os.Exit(0)
+	}
+
+	// Initialize the leader election among the replicas of the service. When this replica takes over, it reconciles the
+	// clusters with the config file (with its own service instance, since the election runs concurrently)
+	haltElection := appDB.ClusterService().InitializeLeaderElection(func() {
+		result, err := appDB.ClusterService().ReloadClusterConfig(context.Background(), repository.ClusterConfigReloadTriggerElection)
+		if err != nil {
+			// Do not crash. Log the error, the clusters will be reconciled at the next change of the config file
+			log.Error(context.Background(), map[string]interface{}{
+				"err": err,
+			}, "unable to reconcile the clusters with the config file after being elected as the leader")
+			return
+		}
+		log.Info(context.Background(), map[string]interface{}{
+			"applied":       result.Applied,
+			"creates":       len(result.Plan.Creates),
+			"updates":       len(result.Plan.Updates),
+			"decommissions": len(result.Plan.Decommissions),
+		}, "clusters reconciled with the config file after being elected as the leader")
+	})
+	defer haltElection()
+	// Create cluster from config for the first time (the other replicas rely on the leader to do so)
+	if appDB.ClusterService().IsLeader() {
+		if _, err := appDB.ClusterService().CreateOrSaveClusterFromConfig(context.Background()); err != nil {
+			log.Panic(context.TODO(), map[string]interface{}{
+				"err": err,
+			}, "failed to create or save cluster")
+		}
 	}
 	// Initialize cluster config watcher
-	haltWatcher, err := appDB.ClusterService().InitializeClusterWatcher()
+	haltWatcher, err := appDB.ClusterService().InitializeClusterWatcher(context.Background())
 	if err != nil {
 		log.Panic(context.TODO(), map[string]interface{}{
 			"err": err,
 		}, "failed to setup the cluster config watcher")
 	}
 	defer haltWatcher()
+	// Initialize cluster health prober
+	haltProber := appDB.ClusterService().InitializeHealthProber()
+	defer haltProber()
+	// Initialize cluster SA token promoter
+	haltPromoter := appDB.ClusterService().InitializeSATokenPromoter()
+	defer haltPromoter()
+	// Initialize webhook events dispatcher
+	haltDispatcher := appDB.WebhookService().InitializeDispatcher()
+	defer haltDispatcher()
+	// Initialize the feed of the cluster changes sent to the watchers
+	haltChangeFeed, err := appDB.ClusterService().InitializeChangeFeed()
+	if err != nil {
+		log.Panic(context.TODO(), map[string]interface{}{
+			"err": err,
+		}, "failed to setup the cluster change feed")
+	}
+	defer haltChangeFeed()
+	// Reload the cluster config file on SIGHUP, in case its changes are not notified (eg: on network filesystems)
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	defer signal.Stop(reloadSignals)
+	go func() {
+		for range reloadSignals {
+			result, err := appDB.ClusterService().ReloadClusterConfig(context.Background(), repository.ClusterConfigReloadTriggerSignal)
+			if _, notLeader := errs.Cause(err).(errors.DataConflictError); notLeader {
+				log.Info(context.Background(), map[string]interface{}{}, "cluster config file reloaded on SIGHUP, the clusters are reconciled by the leader")
+				continue
+			}
+			if err != nil {
+				log.Error(context.Background(), map[string]interface{}{
+					"err": err,
+				}, "unable to reload the cluster config file on SIGHUP")
+				continue
+			}
+			log.Info(context.Background(), map[string]interface{}{
+				"applied":       result.Applied,
+				"creates":       len(result.Plan.Creates),
+				"updates":       len(result.Plan.Updates),
+				"decommissions": len(result.Plan.Decommissions),
+			}, "cluster config file reloaded on SIGHUP")
+		}
+	}()
 
 	// Setup Security
 	tokenManager, err := auth.DefaultManager(config)
@@ -170,17 +267,25 @@ func main() {
 	app.UseJWTMiddleware(service, jwt.New(tokenManager.PublicKeys(), nil, app.NewJWTSecurity()))
 
 	// Mount "status" controller
-	statusCtrl := controller.NewStatusController(service, controller.NewGormDBChecker(db), config)
+	statusCtrl := controller.NewStatusController(service, controller.NewGormDBChecker(db), appDB.ClusterService(), appDB.ClusterService(), config)
 	app.MountStatusController(service, statusCtrl)
 
 	// Mount "clusters" controller
-	clustersCtrl := controller.NewClustersController(service, appDB)
+	clustersCtrl := controller.NewClustersController(service, appDB, config)
 	app.MountClustersController(service, clustersCtrl)
 
+	// Mount "admin" controller
+	adminCtrl := controller.NewAdminController(service, appDB)
+	app.MountAdminController(service, adminCtrl)
+
 	// Mount "user" controller
 	userCtrl := controller.NewUserController(service, appDB)
 	app.MountUserController(service, userCtrl)
 
+	// Mount "subscriptions" controller
+	subscriptionsCtrl := controller.NewSubscriptionsController(service, appDB)
+	app.MountSubscriptionsController(service, subscriptionsCtrl)
+
 	log.Logger().Infoln("Git Commit SHA: ", controller.Commit)
 	log.Logger().Infoln("UTC Build Time: ", controller.BuildTime)
 	log.Logger().Infoln("UTC Start Time: ", controller.StartTime)
@@ -234,6 +339,32 @@ func configFileFromFlags(flagName string, envVarName string) string {
 	return ""
 }
 
+// printClusterConfigSyncPlan prints the clusters which would be created, updated and decommissioned
+func printClusterConfigSyncPlan(plan repository.ClusterConfigSyncPlan) {
+	fmt.Printf("%d cluster(s) to create, %d cluster(s) to update, %d cluster(s) to decommission\n",
+		len(plan.Creates), len(plan.Updates), len(plan.Decommissions))
+	for _, c := range plan.Creates {
+		fmt.Printf("+ %s (%s)\n", c.URL, c.Name)
+	}
+	for _, c := range plan.Updates {
+		if len(c.Changes) == 0 {
+			continue
+		}
+		fields := make([]string, 0, len(c.Changes))
+		for field := range c.Changes {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		fmt.Printf("~ %s (%s)\n", c.URL, c.Name)
+		for _, field := range fields {
+			fmt.Printf("    %s: %v -> %v\n", field, c.Changes[field].Old, c.Changes[field].New)
+		}
+	}
+	for _, c := range plan.Decommissions {
+		fmt.Printf("- %s (%s) with %d linked identities\n", c.URL, c.Name, c.IdentitiesCount)
+	}
+}
+
 func printUserInfo() {
 	u, err := user.Current()
 	if err != nil {
@@ -22,6 +22,21 @@ func Steps() Scripts {
 		{"005-alter-cluster-api-url-index-to-unique.sql"},
 		{"006-add-sa-token-encrypted-to-cluster.sql"},
 		{"007-add-url-trailing-slash.sql"},
+		{"008-add-max-identities-to-cluster.sql"},
+		{"009-cluster-health.sql"},
+		{"010-audit-log.sql"},
+		{"011-add-state-to-cluster.sql"},
+		{"012-add-secrets-key-id-to-cluster.sql"},
+		{"013-add-pending-sa-token-to-cluster.sql"},
+		{"014-add-version-to-cluster.sql"},
+		{"015-identity-cluster-cluster-id-index.sql"},
+		{"016-add-origin-to-cluster.sql"},
+		{"017-leader-lease.sql"},
+		{"018-webhooks.sql"},
+		{"019-outbox-positions.sql"},
+		{"020-add-secret-key-id-to-webhook-subscription.sql"},
+		{"021-add-pending-sa-token-encrypted-to-cluster.sql"},
+		{"022-add-cluster-snapshot-to-outbox-event.sql"},
 	}
 }
 
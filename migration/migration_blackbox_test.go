@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-cluster/migration"
 	"github.com/fabric8-services/fabric8-common/gormsupport"
@@ -87,6 +88,21 @@ func (s *MigrationTestSuite) TestMigrate() {
 	s.T().Run("testMigration005AlterClusterAPIURLIndexToUnique", testMigration005AlterClusterAPIURLIndexToUnique)
 	s.T().Run("testMigration006AddSaTokenEncryptedToCluster", testMigration006AddSaTokenEncryptedToCluster)
 	s.T().Run("testMigration007AddTrailingSlash", testMigration007AddTrailingSlash)
+	s.T().Run("testMigration008AddMaxIdentitiesToCluster", testMigration008AddMaxIdentitiesToCluster)
+	s.T().Run("testMigration009ClusterHealth", testMigration009ClusterHealth)
+	s.T().Run("testMigration010AuditLog", testMigration010AuditLog)
+	s.T().Run("testMigration011AddStateToCluster", testMigration011AddStateToCluster)
+	s.T().Run("testMigration012AddSecretsKeyIDToCluster", testMigration012AddSecretsKeyIDToCluster)
+	s.T().Run("testMigration013AddPendingSATokenToCluster", testMigration013AddPendingSATokenToCluster)
+	s.T().Run("testMigration014AddVersionToCluster", testMigration014AddVersionToCluster)
+	s.T().Run("testMigration015IdentityClusterClusterIDIndex", testMigration015IdentityClusterClusterIDIndex)
+	s.T().Run("testMigration016AddOriginToCluster", testMigration016AddOriginToCluster)
+	s.T().Run("testMigration017LeaderLease", testMigration017LeaderLease)
+	s.T().Run("testMigration018Webhooks", testMigration018Webhooks)
+	s.T().Run("testMigration019OutboxPositions", testMigration019OutboxPositions)
+	s.T().Run("testMigration020AddSecretKeyIDToWebhookSubscription", testMigration020AddSecretKeyIDToWebhookSubscription)
+	s.T().Run("testMigration021AddPendingSATokenEncryptedToCluster", testMigration021AddPendingSATokenEncryptedToCluster)
+	s.T().Run("testMigration022AddClusterSnapshotToOutboxEvent", testMigration022AddClusterSnapshotToOutboxEvent)
 }
 
 func testMigration001Cluster(t *testing.T) {
@@ -281,3 +297,349 @@ func testMigration007AddTrailingSlash(t *testing.T) {
 	}
 
 }
+
+func testMigration008AddMaxIdentitiesToCluster(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:9])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("cluster", "max_identities"))
+	assert.True(t, dialect.HasColumn("cluster", "capacity_threshold"))
+
+	_, err = sqlDB.Exec(`INSERT INTO cluster (cluster_id, name, url, console_url, metrics_url, logging_url, app_dns, max_identities, capacity_threshold)
+		VALUES ('00000000-0000-0000-0008-000000000001', 'cluster1', 'https://cluster1.max-identities.com/', 'https://console.cluster1.com/',
+	   'https://metrics.cluster1.com/', 'https://login.cluster1.com/', 'cluster1.com/', 1000, 80)`)
+	require.NoError(t, err)
+
+	// check that ALL the existing rows have a default value
+	rows, err := sqlDB.Query("SELECT max_identities, capacity_threshold FROM cluster WHERE cluster_id <> '00000000-0000-0000-0008-000000000001'")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var maxIdentities, capacityThreshold int
+		err = rows.Scan(&maxIdentities, &capacityThreshold)
+		require.NoError(t, err)
+		assert.Equal(t, 0, maxIdentities)
+		assert.Equal(t, 0, capacityThreshold)
+	}
+
+	// check that invalid values are rejected
+	_, err = sqlDB.Exec(`UPDATE cluster SET max_identities = -1 WHERE cluster_id = '00000000-0000-0000-0008-000000000001'`)
+	require.Error(t, err)
+	_, err = sqlDB.Exec(`UPDATE cluster SET capacity_threshold = 101 WHERE cluster_id = '00000000-0000-0000-0008-000000000001'`)
+	require.Error(t, err)
+}
+
+func testMigration009ClusterHealth(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:10])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasTable("cluster_health"))
+	assert.True(t, dialect.HasIndex("cluster_health", "cluster_health_state_idx"))
+
+	_, err = sqlDB.Exec(`INSERT INTO cluster_health (cluster_id, state, latency_ms, last_checked_at, last_success_at)
+		VALUES ('00000000-0000-0000-0008-000000000001', 'healthy', 42, now(), now())`)
+	require.NoError(t, err)
+
+	// check that the health of an unknown cluster cannot be recorded
+	_, err = sqlDB.Exec(`INSERT INTO cluster_health (cluster_id, state, last_checked_at)
+		VALUES ('00000000-0000-0000-0009-000000000001', 'healthy', now())`)
+	require.Error(t, err)
+
+	// check that the health is deleted along with the cluster
+	_, err = sqlDB.Exec(`DELETE FROM cluster WHERE cluster_id = '00000000-0000-0000-0008-000000000001'`)
+	require.NoError(t, err)
+	var count int
+	err = sqlDB.QueryRow(`SELECT count(*) FROM cluster_health WHERE cluster_id = '00000000-0000-0000-0008-000000000001'`).Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func testMigration010AuditLog(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:11])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasTable("audit_log"))
+	assert.True(t, dialect.HasIndex("audit_log", "audit_log_cluster_id_idx"))
+	assert.True(t, dialect.HasIndex("audit_log", "audit_log_created_at_idx"))
+
+	// entries are kept even if the cluster does not exist (anymore)
+	_, err = sqlDB.Exec(`INSERT INTO audit_log (audit_id, cluster_id, operation, actor, request_id, changes)
+		VALUES ('00000000-0000-0000-0010-000000000001', '00000000-0000-0000-0010-000000000002', 'cluster.delete', 'toolchain-operator', 'req-1', '{"name":{"old":"foo"}}')`)
+	require.NoError(t, err)
+
+	// check that entries can neither be updated nor deleted
+	_, err = sqlDB.Exec(`UPDATE audit_log SET actor = 'foo' WHERE audit_id = '00000000-0000-0000-0010-000000000001'`)
+	require.NoError(t, err)
+	_, err = sqlDB.Exec(`DELETE FROM audit_log WHERE audit_id = '00000000-0000-0000-0010-000000000001'`)
+	require.NoError(t, err)
+	var actor string
+	err = sqlDB.QueryRow(`SELECT actor FROM audit_log WHERE audit_id = '00000000-0000-0000-0010-000000000001'`).Scan(&actor)
+	require.NoError(t, err)
+	assert.Equal(t, "toolchain-operator", actor)
+
+	// check that the operation and actor are required
+	_, err = sqlDB.Exec(`INSERT INTO audit_log (cluster_id, operation, actor)
+		VALUES ('00000000-0000-0000-0010-000000000002', '', 'toolchain-operator')`)
+	require.Error(t, err)
+	_, err = sqlDB.Exec(`INSERT INTO audit_log (cluster_id, operation, actor)
+		VALUES ('00000000-0000-0000-0010-000000000002', 'cluster.delete', '')`)
+	require.Error(t, err)
+}
+
+func testMigration011AddStateToCluster(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:12])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("cluster", "state"))
+	assert.True(t, dialect.HasIndex("cluster", "cluster_state_idx"))
+
+	// check that ALL the existing rows are active
+	rows, err := sqlDB.Query("SELECT state FROM cluster")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var state string
+		err = rows.Scan(&state)
+		require.NoError(t, err)
+		assert.Equal(t, "active", state)
+	}
+
+	_, err = sqlDB.Exec(`INSERT INTO cluster (cluster_id, name, url, console_url, metrics_url, logging_url, app_dns, state)
+		VALUES ('00000000-0000-0000-0011-000000000001', 'cluster1', 'https://cluster1.state.com/', 'https://console.cluster1.com/',
+	   'https://metrics.cluster1.com/', 'https://login.cluster1.com/', 'cluster1.com/', 'draining')`)
+	require.NoError(t, err)
+
+	// check that invalid values are rejected
+	_, err = sqlDB.Exec(`UPDATE cluster SET state = 'unknown' WHERE cluster_id = '00000000-0000-0000-0011-000000000001'`)
+	require.Error(t, err)
+}
+
+func testMigration012AddSecretsKeyIDToCluster(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:13])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("cluster", "secrets_key_id"))
+
+	// check that the secrets of ALL the existing rows are considered as stored in clear
+	rows, err := sqlDB.Query("SELECT secrets_key_id FROM cluster")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var keyID string
+		err = rows.Scan(&keyID)
+		require.NoError(t, err)
+		assert.Equal(t, "", keyID)
+	}
+}
+
+func testMigration013AddPendingSATokenToCluster(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:14])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("cluster", "sa_token_created_at"))
+	assert.True(t, dialect.HasColumn("cluster", "pending_sa_token"))
+	assert.True(t, dialect.HasColumn("cluster", "pending_sa_token_created_at"))
+	assert.True(t, dialect.HasColumn("cluster", "pending_sa_token_promotion_at"))
+	assert.True(t, dialect.HasIndex("cluster", "cluster_pending_sa_token_promotion_at_idx"))
+
+	// check that ALL the existing rows have a token creation time, but no pending token
+	rows, err := sqlDB.Query("SELECT sa_token_created_at, pending_sa_token FROM cluster")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var createdAt *time.Time
+		var pendingToken string
+		err = rows.Scan(&createdAt, &pendingToken)
+		require.NoError(t, err)
+		assert.NotNil(t, createdAt)
+		assert.Equal(t, "", pendingToken)
+	}
+}
+
+func testMigration014AddVersionToCluster(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:15])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("cluster", "version"))
+
+	// check that ALL the existing rows start with the initial version
+	rows, err := sqlDB.Query("SELECT version FROM cluster")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var version int
+		err = rows.Scan(&version)
+		require.NoError(t, err)
+		assert.Equal(t, 0, version)
+	}
+}
+
+func testMigration015IdentityClusterClusterIDIndex(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:16])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasIndex("identity_cluster", "identity_cluster_cluster_id_idx"))
+}
+
+func testMigration016AddOriginToCluster(t *testing.T) {
+	// given a cluster created through the API, a cluster created from the config file, and a cluster created before
+	// the audit log existed
+	_, err := sqlDB.Exec(`INSERT INTO cluster (cluster_id, name, url, console_url, metrics_url, logging_url, app_dns)
+		VALUES ('00000000-0000-0000-0016-000000000001', 'cluster1', 'https://cluster1.origin.com/', 'https://console.cluster1.com/',
+	   'https://metrics.cluster1.com/', 'https://login.cluster1.com/', 'cluster1.com/'),
+	   ('00000000-0000-0000-0016-000000000002', 'cluster2', 'https://cluster2.origin.com/', 'https://console.cluster2.com/',
+	   'https://metrics.cluster2.com/', 'https://login.cluster2.com/', 'cluster2.com/'),
+	   ('00000000-0000-0000-0016-000000000003', 'cluster3', 'https://cluster3.origin.com/', 'https://console.cluster3.com/',
+	   'https://metrics.cluster3.com/', 'https://login.cluster3.com/', 'cluster3.com/')`)
+	require.NoError(t, err)
+	_, err = sqlDB.Exec(`INSERT INTO audit_log (cluster_id, operation, actor)
+		VALUES ('00000000-0000-0000-0016-000000000001', 'cluster.create', 'toolchain-operator'),
+		('00000000-0000-0000-0016-000000000002', 'cluster.create', 'system'),
+		('00000000-0000-0000-0016-000000000002', 'cluster.update', 'toolchain-operator')`)
+	require.NoError(t, err)
+
+	// when
+	err = migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:17])
+	require.NoError(t, err)
+
+	// then
+	assert.True(t, dialect.HasColumn("cluster", "origin"))
+	rows, err := sqlDB.Query("SELECT cluster_id, origin FROM cluster")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		// the existing clusters are never decommissioned until the config file claims them
+		var clusterID, origin string
+		err = rows.Scan(&clusterID, &origin)
+		require.NoError(t, err)
+		assert.Equal(t, "api", origin, "unexpected origin of cluster %s", clusterID)
+	}
+
+	// check that invalid values are rejected
+	_, err = sqlDB.Exec(`UPDATE cluster SET origin = 'unknown' WHERE cluster_id = '00000000-0000-0000-0016-000000000001'`)
+	require.Error(t, err)
+}
+
+func testMigration017LeaderLease(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:18])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasTable("leader_lease"))
+	_, err = sqlDB.Exec(`INSERT INTO leader_lease (name, holder, expires_at) VALUES ('lease1', 'replica1', now())`)
+	require.NoError(t, err)
+	// check that a lease can only be held by a single replica
+	_, err = sqlDB.Exec(`INSERT INTO leader_lease (name, holder, expires_at) VALUES ('lease1', 'replica2', now())`)
+	require.Error(t, err)
+}
+
+func testMigration018Webhooks(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:19])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasTable("outbox_event"))
+	assert.True(t, dialect.HasTable("webhook_subscription"))
+	assert.True(t, dialect.HasTable("webhook_delivery"))
+	_, err = sqlDB.Exec(`INSERT INTO audit_log (audit_id, cluster_id, operation, actor)
+		VALUES ('4a5e7e3e-5a83-4bd4-95b2-38d5e5d2b4a1', uuid_generate_v4(), 'cluster.create', 'system')`)
+	require.NoError(t, err)
+	var eventID int64
+	err = sqlDB.QueryRow(`INSERT INTO outbox_event (audit_id) VALUES ('4a5e7e3e-5a83-4bd4-95b2-38d5e5d2b4a1') RETURNING event_id`).Scan(&eventID)
+	require.NoError(t, err)
+	_, err = sqlDB.Exec(`INSERT INTO webhook_subscription (subscription_id, owner, url, secret, event_types)
+		VALUES ('9c1b7c3e-0d0e-4c4b-a0b8-77a4a3c1e2f0', 'fabric8-tenant', 'https://tenant/api/webhooks', 'secret', '{cluster.create}')`)
+	require.NoError(t, err)
+	_, err = sqlDB.Exec(`INSERT INTO webhook_delivery (subscription_id, event_id) VALUES ('9c1b7c3e-0d0e-4c4b-a0b8-77a4a3c1e2f0', $1)`, eventID)
+	require.NoError(t, err)
+	// check that an event is delivered only once to each subscription
+	_, err = sqlDB.Exec(`INSERT INTO webhook_delivery (subscription_id, event_id) VALUES ('9c1b7c3e-0d0e-4c4b-a0b8-77a4a3c1e2f0', $1)`, eventID)
+	require.Error(t, err)
+	// check that the deliveries are deleted along with their subscription
+	_, err = sqlDB.Exec(`DELETE FROM webhook_subscription WHERE subscription_id = '9c1b7c3e-0d0e-4c4b-a0b8-77a4a3c1e2f0'`)
+	require.NoError(t, err)
+	var count int
+	err = sqlDB.QueryRow(`SELECT count(*) FROM webhook_delivery WHERE event_id = $1`, eventID).Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func testMigration019OutboxPositions(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:20])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("outbox_event", "txid"))
+	assert.True(t, dialect.HasTable("outbox_watermark"))
+	// check that the existing events are positioned before the new ones
+	var existingTxID int64
+	err = sqlDB.QueryRow(`SELECT txid FROM outbox_event WHERE audit_id = '4a5e7e3e-5a83-4bd4-95b2-38d5e5d2b4a1'`).Scan(&existingTxID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), existingTxID)
+	// check that the new events are recorded with the ID of their transaction
+	var txID, currentTxID int64
+	tx, err := sqlDB.Begin()
+	require.NoError(t, err)
+	err = tx.QueryRow(`INSERT INTO outbox_event (audit_id) VALUES ('4a5e7e3e-5a83-4bd4-95b2-38d5e5d2b4a1') RETURNING txid`).Scan(&txID)
+	require.NoError(t, err)
+	err = tx.QueryRow(`SELECT txid_current()`).Scan(&currentTxID)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, currentTxID, txID)
+	// check that there is a single watermark
+	var count int
+	err = sqlDB.QueryRow(`SELECT count(*) FROM outbox_watermark`).Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = sqlDB.Exec(`INSERT INTO outbox_watermark (id, txid, event_id) VALUES (false, 0, 0)`)
+	require.Error(t, err)
+}
+
+func testMigration020AddSecretKeyIDToWebhookSubscription(t *testing.T) {
+	_, err := sqlDB.Exec(`INSERT INTO webhook_subscription (subscription_id, owner, url, secret)
+		VALUES ('0d8e4f5c-3b8e-4a53-9a0e-6c2f2a7b6c11', 'fabric8-tenant', 'https://tenant/api/webhooks', 'secret')`)
+	require.NoError(t, err)
+	err = migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:21])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("webhook_subscription", "secret_key_id"))
+
+	// check that the secrets of the existing subscriptions are considered as stored in clear
+	var keyID string
+	err = sqlDB.QueryRow(`SELECT secret_key_id FROM webhook_subscription WHERE subscription_id = '0d8e4f5c-3b8e-4a53-9a0e-6c2f2a7b6c11'`).Scan(&keyID)
+	require.NoError(t, err)
+	assert.Equal(t, "", keyID)
+}
+
+func testMigration021AddPendingSATokenEncryptedToCluster(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:22])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("cluster", "pending_sa_token_encrypted"))
+
+	// check that the pending tokens of ALL the existing clusters are considered as not encrypted
+	rows, err := sqlDB.Query("SELECT pending_sa_token_encrypted FROM cluster")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var encrypted bool
+		err = rows.Scan(&encrypted)
+		require.NoError(t, err)
+		assert.False(t, encrypted)
+	}
+}
+
+func testMigration022AddClusterSnapshotToOutboxEvent(t *testing.T) {
+	err := migrationsupport.Migrate(sqlDB, databaseName, migration.Steps()[:23])
+	require.NoError(t, err)
+
+	assert.True(t, dialect.HasColumn("outbox_event", "cluster"))
+
+	// check that the existing events carry no cluster state
+	rows, err := sqlDB.Query("SELECT cluster FROM outbox_event")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var snapshot *string
+		err = rows.Scan(&snapshot)
+		require.NoError(t, err)
+		assert.Nil(t, snapshot)
+	}
+}
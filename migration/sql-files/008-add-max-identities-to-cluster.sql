@@ -0,0 +1,3 @@
+-- Store the maximum number of identities and the capacity threshold (percentage) for cluster
+ALTER TABLE cluster ADD COLUMN max_identities integer NOT NULL DEFAULT 0 CHECK (max_identities >= 0);
+ALTER TABLE cluster ADD COLUMN capacity_threshold integer NOT NULL DEFAULT 0 CHECK (capacity_threshold >= 0 AND capacity_threshold <= 100);
@@ -0,0 +1,14 @@
+-- Store the result of the latest health probe of each cluster
+CREATE TABLE cluster_health (
+    cluster_id uuid primary key references cluster(cluster_id) ON DELETE CASCADE,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    state text NOT NULL CHECK (state <> ''),
+    latency_ms integer NOT NULL DEFAULT 0,
+    last_checked_at timestamp with time zone,
+    last_success_at timestamp with time zone,
+    last_error text,
+    last_error_at timestamp with time zone
+);
+
+CREATE INDEX cluster_health_state_idx ON cluster_health USING BTREE (state);
@@ -0,0 +1,19 @@
+-- Append-only log of the mutations on the clusters and on the identity/cluster relationships.
+-- There is no foreign key on `cluster_id` so that the entries are kept after the cluster was deleted.
+CREATE TABLE audit_log (
+    audit_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    cluster_id uuid NOT NULL,
+    identity_id uuid,
+    operation text NOT NULL CHECK (operation <> ''),
+    actor text NOT NULL CHECK (actor <> ''),
+    request_id text,
+    changes jsonb
+);
+
+CREATE INDEX audit_log_cluster_id_idx ON audit_log USING BTREE (cluster_id, created_at);
+CREATE INDEX audit_log_created_at_idx ON audit_log USING BTREE (created_at);
+
+-- entries can neither be updated nor deleted
+CREATE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING;
+CREATE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
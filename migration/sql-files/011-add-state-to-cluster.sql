@@ -0,0 +1,6 @@
+-- Lifecycle state of the clusters. Deleting a cluster now means moving it to the `decommissioned` state,
+-- so that the identity/cluster relationships are kept.
+ALTER TABLE cluster ADD COLUMN state text NOT NULL DEFAULT 'active'
+    CHECK (state IN ('provisioning', 'active', 'draining', 'decommissioned'));
+
+CREATE INDEX cluster_state_idx ON cluster USING BTREE (state);
@@ -0,0 +1,3 @@
+-- ID of the key with which the SA token and the OAuth client secret of the cluster are encrypted at rest.
+-- An empty value means that the secrets are still stored in clear.
+ALTER TABLE cluster ADD COLUMN secrets_key_id text NOT NULL DEFAULT '';
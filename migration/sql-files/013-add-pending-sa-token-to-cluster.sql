@@ -0,0 +1,12 @@
+-- Rotation of the SA token: a pending token is stored next to the current one during a grace period,
+-- after which it replaces the current token.
+ALTER TABLE cluster ADD COLUMN sa_token_created_at timestamp with time zone;
+ALTER TABLE cluster ADD COLUMN pending_sa_token text NOT NULL DEFAULT '';
+ALTER TABLE cluster ADD COLUMN pending_sa_token_created_at timestamp with time zone;
+ALTER TABLE cluster ADD COLUMN pending_sa_token_promotion_at timestamp with time zone;
+
+-- the current tokens were set at the latest update of their cluster, at best
+UPDATE cluster SET sa_token_created_at = updated_at;
+
+CREATE INDEX cluster_pending_sa_token_promotion_at_idx ON cluster USING BTREE (pending_sa_token_promotion_at)
+    WHERE pending_sa_token_promotion_at IS NOT NULL;
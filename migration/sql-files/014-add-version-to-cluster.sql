@@ -0,0 +1,3 @@
+-- Optimistic concurrency control: the version is incremented each time the cluster is modified,
+-- and exposed as the ETag of the cluster resources.
+ALTER TABLE cluster ADD COLUMN version integer NOT NULL DEFAULT 0;
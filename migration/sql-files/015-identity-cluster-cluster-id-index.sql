@@ -0,0 +1,2 @@
+-- Index to list the identities linked to a cluster (ordered by identity ID)
+CREATE INDEX identity_cluster_cluster_id_idx ON identity_cluster USING BTREE (cluster_id, identity_id);
@@ -0,0 +1,7 @@
+-- Origin of the cluster: 'config' if it is declared in the cluster configuration file, 'api' if it was registered
+-- through the REST API. Only the clusters which originate from the configuration file are reconciled with it.
+-- The existing clusters are considered as registered through the REST API, since the audit log (which only exists since
+-- migration 010) cannot tell them apart, and since the configuration file is not known here. The ones which are declared
+-- in the configuration file become owned by it when the file is loaded, so that no cluster registered through the REST
+-- API is decommissioned by mistake.
+ALTER TABLE cluster ADD COLUMN origin text NOT NULL DEFAULT 'api' CHECK (origin IN ('config', 'api'));
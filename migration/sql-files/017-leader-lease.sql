@@ -0,0 +1,9 @@
+-- Leases through which the replicas of the service elect a leader. The holder of a lease must renew it before
+-- it expires, otherwise another replica can acquire it.
+CREATE TABLE leader_lease (
+    name text PRIMARY KEY,
+    holder text NOT NULL,
+    acquired_at timestamp with time zone NOT NULL DEFAULT now(),
+    renewed_at timestamp with time zone NOT NULL DEFAULT now(),
+    expires_at timestamp with time zone NOT NULL
+);
@@ -0,0 +1,42 @@
+-- Outbox of the changes on the clusters and on the identity/cluster relationships. An event is written along with
+-- the audit entry of each change, in the same transaction, and is then dispatched to the webhook subscriptions.
+CREATE TABLE outbox_event (
+    event_id bigserial PRIMARY KEY,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    audit_id uuid NOT NULL REFERENCES audit_log(audit_id),
+    dispatched_at timestamp with time zone
+);
+
+CREATE INDEX outbox_event_undispatched_idx ON outbox_event USING BTREE (event_id) WHERE dispatched_at IS NULL;
+
+-- Subscriptions of the other services to the cluster change events, which are delivered to their URL
+CREATE TABLE webhook_subscription (
+    subscription_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    owner text NOT NULL CHECK (owner <> ''),
+    url text NOT NULL CHECK (url <> ''),
+    secret text NOT NULL CHECK (secret <> ''),
+    event_types text[]
+);
+
+CREATE INDEX webhook_subscription_owner_idx ON webhook_subscription USING BTREE (owner);
+
+-- Deliveries of the outbox events to the webhook subscriptions. The deliveries which failed too many times
+-- are kept in the `dead` state until they are redelivered or their subscription is deleted.
+CREATE TABLE webhook_delivery (
+    delivery_id uuid primary key DEFAULT uuid_generate_v4() NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    subscription_id uuid NOT NULL REFERENCES webhook_subscription(subscription_id) ON DELETE CASCADE,
+    event_id bigint NOT NULL REFERENCES outbox_event(event_id) ON DELETE CASCADE,
+    state text NOT NULL DEFAULT 'pending' CHECK (state IN ('pending', 'delivered', 'dead')),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
+    last_error text,
+    delivered_at timestamp with time zone,
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_delivery_pending_idx ON webhook_delivery USING BTREE (next_attempt_at) WHERE state = 'pending';
+CREATE INDEX webhook_delivery_subscription_id_idx ON webhook_delivery USING BTREE (subscription_id, event_id);
@@ -0,0 +1,28 @@
+-- Position of the outbox events in the history of the changes: the events are ordered by the ID of the transaction
+-- which recorded them, then by event ID. Unlike the event IDs alone, this order is stable once the transactions
+-- are finished, since a transaction can commit after another one which got a greater event ID.
+ALTER TABLE outbox_event ADD COLUMN txid bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX outbox_event_position_idx ON outbox_event USING BTREE (txid, event_id);
+
+-- Records the transaction ID of each new event, and notifies the listeners on the `outbox_event` channel
+-- (the notification is only sent when the transaction commits)
+CREATE OR REPLACE FUNCTION record_outbox_event() RETURNS trigger AS $$
+BEGIN
+    NEW.txid := txid_current();
+    PERFORM pg_notify('outbox_event', NEW.event_id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER outbox_event_recorded BEFORE INSERT ON outbox_event
+    FOR EACH ROW EXECUTE PROCEDURE record_outbox_event();
+
+-- Position of the newest event purged from the outbox: the history of the changes is incomplete up to it
+CREATE TABLE outbox_watermark (
+    id boolean PRIMARY KEY DEFAULT true CHECK (id),
+    txid bigint NOT NULL,
+    event_id bigint NOT NULL
+);
+
+INSERT INTO outbox_watermark (txid, event_id) VALUES (0, 0);
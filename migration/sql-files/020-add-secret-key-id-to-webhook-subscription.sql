@@ -0,0 +1,3 @@
+-- ID of the key with which the secret of the webhook subscription is encrypted at rest.
+-- An empty value means that the secret is still stored in clear.
+ALTER TABLE webhook_subscription ADD COLUMN secret_key_id text NOT NULL DEFAULT '';
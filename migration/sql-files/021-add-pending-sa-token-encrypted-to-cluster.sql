@@ -0,0 +1,4 @@
+-- Whether the pending SA token is encrypted (as for the current SA token with the `sa_token_encrypted` column),
+-- so that the flag is carried over when the pending token replaces the current one.
+-- The pending tokens of the rotations in progress were set through the API, hence not encrypted.
+ALTER TABLE cluster ADD COLUMN pending_sa_token_encrypted boolean NOT NULL DEFAULT false;
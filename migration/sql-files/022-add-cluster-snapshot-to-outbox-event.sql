@@ -0,0 +1,4 @@
+-- State of the cluster right after the change recorded by the event (without its secrets), so that the watchers of
+-- the clusters receive each state in turn, even if the cluster changed again since then.
+-- Null for the deletions and the identity/cluster relationship changes, and for the events recorded before this column.
+ALTER TABLE outbox_event ADD COLUMN cluster jsonb;
@@ -2,7 +2,9 @@ package test
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -18,6 +20,13 @@ import (
 
 type createClusterOption func(*repository.Cluster)
 
+// WithName an option to specify the name of the cluster to create
+func WithName(name string) func(*repository.Cluster) {
+	return func(c *repository.Cluster) {
+		c.Name = name
+	}
+}
+
 // WithType an option to specify the type of the cluster to create
 func WithType(t string) func(*repository.Cluster) {
 	return func(c *repository.Cluster) {
@@ -25,6 +34,46 @@ func WithType(t string) func(*repository.Cluster) {
 	}
 }
 
+// WithCapacityExhausted an option to specify if the capacity of the cluster to create is exhausted
+func WithCapacityExhausted(exhausted bool) func(*repository.Cluster) {
+	return func(c *repository.Cluster) {
+		c.CapacityExhausted = exhausted
+	}
+}
+
+// WithMaxIdentities an option to specify the maximum number of identities and the capacity threshold of the cluster to create
+func WithMaxIdentities(maxIdentities, capacityThreshold int) func(*repository.Cluster) {
+	return func(c *repository.Cluster) {
+		c.MaxIdentities = maxIdentities
+		c.CapacityThreshold = capacityThreshold
+	}
+}
+
+// WithState an option to specify the lifecycle state of the cluster to create
+func WithState(state string) func(*repository.Cluster) {
+	return func(c *repository.Cluster) {
+		c.State = state
+	}
+}
+
+// WithOrigin an option to specify the origin of the cluster to create (`config` or `api`)
+func WithOrigin(origin string) func(*repository.Cluster) {
+	return func(c *repository.Cluster) {
+		c.Origin = origin
+	}
+}
+
+// WithValidURLs an option to use valid console, metrics and logging URLs (i.e., with a scheme and a host)
+// in the cluster to create
+func WithValidURLs() func(*repository.Cluster) {
+	return func(c *repository.Cluster) {
+		name := uuid.NewV4().String()
+		c.ConsoleURL = "https://console." + name + "/"
+		c.MetricsURL = "https://metrics." + name + "/"
+		c.LoggingURL = "https://logging." + name + "/"
+	}
+}
+
 // CreateCluster returns a new cluster after saves it in the DB
 func CreateCluster(t *testing.T, db *gorm.DB, options ...createClusterOption) repository.Cluster {
 	c := NewCluster(options...)
@@ -139,6 +188,9 @@ func AssertEqualClusterDetails(t *testing.T, expected, actual repository.Cluster
 	assert.Equal(t, expected.LoggingURL, actual.LoggingURL)
 	assert.Equal(t, expected.ConsoleURL, actual.ConsoleURL)
 	assert.Equal(t, expected.CapacityExhausted, actual.CapacityExhausted)
+	assert.Equal(t, expected.MaxIdentities, actual.MaxIdentities)
+	assert.Equal(t, expected.CapacityThreshold, actual.CapacityThreshold)
+	assert.Equal(t, expectedState(expected), actual.State)
 	if expectSensitiveInfo {
 		assert.Equal(t, expected.AuthDefaultScope, actual.AuthDefaultScope)
 		assert.Equal(t, expected.AuthClientID, actual.AuthClientID)
@@ -147,6 +199,9 @@ func AssertEqualClusterDetails(t *testing.T, expected, actual repository.Cluster
 		assert.Equal(t, expected.SAUsername, actual.SAUsername)
 		assert.Equal(t, expected.SAToken, actual.SAToken)
 		assert.Equal(t, expected.SATokenEncrypted, actual.SATokenEncrypted)
+		assert.Equal(t, expected.SecretsKeyID, actual.SecretsKeyID)
+		assert.Equal(t, expected.PendingSAToken, actual.PendingSAToken)
+		assert.Equal(t, expected.PendingSATokenEncrypted, actual.PendingSATokenEncrypted)
 	} else {
 		assert.Equal(t, "", actual.AuthDefaultScope)
 		assert.Equal(t, "", actual.AuthClientID)
@@ -155,30 +210,96 @@ func AssertEqualClusterDetails(t *testing.T, expected, actual repository.Cluster
 		assert.Equal(t, "", actual.SAUsername)
 		assert.Equal(t, "", actual.SAToken)
 		assert.Equal(t, false, actual.SATokenEncrypted)
+		assert.Equal(t, "", actual.SecretsKeyID)
+		assert.Equal(t, "", actual.PendingSAToken)
+		assert.Equal(t, false, actual.PendingSATokenEncrypted)
 	}
 }
 
+// expectedState returns the state of the given cluster, or `active` if it is not set (i.e., the default state)
+func expectedState(c repository.Cluster) string {
+	if c.State == "" {
+		return repository.ClusterStateActive
+	}
+	return c.State
+}
+
 // AssertEqualClustersData verifies that data for all actual clusters match the expected ones
 func AssertEqualClustersData(t *testing.T, expected []repository.Cluster, actual []*app.ClusterData) {
 	require.Len(t, actual, len(expected))
 	for _, a := range actual {
 		require.NotNil(t, a)
-		expected, err := FilterClusterByURL(a.APIURL, expected)
+		require.NotNil(t, a.Attributes)
+		expected, err := FilterClusterByURL(a.Attributes.APIURL, expected)
 		require.NoError(t, err)
 		AssertEqualClusterData(t, expected, *a)
 	}
 }
 
-// AssertEqualClusterData verifies that data for actual cluster match the expected one
+// AssertEqualClusterData verifies that data for actual cluster match the expected one. If the actual cluster
+// has top-level attributes (compatibility mode), then they must match the expected cluster, too.
 func AssertEqualClusterData(t *testing.T, expected repository.Cluster, actual app.ClusterData) {
-	assert.Equal(t, expected.Name, actual.Name)
-	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.URL), actual.APIURL)
-	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.ConsoleURL), actual.ConsoleURL)
-	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.MetricsURL), actual.MetricsURL)
-	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.LoggingURL), actual.LoggingURL)
-	assert.Equal(t, expected.AppDNS, actual.AppDNS)
+	assert.Equal(t, expected.ClusterID, actual.ID)
+	require.NotNil(t, actual.Links)
+	require.NotNil(t, actual.Links.Self)
+	assert.True(t, strings.HasSuffix(*actual.Links.Self, app.ClustersHref(expected.ClusterID.String())))
+	require.NotNil(t, actual.Attributes)
+	attributes := *actual.Attributes
+	assert.Equal(t, expected.Name, attributes.Name)
+	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.URL), attributes.APIURL)
+	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.ConsoleURL), attributes.ConsoleURL)
+	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.MetricsURL), attributes.MetricsURL)
+	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.LoggingURL), attributes.LoggingURL)
+	assert.Equal(t, expected.AppDNS, attributes.AppDNS)
+	assert.Equal(t, expected.Type, attributes.ClusterType)
+	assert.Equal(t, expected.CapacityExhausted, attributes.CapacityExhausted)
+	require.NotNil(t, attributes.MaxIdentities)
+	assert.Equal(t, expected.MaxIdentities, *attributes.MaxIdentities)
+	require.NotNil(t, attributes.CapacityThreshold)
+	assert.Equal(t, expected.CapacityThreshold, *attributes.CapacityThreshold)
+	assert.Equal(t, expectedState(expected), attributes.State)
+	AssertEqualClusterHealthData(t, expected.Health, attributes.Health)
+	if actual.Name == nil {
+		assert.Equal(t, "clusters", actual.Type)
+		return
+	}
+	// compatibility mode
 	assert.Equal(t, expected.Type, actual.Type)
-	assert.Equal(t, expected.CapacityExhausted, actual.CapacityExhausted)
+	assert.Equal(t, app.ClusterData{
+		ID:                actual.ID,
+		Type:              actual.Type,
+		Attributes:        actual.Attributes,
+		Links:             actual.Links,
+		Name:              &attributes.Name,
+		APIURL:            &attributes.APIURL,
+		ConsoleURL:        &attributes.ConsoleURL,
+		MetricsURL:        &attributes.MetricsURL,
+		LoggingURL:        &attributes.LoggingURL,
+		AppDNS:            &attributes.AppDNS,
+		CapacityExhausted: &attributes.CapacityExhausted,
+		MaxIdentities:     attributes.MaxIdentities,
+		CapacityThreshold: attributes.CapacityThreshold,
+		State:             &attributes.State,
+		IdentitiesCount:   attributes.IdentitiesCount,
+		Health:            attributes.Health,
+	}, actual)
+}
+
+// AssertEqualClusterHealthData verifies that the actual health data match the expected one
+func AssertEqualClusterHealthData(t *testing.T, expected *repository.ClusterHealth, actual *app.ClusterHealthData) {
+	if expected == nil {
+		assert.Nil(t, actual)
+		return
+	}
+	require.NotNil(t, actual)
+	assert.Equal(t, expected.State, actual.State)
+	assert.Equal(t, int(expected.Latency), actual.Latency)
+	if expected.LastError == "" {
+		assert.Nil(t, actual.LastError)
+	} else {
+		require.NotNil(t, actual.LastError)
+		assert.Equal(t, expected.LastError, *actual.LastError)
+	}
 }
 
 // AssertEqualFullClustersData verifies that data for all actual clusters match the expected ones
@@ -186,31 +307,89 @@ func AssertEqualFullClustersData(t *testing.T, expected []repository.Cluster, ac
 	require.Len(t, actual, len(expected))
 	for _, a := range actual {
 		require.NotNil(t, a)
-		expected, err := FilterClusterByURL(a.APIURL, expected)
+		require.NotNil(t, a.Attributes)
+		expected, err := FilterClusterByURL(a.Attributes.APIURL, expected)
 		require.NoError(t, err)
 		AssertEqualFullClusterData(t, expected, *a)
 	}
 }
 
-// AssertEqualFullClusterData verifies that data for actual cluster match the expected one
+// AssertEqualFullClusterData verifies that data for actual cluster match the expected one. If the actual cluster
+// has top-level attributes (compatibility mode), then they must match the expected cluster, too.
 func AssertEqualFullClusterData(t *testing.T, expected repository.Cluster, actual app.FullClusterData) {
-	assert.Equal(t, expected.Name, actual.Name)
-	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.URL), actual.APIURL)
-	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.ConsoleURL), actual.ConsoleURL)
-	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.MetricsURL), actual.MetricsURL)
-	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.LoggingURL), actual.LoggingURL)
-	assert.Equal(t, expected.AppDNS, actual.AppDNS)
-	assert.Equal(t, expected.Type, actual.Type)
-	assert.Equal(t, expected.CapacityExhausted, actual.CapacityExhausted)
+	assert.Equal(t, expected.ClusterID, actual.ID)
+	require.NotNil(t, actual.Links)
+	require.NotNil(t, actual.Links.Self)
+	assert.True(t, strings.HasSuffix(*actual.Links.Self, app.ClustersHref(expected.ClusterID.String())))
+	require.NotNil(t, actual.Attributes)
+	attributes := *actual.Attributes
+	assert.Equal(t, expected.Name, attributes.Name)
+	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.URL), attributes.APIURL)
+	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.ConsoleURL), attributes.ConsoleURL)
+	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.MetricsURL), attributes.MetricsURL)
+	assert.Equal(t, httpsupport.AddTrailingSlashToURL(expected.LoggingURL), attributes.LoggingURL)
+	assert.Equal(t, expected.AppDNS, attributes.AppDNS)
+	assert.Equal(t, expected.Type, attributes.ClusterType)
+	assert.Equal(t, expected.CapacityExhausted, attributes.CapacityExhausted)
+	require.NotNil(t, attributes.MaxIdentities)
+	assert.Equal(t, expected.MaxIdentities, *attributes.MaxIdentities)
+	require.NotNil(t, attributes.CapacityThreshold)
+	assert.Equal(t, expected.CapacityThreshold, *attributes.CapacityThreshold)
+	assert.Equal(t, expectedState(expected), attributes.State)
+	AssertEqualClusterHealthData(t, expected.Health, attributes.Health)
 	// sensitive info
-	assert.Equal(t, expected.AuthClientID, actual.AuthClientID)
-	assert.Equal(t, expected.AuthDefaultScope, actual.AuthClientDefaultScope)
-	assert.Equal(t, expected.AuthClientSecret, actual.AuthClientSecret)
-	assert.Equal(t, expected.TokenProviderID, actual.TokenProviderID)
-	require.NotNil(t, actual.SaTokenEncrypted)
-	assert.Equal(t, expected.SATokenEncrypted, *actual.SaTokenEncrypted)
-	assert.Equal(t, expected.SAUsername, actual.ServiceAccountUsername)
-	assert.Equal(t, expected.SAToken, actual.ServiceAccountToken)
+	assert.Equal(t, expected.AuthClientID, attributes.AuthClientID)
+	assert.Equal(t, expected.AuthDefaultScope, attributes.AuthClientDefaultScope)
+	assert.Equal(t, expected.AuthClientSecret, attributes.AuthClientSecret)
+	assert.Equal(t, expected.TokenProviderID, attributes.TokenProviderID)
+	require.NotNil(t, attributes.SaTokenEncrypted)
+	assert.Equal(t, expected.SATokenEncrypted, *attributes.SaTokenEncrypted)
+	assert.Equal(t, expected.SAUsername, attributes.ServiceAccountUsername)
+	assert.Equal(t, expected.SAToken, attributes.ServiceAccountToken)
+	if expected.PendingSAToken != "" {
+		require.NotNil(t, attributes.PendingServiceAccountToken)
+		assert.Equal(t, expected.PendingSAToken, *attributes.PendingServiceAccountToken)
+		require.NotNil(t, attributes.PendingSaTokenEncrypted)
+		assert.Equal(t, expected.PendingSATokenEncrypted, *attributes.PendingSaTokenEncrypted)
+	} else {
+		assert.Nil(t, attributes.PendingServiceAccountToken)
+		assert.Nil(t, attributes.PendingSaTokenEncrypted)
+	}
+	if actual.Name == nil {
+		assert.Equal(t, "clusters", actual.Type)
+		return
+	}
+	// compatibility mode
+	assert.Equal(t, expected.Type, actual.Type)
+	assert.Equal(t, app.FullClusterData{
+		ID:                                    actual.ID,
+		Type:                                  actual.Type,
+		Attributes:                            actual.Attributes,
+		Links:                                 actual.Links,
+		Name:                                  &attributes.Name,
+		APIURL:                                &attributes.APIURL,
+		ConsoleURL:                            &attributes.ConsoleURL,
+		MetricsURL:                            &attributes.MetricsURL,
+		LoggingURL:                            &attributes.LoggingURL,
+		AppDNS:                                &attributes.AppDNS,
+		CapacityExhausted:                     &attributes.CapacityExhausted,
+		MaxIdentities:                         attributes.MaxIdentities,
+		CapacityThreshold:                     attributes.CapacityThreshold,
+		State:                                 &attributes.State,
+		IdentitiesCount:                       attributes.IdentitiesCount,
+		Health:                                attributes.Health,
+		AuthClientDefaultScope:                &attributes.AuthClientDefaultScope,
+		AuthClientID:                          &attributes.AuthClientID,
+		AuthClientSecret:                      &attributes.AuthClientSecret,
+		SaTokenEncrypted:                      attributes.SaTokenEncrypted,
+		ServiceAccountToken:                   &attributes.ServiceAccountToken,
+		ServiceAccountUsername:                &attributes.ServiceAccountUsername,
+		TokenProviderID:                       &attributes.TokenProviderID,
+		ServiceAccountTokenCreatedAt:          attributes.ServiceAccountTokenCreatedAt,
+		PendingServiceAccountToken:            attributes.PendingServiceAccountToken,
+		PendingServiceAccountTokenCreatedAt:   attributes.PendingServiceAccountTokenCreatedAt,
+		PendingServiceAccountTokenPromotionAt: attributes.PendingServiceAccountTokenPromotionAt,
+	}, actual)
 }
 
 // FilterClusterByURL returns the cluster that has the given URL or an error if none was found
@@ -269,6 +448,27 @@ func CreateIdentityCluster(t *testing.T, db *gorm.DB, options ...CreateIdentityC
 	return *loaded
 }
 
+// CreateClusterHealth returns a new ClusterHealth with the given state for the given cluster, after saving it in the DB
+func CreateClusterHealth(t *testing.T, db *gorm.DB, c repository.Cluster, state string) repository.ClusterHealth {
+	now := time.Now()
+	h := repository.ClusterHealth{
+		ClusterID:     c.ClusterID,
+		State:         state,
+		Latency:       42,
+		LastCheckedAt: now,
+	}
+	if state == repository.HealthStateHealthy {
+		h.LastSuccessAt = &now
+	} else {
+		h.LastError = "API URL '" + c.URL + "': connection refused"
+		h.LastErrorAt = &now
+	}
+	repo := repository.NewClusterHealthRepository(db)
+	err := repo.CreateOrSave(context.Background(), &h)
+	require.NoError(t, err)
+	return h
+}
+
 // AssertEqualIdentityClusters verifies that the identity/cluster links are equal
 func AssertEqualIdentityClusters(t *testing.T, expected, actual repository.IdentityCluster) {
 	assert.Equal(t, expected.IdentityID, actual.IdentityID)
@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-cluster/webhook/repository"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// CreateSubscription returns a new webhook subscription of the given owner to the given event types (or to all the
+// events if none is given), after saving it in the DB
+func CreateSubscription(t *testing.T, db *gorm.DB, owner, url string, eventTypes ...string) repository.Subscription {
+	s := repository.Subscription{
+		Owner:      owner,
+		URL:        url,
+		Secret:     uuid.NewV4().String(),
+		EventTypes: eventTypes,
+	}
+	repo := repository.NewSubscriptionRepository(db)
+	err := repo.Create(context.Background(), &s)
+	require.NoError(t, err)
+	loaded, err := repo.Load(context.Background(), s.ID)
+	require.NoError(t, err)
+	return *loaded
+}
+
+// DispatchOutboxEvents dispatches all the outbox events which were not dispatched yet to the webhook subscriptions
+func DispatchOutboxEvents(t *testing.T, db *gorm.DB) {
+	repo := repository.NewDeliveryRepository(db)
+	for {
+		count, err := repo.Enqueue(context.Background(), 1000)
+		require.NoError(t, err)
+		if count == 0 {
+			return
+		}
+	}
+}
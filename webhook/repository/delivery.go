@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	clusterrepository "github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// DeliveryStatePending the state of a delivery which was not attempted yet, or which failed and will be attempted again
+	DeliveryStatePending = "pending"
+	// DeliveryStateDelivered the state of a delivery which succeeded
+	DeliveryStateDelivered = "delivered"
+	// DeliveryStateDead the state of a delivery which failed too many times, and which is kept in the dead letters
+	// of its subscription until it is redelivered
+	DeliveryStateDead = "dead"
+)
+
+// Delivery the delivery of an outbox event to a webhook subscription
+type Delivery struct {
+	// This is the primary key value
+	ID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:delivery_id"`
+	// The time at which the event was dispatched to the subscription
+	CreatedAt time.Time
+	// The time of the last update of the delivery
+	UpdatedAt time.Time
+	// The ID of the subscription
+	SubscriptionID uuid.UUID `sql:"type:uuid"`
+	// The ID of the outbox event
+	EventID int64
+	// The state of the delivery (`pending`, `delivered` or `dead`)
+	State string
+	// The number of attempts so far
+	Attempts int
+	// The time after which the next attempt can be made, when the delivery is pending
+	NextAttemptAt time.Time
+	// The error of the last failed attempt, if any
+	LastError string
+	// The time at which the event was delivered, if it was
+	DeliveredAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (d Delivery) TableName() string {
+	return "webhook_delivery"
+}
+
+// DeliveryRepository represents the storage interface.
+type DeliveryRepository interface {
+	Enqueue(ctx context.Context, limit int) (int, error)
+	Load(ctx context.Context, id uuid.UUID) (*Delivery, error)
+	ListDue(ctx context.Context, limit int) ([]Delivery, error)
+	ListForSubscription(ctx context.Context, subscriptionID uuid.UUID, state *string) ([]Delivery, error)
+	Update(ctx context.Context, d *Delivery) error
+	Redeliver(ctx context.Context, id uuid.UUID) error
+	Purge(ctx context.Context, before time.Time) error
+}
+
+// GormDeliveryRepository is the implementation of the storage interface for Delivery.
+type GormDeliveryRepository struct {
+	db *gorm.DB
+}
+
+// NewDeliveryRepository creates a new storage type.
+func NewDeliveryRepository(db *gorm.DB) DeliveryRepository {
+	return &GormDeliveryRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormDeliveryRepository) TableName() string {
+	return "webhook_delivery"
+}
+
+// Enqueue dispatches the (at most `limit`) oldest settled outbox events which were not dispatched yet, in the order of
+// their position in the outbox: a pending delivery is created for each subscription which existed when the event occurred
+// and which accepts its type, and the events are marked as dispatched, all in a single statement. The events of the
+// transactions which are not settled yet are left for a subsequent call, since an event of a pending transaction could
+// otherwise be dispatched after the later events. The events which are being dispatched by a concurrent call are skipped.
+// Returns the number of dispatched events.
+func (m *GormDeliveryRepository) Enqueue(ctx context.Context, limit int) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_delivery", "enqueue"}, time.Now())
+	var count int
+	err := m.db.Raw(`WITH events AS (
+			UPDATE outbox_event SET dispatched_at = now()
+			WHERE event_id IN (
+				SELECT event_id FROM outbox_event WHERE dispatched_at IS NULL AND `+clusterrepository.OutboxEventSettled+`
+				ORDER BY txid, event_id LIMIT ? FOR UPDATE SKIP LOCKED)
+			RETURNING event_id, audit_id, created_at
+		), deliveries AS (
+			INSERT INTO webhook_delivery (subscription_id, event_id)
+			SELECT s.subscription_id, e.event_id
+			FROM events e
+			JOIN audit_log a ON a.audit_id = e.audit_id
+			JOIN webhook_subscription s ON s.created_at <= e.created_at
+				AND (s.event_types IS NULL OR cardinality(s.event_types) = 0 OR a.operation = ANY(s.event_types))
+			RETURNING delivery_id
+		)
+		SELECT count(*) FROM events`, limit).Row().Scan(&count)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to dispatch the outbox events")
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
+
+// Load returns the delivery with the given ID
+func (m *GormDeliveryRepository) Load(ctx context.Context, id uuid.UUID) (*Delivery, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_delivery", "load"}, time.Now())
+	var native Delivery
+	err := m.db.Table(m.TableName()).Where("delivery_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("delivery", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// ListDue returns the (at most `limit`) pending deliveries whose next attempt is due, in the order of the positions of
+// their events in the outbox.
+// A delivery which follows a pending delivery of the same subscription whose next attempt is not due yet is not
+// returned, so that the events are delivered to each subscription in order.
+func (m *GormDeliveryRepository) ListDue(ctx context.Context, limit int) ([]Delivery, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_delivery", "list_due"}, time.Now())
+	var deliveries []Delivery
+	err := m.db.Table(m.TableName()).
+		Select("webhook_delivery.*").
+		Joins("JOIN outbox_event e ON e.event_id = webhook_delivery.event_id").
+		Where("webhook_delivery.state = ? AND webhook_delivery.next_attempt_at <= now()", DeliveryStatePending).
+		Where(`NOT EXISTS (SELECT 1 FROM webhook_delivery previous JOIN outbox_event pe ON pe.event_id = previous.event_id
+			WHERE previous.subscription_id = webhook_delivery.subscription_id AND (pe.txid, pe.event_id) < (e.txid, e.event_id)
+			AND previous.state = ? AND previous.next_attempt_at > now())`, DeliveryStatePending).
+		Order("e.txid, e.event_id, webhook_delivery.subscription_id").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return deliveries, nil
+}
+
+// ListForSubscription returns the deliveries of the given subscription (in the given state, if specified),
+// in the order of their events
+func (m *GormDeliveryRepository) ListForSubscription(ctx context.Context, subscriptionID uuid.UUID, state *string) ([]Delivery, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_delivery", "list_for_subscription"}, time.Now())
+	db := m.db.Table(m.TableName()).Where("subscription_id = ?", subscriptionID)
+	if state != nil {
+		db = db.Where("state = ?", *state)
+	}
+	var deliveries []Delivery
+	err := db.Order("event_id").Find(&deliveries).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return deliveries, nil
+}
+
+// Update records the outcome of an attempt of the given delivery
+func (m *GormDeliveryRepository) Update(ctx context.Context, d *Delivery) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_delivery", "update"}, time.Now())
+	result := m.db.Model(&Delivery{}).Where("delivery_id = ?", d.ID).Updates(map[string]interface{}{
+		"state":           d.State,
+		"attempts":        d.Attempts,
+		"next_attempt_at": d.NextAttemptAt,
+		"last_error":      d.LastError,
+		"delivered_at":    d.DeliveredAt,
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"delivery_id": d.ID.String(),
+			"err":         result.Error,
+		}, "unable to update the webhook delivery")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("delivery", d.ID.String())
+	}
+	return nil
+}
+
+// Redeliver moves the given dead delivery back to the pending ones, so that it is attempted again as soon as possible.
+// Returns a `DataConflictError` if the delivery is not dead.
+func (m *GormDeliveryRepository) Redeliver(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_delivery", "redeliver"}, time.Now())
+	result := m.db.Model(&Delivery{}).Where("delivery_id = ? AND state = ?", id, DeliveryStateDead).Updates(map[string]interface{}{
+		"state":           DeliveryStatePending,
+		"attempts":        0,
+		"next_attempt_at": gorm.Expr("now()"),
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"delivery_id": id.String(),
+			"err":         result.Error,
+		}, "unable to redeliver the webhook delivery")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		d, err := m.Load(ctx, id)
+		if err != nil {
+			return err
+		}
+		return errors.NewDataConflictError(fmt.Sprintf("delivery with id '%s' is %s, only the dead deliveries can be redelivered", id.String(), d.State))
+	}
+	log.Info(ctx, map[string]interface{}{
+		"delivery_id": id.String(),
+	}, "webhook delivery rescheduled")
+	return nil
+}
+
+// Purge removes the deliveries which succeeded before the given time
+func (m *GormDeliveryRepository) Purge(ctx context.Context, before time.Time) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_delivery", "purge"}, time.Now())
+	err := m.db.Exec("DELETE FROM webhook_delivery WHERE state = ? AND delivered_at < ?", DeliveryStateDelivered, before).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"before": before.String(),
+			"err":    err,
+		}, "unable to purge the webhook deliveries")
+		return errs.WithStack(err)
+	}
+	return nil
+}
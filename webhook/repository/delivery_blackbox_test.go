@@ -0,0 +1,308 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	clusterrepository "github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	"github.com/fabric8-services/fabric8-cluster/test"
+	"github.com/fabric8-services/fabric8-cluster/webhook/repository"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type deliveryTestSuite struct {
+	gormtestsupport.DBTestSuite
+	repo repository.DeliveryRepository
+}
+
+func TestDelivery(t *testing.T) {
+	suite.Run(t, &deliveryTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *deliveryTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = s.Application.Deliveries()
+}
+
+func (s *deliveryTestSuite) TestEnqueue() {
+	// given
+	before := test.CreateSubscription(s.T(), s.DB, uuid.NewV4().String(), "https://tenant.example.com/webhooks")
+	updates := test.CreateSubscription(s.T(), s.DB, uuid.NewV4().String(), "https://idler.example.com/webhooks", "cluster.update")
+	c := test.CreateCluster(s.T(), s.DB)
+	after := test.CreateSubscription(s.T(), s.DB, uuid.NewV4().String(), "https://proxy.example.com/webhooks")
+	eventIDs := s.eventIDs(c.ClusterID)
+	require.Len(s.T(), eventIDs, 1)
+	// when
+	test.DispatchOutboxEvents(s.T(), s.DB)
+	// then
+	s.T().Run("subscription to all events", func(t *testing.T) {
+		deliveries, err := s.repo.ListForSubscription(context.Background(), before.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, eventIDs[0], deliveries[0].EventID)
+		assert.Equal(t, 0, deliveries[0].Attempts)
+	})
+
+	s.T().Run("subscription to other events", func(t *testing.T) {
+		deliveries, err := s.repo.ListForSubscription(context.Background(), updates.ID, nil)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+	})
+
+	s.T().Run("subscription created after the event", func(t *testing.T) {
+		deliveries, err := s.repo.ListForSubscription(context.Background(), after.ID, nil)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+	})
+
+	s.T().Run("events dispatched only once", func(t *testing.T) {
+		// when
+		test.DispatchOutboxEvents(t, s.DB)
+		// then
+		deliveries, err := s.repo.ListForSubscription(context.Background(), before.ID, nil)
+		require.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+	})
+}
+
+func (s *deliveryTestSuite) TestEnqueueSettledEvents() {
+	// given
+	subscription := test.CreateSubscription(s.T(), s.DB, uuid.NewV4().String(), "https://tenant.example.com/webhooks")
+	// an event of a transaction which is not committed yet
+	tx := s.DB.Begin()
+	pending := test.NewCluster()
+	err := clusterrepository.NewClusterRepository(tx).Create(context.Background(), &pending)
+	require.NoError(s.T(), err)
+	// and an event committed after it
+	committed := test.CreateCluster(s.T(), s.DB)
+
+	s.T().Run("events of pending transactions not dispatched", func(t *testing.T) {
+		// when
+		test.DispatchOutboxEvents(t, s.DB)
+		// then neither event is dispatched, so that the pending one is not delivered after the committed one
+		deliveries, err := s.repo.ListForSubscription(context.Background(), subscription.ID, nil)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+	})
+
+	s.T().Run("events dispatched once settled", func(t *testing.T) {
+		// given
+		require.NoError(t, tx.Commit().Error)
+		// when
+		test.DispatchOutboxEvents(t, s.DB)
+		// then
+		deliveries, err := s.repo.ListForSubscription(context.Background(), subscription.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		due, err := s.repo.ListDue(context.Background(), 1000)
+		require.NoError(t, err)
+		var eventIDs []int64
+		for _, d := range due {
+			if d.SubscriptionID == subscription.ID {
+				eventIDs = append(eventIDs, d.EventID)
+			}
+		}
+		// the event of the transaction which started first comes first
+		assert.Equal(t, append(s.eventIDs(pending.ClusterID), s.eventIDs(committed.ClusterID)...), eventIDs)
+	})
+}
+
+func (s *deliveryTestSuite) TestListDue() {
+	// given
+	subscription := test.CreateSubscription(s.T(), s.DB, uuid.NewV4().String(), "https://tenant.example.com/webhooks")
+	test.CreateCluster(s.T(), s.DB)
+	test.CreateCluster(s.T(), s.DB)
+	test.DispatchOutboxEvents(s.T(), s.DB)
+	deliveries, err := s.repo.ListForSubscription(context.Background(), subscription.ID, nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), deliveries, 2)
+
+	s.T().Run("next attempt not due", func(t *testing.T) {
+		// given the second delivery is postponed
+		deliveries[1].Attempts = 1
+		deliveries[1].LastError = "unexpected response status: 503 Service Unavailable"
+		deliveries[1].NextAttemptAt = time.Now().Add(time.Hour)
+		err = s.repo.Update(context.Background(), &deliveries[1])
+		require.NoError(t, err)
+		// when
+		due, err := s.repo.ListDue(context.Background(), 1000)
+		// then
+		require.NoError(t, err)
+		ids := deliveryIDs(due)
+		assert.Contains(t, ids, deliveries[0].ID)
+		assert.NotContains(t, ids, deliveries[1].ID)
+	})
+
+	s.T().Run("previous delivery not due", func(t *testing.T) {
+		// given the first delivery is postponed, and the second one is due
+		deliveries[0].Attempts = 1
+		deliveries[0].LastError = "unexpected response status: 503 Service Unavailable"
+		deliveries[0].NextAttemptAt = time.Now().Add(time.Hour)
+		err = s.repo.Update(context.Background(), &deliveries[0])
+		require.NoError(t, err)
+		deliveries[1].NextAttemptAt = time.Now().Add(-time.Minute)
+		err = s.repo.Update(context.Background(), &deliveries[1])
+		require.NoError(t, err)
+		// when
+		due, err := s.repo.ListDue(context.Background(), 1000)
+		// then the second delivery waits for the first one
+		require.NoError(t, err)
+		ids := deliveryIDs(due)
+		assert.NotContains(t, ids, deliveries[0].ID)
+		assert.NotContains(t, ids, deliveries[1].ID)
+	})
+
+	s.T().Run("previous delivery dead", func(t *testing.T) {
+		// given the first delivery is moved to the dead letters
+		deliveries[0].State = repository.DeliveryStateDead
+		err = s.repo.Update(context.Background(), &deliveries[0])
+		require.NoError(t, err)
+		// when
+		due, err := s.repo.ListDue(context.Background(), 1000)
+		// then
+		require.NoError(t, err)
+		assert.Contains(t, deliveryIDs(due), deliveries[1].ID)
+	})
+}
+
+func deliveryIDs(deliveries []repository.Delivery) []uuid.UUID {
+	ids := make([]uuid.UUID, len(deliveries))
+	for i, d := range deliveries {
+		ids[i] = d.ID
+	}
+	return ids
+}
+
+func (s *deliveryTestSuite) TestUpdate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		d := s.createDelivery(t)
+		now := time.Now()
+		d.State = repository.DeliveryStateDelivered
+		d.Attempts = 2
+		d.DeliveredAt = &now
+		// when
+		err := s.repo.Update(context.Background(), &d)
+		// then
+		require.NoError(t, err)
+		loaded, err := s.repo.Load(context.Background(), d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.DeliveryStateDelivered, loaded.State)
+		assert.Equal(t, 2, loaded.Attempts)
+		require.NotNil(t, loaded.DeliveredAt)
+		assert.Equal(t, now.Unix(), loaded.DeliveredAt.Unix())
+	})
+
+	s.T().Run("unknown", func(t *testing.T) {
+		// when
+		err := s.repo.Update(context.Background(), &repository.Delivery{ID: uuid.NewV4()})
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *deliveryTestSuite) TestRedeliver() {
+
+	s.T().Run("dead delivery", func(t *testing.T) {
+		// given
+		d := s.createDelivery(t)
+		d.State = repository.DeliveryStateDead
+		d.Attempts = 8
+		d.LastError = "unexpected response status: 503 Service Unavailable"
+		err := s.repo.Update(context.Background(), &d)
+		require.NoError(t, err)
+		// when
+		err = s.repo.Redeliver(context.Background(), d.ID)
+		// then
+		require.NoError(t, err)
+		loaded, err := s.repo.Load(context.Background(), d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.DeliveryStatePending, loaded.State)
+		assert.Equal(t, 0, loaded.Attempts)
+		assert.False(t, loaded.NextAttemptAt.After(time.Now()))
+	})
+
+	s.T().Run("pending delivery", func(t *testing.T) {
+		// given
+		d := s.createDelivery(t)
+		// when
+		err := s.repo.Redeliver(context.Background(), d.ID)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, err)
+	})
+
+	s.T().Run("unknown", func(t *testing.T) {
+		// when
+		err := s.repo.Redeliver(context.Background(), uuid.NewV4())
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *deliveryTestSuite) TestPurge() {
+	// given
+	delivered := s.createDelivery(s.T())
+	deliveredAt := time.Now().Add(-2 * time.Hour)
+	delivered.State = repository.DeliveryStateDelivered
+	delivered.DeliveredAt = &deliveredAt
+	err := s.repo.Update(context.Background(), &delivered)
+	require.NoError(s.T(), err)
+	recent := s.createDelivery(s.T())
+	recentAt := time.Now()
+	recent.State = repository.DeliveryStateDelivered
+	recent.DeliveredAt = &recentAt
+	err = s.repo.Update(context.Background(), &recent)
+	require.NoError(s.T(), err)
+	dead := s.createDelivery(s.T())
+	dead.State = repository.DeliveryStateDead
+	err = s.repo.Update(context.Background(), &dead)
+	require.NoError(s.T(), err)
+	// when
+	err = s.repo.Purge(context.Background(), time.Now().Add(-1*time.Hour))
+	// then
+	require.NoError(s.T(), err)
+	_, err = s.repo.Load(context.Background(), delivered.ID)
+	require.Error(s.T(), err)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+	_, err = s.repo.Load(context.Background(), recent.ID)
+	require.NoError(s.T(), err)
+	_, err = s.repo.Load(context.Background(), dead.ID)
+	require.NoError(s.T(), err)
+}
+
+// createDelivery returns a new pending delivery of the creation of a new cluster to a new subscription
+func (s *deliveryTestSuite) createDelivery(t *testing.T) repository.Delivery {
+	subscription := test.CreateSubscription(t, s.DB, uuid.NewV4().String(), "https://tenant.example.com/webhooks")
+	test.CreateCluster(t, s.DB)
+	test.DispatchOutboxEvents(t, s.DB)
+	deliveries, err := s.repo.ListForSubscription(context.Background(), subscription.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	return deliveries[0]
+}
+
+// eventIDs returns the IDs of the outbox events of the changes on the given cluster
+func (s *deliveryTestSuite) eventIDs(clusterID uuid.UUID) []int64 {
+	rows, err := s.DB.Raw(`SELECT e.event_id FROM outbox_event e JOIN audit_log a ON a.audit_id = e.audit_id
+		WHERE a.cluster_id = ? ORDER BY e.event_id`, clusterID).Rows()
+	require.NoError(s.T(), err)
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		require.NoError(s.T(), rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Subscription a subscription of another service to the cluster change events, which are delivered to its URL
+// and signed with its secret
+type Subscription struct {
+	// This is the primary key value
+	ID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:subscription_id"`
+	// The time of creation of the subscription
+	CreatedAt time.Time
+	// The time of the last update of the subscription
+	UpdatedAt time.Time
+	// The name of the service account which created the subscription
+	Owner string
+	// The URL to which the events are delivered
+	URL string
+	// The secret with which the events are signed (encrypted at rest, unless the key ID is empty)
+	Secret string
+	// The ID of the key with which the secret is encrypted, or empty if the secret is stored in clear
+	SecretKeyID string
+	// The types of the events to deliver (eg: `cluster.update`), or all the events if empty
+	EventTypes pq.StringArray `sql:"type:text[]"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (s Subscription) TableName() string {
+	return "webhook_subscription"
+}
+
+// SubscriptionRepository represents the storage interface.
+type SubscriptionRepository interface {
+	Create(ctx context.Context, s *Subscription) error
+	Load(ctx context.Context, id uuid.UUID) (*Subscription, error)
+	List(ctx context.Context, owner *string) ([]Subscription, error)
+	UpdateSecret(ctx context.Context, s *Subscription, previousKeyID string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// GormSubscriptionRepository is the implementation of the storage interface for Subscription.
+type GormSubscriptionRepository struct {
+	db *gorm.DB
+}
+
+// NewSubscriptionRepository creates a new storage type.
+func NewSubscriptionRepository(db *gorm.DB) SubscriptionRepository {
+	return &GormSubscriptionRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormSubscriptionRepository) TableName() string {
+	return "webhook_subscription"
+}
+
+// Create creates a new record.
+func (m *GormSubscriptionRepository) Create(ctx context.Context, s *Subscription) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_subscription", "create"}, time.Now())
+	if s.ID == uuid.Nil {
+		s.ID = uuid.NewV4()
+	}
+	err := m.db.Create(s).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"subscription_id": s.ID.String(),
+			"err":             err,
+		}, "unable to create the webhook subscription")
+		return errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"subscription_id": s.ID.String(),
+		"owner":           s.Owner,
+		"url":             s.URL,
+	}, "webhook subscription created")
+	return nil
+}
+
+// Load returns the subscription with the given ID
+func (m *GormSubscriptionRepository) Load(ctx context.Context, id uuid.UUID) (*Subscription, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_subscription", "load"}, time.Now())
+	var native Subscription
+	err := m.db.Table(m.TableName()).Where("subscription_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("subscription", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// List returns the subscriptions of the given owner (or all the subscriptions if no owner is given),
+// from the oldest to the newest
+func (m *GormSubscriptionRepository) List(ctx context.Context, owner *string) ([]Subscription, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_subscription", "list"}, time.Now())
+	db := m.db.Table(m.TableName())
+	if owner != nil {
+		db = db.Where("owner = ?", *owner)
+	}
+	var subscriptions []Subscription
+	err := db.Order("created_at, subscription_id").Find(&subscriptions).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return subscriptions, nil
+}
+
+// UpdateSecret replaces the (encrypted) secret of the subscription record with the one of the given subscription,
+// along with the ID of the key with which it is encrypted. The update is rejected with a `DataConflictError` if the
+// secret of the record is no longer encrypted with the key identified by `previousKeyID`.
+func (m *GormSubscriptionRepository) UpdateSecret(ctx context.Context, s *Subscription, previousKeyID string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_subscription", "update_secret"}, time.Now())
+	result := m.db.Model(&Subscription{}).Where("subscription_id = ? AND secret_key_id = ?", s.ID, previousKeyID).Updates(map[string]interface{}{
+		"secret":        s.Secret,
+		"secret_key_id": s.SecretKeyID,
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"subscription_id": s.ID.String(),
+			"err":             result.Error,
+		}, "unable to update the webhook subscription secret")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if _, err := m.Load(ctx, s.ID); err != nil {
+			return err
+		}
+		return errors.NewDataConflictError(fmt.Sprintf("secret of subscription '%s' is no longer encrypted with key '%s'", s.ID.String(), previousKeyID))
+	}
+	log.Info(ctx, map[string]interface{}{
+		"subscription_id": s.ID.String(),
+		"key_id":          s.SecretKeyID,
+	}, "webhook subscription secret updated")
+	return nil
+}
+
+// Delete removes the subscription with the given ID, along with its deliveries
+func (m *GormSubscriptionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webhook_subscription", "delete"}, time.Now())
+	result := m.db.Delete(&Subscription{ID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"subscription_id": id.String(),
+			"err":             result.Error,
+		}, "unable to delete the webhook subscription")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("subscription", id.String())
+	}
+	log.Info(ctx, map[string]interface{}{
+		"subscription_id": id.String(),
+	}, "webhook subscription deleted")
+	return nil
+}
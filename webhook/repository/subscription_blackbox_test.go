@@ -0,0 +1,166 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	"github.com/fabric8-services/fabric8-cluster/test"
+	"github.com/fabric8-services/fabric8-cluster/webhook/repository"
+	"github.com/fabric8-services/fabric8-common/errors"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type subscriptionTestSuite struct {
+	gormtestsupport.DBTestSuite
+	repo repository.SubscriptionRepository
+}
+
+func TestSubscription(t *testing.T) {
+	suite.Run(t, &subscriptionTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *subscriptionTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = s.Application.Subscriptions()
+}
+
+func (s *subscriptionTestSuite) TestCreateAndLoad() {
+	// given
+	subscription := repository.Subscription{
+		Owner:      uuid.NewV4().String(),
+		URL:        "https://tenant.example.com/webhooks",
+		Secret:     uuid.NewV4().String(),
+		EventTypes: []string{"cluster.create", "cluster.delete"},
+	}
+	// when
+	err := s.repo.Create(context.Background(), &subscription)
+	// then
+	require.NoError(s.T(), err)
+	require.NotEqual(s.T(), uuid.Nil, subscription.ID)
+	loaded, err := s.repo.Load(context.Background(), subscription.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), subscription.Owner, loaded.Owner)
+	assert.Equal(s.T(), subscription.URL, loaded.URL)
+	assert.Equal(s.T(), subscription.Secret, loaded.Secret)
+	assert.Equal(s.T(), []string{"cluster.create", "cluster.delete"}, []string(loaded.EventTypes))
+}
+
+func (s *subscriptionTestSuite) TestLoadUnknown() {
+	// given
+	id := uuid.NewV4()
+	// when
+	_, err := s.repo.Load(context.Background(), id)
+	// then
+	require.Error(s.T(), err)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *subscriptionTestSuite) TestList() {
+	// given
+	owner := uuid.NewV4().String()
+	s1 := test.CreateSubscription(s.T(), s.DB, owner, "https://tenant.example.com/webhooks/1")
+	s2 := test.CreateSubscription(s.T(), s.DB, owner, "https://tenant.example.com/webhooks/2", "cluster.update")
+	other := test.CreateSubscription(s.T(), s.DB, uuid.NewV4().String(), "https://idler.example.com/webhooks")
+
+	s.T().Run("by owner", func(t *testing.T) {
+		// when
+		subscriptions, err := s.repo.List(context.Background(), &owner)
+		// then
+		require.NoError(t, err)
+		require.Len(t, subscriptions, 2)
+		assert.Equal(t, s1.ID, subscriptions[0].ID)
+		assert.Equal(t, s2.ID, subscriptions[1].ID)
+	})
+
+	s.T().Run("all", func(t *testing.T) {
+		// when
+		subscriptions, err := s.repo.List(context.Background(), nil)
+		// then
+		require.NoError(t, err)
+		ids := []uuid.UUID{}
+		for _, subscription := range subscriptions {
+			ids = append(ids, subscription.ID)
+		}
+		assert.Contains(t, ids, s1.ID)
+		assert.Contains(t, ids, s2.ID)
+		assert.Contains(t, ids, other.ID)
+	})
+}
+
+func (s *subscriptionTestSuite) TestUpdateSecret() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		subscription := test.CreateSubscription(t, s.DB, uuid.NewV4().String(), "https://tenant.example.com/webhooks")
+		other := test.CreateSubscription(t, s.DB, uuid.NewV4().String(), "https://tenant.example.com/webhooks") // noise
+		updated := subscription
+		updated.URL = "https://ignored.example.com/webhooks"
+		updated.Secret = "encrypted-secret"
+		updated.SecretKeyID = "key-2"
+		// when
+		err := s.repo.UpdateSecret(context.Background(), &updated, "")
+		// then only the secret and the key ID were updated
+		require.NoError(t, err)
+		loaded, err := s.repo.Load(context.Background(), subscription.ID)
+		require.NoError(t, err)
+		assert.Equal(t, subscription.URL, loaded.URL)
+		assert.Equal(t, "encrypted-secret", loaded.Secret)
+		assert.Equal(t, "key-2", loaded.SecretKeyID)
+		loadedOther, err := s.repo.Load(context.Background(), other.ID)
+		require.NoError(t, err)
+		assert.Equal(t, other.Secret, loadedOther.Secret)
+		assert.Equal(t, "", loadedOther.SecretKeyID)
+	})
+
+	s.T().Run("conflict", func(t *testing.T) {
+		// given
+		subscription := test.CreateSubscription(t, s.DB, uuid.NewV4().String(), "https://tenant.example.com/webhooks")
+		updated := subscription
+		updated.Secret = "encrypted-secret"
+		updated.SecretKeyID = "key-2"
+		// when the secret is not encrypted with the expected key
+		err := s.repo.UpdateSecret(context.Background(), &updated, "key-1")
+		// then
+		test.AssertError(t, err, errors.DataConflictError{}, "secret of subscription '%s' is no longer encrypted with key 'key-1'", subscription.ID)
+		loaded, err := s.repo.Load(context.Background(), subscription.ID)
+		require.NoError(t, err)
+		assert.Equal(t, subscription.Secret, loaded.Secret)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// given
+		id := uuid.NewV4()
+		// when
+		err := s.repo.UpdateSecret(context.Background(), &repository.Subscription{ID: id, Secret: "encrypted-secret", SecretKeyID: "key-2"}, "")
+		// then
+		test.AssertError(t, err, errors.NotFoundError{}, "subscription with id '%s' not found", id)
+	})
+}
+
+func (s *subscriptionTestSuite) TestDelete() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		subscription := test.CreateSubscription(t, s.DB, uuid.NewV4().String(), "https://tenant.example.com/webhooks")
+		// when
+		err := s.repo.Delete(context.Background(), subscription.ID)
+		// then
+		require.NoError(t, err)
+		_, err = s.repo.Load(context.Background(), subscription.ID)
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("unknown", func(t *testing.T) {
+		// when
+		err := s.repo.Delete(context.Background(), uuid.NewV4())
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
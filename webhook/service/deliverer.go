@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/webhook/repository"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Deliverer delivers the cluster change events to the URLs of the webhook subscriptions
+type Deliverer struct {
+	client *http.Client
+}
+
+// NewDeliverer returns a new Deliverer whose requests time out after the given duration
+func NewDeliverer(timeout time.Duration) *Deliverer {
+	return &Deliverer{
+		client: &http.Client{
+			Timeout: timeout,
+			// the subscribers must respond on the URL of their subscription
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Deliver posts the given payload to the URL of the given subscription, along with the type of the event, the ID of
+// the delivery and the signature of the payload in the headers of the request.
+// Returns an error if the request failed or if the response status was not 2xx.
+func (d *Deliverer) Deliver(ctx context.Context, s repository.Subscription, deliveryID uuid.UUID, eventType string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(s.Secret, timestamp, payload))
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain (a reasonable part of) the body so that the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
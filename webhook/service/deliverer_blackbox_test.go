@@ -0,0 +1,129 @@
+package service_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/webhook/repository"
+	"github.com/fabric8-services/fabric8-cluster/webhook/service"
+	"github.com/fabric8-services/fabric8-common/resource"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	// given
+	payload := []byte(`{"id":1,"type":"cluster.create"}`)
+	signature := service.Sign("0123456789abcdef", 1546300800, payload)
+
+	t.Run("valid", func(t *testing.T) {
+		assert.Regexp(t, "^sha256=[0-9a-f]{64}$", signature)
+		assert.True(t, service.VerifySignature("0123456789abcdef", 1546300800, payload, signature))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.False(t, service.VerifySignature("fedcba9876543210", 1546300800, payload, signature), "other secret")
+		assert.False(t, service.VerifySignature("0123456789abcdef", 1546300801, payload, signature), "other timestamp")
+		assert.False(t, service.VerifySignature("0123456789abcdef", 1546300800, []byte(`{"id":2,"type":"cluster.create"}`), signature), "other payload")
+	})
+}
+
+func TestDeliverer(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	deliverer := service.NewDeliverer(100 * time.Millisecond)
+	payload := []byte(`{"id":1,"type":"cluster.create"}`)
+	newSubscription := func(url string) repository.Subscription {
+		return repository.Subscription{
+			ID:     uuid.NewV4(),
+			URL:    url,
+			Secret: "0123456789abcdef",
+		}
+	}
+
+	t.Run("delivered", func(t *testing.T) {
+		// given
+		var received *http.Request
+		var body []byte
+		subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			body, _ = ioutil.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer subscriber.Close()
+		deliveryID := uuid.NewV4()
+		// when
+		err := deliverer.Deliver(context.Background(), newSubscription(subscriber.URL), deliveryID, "cluster.create", payload)
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, received)
+		assert.Equal(t, http.MethodPost, received.Method)
+		assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+		assert.Equal(t, "cluster.create", received.Header.Get(service.EventHeader))
+		assert.Equal(t, deliveryID.String(), received.Header.Get(service.DeliveryHeader))
+		assert.Equal(t, payload, body)
+		timestamp, err := strconv.ParseInt(received.Header.Get(service.TimestampHeader), 10, 64)
+		require.NoError(t, err)
+		assert.True(t, service.VerifySignature("0123456789abcdef", timestamp, body, received.Header.Get(service.SignatureHeader)))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+
+		t.Run("server error", func(t *testing.T) {
+			// given
+			subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer subscriber.Close()
+			// when
+			err := deliverer.Deliver(context.Background(), newSubscription(subscriber.URL), uuid.NewV4(), "cluster.create", payload)
+			// then
+			require.Error(t, err)
+			assert.Equal(t, "unexpected response status: 503 Service Unavailable", err.Error())
+		})
+
+		t.Run("redirect", func(t *testing.T) {
+			// given
+			subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "https://login.example.com/", http.StatusFound)
+			}))
+			defer subscriber.Close()
+			// when
+			err := deliverer.Deliver(context.Background(), newSubscription(subscriber.URL), uuid.NewV4(), "cluster.create", payload)
+			// then
+			require.Error(t, err)
+			assert.Equal(t, "unexpected response status: 302 Found", err.Error())
+		})
+
+		t.Run("timeout", func(t *testing.T) {
+			// given
+			subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(500 * time.Millisecond)
+			}))
+			defer subscriber.Close()
+			// when
+			err := deliverer.Deliver(context.Background(), newSubscription(subscriber.URL), uuid.NewV4(), "cluster.create", payload)
+			// then
+			require.Error(t, err)
+		})
+
+		t.Run("unreachable", func(t *testing.T) {
+			// given
+			closed := httptest.NewServer(http.NotFoundHandler())
+			closed.Close()
+			// when
+			err := deliverer.Deliver(context.Background(), newSubscription(closed.URL), uuid.NewV4(), "cluster.create", payload)
+			// then
+			require.Error(t, err)
+		})
+	})
+}
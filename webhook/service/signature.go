@@ -0,0 +1,40 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	// EventHeader the header holding the type of the delivered event (eg: `cluster.update`)
+	EventHeader = "X-Fabric8-Cluster-Event"
+	// DeliveryHeader the header holding the ID of the delivery, which is the same for all its attempts
+	DeliveryHeader = "X-Fabric8-Cluster-Delivery"
+	// TimestampHeader the header holding the time of the attempt, in seconds since the epoch
+	TimestampHeader = "X-Fabric8-Cluster-Timestamp"
+	// SignatureHeader the header holding the signature of the delivered payload (see `Sign`)
+	SignatureHeader = "X-Fabric8-Cluster-Signature"
+
+	// signaturePrefix the prefix of the signatures, which identifies the algorithm
+	signaturePrefix = "sha256="
+)
+
+// Sign returns the signature of the given payload delivered at the given time (in seconds since the epoch):
+// the hex-encoded HMAC-SHA256 of the timestamp, a dot and the payload, keyed with the secret of the subscription
+// and prefixed with `sha256=`. Since the timestamp is signed along with the payload, the subscribers can reject
+// the replayed deliveries.
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature returns `true` if the given signature matches the payload delivered at the given time, for the
+// given secret. The comparison is made in constant time.
+func VerifySignature(secret string, timestamp int64, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signature))
+}
@@ -0,0 +1,489 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/application/service"
+	"github.com/fabric8-services/fabric8-cluster/application/service/base"
+	servicectx "github.com/fabric8-services/fabric8-cluster/application/service/context"
+	clusterrepository "github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	clusterservice "github.com/fabric8-services/fabric8-cluster/cluster/service"
+	"github.com/fabric8-services/fabric8-cluster/webhook/repository"
+	"github.com/fabric8-services/fabric8-common/auth"
+	"github.com/fabric8-services/fabric8-common/errors"
+	"github.com/fabric8-services/fabric8-common/log"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// dispatchBatchSize the maximum number of events dispatched, and of deliveries attempted, in a single round
+	dispatchBatchSize = 100
+	// maxDeliveryBackoff the maximum delay between two attempts of a failed delivery
+	maxDeliveryBackoff = time.Hour
+	// minSecretLength the minimum length of the secret of a subscription
+	minSecretLength = 16
+)
+
+// EventTypes the types of the events which can be delivered to the webhook subscriptions
+var EventTypes = []string{
+	clusterrepository.AuditOperationClusterCreate,
+	clusterrepository.AuditOperationClusterUpdate,
+	clusterrepository.AuditOperationClusterDelete,
+	clusterrepository.AuditOperationIdentityLink,
+	clusterrepository.AuditOperationIdentityUnlink,
+}
+
+// subscribers the service accounts allowed to subscribe to the cluster change events
+var subscribers = []string{auth.OsoProxy, auth.Tenant, auth.JenkinsIdler, auth.JenkinsProxy, auth.Auth, auth.ToolChainOperator}
+
+// Event the payload delivered to the webhook subscriptions, for a change on a cluster or on an identity/cluster relationship
+type Event struct {
+	// The ID of the event. The IDs are sequential, in the order of the changes
+	ID int64 `json:"id"`
+	// The type of the event (eg: `cluster.update`)
+	Type string `json:"type"`
+	// The time of the change
+	Time time.Time `json:"time"`
+	// The ID of the cluster
+	ClusterID uuid.UUID `json:"cluster-id"`
+	// The ID of the identity, for the changes on an identity/cluster relationship
+	IdentityID *uuid.UUID `json:"identity-id,omitempty"`
+	// The name of the service account (or the ID of the identity) on behalf of which the change was performed
+	Actor string `json:"actor"`
+	// The ID of the request in which the change was performed, if any
+	RequestID string `json:"request-id,omitempty"`
+	// The changed attributes of the cluster, with the secrets redacted
+	Changes clusterrepository.AuditChanges `json:"changes,omitempty"`
+}
+
+type webhookService struct {
+	base.BaseService
+	config    Configuration
+	encryptor clusterservice.Encryptor
+}
+
+// Configuration the interface for the configuration used by the webhook service
+type Configuration interface {
+	GetWebhookDispatchInterval() time.Duration
+	GetWebhookDeliveryTimeout() time.Duration
+	GetWebhookDeliveryMaxAttempts() int
+	GetWebhookDeliveryBackoff() time.Duration
+	GetWebhookDeliveryRetention() time.Duration
+	GetClusterEncryptionKeys() map[string][]byte
+	GetClusterEncryptionKeyID() string
+}
+
+// NewWebhookService creates a new webhook service with the default implementation.
+// If no encryptor is given, the secrets of the subscriptions are encrypted with the keys of the cluster secrets from the configuration.
+func NewWebhookService(context servicectx.ServiceContext, config Configuration, encryptor clusterservice.Encryptor) service.WebhookService {
+	return &webhookService{
+		BaseService: base.NewBaseService(context),
+		config:      config,
+		encryptor:   encryptor,
+	}
+}
+
+// CreateSubscription creates a subscription to the cluster change events on behalf of the service account of the
+// request, after validating its URL and event types. If the subscription has no secret, a random one is generated.
+// The secret is encrypted in the DB, but the given subscription keeps it in clear.
+// This method is allowed for the following service accounts:
+// - OsoProxy
+// - Tenant
+// - JenkinsIdler
+// - JenkinsProxy
+// - Auth
+// - ToolChainOperator
+func (s webhookService) CreateSubscription(ctx context.Context, subscription *repository.Subscription) error {
+	if !auth.IsSpecificServiceAccount(ctx, subscribers...) {
+		return errors.NewUnauthorizedError("account not authorized to manage webhook subscriptions")
+	}
+	u, err := url.Parse(subscription.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf("invalid webhook URL: '%s'", subscription.URL))
+	}
+	for _, eventType := range subscription.EventTypes {
+		if !isEventType(eventType) {
+			return errors.NewBadParameterErrorFromString(fmt.Sprintf("unknown event type: '%s'", eventType))
+		}
+	}
+	if subscription.Secret == "" {
+		if subscription.Secret, err = generateSecret(); err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+	} else if len(subscription.Secret) < minSecretLength {
+		return errors.NewBadParameterErrorFromString(fmt.Sprintf("the secret must be at least %d characters long", minSecretLength))
+	}
+	subscription.Owner = clusterrepository.Actor(ctx)
+	encrypted := *subscription
+	if err := s.encryptSecret(&encrypted); err != nil {
+		return err
+	}
+	err = s.ExecuteInTransaction(func() error {
+		return s.Repositories().Subscriptions().Create(ctx, &encrypted)
+	})
+	if err != nil {
+		return err
+	}
+	subscription.ID = encrypted.ID
+	subscription.CreatedAt = encrypted.CreatedAt
+	subscription.UpdatedAt = encrypted.UpdatedAt
+	return nil
+}
+
+// secretEncryptor returns the encryptor given to the service, or a new one using the keys of the cluster secrets from the configuration
+func (s webhookService) secretEncryptor() (clusterservice.Encryptor, error) {
+	if s.encryptor != nil {
+		return s.encryptor, nil
+	}
+	encryptor, err := clusterservice.NewEncryptor(s.config.GetClusterEncryptionKeys(), s.config.GetClusterEncryptionKeyID())
+	if err != nil {
+		return nil, errors.NewInternalErrorFromString(fmt.Sprintf("unable to initialize the encryption of the subscription secrets: %v", err))
+	}
+	return encryptor, nil
+}
+
+// encryptSecret encrypts the secret of the given subscription (which is in clear) with the current key
+func (s webhookService) encryptSecret(subscription *repository.Subscription) error {
+	encryptor, err := s.secretEncryptor()
+	if err != nil {
+		return err
+	}
+	if subscription.Secret, err = encryptor.Encrypt(subscription.Secret); err != nil {
+		return errors.NewInternalErrorFromString(fmt.Sprintf("unable to encrypt the secret of the subscription: %v", err))
+	}
+	subscription.SecretKeyID = encryptor.KeyID()
+	return nil
+}
+
+// decryptSecret decrypts the secret of the given subscription, unless it is stored in clear
+func (s webhookService) decryptSecret(subscription *repository.Subscription) error {
+	if subscription.SecretKeyID == "" {
+		return nil
+	}
+	encryptor, err := s.secretEncryptor()
+	if err != nil {
+		return err
+	}
+	if subscription.Secret, err = encryptor.Decrypt(subscription.SecretKeyID, subscription.Secret); err != nil {
+		return errors.NewInternalErrorFromString(fmt.Sprintf("unable to decrypt the secret of subscription '%s': %v", subscription.ID, err))
+	}
+	subscription.SecretKeyID = ""
+	return nil
+}
+
+// ListSubscriptions returns the subscriptions of the service account of the request (all the subscriptions for
+// the ToolChainOperator service account)
+func (s webhookService) ListSubscriptions(ctx context.Context) ([]repository.Subscription, error) {
+	if !auth.IsSpecificServiceAccount(ctx, subscribers...) {
+		return nil, errors.NewUnauthorizedError("account not authorized to manage webhook subscriptions")
+	}
+	var owner *string
+	if !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
+		actor := clusterrepository.Actor(ctx)
+		owner = &actor
+	}
+	return s.Repositories().Subscriptions().List(ctx, owner)
+}
+
+// LoadSubscription returns the subscription with the given ID, if it belongs to the service account of the request
+// (or if the request was made by the ToolChainOperator service account)
+func (s webhookService) LoadSubscription(ctx context.Context, subscriptionID uuid.UUID) (*repository.Subscription, error) {
+	if !auth.IsSpecificServiceAccount(ctx, subscribers...) {
+		return nil, errors.NewUnauthorizedError("account not authorized to manage webhook subscriptions")
+	}
+	return s.loadSubscription(ctx, subscriptionID)
+}
+
+// loadSubscription returns the subscription with the given ID, or a `NotFoundError` if it belongs to another service
+// account than the one of the request (unless the request was made by the ToolChainOperator service account)
+func (s webhookService) loadSubscription(ctx context.Context, subscriptionID uuid.UUID) (*repository.Subscription, error) {
+	subscription, err := s.Repositories().Subscriptions().Load(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Owner != clusterrepository.Actor(ctx) && !auth.IsSpecificServiceAccount(ctx, auth.ToolChainOperator) {
+		return nil, errors.NewNotFoundError("subscription", subscriptionID.String())
+	}
+	return subscription, nil
+}
+
+// DeleteSubscription deletes the subscription with the given ID, along with its pending and dead deliveries
+func (s webhookService) DeleteSubscription(ctx context.Context, subscriptionID uuid.UUID) error {
+	if !auth.IsSpecificServiceAccount(ctx, subscribers...) {
+		return errors.NewUnauthorizedError("account not authorized to manage webhook subscriptions")
+	}
+	return s.ExecuteInTransaction(func() error {
+		if _, err := s.loadSubscription(ctx, subscriptionID); err != nil {
+			return err
+		}
+		return s.Repositories().Subscriptions().Delete(ctx, subscriptionID)
+	})
+}
+
+// ListDeliveries returns the deliveries of the subscription with the given ID (in the given state, if specified).
+// The dead deliveries are the dead letters of the subscription.
+func (s webhookService) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, state *string) ([]repository.Delivery, error) {
+	if !auth.IsSpecificServiceAccount(ctx, subscribers...) {
+		return nil, errors.NewUnauthorizedError("account not authorized to manage webhook subscriptions")
+	}
+	if _, err := s.loadSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	return s.Repositories().Deliveries().ListForSubscription(ctx, subscriptionID, state)
+}
+
+// Redeliver moves the given dead delivery of the given subscription back to the pending ones, so that it is attempted
+// again during the next dispatch. Returns the rescheduled delivery.
+func (s webhookService) Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*repository.Delivery, error) {
+	if !auth.IsSpecificServiceAccount(ctx, subscribers...) {
+		return nil, errors.NewUnauthorizedError("account not authorized to manage webhook subscriptions")
+	}
+	var delivery *repository.Delivery
+	err := s.ExecuteInTransaction(func() error {
+		if _, err := s.loadSubscription(ctx, subscriptionID); err != nil {
+			return err
+		}
+		d, err := s.Repositories().Deliveries().Load(ctx, deliveryID)
+		if err != nil {
+			return err
+		}
+		if d.SubscriptionID != subscriptionID {
+			return errors.NewNotFoundError("delivery", deliveryID.String())
+		}
+		if err := s.Repositories().Deliveries().Redeliver(ctx, deliveryID); err != nil {
+			return err
+		}
+		delivery, err = s.Repositories().Deliveries().Load(ctx, deliveryID)
+		return err
+	})
+	return delivery, err
+}
+
+// DispatchEvents dispatches the new outbox events to the webhook subscriptions, then attempts the pending deliveries
+// whose next attempt is due. The deliveries of a subscription are attempted in the order of their events, and the
+// subscriptions are processed in parallel until the dispatch times out. After a failed delivery, the next deliveries
+// of its subscription are postponed until it succeeds, so that the events are always delivered in order.
+// A failed delivery is attempted again after a delay which doubles after each attempt, until it is moved to the
+// dead letters of its subscription after too many attempts.
+// Finally, the deliveries which succeeded before the retention period are removed, along with the outbox events
+// which were dispatched before then and which have no remaining delivery.
+func (s webhookService) DispatchEvents(ctx context.Context) error {
+	count, err := s.Repositories().Deliveries().Enqueue(ctx, dispatchBatchSize)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.Debug(ctx, map[string]interface{}{
+			"count": count,
+		}, "outbox events dispatched")
+	}
+	deliveries, err := s.Repositories().Deliveries().ListDue(ctx, dispatchBatchSize)
+	if err != nil {
+		return err
+	}
+	// group the deliveries by subscription, in the order of their events
+	var subscriptions []*repository.Subscription
+	bySubscription := map[uuid.UUID][]int{}
+	payloads := make([][]byte, len(deliveries))
+	events := map[int64]*Event{}
+	undecryptable := map[uuid.UUID]bool{}
+	for i, d := range deliveries {
+		if undecryptable[d.SubscriptionID] {
+			continue
+		}
+		if _, found := bySubscription[d.SubscriptionID]; !found {
+			subscription, err := s.Repositories().Subscriptions().Load(ctx, d.SubscriptionID)
+			if err != nil {
+				return err
+			}
+			// keep on dispatching to the other subscriptions if the secret of this one cannot be decrypted
+			// (eg: its key was removed from the configuration before it was re-encrypted)
+			if err := s.decryptSecret(subscription); err != nil {
+				log.Error(ctx, map[string]interface{}{
+					"subscription_id": d.SubscriptionID.String(),
+					"err":             err,
+				}, "skipping the deliveries of the webhook subscription")
+				undecryptable[d.SubscriptionID] = true
+				continue
+			}
+			subscriptions = append(subscriptions, subscription)
+		}
+		bySubscription[d.SubscriptionID] = append(bySubscription[d.SubscriptionID], i)
+		e, found := events[d.EventID]
+		if !found {
+			if e, err = s.loadEvent(ctx, d.EventID); err != nil {
+				return err
+			}
+			events[d.EventID] = e
+		}
+		if payloads[i], err = json.Marshal(e); err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+	}
+	// a slow subscriber cannot hold the deliveries to the other subscribers beyond the timeout of the dispatch
+	dispatchCtx, cancel := context.WithTimeout(ctx, s.config.GetWebhookDispatchTimeout())
+	defer cancel()
+	deliverer := NewDeliverer(s.config.GetWebhookDeliveryTimeout())
+	results := make([]error, len(deliveries))
+	attempted := make([]bool, len(deliveries))
+	var wg sync.WaitGroup
+	for _, subscription := range subscriptions {
+		wg.Add(1)
+		go func(subscription repository.Subscription) {
+			defer wg.Done()
+			for _, i := range bySubscription[subscription.ID] {
+				if dispatchCtx.Err() != nil {
+					return
+				}
+				d := deliveries[i]
+				results[i] = deliverer.Deliver(dispatchCtx, subscription, d.ID, events[d.EventID].Type, payloads[i])
+				if results[i] == nil {
+					attempted[i] = true
+					continue
+				}
+				// an attempt interrupted by the timeout of the dispatch is not counted
+				attempted[i] = dispatchCtx.Err() == nil
+				// postpone the next deliveries of the subscription
+				return
+			}
+		}(*subscription)
+	}
+	wg.Wait()
+	for i := range deliveries {
+		if !attempted[i] {
+			continue
+		}
+		d := &deliveries[i]
+		s.recordAttempt(d, results[i], time.Now())
+		if d.State == repository.DeliveryStateDead {
+			log.Warn(ctx, map[string]interface{}{
+				"delivery_id":     d.ID.String(),
+				"subscription_id": d.SubscriptionID.String(),
+				"event_id":        d.EventID,
+				"attempts":        d.Attempts,
+				"last_error":      d.LastError,
+			}, "webhook delivery moved to the dead letters")
+		}
+		// keep on recording the outcome of the other deliveries if something wrong happened
+		// (eg: the subscription was deleted in the mean time)
+		if err := s.Repositories().Deliveries().Update(ctx, d); err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"delivery_id": d.ID.String(),
+				"err":         err,
+			}, "unable to record the outcome of the webhook delivery")
+		}
+	}
+	before := time.Now().Add(-s.config.GetWebhookDeliveryRetention())
+	if err := s.Repositories().Deliveries().Purge(ctx, before); err != nil {
+		return err
+	}
+	return s.Repositories().Outbox().Purge(ctx, before)
+}
+
+// recordAttempt updates the given delivery with the outcome of an attempt made at the given time
+func (s webhookService) recordAttempt(d *repository.Delivery, err error, now time.Time) {
+	d.Attempts++
+	if err == nil {
+		d.State = repository.DeliveryStateDelivered
+		d.DeliveredAt = &now
+		return
+	}
+	d.LastError = err.Error()
+	if d.Attempts >= s.config.GetWebhookDeliveryMaxAttempts() {
+		d.State = repository.DeliveryStateDead
+		return
+	}
+	backoff := s.config.GetWebhookDeliveryBackoff()
+	for i := 1; i < d.Attempts && backoff < maxDeliveryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDeliveryBackoff {
+		backoff = maxDeliveryBackoff
+	}
+	d.NextAttemptAt = now.Add(backoff)
+}
+
+// loadEvent returns the payload of the outbox event with the given ID, built from the audit entry of the change
+func (s webhookService) loadEvent(ctx context.Context, eventID int64) (*Event, error) {
+	e, err := s.Repositories().Outbox().Load(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.Repositories().Audit().Load(ctx, e.AuditID)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{
+		ID:         e.ID,
+		Type:       entry.Operation,
+		Time:       entry.CreatedAt,
+		ClusterID:  entry.ClusterID,
+		IdentityID: entry.IdentityID,
+		Actor:      entry.Actor,
+		RequestID:  entry.RequestID,
+		Changes:    entry.Changes,
+	}, nil
+}
+
+// InitializeDispatcher starts a background routine which periodically dispatches the cluster change events to the
+// webhook subscriptions, unless the dispatch interval in the configuration is not positive.
+// Returns the function to call to stop the routine.
+func (s webhookService) InitializeDispatcher() func() {
+	interval := s.config.GetWebhookDispatchInterval()
+	if interval <= 0 {
+		log.Warn(context.Background(), map[string]interface{}{}, "webhooks dispatcher disabled")
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// only the leader dispatches the events
+			if !s.Services().ClusterService().IsLeader() {
+				log.Debug(ctx, map[string]interface{}{}, "not the leader, skipping the dispatch of the webhook events")
+			} else if err := s.DispatchEvents(ctx); err != nil {
+				// Do not crash. Log the error and try again later
+				log.Error(ctx, map[string]interface{}{
+					"err": err,
+				}, "unable to dispatch the webhook events")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Info(context.Background(), map[string]interface{}{
+		"interval": interval.String(),
+	}, "webhooks dispatcher initialized")
+	return cancel
+}
+
+// isEventType returns `true` if the given event type can be delivered to the webhook subscriptions
+func isEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// generateSecret returns a new random secret for a subscription
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
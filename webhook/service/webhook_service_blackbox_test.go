@@ -0,0 +1,588 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-cluster/cluster/repository"
+	"github.com/fabric8-services/fabric8-cluster/gormtestsupport"
+	"github.com/fabric8-services/fabric8-cluster/test"
+	webhookrepository "github.com/fabric8-services/fabric8-cluster/webhook/repository"
+	"github.com/fabric8-services/fabric8-cluster/webhook/service"
+	"github.com/fabric8-services/fabric8-common/auth"
+	"github.com/fabric8-services/fabric8-common/errors"
+	testsupport "github.com/fabric8-services/fabric8-common/test"
+	authtestsupport "github.com/fabric8-services/fabric8-common/test/auth"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWebhookService(t *testing.T) {
+	suite.Run(t, &WebhookServiceTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type WebhookServiceTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *WebhookServiceTestSuite) TestCreateSubscription() {
+
+	s.T().Run("ok", func(t *testing.T) {
+
+		t.Run("generated secret", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.Tenant)
+			require.NoError(t, err)
+			subscription := &webhookrepository.Subscription{
+				URL:        "https://tenant.example.com/webhooks",
+				EventTypes: []string{repository.AuditOperationClusterUpdate},
+			}
+			// when
+			err = s.Application.WebhookService().CreateSubscription(ctx, subscription)
+			// then
+			require.NoError(t, err)
+			assert.NotEqual(t, uuid.Nil, subscription.ID)
+			assert.Equal(t, auth.Tenant, subscription.Owner)
+			assert.Len(t, subscription.Secret, 64)
+		})
+
+		t.Run("provided secret", func(t *testing.T) {
+			// given
+			ctx, err := createContext(auth.JenkinsIdler)
+			require.NoError(t, err)
+			subscription := &webhookrepository.Subscription{
+				URL:    "http://idler.example.com/webhooks",
+				Secret: "0123456789abcdef",
+			}
+			// when
+			err = s.Application.WebhookService().CreateSubscription(ctx, subscription)
+			// then
+			require.NoError(t, err)
+			// the caller keeps the secret in clear
+			assert.Equal(t, "0123456789abcdef", subscription.Secret)
+			// but it is encrypted in the DB
+			loaded, err := s.Application.Subscriptions().Load(context.Background(), subscription.ID)
+			require.NoError(t, err)
+			assert.Equal(t, auth.JenkinsIdler, loaded.Owner)
+			assert.Equal(t, "dev", loaded.SecretKeyID)
+			assert.NotEqual(t, "0123456789abcdef", loaded.Secret)
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+		ctx, err := createContext(auth.Tenant)
+		require.NoError(t, err)
+
+		t.Run("invalid URL", func(t *testing.T) {
+			for _, url := range []string{"", "/webhooks", "ftp://tenant.example.com/webhooks", "https://"} {
+				t.Run(url, func(t *testing.T) {
+					// when
+					err := s.Application.WebhookService().CreateSubscription(ctx, &webhookrepository.Subscription{URL: url})
+					// then
+					testsupport.AssertError(t, err, errors.BadParameterError{}, "invalid webhook URL: '%s'", url)
+				})
+			}
+		})
+
+		t.Run("unknown event type", func(t *testing.T) {
+			// when
+			err := s.Application.WebhookService().CreateSubscription(ctx, &webhookrepository.Subscription{
+				URL:        "https://tenant.example.com/webhooks",
+				EventTypes: []string{repository.AuditOperationClusterCreate, "cluster.explode"},
+			})
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "unknown event type: 'cluster.explode'")
+		})
+
+		t.Run("short secret", func(t *testing.T) {
+			// when
+			err := s.Application.WebhookService().CreateSubscription(ctx, &webhookrepository.Subscription{
+				URL:    "https://tenant.example.com/webhooks",
+				Secret: "secret",
+			})
+			// then
+			testsupport.AssertError(t, err, errors.BadParameterError{}, "the secret must be at least 16 characters long")
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			ctx, err := createContext("other")
+			require.NoError(t, err)
+			// when
+			err = s.Application.WebhookService().CreateSubscription(ctx, &webhookrepository.Subscription{
+				URL: "https://tenant.example.com/webhooks",
+			})
+			// then
+			testsupport.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to manage webhook subscriptions")
+		})
+	})
+}
+
+func (s *WebhookServiceTestSuite) TestManageSubscriptions() {
+	// given
+	tenantCtx, err := createContext(auth.Tenant)
+	require.NoError(s.T(), err)
+	idlerCtx, err := createContext(auth.JenkinsIdler)
+	require.NoError(s.T(), err)
+	operatorCtx, err := createContext(auth.ToolChainOperator)
+	require.NoError(s.T(), err)
+	tenantSubscription := test.CreateSubscription(s.T(), s.DB, auth.Tenant, "https://tenant.example.com/webhooks")
+	idlerSubscription := test.CreateSubscription(s.T(), s.DB, auth.JenkinsIdler, "https://idler.example.com/webhooks")
+
+	s.T().Run("list", func(t *testing.T) {
+
+		t.Run("own subscriptions", func(t *testing.T) {
+			// when
+			subscriptions, err := s.Application.WebhookService().ListSubscriptions(tenantCtx)
+			// then
+			require.NoError(t, err)
+			ids := subscriptionIDs(subscriptions)
+			assert.Contains(t, ids, tenantSubscription.ID)
+			assert.NotContains(t, ids, idlerSubscription.ID)
+		})
+
+		t.Run("all subscriptions", func(t *testing.T) {
+			// when
+			subscriptions, err := s.Application.WebhookService().ListSubscriptions(operatorCtx)
+			// then
+			require.NoError(t, err)
+			ids := subscriptionIDs(subscriptions)
+			assert.Contains(t, ids, tenantSubscription.ID)
+			assert.Contains(t, ids, idlerSubscription.ID)
+		})
+
+		t.Run("unauthorized", func(t *testing.T) {
+			// given
+			ctx, err := createContext("other")
+			require.NoError(t, err)
+			// when
+			_, err = s.Application.WebhookService().ListSubscriptions(ctx)
+			// then
+			testsupport.AssertError(t, err, errors.UnauthorizedError{}, "account not authorized to manage webhook subscriptions")
+		})
+	})
+
+	s.T().Run("load", func(t *testing.T) {
+
+		t.Run("own subscription", func(t *testing.T) {
+			// when
+			subscription, err := s.Application.WebhookService().LoadSubscription(tenantCtx, tenantSubscription.ID)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, tenantSubscription.URL, subscription.URL)
+		})
+
+		t.Run("subscription of another service account", func(t *testing.T) {
+			// when
+			_, err := s.Application.WebhookService().LoadSubscription(tenantCtx, idlerSubscription.ID)
+			// then
+			testsupport.AssertError(t, err, errors.NotFoundError{}, "subscription with id '%s' not found", idlerSubscription.ID)
+			// when
+			subscription, err := s.Application.WebhookService().LoadSubscription(operatorCtx, idlerSubscription.ID)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, idlerSubscription.URL, subscription.URL)
+		})
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+
+		t.Run("subscription of another service account", func(t *testing.T) {
+			// when
+			err := s.Application.WebhookService().DeleteSubscription(tenantCtx, idlerSubscription.ID)
+			// then
+			testsupport.AssertError(t, err, errors.NotFoundError{}, "subscription with id '%s' not found", idlerSubscription.ID)
+		})
+
+		t.Run("own subscription", func(t *testing.T) {
+			// when
+			err := s.Application.WebhookService().DeleteSubscription(idlerCtx, idlerSubscription.ID)
+			// then
+			require.NoError(t, err)
+			_, err = s.Application.Subscriptions().Load(context.Background(), idlerSubscription.ID)
+			testsupport.AssertError(t, err, errors.NotFoundError{}, "subscription with id '%s' not found", idlerSubscription.ID)
+		})
+	})
+}
+
+func (s *WebhookServiceTestSuite) TestDispatchEvents() {
+	existingMaxAttempts := os.Getenv("F8_WEBHOOK_DELIVERY_MAX_ATTEMPTS")
+	existingBackoff := os.Getenv("F8_WEBHOOK_DELIVERY_BACKOFF")
+	existingTimeout := os.Getenv("F8_WEBHOOK_DELIVERY_TIMEOUT")
+	defer func() {
+		os.Setenv("F8_WEBHOOK_DELIVERY_MAX_ATTEMPTS", existingMaxAttempts)
+		os.Setenv("F8_WEBHOOK_DELIVERY_BACKOFF", existingBackoff)
+		os.Setenv("F8_WEBHOOK_DELIVERY_TIMEOUT", existingTimeout)
+	}()
+	os.Setenv("F8_WEBHOOK_DELIVERY_MAX_ATTEMPTS", "2")
+	os.Setenv("F8_WEBHOOK_DELIVERY_BACKOFF", "200ms")
+	os.Setenv("F8_WEBHOOK_DELIVERY_TIMEOUT", "1s")
+
+	s.T().Run("delivered", func(t *testing.T) {
+		// given
+		subscriber := newSubscriber(t, "0123456789abcdef")
+		defer subscriber.Close()
+		ctx, err := createContext(auth.Tenant)
+		require.NoError(t, err)
+		subscription := &webhookrepository.Subscription{
+			URL:    subscriber.URL,
+			Secret: "0123456789abcdef",
+		}
+		err = s.Application.WebhookService().CreateSubscription(ctx, subscription)
+		require.NoError(t, err)
+		c := test.CreateCluster(t, s.DB)
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		// when
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then
+		require.NoError(t, err)
+		events := subscriber.Events(c.ClusterID)
+		require.Len(t, events, 2)
+		assert.Equal(t, repository.AuditOperationClusterCreate, events[0].Type)
+		assert.Equal(t, repository.SystemActor, events[0].Actor)
+		assert.Nil(t, events[0].IdentityID)
+		assert.Equal(t, c.Name, events[0].Changes["name"].New)
+		assert.Equal(t, "********", events[0].Changes["service-account-token"].New)
+		assert.Equal(t, repository.AuditOperationIdentityLink, events[1].Type)
+		assert.NotNil(t, events[1].IdentityID)
+		assert.True(t, events[0].ID < events[1].ID)
+		deliveries, err := s.Application.WebhookService().ListDeliveries(ctx, subscription.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		for _, d := range deliveries {
+			assert.Equal(t, webhookrepository.DeliveryStateDelivered, d.State)
+			assert.Equal(t, 1, d.Attempts)
+			assert.NotNil(t, d.DeliveredAt)
+		}
+
+		t.Run("not delivered twice", func(t *testing.T) {
+			// when
+			err := s.Application.WebhookService().DispatchEvents(context.Background())
+			// then
+			require.NoError(t, err)
+			assert.Len(t, subscriber.Events(c.ClusterID), 2)
+		})
+	})
+
+	s.T().Run("filtered by event type", func(t *testing.T) {
+		// given
+		subscriber := newSubscriber(t, "0123456789abcdef")
+		defer subscriber.Close()
+		ctx, err := createContext(auth.OsoProxy)
+		require.NoError(t, err)
+		err = s.Application.WebhookService().CreateSubscription(ctx, &webhookrepository.Subscription{
+			URL:        subscriber.URL,
+			Secret:     "0123456789abcdef",
+			EventTypes: []string{repository.AuditOperationIdentityLink},
+		})
+		require.NoError(t, err)
+		c := test.CreateCluster(t, s.DB)
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+		// when
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then
+		require.NoError(t, err)
+		events := subscriber.Events(c.ClusterID)
+		require.Len(t, events, 1)
+		assert.Equal(t, repository.AuditOperationIdentityLink, events[0].Type)
+	})
+
+	s.T().Run("retried then dead-lettered", func(t *testing.T) {
+		// given
+		subscriber := newSubscriber(t, "0123456789abcdef")
+		defer subscriber.Close()
+		subscriber.SetStatus(http.StatusServiceUnavailable)
+		ctx, err := createContext(auth.Tenant)
+		require.NoError(t, err)
+		subscription := &webhookrepository.Subscription{
+			URL:    subscriber.URL,
+			Secret: "0123456789abcdef",
+		}
+		err = s.Application.WebhookService().CreateSubscription(ctx, subscription)
+		require.NoError(t, err)
+		c := test.CreateCluster(t, s.DB)
+
+		// when the first attempt fails
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then
+		require.NoError(t, err)
+		deliveries, err := s.Application.WebhookService().ListDeliveries(ctx, subscription.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, webhookrepository.DeliveryStatePending, deliveries[0].State)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, "unexpected response status: 503 Service Unavailable", deliveries[0].LastError)
+		assert.True(t, deliveries[0].NextAttemptAt.After(time.Now()))
+		assert.Len(t, subscriber.Events(c.ClusterID), 1)
+
+		// when the next attempt is not due yet
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Len(t, subscriber.Events(c.ClusterID), 1)
+
+		// when the second attempt fails after the backoff
+		time.Sleep(300 * time.Millisecond)
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then the delivery is moved to the dead letters
+		require.NoError(t, err)
+		assert.Len(t, subscriber.Events(c.ClusterID), 2)
+		dead := webhookrepository.DeliveryStateDead
+		deliveries, err = s.Application.WebhookService().ListDeliveries(ctx, subscription.ID, &dead)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, 2, deliveries[0].Attempts)
+
+		// when the dead delivery is redelivered once the subscriber is fixed
+		subscriber.SetStatus(http.StatusOK)
+		delivery, err := s.Application.WebhookService().Redeliver(ctx, subscription.ID, deliveries[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, webhookrepository.DeliveryStatePending, delivery.State)
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then
+		require.NoError(t, err)
+		assert.Len(t, subscriber.Events(c.ClusterID), 3)
+		delivered, err := s.Application.Deliveries().Load(context.Background(), deliveries[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, webhookrepository.DeliveryStateDelivered, delivered.State)
+	})
+
+	s.T().Run("next deliveries postponed after a failure", func(t *testing.T) {
+		// given
+		subscriber := newSubscriber(t, "0123456789abcdef")
+		defer subscriber.Close()
+		subscriber.SetStatus(http.StatusServiceUnavailable)
+		ctx, err := createContext(auth.Tenant)
+		require.NoError(t, err)
+		subscription := &webhookrepository.Subscription{
+			URL:    subscriber.URL,
+			Secret: "0123456789abcdef",
+		}
+		err = s.Application.WebhookService().CreateSubscription(ctx, subscription)
+		require.NoError(t, err)
+		c := test.CreateCluster(t, s.DB)
+		test.CreateIdentityCluster(t, s.DB, test.WithCluster(c))
+
+		// when the first delivery fails
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then the second one is not attempted
+		require.NoError(t, err)
+		events := subscriber.Events(c.ClusterID)
+		require.Len(t, events, 1)
+		assert.Equal(t, repository.AuditOperationClusterCreate, events[0].Type)
+		deliveries, err := s.Application.WebhookService().ListDeliveries(ctx, subscription.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, 0, deliveries[1].Attempts)
+
+		// when the subscriber is fixed but the first delivery is not due yet
+		subscriber.SetStatus(http.StatusOK)
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then the second delivery still waits for the first one
+		require.NoError(t, err)
+		assert.Len(t, subscriber.Events(c.ClusterID), 1)
+
+		// when the first delivery is attempted again after the backoff
+		time.Sleep(300 * time.Millisecond)
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then the events are delivered in order
+		require.NoError(t, err)
+		events = subscriber.Events(c.ClusterID)
+		require.Len(t, events, 3)
+		assert.Equal(t, repository.AuditOperationClusterCreate, events[1].Type)
+		assert.Equal(t, repository.AuditOperationIdentityLink, events[2].Type)
+	})
+
+	s.T().Run("slow subscriber", func(t *testing.T) {
+		// given
+		existingDispatchTimeout, found := os.LookupEnv("F8_WEBHOOK_DISPATCH_TIMEOUT")
+		defer func() {
+			if found {
+				os.Setenv("F8_WEBHOOK_DISPATCH_TIMEOUT", existingDispatchTimeout)
+			} else {
+				os.Unsetenv("F8_WEBHOOK_DISPATCH_TIMEOUT")
+			}
+		}()
+		os.Setenv("F8_WEBHOOK_DISPATCH_TIMEOUT", "300ms")
+		slow := newSubscriber(t, "0123456789abcdef")
+		defer slow.Close()
+		slow.SetDelay(800 * time.Millisecond)
+		fast := newSubscriber(t, "fedcba9876543210")
+		defer fast.Close()
+		ctx, err := createContext(auth.Tenant)
+		require.NoError(t, err)
+		slowSubscription := &webhookrepository.Subscription{
+			URL:    slow.URL,
+			Secret: "0123456789abcdef",
+		}
+		err = s.Application.WebhookService().CreateSubscription(ctx, slowSubscription)
+		require.NoError(t, err)
+		err = s.Application.WebhookService().CreateSubscription(ctx, &webhookrepository.Subscription{
+			URL:    fast.URL,
+			Secret: "fedcba9876543210",
+		})
+		require.NoError(t, err)
+		c := test.CreateCluster(t, s.DB)
+		// when
+		start := time.Now()
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then the dispatch does not wait for the slow subscriber
+		require.NoError(t, err)
+		assert.True(t, time.Since(start) < 800*time.Millisecond)
+		assert.Len(t, fast.Events(c.ClusterID), 1)
+		// and the interrupted delivery is not counted as an attempt
+		deliveries, err := s.Application.WebhookService().ListDeliveries(ctx, slowSubscription.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, webhookrepository.DeliveryStatePending, deliveries[0].State)
+		assert.Equal(t, 0, deliveries[0].Attempts)
+		// remove the subscription, so its deliveries are not attempted in other tests
+		err = s.Application.Subscriptions().Delete(context.Background(), slowSubscription.ID)
+		require.NoError(t, err)
+	})
+
+	s.T().Run("secret encrypted with an unknown key", func(t *testing.T) {
+		// given
+		subscriber := newSubscriber(t, "0123456789abcdef")
+		defer subscriber.Close()
+		subscription := webhookrepository.Subscription{
+			Owner:       auth.Tenant,
+			URL:         subscriber.URL,
+			Secret:      "0123456789abcdef",
+			SecretKeyID: "unknown",
+		}
+		err := s.Application.Subscriptions().Create(context.Background(), &subscription)
+		require.NoError(t, err)
+		c := test.CreateCluster(t, s.DB)
+		// when
+		err = s.Application.WebhookService().DispatchEvents(context.Background())
+		// then the deliveries of the subscription are skipped
+		require.NoError(t, err)
+		assert.Empty(t, subscriber.Events(c.ClusterID))
+		deliveries, err := s.Application.Deliveries().ListForSubscription(context.Background(), subscription.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, webhookrepository.DeliveryStatePending, deliveries[0].State)
+		assert.Equal(t, 0, deliveries[0].Attempts)
+		// remove the subscription, so its deliveries are not attempted in other tests
+		err = s.Application.Subscriptions().Delete(context.Background(), subscription.ID)
+		require.NoError(t, err)
+	})
+
+	s.T().Run("redeliver failures", func(t *testing.T) {
+		// given
+		ctx, err := createContext(auth.Tenant)
+		require.NoError(t, err)
+		subscription := test.CreateSubscription(t, s.DB, auth.Tenant, "https://tenant.example.com/webhooks")
+		other := test.CreateSubscription(t, s.DB, auth.Tenant, "https://tenant.example.com/other-webhooks")
+		test.CreateCluster(t, s.DB)
+		test.DispatchOutboxEvents(t, s.DB)
+		deliveries, err := s.Application.Deliveries().ListForSubscription(context.Background(), subscription.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+
+		t.Run("pending delivery", func(t *testing.T) {
+			// when
+			_, err := s.Application.WebhookService().Redeliver(ctx, subscription.ID, deliveries[0].ID)
+			// then
+			testsupport.AssertError(t, err, errors.DataConflictError{}, "delivery with id '%s' is pending, only the dead deliveries can be redelivered", deliveries[0].ID)
+		})
+
+		t.Run("delivery of another subscription", func(t *testing.T) {
+			// when
+			_, err := s.Application.WebhookService().Redeliver(ctx, other.ID, deliveries[0].ID)
+			// then
+			testsupport.AssertError(t, err, errors.NotFoundError{}, "delivery with id '%s' not found", deliveries[0].ID)
+		})
+	})
+}
+
+// subscriber a local HTTP stand-in for a webhook subscriber, which verifies the signatures of the deliveries
+type subscriber struct {
+	*httptest.Server
+	lock   sync.Mutex
+	status int
+	delay  time.Duration
+	events []service.Event
+}
+
+func newSubscriber(t *testing.T, secret string) *subscriber {
+	s := &subscriber{status: http.StatusNoContent}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		timestamp, err := strconv.ParseInt(r.Header.Get(service.TimestampHeader), 10, 64)
+		require.NoError(t, err)
+		if !service.VerifySignature(secret, timestamp, body, r.Header.Get(service.SignatureHeader)) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var e service.Event
+		require.NoError(t, json.Unmarshal(body, &e))
+		assert.Equal(t, e.Type, r.Header.Get(service.EventHeader))
+		s.lock.Lock()
+		delay := s.delay
+		s.lock.Unlock()
+		time.Sleep(delay)
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.events = append(s.events, e)
+		w.WriteHeader(s.status)
+	}))
+	return s
+}
+
+// SetStatus sets the status of the responses to the next deliveries
+func (s *subscriber) SetStatus(status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.status = status
+}
+
+// SetDelay sets the delay of the responses to the next deliveries
+func (s *subscriber) SetDelay(delay time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.delay = delay
+}
+
+// Events returns the events received for the given cluster, including the ones of the failed deliveries
+func (s *subscriber) Events(clusterID uuid.UUID) []service.Event {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := []service.Event{}
+	for _, e := range s.events {
+		if e.ClusterID == clusterID {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func subscriptionIDs(subscriptions []webhookrepository.Subscription) []uuid.UUID {
+	ids := make([]uuid.UUID, len(subscriptions))
+	for i, s := range subscriptions {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+func createContext(username string) (context.Context, error) {
+	sa := &authtestsupport.Identity{
+		Username: username,
+		ID:       uuid.NewV4(),
+	}
+	return authtestsupport.EmbedServiceAccountTokenInContext(context.Background(), sa)
+}